@@ -0,0 +1,208 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cenkalti/backoff/v4"
+	"github.com/xefino/goutils/collections"
+)
+
+// The maximum number of keys that may be requested in a single BatchGetItem request
+const batchGetSize = 100
+
+// Error returned when the backoff used to retry unprocessed requests has expired
+var errBackoffExpired = errors.New("maximum backoff time elapsed before all requests were processed")
+
+// BatchWriteResult describes the result of a BatchWrite call
+type BatchWriteResult struct {
+
+	// The number of requests that were written to DynamoDB
+	Written int
+
+	// The number of attempts that were made to write the requests
+	Attempts int
+
+	// The requests that were never written to DynamoDB
+	Unprocessed []types.WriteRequest
+}
+
+// Helper type that associates a single key with the table it should be read from
+type tableKey struct {
+	table string
+	key   map[string]types.AttributeValue
+}
+
+// BatchGet reads a number of items from one or more tables in DynamoDB. The requests should be keyed to the
+// name of the table to read from and each request may contain its own projection expression, attribute
+// names and read consistency. The keys will be split into chunks of 100 and any unprocessed keys returned
+// by DynamoDB will be retried with an exponential backoff until all the keys have been read or the backoff
+// expires. The items read will be returned keyed to the name of the table they were read from, in no
+// particular order. Keys for items that do not exist will be ignored. This function does not return
+// capacity statistics
+func (conn *DatabaseConnection) BatchGet(ctx context.Context,
+	requests map[string]types.KeysAndAttributes) (map[string][]map[string]types.AttributeValue, error) {
+	tables := collections.Keys(requests)
+	sort.Strings(tables)
+	ctx, scope := conn.startMetrics(ctx, strings.Join(tables, ", "), "BATCH GET")
+	results, err := conn.batchGet(ctx, requests)
+	scope.finish(err)
+	return results, err
+}
+
+// Helper function that reads the keys in the requests from DynamoDB in chunks, retrying unprocessed keys, on
+// behalf of BatchGet
+func (conn *DatabaseConnection) batchGet(ctx context.Context,
+	requests map[string]types.KeysAndAttributes) (map[string][]map[string]types.AttributeValue, error) {
+
+	// First, flatten all the requests into a list of keys, associated with their tables. We'll sort the
+	// tables so that the order of our requests is deterministic
+	tables := collections.Keys(requests)
+	sort.Strings(tables)
+	pending := make([]tableKey, 0)
+	for _, table := range tables {
+		for _, key := range requests[table].Keys {
+			pending = append(pending, tableKey{table: table, key: key})
+		}
+	}
+
+	// Next, create the results and the backoff we'll use to wait between retries of unprocessed keys
+	tableNames := strings.Join(tables, ", ")
+	results := make(map[string][]map[string]types.AttributeValue, len(tables))
+	timer := conn.createExponentialBackoff()
+	conn.logger.Log("Attempting batch-get of %d keys from %s...", len(pending), tableNames)
+
+	// Now, iterate until we have no more keys to read. On each attempt, we'll read all the pending keys in
+	// chunks and collect any keys that weren't processed so that we can retry them
+	for attempt := 0; len(pending) > 0; attempt++ {
+
+		// First, if this isn't our first attempt then wait for the backoff before trying again. If the
+		// backoff has expired then return an error
+		if attempt > 0 {
+			if err := conn.waitBackoff(ctx, timer); err != nil {
+				return results, conn.NewError(err, tableNames, "BATCH GET request to %s in DynamoDB failed; "+
+					"%d keys were not processed", tableNames, len(pending))
+			}
+		}
+
+		// Next, read each chunk of keys from DynamoDB, collecting the items and any unprocessed keys
+		unprocessed := make([]tableKey, 0)
+		for _, chunk := range collections.Page(pending, batchGetSize) {
+
+			// Attempt to read the chunk of keys from DynamoDB; if this fails then return an error
+			output, err := conn.batchGetInner(ctx, tableNames, requests, chunk)
+			if err != nil {
+				return results, err
+			}
+
+			// Save the items that were read and flatten any unprocessed keys so they can be retried
+			for table, items := range output.Responses {
+				results[table] = append(results[table], items...)
+			}
+
+			for table, keys := range output.UnprocessedKeys {
+				for _, key := range keys.Keys {
+					unprocessed = append(unprocessed, tableKey{table: table, key: key})
+				}
+			}
+		}
+
+		// Finally, log the results of this attempt and retry any unprocessed keys
+		conn.logger.Log("Batch-get from %s completed. Retries? %t", tableNames, len(unprocessed) > 0)
+		pending = unprocessed
+	}
+
+	return results, nil
+}
+
+// BatchGetItems reads a number of items from a single table in DynamoDB and converts them to the type
+// provided. The projection expression, attribute names and read consistency may be set on the request and
+// any keys set on the request will be read in addition to the keys provided. This function behaves in the
+// same way as BatchGet
+func BatchGetItems[T any](ctx context.Context, conn *DatabaseConnection, tableName string,
+	request types.KeysAndAttributes, keys ...map[string]types.AttributeValue) ([]*T, error) {
+
+	// First, add the keys to a new list on the request so the caller's keys aren't modified and attempt to read
+	// all the items from DynamoDB
+	combined := make([]map[string]types.AttributeValue, 0, len(request.Keys)+len(keys))
+	combined = append(combined, request.Keys...)
+	request.Keys = append(combined, keys...)
+	results, err := conn.BatchGet(ctx, map[string]types.KeysAndAttributes{tableName: request})
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, attempt to convert the items to our output type and return them
+	items := make([]*T, 0, len(results[tableName]))
+	if err := conn.UnmarshalList(results[tableName], &items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Helper function that reads a single chunk of keys from DynamoDB with a backoff-retry loop. The keys will
+// be grouped by table and each table will use the projection and consistency settings from its request
+func (conn *DatabaseConnection) batchGetInner(ctx context.Context, tableNames string,
+	requests map[string]types.KeysAndAttributes, chunk []tableKey) (*dynamodb.BatchGetItemOutput, error) {
+
+	// First, create our batch get input from the chunk of keys, copying the settings from the original
+	// request for each table
+	input := dynamodb.BatchGetItemInput{
+		RequestItems:           make(map[string]types.KeysAndAttributes),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityNone,
+	}
+
+	// Each table's keys are collected in a new list so that the keys on the caller's request are never modified
+	for _, entry := range chunk {
+		request, ok := input.RequestItems[entry.table]
+		if !ok {
+			request = requests[entry.table]
+			request.Keys = make([]map[string]types.AttributeValue, 0, len(chunk))
+		}
+
+		request.Keys = append(request.Keys, entry.key)
+		input.RequestItems[entry.table] = request
+	}
+
+	// Next, attempt to read the items from DynamoDB with a backoff-retry loop
+	var output *dynamodb.BatchGetItemOutput
+	err := conn.doRetry(ctx, tableNames, "BATCH GET", func() error {
+		var inner error
+		output, inner = conn.db.BatchGetItem(ctx, &input)
+		return inner
+	})
+
+	// Finally, if the operation failed then return the error; otherwise, return the output
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// Helper function that waits for the next interval from the backoff timer. If the backoff has expired or the
+// context finishes before the interval has elapsed then an error will be returned
+func (conn *DatabaseConnection) waitBackoff(ctx context.Context, timer backoff.BackOff) error {
+
+	// First, get the next interval from the backoff; if the backoff has expired then return an error
+	next := timer.NextBackOff()
+	if next == backoff.Stop {
+		return errBackoffExpired
+	}
+
+	// Next, wait for the interval to elapse or for the context to finish, whichever happens first
+	wait := time.NewTimer(next)
+	defer wait.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-wait.C:
+		return nil
+	}
+}
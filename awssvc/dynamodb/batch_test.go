@@ -0,0 +1,453 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/awssvc/testing"
+	"github.com/xefino/goutils/utils"
+)
+
+var _ = Describe("Batch Tests", Ordered, func() {
+
+	// Ensure that the AWS config is created before each test; this could be set as a global variable
+	var cfg aws.Config
+	BeforeAll(func() {
+		cfg = testing.TestAWSConfig(context.Background(), "us-east-1", 9000)
+	})
+
+	// Create our test table definitions that we'll use for all module tests; we'll use two tables so that
+	// we can verify requests against multiple tables
+	tables := make([]*dynamodb.CreateTableInput, 2)
+	for i, name := range []string{"TEST_TABLE", "TEST_TABLE_2"} {
+		tables[i] = &dynamodb.CreateTableInput{
+			AttributeDefinitions: []types.AttributeDefinition{
+				{
+					AttributeName: aws.String("id"),
+					AttributeType: types.ScalarAttributeTypeS,
+				},
+				{
+					AttributeName: aws.String("sort_key"),
+					AttributeType: types.ScalarAttributeTypeS,
+				},
+			},
+			KeySchema: []types.KeySchemaElement{
+				{
+					AttributeName: aws.String("id"),
+					KeyType:       types.KeyTypeHash,
+				},
+				{
+					AttributeName: aws.String("sort_key"),
+					KeyType:       types.KeyTypeRange,
+				},
+			},
+			TableName:   aws.String(name),
+			BillingMode: types.BillingModeProvisioned,
+			ProvisionedThroughput: &types.ProvisionedThroughput{
+				ReadCapacityUnits:  aws.Int64(1),
+				WriteCapacityUnits: aws.Int64(1),
+			},
+			TableClass: types.TableClassStandard,
+		}
+	}
+
+	// Esnure that the tables exist and contain our test data before the start of each test. The first
+	// table will contain 150 items so that we need multiple chunks to read them and the second will
+	// contain 10 items
+	BeforeEach(func() {
+		for i, table := range tables {
+			if err := testing.EnsureTableExists(context.Background(), cfg, table); err != nil {
+				panic(err)
+			}
+
+			typed := createTable[testObject](createTestConnection(cfg), *table.TableName)
+			for j := 0; j < 150-140*i; j++ {
+				err := typed.Put(context.Background(),
+					&testObject{ID: "test_id", SortKey: fmt.Sprintf("test|sort|key|%03d", j), Data: j})
+				if err != nil {
+					panic(err)
+				}
+			}
+		}
+	})
+
+	// Ensure that the tables are empty at the end of each test
+	AfterEach(func() {
+		for _, table := range tables {
+			if err := testing.EmptyTable(context.Background(), cfg, table); err != nil {
+				panic(err)
+			}
+		}
+	})
+
+	// Test that, if the table does not exist, then calling BatchGet will return an error
+	It("BatchGet - Fails - Error", func() {
+
+		// First, create our test database connection from our test config
+		conn := createTestConnection(cfg)
+
+		// Next, attempt to read some keys from a table that doesn't exist; this should fail
+		results, err := conn.BatchGet(context.Background(), map[string]types.KeysAndAttributes{
+			"FAKE_TABLE": {Keys: createBatchKeys(0, 10)},
+		})
+
+		// Finally, verify the failure
+		Expect(results).Should(BeEmpty())
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).TableName).Should(Equal("FAKE_TABLE"))
+		Expect(err.(*Error).Message).Should(Equal("BATCH GET request to FAKE_TABLE in DynamoDB failed"))
+	})
+
+	// Test that, if no failure occurs, then calling BatchGet will read the items from multiple tables, in
+	// multiple chunks, using the projection associated with each table
+	It("BatchGet - No failures - Data returned", func() {
+
+		// First, create our test database connection from our test config
+		conn := createTestConnection(cfg)
+
+		// Next, attempt to read all the items from the first table, and some of the items from the second
+		// table with a projection, including keys that don't exist; this should not fail
+		results, err := conn.BatchGet(context.Background(), map[string]types.KeysAndAttributes{
+			"TEST_TABLE": {Keys: createBatchKeys(0, 160), ConsistentRead: aws.Bool(true)},
+			"TEST_TABLE_2": {
+				Keys:                     createBatchKeys(5, 15),
+				ProjectionExpression:     aws.String("#sk, #data"),
+				ExpressionAttributeNames: map[string]string{"#sk": "sort_key", "#data": "data"},
+			},
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(results).Should(HaveLen(2))
+
+		// Now, verify the items that were read from the first table
+		first := make([]*testObject, 0)
+		Expect(conn.UnmarshalList(results["TEST_TABLE"], &first)).ShouldNot(HaveOccurred())
+		sort.Slice(first, func(i, j int) bool { return first[i].Data < first[j].Data })
+		Expect(first).Should(HaveLen(150))
+		for i, item := range first {
+			Expect(*item).Should(Equal(testObject{
+				ID:      "test_id",
+				SortKey: fmt.Sprintf("test|sort|key|%03d", i),
+				Data:    i,
+			}))
+		}
+
+		// Finally, verify that the items read from the second table were projected
+		second := make([]*testObject, 0)
+		Expect(conn.UnmarshalList(results["TEST_TABLE_2"], &second)).ShouldNot(HaveOccurred())
+		sort.Slice(second, func(i, j int) bool { return second[i].Data < second[j].Data })
+		Expect(second).Should(HaveLen(5))
+		for i, item := range second {
+			Expect(*item).Should(Equal(testObject{
+				SortKey: fmt.Sprintf("test|sort|key|%03d", i+5),
+				Data:    i + 5,
+			}))
+		}
+	})
+
+	// Test that calling BatchGetItems will read the items and convert them to the type provided
+	It("BatchGetItems - No failures - Data returned", func() {
+
+		// First, create our test database connection from our test config
+		conn := createTestConnection(cfg)
+
+		// Next, attempt to read some items from the first table; this should not fail
+		results, err := BatchGetItems[testObject](context.Background(), conn, "TEST_TABLE",
+			types.KeysAndAttributes{ConsistentRead: aws.Bool(true)}, createBatchKeys(140, 155)...)
+
+		// Finally, verify the items that were read
+		Expect(err).ShouldNot(HaveOccurred())
+		sort.Slice(results, func(i, j int) bool { return results[i].Data < results[j].Data })
+		Expect(results).Should(HaveLen(10))
+		for i, item := range results {
+			Expect(item.Data).Should(Equal(i + 140))
+		}
+	})
+
+	// Test that calling BatchGet on a table will read the items with the keys of the items provided
+	It("Table.BatchGet - No failures - Data returned", func() {
+
+		// First, create our test table from our test config
+		table := createTable[testObject](createTestConnection(cfg), "TEST_TABLE_2")
+
+		// Next, attempt to read some items from the table; this should not fail
+		results, err := table.BatchGet(context.Background(), []*testObject{
+			{ID: "test_id", SortKey: "test|sort|key|001"},
+			{ID: "test_id", SortKey: "test|sort|key|003"},
+			{ID: "test_id", SortKey: "test|sort|key|100"},
+		}, true)
+
+		// Finally, verify the items that were read
+		Expect(err).ShouldNot(HaveOccurred())
+		sort.Slice(results, func(i, j int) bool { return results[i].Data < results[j].Data })
+		Expect(results).Should(HaveLen(2))
+		Expect(results[0].Data).Should(Equal(1))
+		Expect(results[1].Data).Should(Equal(3))
+	})
+})
+
+var _ = Describe("Batch Unprocessed Tests", func() {
+
+	// Test that, if DynamoDB returns unprocessed keys, then calling BatchGet will retry them until all the
+	// keys have been processed
+	It("BatchGet - Unprocessed keys - Retried", func() {
+
+		// First, create a test connection from a client that will only process 30 keys per request
+		logger := utils.NewLogger("testd", "test")
+		logger.Discard()
+		client := &unprocessedDynamoDBClient{processed: 30}
+		conn := FromClient(client, logger, WithBackoffStart(1), WithBackoffEnd(5), WithBackoffMaxElapsed(1000))
+
+		// Next, attempt to read 150 keys from the table; this should not fail
+		results, err := conn.BatchGet(context.Background(), map[string]types.KeysAndAttributes{
+			"TEST_TABLE": {Keys: createBatchKeys(0, 150)},
+		})
+
+		// Finally, verify that every key was read and that the requests were chunked
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(results["TEST_TABLE"]).Should(HaveLen(150))
+		Expect(client.sizes).Should(Equal([]int{100, 50, 90, 60, 30}))
+	})
+
+	// Test that calling BatchGetItems will not write the keys provided into the backing array of the keys on the
+	// caller's request
+	It("BatchGetItems - Request has spare capacity - Caller's keys not modified", func() {
+
+		// First, create a test connection from a client that will process every key
+		logger := utils.NewLogger("testd", "test")
+		logger.Discard()
+		client := &unprocessedDynamoDBClient{processed: 100}
+		conn := FromClient(client, logger, WithBackoffStart(1), WithBackoffEnd(5), WithBackoffMaxElapsed(1000))
+
+		// Next, create a request whose keys have spare capacity and read them along with some other keys
+		backing := make([]map[string]types.AttributeValue, 1, 10)
+		backing[0] = createBatchKeys(0, 1)[0]
+		items, err := BatchGetItems[testObject](context.Background(), conn, "TEST_TABLE",
+			types.KeysAndAttributes{Keys: backing}, createBatchKeys(1, 3)...)
+
+		// Finally, verify that all the items were read and that the spare capacity was not written to
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(items).Should(HaveLen(3))
+		Expect(backing[:3][1]).Should(BeNil())
+		Expect(backing[:3][2]).Should(BeNil())
+	})
+
+	// Test that, if DynamoDB never processes some keys, then calling BatchGet will return an error once the
+	// backoff has expired
+	It("BatchGet - Keys never processed - Error", func() {
+
+		// First, create a test connection from a client that will not process any keys
+		logger := utils.NewLogger("testd", "test")
+		logger.Discard()
+		client := &unprocessedDynamoDBClient{processed: 0}
+		conn := FromClient(client, logger, WithBackoffStart(1), WithBackoffEnd(2), WithBackoffMaxElapsed(10))
+
+		// Next, attempt to read some keys from the table; this should fail
+		results, err := conn.BatchGet(context.Background(), map[string]types.KeysAndAttributes{
+			"TEST_TABLE": {Keys: createBatchKeys(0, 10)},
+		})
+
+		// Finally, verify the failure
+		Expect(results).Should(BeEmpty())
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).TableName).Should(Equal("TEST_TABLE"))
+		Expect(err.(*Error).Message).Should(Equal("BATCH GET request to TEST_TABLE in DynamoDB failed; " +
+			"10 keys were not processed"))
+		Expect(len(client.sizes)).Should(BeNumerically(">", 1))
+	})
+
+	// Test that, if DynamoDB returns unprocessed items, then calling BatchWrite will retry them, writing the
+	// chunks concurrently up to the degree of parallelism set on the connection
+	It("BatchWrite - Unprocessed items - Retried", func() {
+
+		// First, create a test connection from a client that will only process 10 items per request
+		logger := utils.NewLogger("testd", "test")
+		logger.Discard()
+		client := &unprocessedDynamoDBClient{processed: 10, delay: 10 * time.Millisecond}
+		conn := FromClient(client, logger, WithBackoffStart(1), WithBackoffEnd(5), WithBackoffMaxElapsed(1000),
+			WithBatchWriteParallelism(2))
+
+		// Next, attempt to write 100 requests to the table; this should not fail
+		result, err := conn.BatchWrite(context.Background(), "TEST_TABLE", createBatchWrites(100)...)
+
+		// Finally, verify that every request was written and that no more than two chunks were written
+		// at the same time
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.Written).Should(Equal(100))
+		Expect(result.Unprocessed).Should(BeEmpty())
+		Expect(result.Attempts).Should(Equal(5))
+		Expect(client.written).Should(HaveLen(100))
+		Expect(client.maxActive).Should(Equal(2))
+	})
+
+	// Test that, if DynamoDB never processes some items, then calling BatchWrite will stop once the maximum
+	// number of attempts has been reached and return the items that were never written
+	It("BatchWrite - Attempts exhausted - Unprocessed returned", func() {
+
+		// First, create a test connection from a client that will only process 10 items per request
+		logger := utils.NewLogger("testd", "test")
+		logger.Discard()
+		client := &unprocessedDynamoDBClient{processed: 10}
+		conn := FromClient(client, logger, WithBackoffStart(1), WithBackoffEnd(5), WithBackoffMaxElapsed(1000),
+			WithBatchWriteAttempts(2))
+
+		// Next, attempt to write 75 requests to the table; this should fail
+		result, err := conn.BatchWrite(context.Background(), "TEST_TABLE", createBatchWrites(75)...)
+
+		// Finally, verify the failure and the requests that were never written
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).TableName).Should(Equal("TEST_TABLE"))
+		Expect(err.(*Error).Message).Should(Equal("BATCH WRITE request to TEST_TABLE in DynamoDB failed; " +
+			"25 of 75 requests were not written after 2 attempts"))
+		Expect(result.Attempts).Should(Equal(2))
+		Expect(result.Written).Should(Equal(50))
+		Expect(result.Unprocessed).Should(HaveLen(25))
+		Expect(client.sizes).Should(ConsistOf(25, 25, 25, 25, 20))
+	})
+
+	// Test that, if a chunk fails, then calling BatchWrite will return the error along with every request that
+	// was not written
+	It("BatchWrite - Chunk fails - Unprocessed returned", func() {
+
+		// First, create a test connection from a client that will fail on the second chunk
+		logger := utils.NewLogger("testd", "test")
+		logger.Discard()
+		client := &unprocessedDynamoDBClient{processed: 25, failKey: "test|sort|key|025"}
+		conn := FromClient(client, logger, WithBackoffStart(1), WithBackoffEnd(5), WithBackoffMaxElapsed(10))
+
+		// Next, attempt to write 60 requests to the table; this should fail
+		result, err := conn.BatchWrite(context.Background(), "TEST_TABLE", createBatchWrites(60)...)
+
+		// Finally, verify the failure and the requests that were never written
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).Message).Should(Equal("BATCH WRITE request to TEST_TABLE in DynamoDB failed"))
+		Expect(result.Attempts).Should(Equal(1))
+		Expect(result.Written).Should(Equal(len(client.written)))
+		Expect(result.Written + len(result.Unprocessed)).Should(Equal(60))
+		Expect(result.Unprocessed).Should(ContainElements(createBatchWrites(60)[25:50]))
+	})
+})
+
+// Helper function that creates the keys of the test items with data in the range provided
+func createBatchKeys(start int, end int) []map[string]types.AttributeValue {
+	keys := make([]map[string]types.AttributeValue, 0, end-start)
+	for i := start; i < end; i++ {
+		keys = append(keys, map[string]types.AttributeValue{
+			"id":       &types.AttributeValueMemberS{Value: "test_id"},
+			"sort_key": &types.AttributeValueMemberS{Value: fmt.Sprintf("test|sort|key|%03d", i)},
+		})
+	}
+
+	return keys
+}
+
+// Helper function that creates a number of put requests for test items
+func createBatchWrites(count int) []types.WriteRequest {
+	requests := make([]types.WriteRequest, count)
+	for i := 0; i < count; i++ {
+		requests[i] = types.WriteRequest{
+			PutRequest: &types.PutRequest{
+				Item: map[string]types.AttributeValue{
+					"id":       &types.AttributeValueMemberS{Value: "test_id"},
+					"sort_key": &types.AttributeValueMemberS{Value: fmt.Sprintf("test|sort|key|%03d", i)},
+				},
+			},
+		}
+	}
+
+	return requests
+}
+
+// Helper type that mocks out BatchGetItem and BatchWriteItem so that only a fixed number of keys or items are
+// processed per request
+type unprocessedDynamoDBClient struct {
+	DynamoDBAPI
+	processed int
+	failKey   string
+	delay     time.Duration
+	sizes     []int
+	written   map[string]bool
+	active    int
+	maxActive int
+	lock      sync.Mutex
+}
+
+// Mocks out the BatchGetItem function so that only the first keys of each table are processed, returning each
+// key as the item and the remaining keys as unprocessed keys
+func (client *unprocessedDynamoDBClient) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	output := dynamodb.BatchGetItemOutput{
+		Responses:       make(map[string][]map[string]types.AttributeValue),
+		UnprocessedKeys: make(map[string]types.KeysAndAttributes),
+	}
+
+	for table, request := range params.RequestItems {
+		client.sizes = append(client.sizes, len(request.Keys))
+		for i, key := range request.Keys {
+			if i < client.processed {
+				output.Responses[table] = append(output.Responses[table], key)
+			} else {
+				unprocessed := output.UnprocessedKeys[table]
+				unprocessed.Keys = append(unprocessed.Keys, key)
+				output.UnprocessedKeys[table] = unprocessed
+			}
+		}
+	}
+
+	return &output, nil
+}
+
+// Mocks out the BatchWriteItem function so that only the first items of each table are processed, recording
+// each item that was written and the maximum number of concurrent requests. If the client was set to fail on
+// a specific key then an error will be returned for any request beginning with that key
+func (client *unprocessedDynamoDBClient) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+
+	// First, record the request and the number of requests that are currently active
+	client.lock.Lock()
+	requests := params.RequestItems["TEST_TABLE"]
+	client.sizes = append(client.sizes, len(requests))
+	client.active++
+	client.maxActive = int(math.Max(float64(client.active), float64(client.maxActive)))
+	failed := requests[0].PutRequest.Item["sort_key"].(*types.AttributeValueMemberS).Value == client.failKey
+	client.lock.Unlock()
+
+	// Next, wait for the delay so that concurrent requests overlap and then mark this request as finished
+	time.Sleep(client.delay)
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	client.active--
+
+	// If this request should fail then return an error similar to what the actual AWS operation would generate
+	if failed {
+		return nil, &smithy.OperationError{Err: fmt.Errorf("request failed")}
+	}
+
+	// Finally, process the first items and return the remainder as unprocessed items
+	output := dynamodb.BatchWriteItemOutput{UnprocessedItems: make(map[string][]types.WriteRequest)}
+	if client.written == nil {
+		client.written = make(map[string]bool)
+	}
+
+	for table, requests := range params.RequestItems {
+		for i, request := range requests {
+			if i < client.processed {
+				client.written[request.PutRequest.Item["sort_key"].(*types.AttributeValueMemberS).Value] = true
+			} else {
+				output.UnprocessedItems[table] = append(output.UnprocessedItems[table], request)
+			}
+		}
+	}
+
+	return &output, nil
+}
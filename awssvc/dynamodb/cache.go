@@ -0,0 +1,399 @@
+package dynamodb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/xefino/goutils/concurrency"
+	"github.com/xefino/goutils/utils"
+	"golang.org/x/sync/singleflight"
+)
+
+// The value that will be stored in the cache to record that an item does not exist
+var missingEntry = []byte("null")
+
+// ICacheBackend defines the functionality that will allow an ItemCache to store entries
+type ICacheBackend interface {
+
+	// Get retrieves the value associated with the key. If no value exists, or it has expired, then false
+	// should be returned without an error
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set stores the value associated with the key, replacing any existing value. The value should expire
+	// after the time-to-live has passed
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete removes the values associated with the keys, if they exist
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// ItemCache is a read-through cache for items retrieved with GetItem. Items are stored in a pluggable backend
+// as lossless JSON for a time-to-live and, optionally, the absence of items may also be cached. Concurrent
+// requests for the same uncached item will be collapsed into a single request to DynamoDB. An ItemCache should
+// be attached to a DatabaseConnection with the WithItemCache option, after which writes made through the
+// connection with PutItem, UpdateItem, DeleteItem, BatchWrite or a write transaction will invalidate the
+// entries for the items they affect. Writes made through other connections, or with PartiQL statements, will
+// not invalidate entries so they will only be seen once the entries expire. Requests that use strongly
+// consistent reads or projections will always be sent to DynamoDB
+type ItemCache struct {
+	backend     ICacheBackend
+	ttl         time.Duration
+	negativeTTL time.Duration
+	tables      map[string]bool
+	maxItemSize int
+	prefix      string
+	timeout     time.Duration
+	group       singleflight.Group
+	lock        sync.RWMutex
+	epoch       uint64
+	keyNames    map[string][]string
+}
+
+// NewItemCache creates a new ItemCache from the backend where entries will be stored and options
+func NewItemCache(backend ICacheBackend, opts ...IItemCacheOption) *ItemCache {
+
+	// First, create our cache with default values
+	cache := ItemCache{
+		backend:  backend,
+		ttl:      time.Minute,
+		prefix:   "dynamodb",
+		timeout:  30 * time.Second,
+		keyNames: make(map[string][]string),
+	}
+
+	// Next, iterate over the options provided and update the associated values in the cache
+	for _, opt := range opts {
+		opt.Apply(&cache)
+	}
+
+	// Finally, return a reference to the cache
+	return &cache
+}
+
+// Invalidate removes the entry for the item with the key provided from the cache so that the next request for
+// it will be sent to DynamoDB. This should be called if the item was modified without going through a
+// connection using this cache
+func (cache *ItemCache) Invalidate(ctx context.Context, tableName string, key map[string]types.AttributeValue) error {
+	entry, err := cache.entryKey(tableName, key)
+	if err != nil {
+		return err
+	}
+
+	return cache.invalidate(ctx, entry)
+}
+
+// Helper function that determines whether or not items from the table should be cached
+func (cache *ItemCache) caches(tableName string) bool {
+	return cache.tables == nil || cache.tables[tableName]
+}
+
+// Helper function that creates the key of the cache entry associated with an item. The key is derived from the
+// lossless JSON of the item's key, which will always have its attributes in the same order
+func (cache *ItemCache) entryKey(tableName string, key map[string]types.AttributeValue) (string, error) {
+	data, err := AttributeValuesToJSON(key, WithLosslessJSON(true))
+	if err != nil {
+		return "", fmt.Errorf("failed to convert key to cache entry key: %w", err)
+	}
+
+	return fmt.Sprintf("%s:%s:%s", cache.prefix, tableName, data), nil
+}
+
+// Helper function that retrieves the names of the key attributes that were recorded for the table
+func (cache *ItemCache) getKeyNames(tableName string) ([]string, bool) {
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	names, ok := cache.keyNames[tableName]
+	return names, ok
+}
+
+// Helper function that records the names of the key attributes for the table so that the key can be derived
+// from items that are written to it
+func (cache *ItemCache) setKeyNames(tableName string, names []string) {
+	sort.Strings(names)
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.keyNames[tableName] = names
+}
+
+// Helper function that returns the current epoch, which changes every time an entry is invalidated
+func (cache *ItemCache) currentEpoch() uint64 {
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	return cache.epoch
+}
+
+// Helper function that stores an item in the cache, provided no entries were invalidated since the epoch
+// provided was read. This ensures that an item read from DynamoDB before a write was made can't be cached
+// after the write invalidated it. If the item is nil then its absence will be cached if negative caching is
+// enabled
+func (cache *ItemCache) store(ctx context.Context, entry string, item map[string]types.AttributeValue,
+	epoch uint64) error {
+
+	// First, determine what we'll store and for how long. If the item doesn't exist and negative caching is
+	// disabled then there's nothing to store
+	value, ttl := missingEntry, cache.negativeTTL
+	if item != nil {
+		data, err := AttributeValuesToJSON(item, WithLosslessJSON(true))
+		if err != nil {
+			return fmt.Errorf("failed to convert item to cache entry: %w", err)
+		}
+
+		value, ttl = data, cache.ttl
+	}
+
+	if ttl <= 0 || (cache.maxItemSize > 0 && len(value) > cache.maxItemSize) {
+		return nil
+	}
+
+	// Next, hold the read lock while we store the entry so that it can't be invalidated between checking the
+	// epoch and storing the entry. If the epoch has changed then the item may be stale so don't store it
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	if cache.epoch != epoch {
+		return nil
+	}
+
+	// Finally, store the entry in the backend
+	return cache.backend.Set(ctx, entry, value, ttl)
+}
+
+// Helper function that removes entries from the cache. The epoch will be changed so that any reads that are
+// in flight will not store their results and requests for the entries that arrive after this will not join
+// reads that are in flight
+func (cache *ItemCache) invalidate(ctx context.Context, entries ...string) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	cache.lock.Lock()
+	cache.epoch++
+	cache.lock.Unlock()
+	for _, entry := range entries {
+		cache.group.Forget(entry)
+	}
+
+	return cache.backend.Delete(ctx, entries...)
+}
+
+// Helper type that decorates a DynamoDB client so that GetItem requests are served through an ItemCache and
+// writes invalidate the entries they affect
+type cachingClient struct {
+	DynamoDBAPI
+	cache  *ItemCache
+	logger *utils.Logger
+}
+
+// GetItem retrieves an item from the cache if an entry exists for it. Otherwise, the item will be retrieved
+// from DynamoDB and cached. Concurrent requests for the same item will share a single request to DynamoDB,
+// which will not be cancelled if the context of any one caller is cancelled
+func (client *cachingClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	tableName := aws.ToString(params.TableName)
+
+	// First, if the request can't be served from the cache then send it directly to DynamoDB
+	if !client.cache.caches(tableName) || aws.ToBool(params.ConsistentRead) ||
+		params.ProjectionExpression != nil || len(params.AttributesToGet) > 0 {
+		return client.DynamoDBAPI.GetItem(ctx, params, optFns...)
+	}
+
+	// Next, derive the key of the entry and record the names of the key attributes so that writes to the table
+	// can be invalidated. If the entry key can't be derived then send the request directly to DynamoDB
+	entry, err := client.cache.entryKey(tableName, params.Key)
+	if err != nil {
+		client.logger.Log("Cache bypassed for GET on %s: %v", tableName, err)
+		return client.DynamoDBAPI.GetItem(ctx, params, optFns...)
+	}
+
+	if _, ok := client.cache.getKeyNames(tableName); !ok {
+		client.cache.setKeyNames(tableName, keyNames(params.Key))
+	}
+
+	// Now, attempt to retrieve the entry from the cache. If it exists then return the item it contains.
+	// Failures of the backend should not fail the request so we'll log them and continue
+	if data, ok, err := client.cache.backend.Get(ctx, entry); err != nil {
+		client.logger.Log("Failed to read cache entry %s: %v", entry, err)
+	} else if ok {
+		if bytes.Equal(data, missingEntry) {
+			return new(dynamodb.GetItemOutput), nil
+		} else if item, err := JSONToAttributeValues(data, WithLosslessJSON(true)); err != nil {
+			client.logger.Log("Failed to decode cache entry %s: %v", entry, err)
+		} else {
+			return &dynamodb.GetItemOutput{Item: item}, nil
+		}
+	}
+
+	// Finally, retrieve the item from DynamoDB, sharing the request with any other callers requesting the same
+	// item, and cache the result. The request is shared so it's made with a context that won't be cancelled
+	// when this caller's context is, although we'll stop waiting for it if it is. Each caller receives its own
+	// copy of the output so that modifying the item doesn't affect other callers
+	results := client.cache.group.DoChan(entry, func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(concurrency.WithoutCancel(ctx), client.cache.timeout)
+		defer cancel()
+
+		epoch := client.cache.currentEpoch()
+		output, err := client.DynamoDBAPI.GetItem(fetchCtx, params, optFns...)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := client.cache.store(fetchCtx, entry, output.Item, epoch); err != nil {
+			client.logger.Log("Failed to write cache entry %s: %v", entry, err)
+		}
+
+		return output, nil
+	})
+
+	var result singleflight.Result
+	select {
+	case result = <-results:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	output := *result.Val.(*dynamodb.GetItemOutput)
+	if output.Item != nil {
+		output.Item = copyItem(output.Item)
+	}
+
+	return &output, nil
+}
+
+// PutItem writes an item to DynamoDB and invalidates the cache entry for it
+func (client *cachingClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	output, err := client.DynamoDBAPI.PutItem(ctx, params, optFns...)
+	client.invalidateItems(ctx, aws.ToString(params.TableName), params.Item)
+	return output, err
+}
+
+// UpdateItem updates an item in DynamoDB and invalidates the cache entry for it
+func (client *cachingClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	output, err := client.DynamoDBAPI.UpdateItem(ctx, params, optFns...)
+	client.invalidateKeys(ctx, aws.ToString(params.TableName), params.Key)
+	return output, err
+}
+
+// DeleteItem deletes an item from DynamoDB and invalidates the cache entry for it
+func (client *cachingClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	output, err := client.DynamoDBAPI.DeleteItem(ctx, params, optFns...)
+	client.invalidateKeys(ctx, aws.ToString(params.TableName), params.Key)
+	return output, err
+}
+
+// BatchWriteItem writes a batch of items to DynamoDB and invalidates the cache entries for all of them
+func (client *cachingClient) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	output, err := client.DynamoDBAPI.BatchWriteItem(ctx, params, optFns...)
+	for tableName, requests := range params.RequestItems {
+		for _, request := range requests {
+			if request.PutRequest != nil {
+				client.invalidateItems(ctx, tableName, request.PutRequest.Item)
+			} else if request.DeleteRequest != nil {
+				client.invalidateKeys(ctx, tableName, request.DeleteRequest.Key)
+			}
+		}
+	}
+
+	return output, err
+}
+
+// TransactWriteItems makes a write transaction against DynamoDB and invalidates the cache entries for all the
+// items that it writes
+func (client *cachingClient) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	output, err := client.DynamoDBAPI.TransactWriteItems(ctx, params, optFns...)
+	for _, item := range params.TransactItems {
+		if item.Put != nil {
+			client.invalidateItems(ctx, aws.ToString(item.Put.TableName), item.Put.Item)
+		} else if item.Update != nil {
+			client.invalidateKeys(ctx, aws.ToString(item.Update.TableName), item.Update.Key)
+		} else if item.Delete != nil {
+			client.invalidateKeys(ctx, aws.ToString(item.Delete.TableName), item.Delete.Key)
+		}
+	}
+
+	return output, err
+}
+
+// Helper function that invalidates the cache entry for an item that was written to the table. The key of the
+// item is derived from the names of the key attributes recorded for the table or, if none were recorded, from
+// the key schema of the table
+func (client *cachingClient) invalidateItems(ctx context.Context, tableName string,
+	item map[string]types.AttributeValue) {
+	if !client.cache.caches(tableName) {
+		return
+	}
+
+	// First, get the names of the key attributes for the table. If we don't have them then we'll need to
+	// describe the table to get them; if this fails then we can't invalidate the entry so log the error
+	names, ok := client.cache.getKeyNames(tableName)
+	if !ok {
+		output, err := client.DynamoDBAPI.DescribeTable(ctx,
+			&dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+		if err != nil {
+			client.logger.Log("Failed to describe %s to invalidate cache entry: %v", tableName, err)
+			return
+		}
+
+		names = make([]string, len(output.Table.KeySchema))
+		for i, element := range output.Table.KeySchema {
+			names[i] = aws.ToString(element.AttributeName)
+		}
+
+		client.cache.setKeyNames(tableName, names)
+	}
+
+	// Next, extract the key from the item and invalidate the entry associated with it
+	key := make(map[string]types.AttributeValue, len(names))
+	for _, name := range names {
+		key[name] = item[name]
+	}
+
+	client.invalidateKeys(ctx, tableName, key)
+}
+
+// Helper function that invalidates the cache entry for the item with the key provided. Failures are logged
+// rather than returned so that they don't fail the write that caused them
+func (client *cachingClient) invalidateKeys(ctx context.Context, tableName string,
+	key map[string]types.AttributeValue) {
+	if !client.cache.caches(tableName) {
+		return
+	}
+
+	if err := client.cache.Invalidate(ctx, tableName, key); err != nil {
+		client.logger.Log("Failed to invalidate cache entry for item in %s: %v", tableName, err)
+	}
+}
+
+// Helper function that returns the names of the attributes in a key
+func keyNames(key map[string]types.AttributeValue) []string {
+	names := make([]string, 0, len(key))
+	for name := range key {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// Helper function that creates a shallow copy of an item
+func copyItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	copied := make(map[string]types.AttributeValue, len(item))
+	for name, value := range item {
+		copied[name] = value
+	}
+
+	return copied
+}
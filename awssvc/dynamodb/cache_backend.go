@@ -0,0 +1,185 @@
+package dynamodb
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/xefino/goutils/math"
+)
+
+// MemoryCacheBackend stores cache entries in process memory. The total size of the entries is limited and, when
+// the limit is exceeded, the least-recently used entries will be evicted
+type MemoryCacheBackend struct {
+	maxBytes int64
+	size     int64
+	order    *list.List
+	entries  map[string]*list.Element
+	now      func() time.Time
+	lock     sync.Mutex
+}
+
+// Helper type describing a single entry in the memory cache backend
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCacheBackend creates a new MemoryCacheBackend that will hold at most the number of bytes of keys and
+// values provided. If this value is zero or negative then the size of the backend will not be limited
+func NewMemoryCacheBackend(maxBytes int64) *MemoryCacheBackend {
+	return &MemoryCacheBackend{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		now:      time.Now,
+	}
+}
+
+// Get retrieves the value associated with the key, if it exists and hasn't expired
+func (backend *MemoryCacheBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+
+	// First, check if we have an entry for the key; if we don't then return nothing
+	element, ok := backend.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	// Next, if the entry has expired then remove it and return nothing
+	entry := element.Value.(*memoryEntry)
+	if !backend.now().Before(entry.expires) {
+		backend.remove(element)
+		return nil, false, nil
+	}
+
+	// Finally, mark the entry as the most-recently used and return its value
+	backend.order.MoveToFront(element)
+	return entry.value, true, nil
+}
+
+// Set stores the value associated with the key, evicting the least-recently used entries if the backend is
+// over its size limit. Values larger than the size limit will not be stored
+func (backend *MemoryCacheBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+
+	// First, remove any existing entry for the key
+	if element, ok := backend.entries[key]; ok {
+		backend.remove(element)
+	}
+
+	// Next, if the entry is too large to fit in the backend then don't store it
+	entry := memoryEntry{key: key, value: value, expires: backend.now().Add(ttl)}
+	if backend.maxBytes > 0 && entry.size() > backend.maxBytes {
+		return nil
+	}
+
+	// Now, add the entry as the most-recently used
+	backend.entries[key] = backend.order.PushFront(&entry)
+	backend.size += entry.size()
+
+	// Finally, evict the least-recently used entries until we're within the size limit
+	for backend.maxBytes > 0 && backend.size > backend.maxBytes {
+		backend.remove(backend.order.Back())
+	}
+
+	return nil
+}
+
+// Delete removes the entries associated with the keys, if they exist
+func (backend *MemoryCacheBackend) Delete(ctx context.Context, keys ...string) error {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	for _, key := range keys {
+		if element, ok := backend.entries[key]; ok {
+			backend.remove(element)
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of entries currently held by the backend, including any that have expired but have
+// not yet been removed
+func (backend *MemoryCacheBackend) Len() int {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	return len(backend.entries)
+}
+
+// Helper function that removes an entry from the backend. This function assumes that the lock is held
+func (backend *MemoryCacheBackend) remove(element *list.Element) {
+	entry := backend.order.Remove(element).(*memoryEntry)
+	delete(backend.entries, entry.key)
+	backend.size -= entry.size()
+}
+
+// Helper function that calculates the size of an entry
+func (entry *memoryEntry) size() int64 {
+	return int64(len(entry.key) + len(entry.value))
+}
+
+// IRedisPool defines the functionality that will allow a RedisCacheBackend to get connections to Redis. This is
+// implemented by *redis.Pool
+type IRedisPool interface {
+	Get() redis.Conn
+}
+
+// RedisCacheBackend stores cache entries in Redis so that they can be shared between processes. Entries expire
+// using Redis's own expiry so the size of the cache should be limited by configuring a maxmemory policy on
+// the Redis server
+type RedisCacheBackend struct {
+	pool IRedisPool
+}
+
+// NewRedisCacheBackend creates a new RedisCacheBackend from the pool that will provide connections to Redis
+func NewRedisCacheBackend(pool IRedisPool) *RedisCacheBackend {
+	return &RedisCacheBackend{pool: pool}
+}
+
+// Get retrieves the value associated with the key from Redis, if it exists
+func (backend *RedisCacheBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	conn := backend.pool.Get()
+	defer conn.Close()
+
+	value, err := redis.Bytes(conn.Do("GET", key))
+	if err == redis.ErrNil {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	return value, true, nil
+}
+
+// Set stores the value associated with the key in Redis, expiring it after the time-to-live
+func (backend *RedisCacheBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	conn := backend.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("SET", key, value, "PX", math.Max(ttl.Milliseconds(), 1))
+	return err
+}
+
+// Delete removes the entries associated with the keys from Redis
+func (backend *RedisCacheBackend) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	conn := backend.pool.Get()
+	defer conn.Close()
+
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+
+	_, err := conn.Do("DEL", args...)
+	return err
+}
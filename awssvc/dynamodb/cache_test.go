@@ -0,0 +1,410 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/gomodule/redigo/redis"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/awssvc/testing"
+	"github.com/xefino/goutils/utils"
+)
+
+var _ = Describe("Item Cache Tests", func() {
+
+	// Create a new fake with our test table before each test
+	var fake *testing.FakeDynamoDB
+	BeforeEach(func() {
+		fake, _ = createFakeConnection()
+	})
+
+	// Tests that GetItem will only read an item from DynamoDB once while it is cached
+	It("GetItem - Cached - Read once", func() {
+
+		// First, create a cached connection and write an item to the table
+		conn := createCachedConnection(fake, NewItemCache(NewMemoryCacheBackend(0)))
+		writeFakeItems(conn, "a", 1)
+
+		// Next, read the item several times; this should not fail
+		for i := 0; i < 3; i++ {
+			output, err := conn.GetItem(context.Background(), createCacheInput("a", "0"))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(output.Item).Should(Equal(createFakeItem("a", "0", 0)))
+		}
+
+		// Finally, verify that the item was only read from DynamoDB once
+		Expect(fake.Calls("GetItem")).Should(Equal(1))
+	})
+
+	// Tests that writes made through the connection will invalidate the cache entries for the items they affect
+	It("GetItem - Written - Invalidated", func() {
+
+		// First, create a cached connection, write an item to the table and read it into the cache
+		conn := createCachedConnection(fake, NewItemCache(NewMemoryCacheBackend(0)))
+		writeFakeItems(conn, "a", 1)
+		readCachedData(conn, "a", "0")
+
+		// Next, verify that the entry is invalidated by a put
+		_, err := conn.PutItem(context.Background(), &dynamodb.PutItemInput{
+			TableName: aws.String("TEST_TABLE"),
+			Item:      createFakeItem("a", "0", 1),
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(readCachedData(conn, "a", "0")).Should(Equal("1"))
+
+		// Now, verify that the entry is invalidated by an update
+		input := dynamodb.UpdateItemInput{TableName: aws.String("TEST_TABLE"), Key: createFakeKey("a", "0")}
+		Expect(NewExpression().Set("data", 2).ApplyUpdate(&input)).ShouldNot(HaveOccurred())
+		_, err = conn.UpdateItem(context.Background(), &input)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(readCachedData(conn, "a", "0")).Should(Equal("2"))
+
+		// Finally, verify that the entry is invalidated by a batch write and then by a delete
+		_, err = conn.BatchWrite(context.Background(), "TEST_TABLE",
+			types.WriteRequest{PutRequest: &types.PutRequest{Item: createFakeItem("a", "0", 3)}})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(readCachedData(conn, "a", "0")).Should(Equal("3"))
+
+		_, err = conn.DeleteItem(context.Background(), &dynamodb.DeleteItemInput{
+			TableName: aws.String("TEST_TABLE"),
+			Key:       createFakeKey("a", "0"),
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(readCachedData(conn, "a", "0")).Should(BeEmpty())
+		Expect(fake.Calls("GetItem")).Should(Equal(5))
+	})
+
+	// Tests that, if the key attributes of a table haven't been seen by the cache, then a put will describe the
+	// table to invalidate the entry for the item in a shared backend
+	It("PutItem - Unknown key - Described", func() {
+
+		// First, create two connections with separate caches that share a backend and read an item into the
+		// backend through the first connection
+		backend := NewMemoryCacheBackend(0)
+		reader := createCachedConnection(fake, NewItemCache(backend))
+		writer := createCachedConnection(fake, NewItemCache(backend))
+		writeFakeItems(reader, "a", 1)
+		readCachedData(reader, "a", "0")
+
+		// Next, write the item through the second connection; this should not fail
+		_, err := writer.PutItem(context.Background(), &dynamodb.PutItemInput{
+			TableName: aws.String("TEST_TABLE"),
+			Item:      createFakeItem("a", "0", 1),
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that the first connection sees the new value. The table will have been described by
+		// both connections because the first wrote the item before it had read anything from the table
+		Expect(readCachedData(reader, "a", "0")).Should(Equal("1"))
+		Expect(fake.Calls("DescribeTable")).Should(Equal(2))
+	})
+
+	// Tests that, if negative caching is enabled, then the absence of an item will be cached
+	It("GetItem - Missing - Negative cached", func() {
+
+		// First, create a cached connection with negative caching enabled
+		conn := createCachedConnection(fake, NewItemCache(NewMemoryCacheBackend(0),
+			WithNegativeCacheTTL(time.Minute)))
+
+		// Next, read a missing item several times; this should not fail
+		for i := 0; i < 3; i++ {
+			output, err := conn.GetItem(context.Background(), createCacheInput("a", "0"))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(output.Item).Should(BeNil())
+		}
+
+		// Now, verify that the item was only read from DynamoDB once
+		Expect(fake.Calls("GetItem")).Should(Equal(1))
+
+		// Finally, write the item and verify that the negative entry was invalidated
+		writeFakeItems(conn, "a", 1)
+		Expect(readCachedData(conn, "a", "0")).Should(Equal("0"))
+	})
+
+	// Tests that, if negative caching is disabled, then missing items will always be read from DynamoDB
+	It("GetItem - Missing - Not cached", func() {
+		conn := createCachedConnection(fake, NewItemCache(NewMemoryCacheBackend(0)))
+		readCachedData(conn, "a", "0")
+		readCachedData(conn, "a", "0")
+		Expect(fake.Calls("GetItem")).Should(Equal(2))
+	})
+
+	// Tests that cached items will be read from DynamoDB again after they expire
+	It("GetItem - Expired - Read again", func() {
+		conn := createCachedConnection(fake, NewItemCache(NewMemoryCacheBackend(0),
+			WithCacheTTL(10*time.Millisecond)))
+		writeFakeItems(conn, "a", 1)
+
+		readCachedData(conn, "a", "0")
+		readCachedData(conn, "a", "0")
+		Expect(fake.Calls("GetItem")).Should(Equal(1))
+
+		time.Sleep(20 * time.Millisecond)
+		readCachedData(conn, "a", "0")
+		Expect(fake.Calls("GetItem")).Should(Equal(2))
+	})
+
+	// Tests that strongly consistent reads, projections and tables that aren't cached bypass the cache
+	It("GetItem - Bypassed - Always read", func() {
+
+		// First, create a cached connection that only caches another table and one that caches everything
+		others := createCachedConnection(fake, NewItemCache(NewMemoryCacheBackend(0),
+			WithCachedTables{"OTHER_TABLE"}))
+		conn := createCachedConnection(fake, NewItemCache(NewMemoryCacheBackend(0)))
+		writeFakeItems(conn, "a", 1)
+
+		// Next, read the item through the first connection twice
+		readCachedData(others, "a", "0")
+		readCachedData(others, "a", "0")
+
+		// Now, read the item with a strongly consistent read and a projection twice each
+		for i := 0; i < 2; i++ {
+			consistent := createCacheInput("a", "0")
+			consistent.ConsistentRead = aws.Bool(true)
+			_, err := conn.GetItem(context.Background(), consistent)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			projected := createCacheInput("a", "0")
+			projected.ProjectionExpression = aws.String("id")
+			_, err = conn.GetItem(context.Background(), projected)
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+
+		// Finally, verify that every read went to DynamoDB
+		Expect(fake.Calls("GetItem")).Should(Equal(6))
+	})
+
+	// Tests that concurrent requests for the same uncached item will share a single request to DynamoDB
+	It("GetItem - Concurrent - Single flight", func() {
+
+		// First, create a cached connection whose reads are slow and write an item to the table
+		conn := createCachedConnection(&slowGetClient{DynamoDBAPI: fake, delay: 50 * time.Millisecond},
+			NewItemCache(NewMemoryCacheBackend(0)))
+		writeFakeItems(conn, "a", 1)
+
+		// Next, read the item from a number of goroutines concurrently
+		var wg sync.WaitGroup
+		results := make([]string, 10)
+		for i := range results {
+			wg.Add(1)
+			go func(index int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				results[index] = readCachedData(conn, "a", "0")
+			}(i)
+		}
+
+		wg.Wait()
+
+		// Finally, verify that every goroutine got the item and that it was only read from DynamoDB once
+		for _, result := range results {
+			Expect(result).Should(Equal("0"))
+		}
+
+		Expect(fake.Calls("GetItem")).Should(Equal(1))
+	})
+
+	// Tests that, if the context of the caller that started a shared request is cancelled, then that caller
+	// will stop waiting but the request will still complete for the other callers sharing it
+	It("GetItem - Concurrent, first caller cancelled - Others served", func() {
+
+		// First, create a cached connection whose reads are slow and write an item to the table
+		conn := createCachedConnection(&slowGetClient{DynamoDBAPI: fake, delay: 50 * time.Millisecond},
+			NewItemCache(NewMemoryCacheBackend(0)))
+		writeFakeItems(conn, "a", 1)
+
+		// Next, start reading the item with a context that will be cancelled before the read completes
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		cancelled := make(chan error, 1)
+		go func() {
+			_, err := conn.GetItem(ctx, createCacheInput("a", "0"))
+			cancelled <- err
+		}()
+
+		// Now, read the item with a context that won't be cancelled once the first read has started
+		time.Sleep(5 * time.Millisecond)
+		data := readCachedData(conn, "a", "0")
+
+		// Finally, verify that the first caller was cancelled, that the second got the item and that it was only
+		// read from DynamoDB once
+		Expect(<-cancelled).Should(HaveOccurred())
+		Expect(data).Should(Equal("0"))
+		Expect(fake.Calls("GetItem")).Should(Equal(1))
+	})
+
+	// Tests that items larger than the maximum item size will not be cached
+	It("GetItem - Too large - Not cached", func() {
+		conn := createCachedConnection(fake, NewItemCache(NewMemoryCacheBackend(0), WithMaxCachedItemSize(10)))
+		writeFakeItems(conn, "a", 1)
+
+		readCachedData(conn, "a", "0")
+		readCachedData(conn, "a", "0")
+		Expect(fake.Calls("GetItem")).Should(Equal(2))
+	})
+
+	// Tests that the memory backend will evict the least-recently used entries when it exceeds its size limit
+	It("MemoryCacheBackend - Full - Evicts least-recently used", func() {
+
+		// First, create a backend that can hold two entries and add two entries to it
+		backend := NewMemoryCacheBackend(8)
+		Expect(backend.Set(context.Background(), "a", []byte("123"), time.Minute)).ShouldNot(HaveOccurred())
+		Expect(backend.Set(context.Background(), "b", []byte("123"), time.Minute)).ShouldNot(HaveOccurred())
+
+		// Next, read the first entry so that the second is the least-recently used and add a third entry
+		_, ok, err := backend.Get(context.Background(), "a")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).Should(BeTrue())
+		Expect(backend.Set(context.Background(), "c", []byte("123"), time.Minute)).ShouldNot(HaveOccurred())
+
+		// Finally, verify that the second entry was evicted and that an entry larger than the backend isn't stored
+		Expect(backend.Len()).Should(Equal(2))
+		_, ok, _ = backend.Get(context.Background(), "b")
+		Expect(ok).Should(BeFalse())
+
+		Expect(backend.Set(context.Background(), "d", []byte("123456789"), time.Minute)).ShouldNot(HaveOccurred())
+		_, ok, _ = backend.Get(context.Background(), "d")
+		Expect(ok).Should(BeFalse())
+		Expect(backend.Len()).Should(Equal(2))
+	})
+
+	// Tests that the Redis backend will store, retrieve and delete entries with the expected commands
+	It("RedisCacheBackend - Works", func() {
+
+		// First, create a Redis backend over a fake connection
+		conn := &fakeRedisConn{values: make(map[string][]byte)}
+		backend := NewRedisCacheBackend(&fakeRedisPool{conn: conn})
+
+		// Next, store and retrieve an entry; neither should fail
+		Expect(backend.Set(context.Background(), "key", []byte("value"), time.Second)).ShouldNot(HaveOccurred())
+		value, ok, err := backend.Get(context.Background(), "key")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).Should(BeTrue())
+		Expect(value).Should(Equal([]byte("value")))
+
+		// Finally, delete the entry and verify that it's gone
+		Expect(backend.Delete(context.Background(), "key", "other")).ShouldNot(HaveOccurred())
+		_, ok, err = backend.Get(context.Background(), "key")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).Should(BeFalse())
+		Expect(conn.commands).Should(Equal([]string{"SET key value PX 1000", "GET key", "DEL key other", "GET key"}))
+	})
+})
+
+// Helper type that delays GetItem requests so that concurrent requests overlap
+type slowGetClient struct {
+	DynamoDBAPI
+	delay time.Duration
+}
+
+// GetItem waits for the delay and then retrieves the item. If the context is cancelled during the delay then
+// its error will be returned
+func (client *slowGetClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	select {
+	case <-time.After(client.delay):
+		return client.DynamoDBAPI.GetItem(ctx, params, optFns...)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Helper type that implements a Redis pool by returning the same fake connection every time
+type fakeRedisPool struct {
+	conn *fakeRedisConn
+}
+
+// Get returns the fake connection
+func (pool *fakeRedisPool) Get() redis.Conn {
+	return pool.conn
+}
+
+// Helper type that implements a Redis connection supporting the GET, SET and DEL commands in memory
+type fakeRedisConn struct {
+	values   map[string][]byte
+	commands []string
+}
+
+// Close does nothing
+func (conn *fakeRedisConn) Close() error { return nil }
+
+// Err always returns nil
+func (conn *fakeRedisConn) Err() error { return nil }
+
+// Do records the command and executes it against the values in memory
+func (conn *fakeRedisConn) Do(command string, args ...interface{}) (interface{}, error) {
+	parts := []string{command}
+	for _, arg := range args {
+		if data, ok := arg.([]byte); ok {
+			arg = string(data)
+		}
+
+		parts = append(parts, fmt.Sprint(arg))
+	}
+
+	conn.commands = append(conn.commands, strings.Join(parts, " "))
+	switch command {
+	case "GET":
+		if value, ok := conn.values[args[0].(string)]; ok {
+			return value, nil
+		}
+
+		return nil, nil
+	case "SET":
+		conn.values[args[0].(string)] = args[1].([]byte)
+		return "OK", nil
+	case "DEL":
+		for _, key := range args {
+			delete(conn.values, key.(string))
+		}
+
+		return int64(len(args)), nil
+	default:
+		return nil, fmt.Errorf("unsupported command %s", command)
+	}
+}
+
+// Send is not supported
+func (conn *fakeRedisConn) Send(command string, args ...interface{}) error {
+	return fmt.Errorf("unsupported")
+}
+
+// Flush is not supported
+func (conn *fakeRedisConn) Flush() error { return fmt.Errorf("unsupported") }
+
+// Receive is not supported
+func (conn *fakeRedisConn) Receive() (interface{}, error) { return nil, fmt.Errorf("unsupported") }
+
+// Helper function that creates a connection whose GetItem requests are served through the cache provided
+func createCachedConnection(client DynamoDBAPI, cache *ItemCache) *DatabaseConnection {
+	logger := utils.NewLogger("testd", "test")
+	logger.Discard()
+	return FromClient(client, logger, WithBackoffStart(1), WithBackoffEnd(5), WithBackoffMaxElapsed(1000),
+		WithItemCache{Cache: cache})
+}
+
+// Helper function that creates the input used to get a test item
+func createCacheInput(id string, sortKey string) *dynamodb.GetItemInput {
+	return &dynamodb.GetItemInput{TableName: aws.String("TEST_TABLE"), Key: createFakeKey(id, sortKey)}
+}
+
+// Helper function that reads a test item through the connection and returns its data field, or an empty
+// string if the item doesn't exist
+func readCachedData(conn *DatabaseConnection, id string, sortKey string) string {
+	output, err := conn.GetItem(context.Background(), createCacheInput(id, sortKey))
+	Expect(err).ShouldNot(HaveOccurred())
+	if output.Item == nil {
+		return ""
+	}
+
+	return output.Item["data"].(*types.AttributeValueMemberN).Value
+}
@@ -0,0 +1,343 @@
+package dynamodb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xefino/goutils/collections"
+)
+
+// Boolean connection operations used to join condition clauses together
+const (
+	andOp = "AND"
+	orOp  = "OR"
+)
+
+// ConditionClause describes the functionality that should exist in any condition, key-condition or filter
+// expression terms
+type ConditionClause interface {
+	ModifyExpression(*Expression) string
+}
+
+// Operand describes the functionality that should exist for any value that may appear on either side of a
+// comparison or on the right-hand side of an update action
+type Operand interface {
+	ModifyExpression(*Expression) string
+}
+
+// NameOperand is an operand that refers to an attribute on the item
+type NameOperand struct {
+	Path string
+}
+
+// Name creates a new operand referring to the attribute with the path provided
+func Name(path string) *NameOperand {
+	return &NameOperand{Path: path}
+}
+
+// ModifyExpression modifies the expression so that it contains a placeholder for the attribute name,
+// returning the placeholder
+func (op *NameOperand) ModifyExpression(exp *Expression) string {
+	return exp.name(op.Path)
+}
+
+// ValueOperand is an operand that refers to a constant value
+type ValueOperand struct {
+	Value any
+}
+
+// Value creates a new operand referring to the constant value provided
+func Value(value any) *ValueOperand {
+	return &ValueOperand{Value: value}
+}
+
+// ModifyExpression modifies the expression so that it contains a placeholder for the value, returning
+// the placeholder
+func (op *ValueOperand) ModifyExpression(exp *Expression) string {
+	return exp.value(op.Value)
+}
+
+// FunctionOperand is an operand that refers to the result of calling a DynamoDB function, such as size,
+// if_not_exists or list_append
+type FunctionOperand struct {
+	Function  string
+	Arguments []Operand
+}
+
+// NewFunctionOperand creates a new function operand from the name of the function and its arguments
+func NewFunctionOperand(function string, args ...Operand) *FunctionOperand {
+	return &FunctionOperand{
+		Function:  function,
+		Arguments: args,
+	}
+}
+
+// ModifyExpression modifies the expression so that it contains placeholders for the arguments of the
+// function, returning the function call
+func (op *FunctionOperand) ModifyExpression(exp *Expression) string {
+	return fmt.Sprintf("%s(%s)", op.Function, strings.Join(collections.Convert(
+		func(arg Operand) string { return arg.ModifyExpression(exp) }, op.Arguments...), ", "))
+}
+
+// ArithmeticOperand is an operand that refers to the sum or difference of two other operands. This may only
+// be used in update expressions
+type ArithmeticOperand struct {
+	Left     Operand
+	Operator string
+	Right    Operand
+}
+
+// ModifyExpression modifies the expression so that it contains placeholders for both operands, returning
+// the arithmetic expression
+func (op *ArithmeticOperand) ModifyExpression(exp *Expression) string {
+	return op.Left.ModifyExpression(exp) + " " + op.Operator + " " + op.Right.ModifyExpression(exp)
+}
+
+// Size creates a new operand referring to the size of the attribute with the path provided
+func Size(path string) Operand {
+	return NewFunctionOperand("size", Name(path))
+}
+
+// IfNotExists creates a new operand that refers to the attribute with the path provided if it exists, or
+// the value provided otherwise. This may only be used in update expressions
+func IfNotExists(path string, value any) Operand {
+	return NewFunctionOperand("if_not_exists", Name(path), operand(value))
+}
+
+// ListAppend creates a new operand that refers to the concatenation of two lists. This may only be used in
+// update expressions
+func ListAppend(first any, second any) Operand {
+	return NewFunctionOperand("list_append", operand(first), operand(second))
+}
+
+// Plus creates a new operand that refers to the sum of two operands. This may only be used in update expressions
+func Plus(left any, right any) Operand {
+	return &ArithmeticOperand{Left: operand(left), Operator: "+", Right: operand(right)}
+}
+
+// Minus creates a new operand that refers to the difference of two operands. This may only be used in update
+// expressions
+func Minus(left any, right any) Operand {
+	return &ArithmeticOperand{Left: operand(left), Operator: "-", Right: operand(right)}
+}
+
+// ComparisonTerm is the base condition clause that allows a single comparison between two operands
+type ComparisonTerm struct {
+	Left     Operand
+	Operator string
+	Right    Operand
+}
+
+// NewComparisonTerm creates a new ComparisonTerm from a left operand, an operator and a right operand
+func NewComparisonTerm(left Operand, op string, right Operand) *ComparisonTerm {
+	return &ComparisonTerm{
+		Left:     left,
+		Operator: op,
+		Right:    right,
+	}
+}
+
+// ModifyExpression modifies the expression to include this comparison term
+func (term *ComparisonTerm) ModifyExpression(exp *Expression) string {
+	return fmt.Sprintf("%s %s %s", term.Left.ModifyExpression(exp), term.Operator, term.Right.ModifyExpression(exp))
+}
+
+// UnaryTerm creates a new condition term that allows a single condition clause to be combined with a
+// unary operator
+type UnaryTerm struct {
+	Operator string
+	Clause   ConditionClause
+}
+
+// NewUnaryTerm creates a new unary term from an operator and an inner condition clause
+func NewUnaryTerm(op string, clause ConditionClause) *UnaryTerm {
+	return &UnaryTerm{
+		Operator: op,
+		Clause:   clause,
+	}
+}
+
+// ModifyExpression modifies the expression to include this unary term
+func (term *UnaryTerm) ModifyExpression(exp *Expression) string {
+
+	// Get the result of the clause; if this is empty then we have no work to do here
+	result := term.Clause.ModifyExpression(exp)
+	if result == "" {
+		return ""
+	}
+
+	// Return the result enclosed in parentheses, preceeded by the operator
+	return term.Operator + " (" + result + ")"
+}
+
+// MultiTerm creates a new condition term that allows multiple condition clauses to be joined together inside
+// a set of parentheses. This is intended to allow for alternating sets of AND/OR logic (i.e. A AND (B OR C))
+type MultiTerm struct {
+	Operator string
+	Inner    []ConditionClause
+}
+
+// NewMultiTerm creates a new multi-term from a connecting operator and a list of inner clauses
+func NewMultiTerm(op string, clauses ...ConditionClause) *MultiTerm {
+	return &MultiTerm{
+		Operator: op,
+		Inner:    clauses,
+	}
+}
+
+// ModifyExpression modifies the expression to include this multi-term
+func (term *MultiTerm) ModifyExpression(exp *Expression) string {
+
+	// If we have no inner clauses then we have no work to do so return here
+	result := exp.join(term.Operator, term.Inner...)
+	if result == "" {
+		return ""
+	}
+
+	// Otherwise, return the joined clauses enclosed in parentheses
+	return "(" + result + ")"
+}
+
+// FunctionTerm creates a new condition term that calls a DynamoDB condition function, such as
+// attribute_exists or begins_with
+type FunctionTerm struct {
+	Function  string
+	Arguments []Operand
+}
+
+// NewFunctionTerm creates a new function term from the name of the function and its arguments
+func NewFunctionTerm(function string, args ...Operand) *FunctionTerm {
+	return &FunctionTerm{
+		Function:  function,
+		Arguments: args,
+	}
+}
+
+// ModifyExpression modifies the expression to include this function term
+func (term *FunctionTerm) ModifyExpression(exp *Expression) string {
+	return NewFunctionOperand(term.Function, term.Arguments...).ModifyExpression(exp)
+}
+
+// BetweenTerm creates a new condition term stating that an operand is between two other operands, inclusive
+type BetweenTerm struct {
+	Operand Operand
+	Lower   Operand
+	Upper   Operand
+}
+
+// ModifyExpression modifies the expression to include this between term
+func (term *BetweenTerm) ModifyExpression(exp *Expression) string {
+	return fmt.Sprintf("%s BETWEEN %s AND %s", term.Operand.ModifyExpression(exp),
+		term.Lower.ModifyExpression(exp), term.Upper.ModifyExpression(exp))
+}
+
+// InTerm creates a new condition term stating that an operand is equal to one of a list of operands
+type InTerm struct {
+	Operand Operand
+	Values  []Operand
+}
+
+// ModifyExpression modifies the expression to include this in term
+func (term *InTerm) ModifyExpression(exp *Expression) string {
+	return fmt.Sprintf("%s IN (%s)", term.Operand.ModifyExpression(exp), strings.Join(collections.Convert(
+		func(value Operand) string { return value.ModifyExpression(exp) }, term.Values...), ", "))
+}
+
+// Negate creates a new condition clause negating the clause sent to it as a parameter
+func Negate(clause ConditionClause) ConditionClause {
+	return NewUnaryTerm("NOT", clause)
+}
+
+// All creates a new condition clause stating that all the clauses sent to it must be true
+func All(clauses ...ConditionClause) ConditionClause {
+	return NewMultiTerm(andOp, clauses...)
+}
+
+// Any creates a new condition clause stating that at least one of the clauses sent to it must be true
+func Any(clauses ...ConditionClause) ConditionClause {
+	return NewMultiTerm(orOp, clauses...)
+}
+
+// Equals creates a new condition clause stating that an attribute is equal to a value
+func Equals(field string, value any) ConditionClause {
+	return NewComparisonTerm(Name(field), "=", operand(value))
+}
+
+// NotEquals creates a new condition clause stating that an attribute is not equal to a value
+func NotEquals(field string, value any) ConditionClause {
+	return NewComparisonTerm(Name(field), "<>", operand(value))
+}
+
+// LessThan creates a new condition clause stating that an attribute is less than a value
+func LessThan(field string, value any) ConditionClause {
+	return NewComparisonTerm(Name(field), "<", operand(value))
+}
+
+// LessThanOrEqualTo creates a new condition clause stating that an attribute is less than or equal to a value
+func LessThanOrEqualTo(field string, value any) ConditionClause {
+	return NewComparisonTerm(Name(field), "<=", operand(value))
+}
+
+// GreaterThan creates a new condition clause stating that an attribute is greater than a value
+func GreaterThan(field string, value any) ConditionClause {
+	return NewComparisonTerm(Name(field), ">", operand(value))
+}
+
+// GreaterThanOrEqualTo creates a new condition clause stating that an attribute is greater than or equal to
+// a value
+func GreaterThanOrEqualTo(field string, value any) ConditionClause {
+	return NewComparisonTerm(Name(field), ">=", operand(value))
+}
+
+// Between creates a new condition clause stating that an attribute is between two values, inclusive
+func Between(field string, lower any, upper any) ConditionClause {
+	return &BetweenTerm{Operand: Name(field), Lower: operand(lower), Upper: operand(upper)}
+}
+
+// In creates a new condition clause stating that an attribute is equal to one of the values provided
+func In(field string, values ...any) ConditionClause {
+	return &InTerm{Operand: Name(field), Values: collections.Convert(operand, values...)}
+}
+
+// BeginsWith creates a new condition clause stating that an attribute begins with the prefix provided
+func BeginsWith(field string, prefix any) ConditionClause {
+	return NewFunctionTerm("begins_with", Name(field), operand(prefix))
+}
+
+// Contains creates a new condition clause stating that a string attribute contains the substring provided
+// or that a set or list attribute contains the value provided
+func Contains(field string, value any) ConditionClause {
+	return NewFunctionTerm("contains", Name(field), operand(value))
+}
+
+// AttributeExists creates a new condition clause stating that an attribute exists on the item
+func AttributeExists(field string) ConditionClause {
+	return NewFunctionTerm("attribute_exists", Name(field))
+}
+
+// AttributeNotExists creates a new condition clause stating that an attribute does not exist on the item
+func AttributeNotExists(field string) ConditionClause {
+	return NewFunctionTerm("attribute_not_exists", Name(field))
+}
+
+// AttributeType creates a new condition clause stating that an attribute has the DynamoDB type provided,
+// which should be one of S, SS, N, NS, B, BS, BOOL, NULL, L or M
+func AttributeType(field string, attrType string) ConditionClause {
+	return NewFunctionTerm("attribute_type", Name(field), Value(attrType))
+}
+
+// Compare creates a new condition clause comparing two values with the operator provided. Either value
+// may be an Operand, such as Name or Size, or a constant value
+func Compare(left any, op string, right any) ConditionClause {
+	return NewComparisonTerm(operand(left), op, operand(right))
+}
+
+// Helper function that converts a value to an operand. If the value is already an operand then it will be
+// returned as-is; otherwise, it will be treated as a constant value
+func operand(value any) Operand {
+	if casted, ok := value.(Operand); ok {
+		return casted
+	}
+
+	return Value(value)
+}
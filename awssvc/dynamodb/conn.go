@@ -3,24 +3,33 @@ package dynamodb
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/aws/smithy-go"
 	"github.com/cenkalti/backoff/v4"
+	"github.com/xefino/goutils/collections"
+	"github.com/xefino/goutils/concurrency"
+	"github.com/xefino/goutils/math"
 	"github.com/xefino/goutils/utils"
 )
 
 // DatabaseConnection contains functinoality allowing for systemical access to DynamoDB
 type DatabaseConnection struct {
-	db            DynamoDBAPI
-	startInterval time.Duration
-	endInterval   time.Duration
-	maxElapsed    time.Duration
-	tagKey        string
-	logger        *utils.Logger
+	db               DynamoDBAPI
+	startInterval    time.Duration
+	endInterval      time.Duration
+	maxElapsed       time.Duration
+	batchAttempts    int
+	batchParallelism int
+	tagKey           string
+	retryPolicy      IRetryPolicy
+	metricsHook      IMetricsHook
+	logger           *utils.Logger
+	keySchemas       map[string][]string
+	keySchemaLock    sync.RWMutex
 }
 
 // NewDatabaseConnection creates a new DynamoDB database connection from an AWS session and logger
@@ -33,12 +42,16 @@ func FromClient(inner DynamoDBAPI, logger *utils.Logger, opts ...IDynamoDBOption
 
 	// First, create our database connection from the config and logger with default values
 	conn := DatabaseConnection{
-		db:            inner,
-		startInterval: 500,
-		endInterval:   60000,
-		maxElapsed:    900000,
-		tagKey:        "json",
-		logger:        logger.ChangeFrame(4),
+		db:               inner,
+		startInterval:    500,
+		endInterval:      60000,
+		maxElapsed:       900000,
+		batchAttempts:    10,
+		batchParallelism: 1,
+		tagKey:           "json",
+		retryPolicy:      new(RetryPolicy),
+		logger:           logger.ChangeFrame(4),
+		keySchemas:       make(map[string][]string),
 	}
 
 	// Next, iterate over the options provided and update the associated values in the connection
@@ -56,6 +69,7 @@ func (conn *DatabaseConnection) PutItem(ctx context.Context,
 
 	// Attempt to retry the operation to put the item in the table; if this
 	// fails then we'll return the associated error. Otherwise, return the output
+	ctx, scope := conn.startMetrics(ctx, *input.TableName, "PUT")
 	var output *dynamodb.PutItemOutput
 	err := conn.doRetry(ctx, *input.TableName, "PUT", func() error {
 		var inner error
@@ -63,6 +77,7 @@ func (conn *DatabaseConnection) PutItem(ctx context.Context,
 		return inner
 	})
 
+	scope.finish(err)
 	return output, err
 }
 
@@ -72,6 +87,7 @@ func (conn *DatabaseConnection) GetItem(ctx context.Context,
 
 	// Attempt to retry the operation to get the item from the table; if this
 	// fails then we'll return the associated error. Otherwise, return the output
+	ctx, scope := conn.startMetrics(ctx, *input.TableName, "GET")
 	var output *dynamodb.GetItemOutput
 	err := conn.doRetry(ctx, *input.TableName, "GET", func() error {
 		var inner error
@@ -79,6 +95,7 @@ func (conn *DatabaseConnection) GetItem(ctx context.Context,
 		return inner
 	})
 
+	scope.finish(err)
 	return output, err
 }
 
@@ -88,6 +105,7 @@ func (conn *DatabaseConnection) UpdateItem(ctx context.Context,
 
 	// Attempt to retry the operation to update the item in the table; if this
 	// fails then we'll return the associated error. Otherwise, return the output
+	ctx, scope := conn.startMetrics(ctx, *input.TableName, "UPDATE")
 	var output *dynamodb.UpdateItemOutput
 	err := conn.doRetry(ctx, *input.TableName, "UPDATE", func() error {
 		var inner error
@@ -95,6 +113,7 @@ func (conn *DatabaseConnection) UpdateItem(ctx context.Context,
 		return inner
 	})
 
+	scope.finish(err)
 	return output, err
 }
 
@@ -104,6 +123,7 @@ func (conn *DatabaseConnection) DeleteItem(ctx context.Context,
 
 	// Attempt to retry the operation to delete the item from the table; if this
 	// fails then we'll return the associated error. Otherwise, return the output
+	ctx, scope := conn.startMetrics(ctx, *input.TableName, "DELETE")
 	var output *dynamodb.DeleteItemOutput
 	err := conn.doRetry(ctx, *input.TableName, "DELETE", func() error {
 		var inner error
@@ -111,57 +131,114 @@ func (conn *DatabaseConnection) DeleteItem(ctx context.Context,
 		return inner
 	})
 
+	scope.finish(err)
 	return output, err
 }
 
-// BatchWrite makes a number of write requests against a table in DynamoDB. This function does not
-// return collection or capacity statistics.
+// BatchWrite makes a number of write requests against a table in DynamoDB. The requests will be split into
+// chunks of 25, which will be written concurrently up to the degree of parallelism set on the connection. Any
+// unprocessed items returned by DynamoDB will be retried with an exponential backoff until they have all been
+// written or the maximum number of attempts has been reached. The result will contain the requests that were
+// never written, which will be returned alongside an error if any exist. This function does not return
+// collection or capacity statistics.
 func (conn *DatabaseConnection) BatchWrite(ctx context.Context, tableName string,
-	requests ...types.WriteRequest) error {
+	requests ...types.WriteRequest) (*BatchWriteResult, error) {
+	ctx, scope := conn.startMetrics(ctx, tableName, "BATCH WRITE")
+	result, err := conn.batchWrite(ctx, tableName, requests...)
+	scope.finish(err)
+	return result, err
+}
+
+// Helper function that writes the requests to the table in chunks, retrying unprocessed items, on behalf of
+// BatchWrite
+func (conn *DatabaseConnection) batchWrite(ctx context.Context, tableName string,
+	requests ...types.WriteRequest) (*BatchWriteResult, error) {
 
-	// First, calculate the length from the number of requests. If there were no requests
-	// then exit (this also serves as the base case for our recursion)
-	length := len(requests)
-	if length == 0 {
-		return nil
+	// First, create our result. If there were no requests then we have nothing to do so exit here
+	result := BatchWriteResult{Unprocessed: make([]types.WriteRequest, 0)}
+	if len(requests) == 0 {
+		return &result, nil
 	}
 
-	conn.logger.Log("Attempting batch-write of %d entries to %s...", length, tableName)
+	conn.logger.Log("Attempting batch-write of %d entries to %s...", len(requests), tableName)
 
-	// Next, iterate over all the requests and chunk them so we don't have issues with the AWS
-	// batch size and request limits; accumulate any unprocessed items
-	retries := make([]types.WriteRequest, 0)
-	for current := 0; current < length; current += 25 {
-		next := current + 25
+	// Next, create the backoff we'll use to wait between attempts and a semaphore we'll use to limit the
+	// number of chunks that are written concurrently
+	timer := conn.createExponentialBackoff()
+	semaphore := make(chan struct{}, math.Max(conn.batchParallelism, 1))
 
-		// First, get the chunk of data we'll attempt to write. If we have 25 or more items left in
-		// the chunk then we'll take the whole page; otherwise, we'll take the remainder of the list
-		var chunk []types.WriteRequest
-		if next > length {
-			chunk = requests[current:]
-		} else {
-			chunk = requests[current:next]
+	// Now, iterate until we have no more requests to write or we run out of attempts
+	pending := requests
+	for len(pending) > 0 && result.Attempts < math.Max(conn.batchAttempts, 1) {
+
+		// First, if this isn't our first attempt then wait for the backoff before trying again. If the
+		// backoff has expired or the context was cancelled then return the requests we didn't write
+		if result.Attempts > 0 {
+			if err := conn.waitBackoff(ctx, timer); err != nil {
+				result.Unprocessed = pending
+				return &result, conn.NewError(err, tableName, "BATCH WRITE request to %s in DynamoDB failed; "+
+					"%d of %d requests were not written", tableName, len(pending), len(requests))
+			}
+		}
+
+		// Next, split the pending requests into chunks so we don't have issues with the AWS batch size and
+		// request limits. We'll assume that each chunk was not written until we know otherwise
+		result.Attempts++
+		chunks := collections.Page(pending, 25)
+		unprocessed := make([][]types.WriteRequest, len(chunks))
+		copy(unprocessed, chunks)
+
+		// Now, attempt to write each of the chunks concurrently, saving any unprocessed items in the slot
+		// associated with the chunk so that no synchronization is required
+		err := concurrency.ForAllAsync(ctx, len(chunks), true,
+			func(ctx context.Context, index int, _ context.CancelFunc) error {
+				select {
+				case semaphore <- struct{}{}:
+					defer func() { <-semaphore }()
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+
+				remaining, err := conn.batchWriteInner(ctx, tableName, chunks[index])
+				if err != nil {
+					return err
+				}
+
+				unprocessed[index] = remaining
+				return nil
+			})
+
+		// Finally, collect all the unprocessed items so they can be written on the next attempt. If any of
+		// the chunks failed then return the requests we didn't write with the error
+		pending = make([]types.WriteRequest, 0)
+		for _, chunk := range unprocessed {
+			pending = append(pending, chunk...)
 		}
 
-		// Next, attempt to write the items to DynamoDB; if this fails then return an error
-		unprocessed, err := conn.batchWriteInner(ctx, tableName, chunk)
+		result.Written = len(requests) - len(pending)
 		if err != nil {
-			return err
+			result.Unprocessed = pending
+			return &result, err
 		}
 
-		// Finally, save any unprocessed items so they can be written later
-		retries = append(retries, unprocessed...)
+		conn.logger.Log("Batch-write to %s completed. Retries? %t", tableName, len(pending) > 0)
+	}
+
+	// If we still have requests that weren't written then we ran out of attempts so return them with an error
+	if len(pending) > 0 {
+		result.Unprocessed = pending
+		return &result, conn.NewError(nil, tableName, "BATCH WRITE request to %s in DynamoDB failed; %d of %d "+
+			"requests were not written after %d attempts", tableName, len(pending), len(requests), result.Attempts)
 	}
 
-	// Finally, again attempt to do a batch write to the table with our unprocessed data
-	conn.logger.Log("Batch-write to %s completed. Retries? %t", tableName, len(retries) == 0)
-	return conn.BatchWrite(ctx, tableName, retries...)
+	return &result, nil
 }
 
 // Query makes a search on a particular partition in a DynamoDB table and returns the results. This
 // function does not return capacity statistics, just the queried results.
 func (conn *DatabaseConnection) Query(ctx context.Context,
 	input *dynamodb.QueryInput) ([]map[string]types.AttributeValue, error) {
+	ctx, scope := conn.startMetrics(ctx, *input.TableName, "QUERY")
 	results := make([]map[string]types.AttributeValue, 0)
 
 	// We'll start a loop that will query each page of results until all the pages have been retrieved
@@ -177,6 +254,7 @@ func (conn *DatabaseConnection) Query(ctx context.Context,
 
 		// If the query failed then pass the error back up
 		if err != nil {
+			scope.finish(err)
 			return nil, err
 		}
 
@@ -194,6 +272,7 @@ func (conn *DatabaseConnection) Query(ctx context.Context,
 	}
 
 	// Return the accumulated results
+	scope.finish(nil)
 	return results, nil
 }
 
@@ -201,6 +280,7 @@ func (conn *DatabaseConnection) Query(ctx context.Context,
 // capacity statistics, just the scanned results
 func (conn *DatabaseConnection) Scan(ctx context.Context,
 	input *dynamodb.ScanInput) ([]map[string]types.AttributeValue, error) {
+	ctx, scope := conn.startMetrics(ctx, *input.TableName, "SCAN")
 	results := make([]map[string]types.AttributeValue, 0)
 
 	// We'll start a loop that will scan each page of results until all the pages have been retrieved
@@ -216,6 +296,7 @@ func (conn *DatabaseConnection) Scan(ctx context.Context,
 
 		// If the scan failed then pass the error back up
 		if err != nil {
+			scope.finish(err)
 			return nil, err
 		}
 
@@ -233,6 +314,7 @@ func (conn *DatabaseConnection) Scan(ctx context.Context,
 	}
 
 	// Return the accumulated results
+	scope.finish(nil)
 	return results, nil
 }
 
@@ -268,43 +350,50 @@ func (conn *DatabaseConnection) batchWriteInner(ctx context.Context, tableName s
 	return output.UnprocessedItems[tableName], nil
 }
 
-// Helper function that does a retry operation to handle a number of common AWS DynamoDB retry cases
+// Helper function that does a retry operation to handle a number of common AWS DynamoDB retry cases. The
+// retry policy associated with the connection will determine which errors are retried and will be notified
+// of each attempt. If the context is cancelled then the operation will not be retried
 func (conn *DatabaseConnection) doRetry(ctx context.Context, tableName string, verb string,
 	operation func() error) error {
 	conn.logger.Log("Attempting %s operation to %s in DynamoDB...", verb, tableName)
 
 	// Attempt the operation with a backoff in the case where an intermittent failure occurs
+	attempt := 0
 	err := backoff.Retry(func() error {
-		if err := operation(); err != nil {
-			var message string
-
-			// Check that the error type is one that we'd want to retry on. For throughput or request
-			// limit exceptions, waiting a bit may allow the request to succeed. For internal server
-			// errors, since we're not sure of the cause, we'll wait to see if the service manages
-			// to fix itself. Otherwise, we'll return the error wrapped in a permanent failure
-			inner := err.(*smithy.OperationError)
-			switch casted := inner.Err.(type) {
-			case *types.ProvisionedThroughputExceededException:
-				message = *casted.Message
-			case *types.RequestLimitExceeded:
-				message = *casted.Message
-			case *types.InternalServerError:
-				message = *casted.Message
-			default:
-				return backoff.Permanent(err)
-			}
 
-			// If we reached this point then we want to retry so log a message stating that there was
-			// a failure and we're going to retry
-			conn.logger.Log("DynamoDB request to %s failed: %s. Retrying...",
-				tableName, message)
-			return err
+		// First, attempt the operation, timing how long it takes
+		attempt++
+		metricsFromContext(ctx).addAttempt()
+		start := time.Now()
+		err := operation()
+
+		// Next, check whether the error is one that we'd want to retry on. If the context has finished then
+		// we won't retry regardless of the error as the retry would fail anyway. Otherwise, we'll ask the
+		// retry policy. Then, record the attempt with the retry policy
+		retry := err != nil && ctx.Err() == nil && conn.retryPolicy.ShouldRetry(err)
+		conn.retryPolicy.OnAttempt(&RetryAttempt{
+			TableName: tableName,
+			Verb:      verb,
+			Attempt:   attempt,
+			Duration:  time.Since(start),
+			Err:       err,
+			Retry:     retry,
+		})
+
+		// Now, if the operation did not fail then we'll return nil to tell the backoff that there's nothing
+		// else to do here. If it failed and we don't want to retry then return the error wrapped in a
+		// permanent failure
+		if err == nil {
+			conn.logger.Log("Completed %s operation to %s in DynamoDB", verb, tableName)
+			return nil
+		} else if !retry {
+			return backoff.Permanent(err)
 		}
 
-		// Finally, since the operation did not fail we'll return nil to tell the backoff that
-		// there's nothing else to do here
-		conn.logger.Log("Completed %s operation to %s in DynamoDB", verb, tableName)
-		return nil
+		// Finally, if we reached this point then we want to retry so log a message stating that there was
+		// a failure and we're going to retry
+		conn.logger.Log("DynamoDB request to %s failed: %s. Retrying...", tableName, retryMessage(err))
+		return err
 	}, backoff.WithContext(conn.createExponentialBackoff(), ctx))
 
 	// For whatever reason, the operation failed so create an error and return it
@@ -169,10 +169,10 @@ var _ = Describe("Database Connection Tests", Ordered, func() {
 		Expect(err).Should(HaveOccurred())
 		Expect(casted.TableName).Should(Equal("FAKE_TABLE"))
 		testutils.ErrorVerifier("test", "dynamodb", "/goutils/awssvc/dynamodb/conn.go", "DatabaseConnection",
-			"PutItem", 60, testutils.InnerErrorPrefixSuffixVerifier("operation error DynamoDB: PutItem, "+
+			"PutItem", 74, testutils.InnerErrorPrefixSuffixVerifier("operation error DynamoDB: PutItem, "+
 				"https response error StatusCode: 400, RequestID: ", ", ResourceNotFoundException: "),
 			"PUT request to FAKE_TABLE in DynamoDB failed", "[test] dynamodb.DatabaseConnection.PutItem "+
-				"(/goutils/awssvc/dynamodb/conn.go 60): PUT request to FAKE_TABLE in DynamoDB failed, Inner:\n\t"+
+				"(/goutils/awssvc/dynamodb/conn.go 74): PUT request to FAKE_TABLE in DynamoDB failed, Inner:\n\t"+
 				"operation error DynamoDB: PutItem, https response error StatusCode: 400, RequestID: ",
 			", ResourceNotFoundException: .")(casted.GError)
 	})
@@ -268,10 +268,10 @@ var _ = Describe("Database Connection Tests", Ordered, func() {
 		Expect(err).Should(HaveOccurred())
 		Expect(casted.TableName).Should(Equal("FAKE_TABLE"))
 		testutils.ErrorVerifier("test", "dynamodb", "/goutils/awssvc/dynamodb/conn.go", "DatabaseConnection",
-			"GetItem", 76, testutils.InnerErrorPrefixSuffixVerifier("operation error DynamoDB: GetItem, "+
+			"GetItem", 92, testutils.InnerErrorPrefixSuffixVerifier("operation error DynamoDB: GetItem, "+
 				"https response error StatusCode: 400, RequestID: ", ", ResourceNotFoundException: "),
 			"GET request to FAKE_TABLE in DynamoDB failed", "[test] dynamodb.DatabaseConnection.GetItem "+
-				"(/goutils/awssvc/dynamodb/conn.go 76): GET request to FAKE_TABLE in DynamoDB failed, Inner:\n\t"+
+				"(/goutils/awssvc/dynamodb/conn.go 92): GET request to FAKE_TABLE in DynamoDB failed, Inner:\n\t"+
 				"operation error DynamoDB: GetItem, https response error StatusCode: 400, RequestID: ",
 			", ResourceNotFoundException: .")(casted.GError)
 	})
@@ -366,10 +366,10 @@ var _ = Describe("Database Connection Tests", Ordered, func() {
 		Expect(err).Should(HaveOccurred())
 		Expect(casted.TableName).Should(Equal("FAKE_TABLE"))
 		testutils.ErrorVerifier("test", "dynamodb", "/goutils/awssvc/dynamodb/conn.go", "DatabaseConnection",
-			"UpdateItem", 92, testutils.InnerErrorPrefixSuffixVerifier("operation error DynamoDB: UpdateItem, "+
+			"UpdateItem", 110, testutils.InnerErrorPrefixSuffixVerifier("operation error DynamoDB: UpdateItem, "+
 				"https response error StatusCode: 400, RequestID: ", ", ResourceNotFoundException: "),
 			"UPDATE request to FAKE_TABLE in DynamoDB failed", "[test] dynamodb.DatabaseConnection.UpdateItem "+
-				"(/goutils/awssvc/dynamodb/conn.go 92): UPDATE request to FAKE_TABLE in DynamoDB failed, Inner:\n\t"+
+				"(/goutils/awssvc/dynamodb/conn.go 110): UPDATE request to FAKE_TABLE in DynamoDB failed, Inner:\n\t"+
 				"operation error DynamoDB: UpdateItem, https response error StatusCode: 400, RequestID: ",
 			", ResourceNotFoundException: .")(casted.GError)
 	})
@@ -468,10 +468,10 @@ var _ = Describe("Database Connection Tests", Ordered, func() {
 		Expect(err).Should(HaveOccurred())
 		Expect(casted.TableName).Should(Equal("FAKE_TABLE"))
 		testutils.ErrorVerifier("test", "dynamodb", "/goutils/awssvc/dynamodb/conn.go", "DatabaseConnection",
-			"DeleteItem", 108, testutils.InnerErrorPrefixSuffixVerifier("operation error DynamoDB: DeleteItem, "+
+			"DeleteItem", 128, testutils.InnerErrorPrefixSuffixVerifier("operation error DynamoDB: DeleteItem, "+
 				"https response error StatusCode: 400, RequestID: ", ", ResourceNotFoundException: "),
 			"DELETE request to FAKE_TABLE in DynamoDB failed", "[test] dynamodb.DatabaseConnection.DeleteItem "+
-				"(/goutils/awssvc/dynamodb/conn.go 108): DELETE request to FAKE_TABLE in DynamoDB failed, Inner:\n\t"+
+				"(/goutils/awssvc/dynamodb/conn.go 128): DELETE request to FAKE_TABLE in DynamoDB failed, Inner:\n\t"+
 				"operation error DynamoDB: DeleteItem, https response error StatusCode: 400, RequestID: ",
 			", ResourceNotFoundException: .")(casted.GError)
 	})
@@ -555,7 +555,7 @@ var _ = Describe("Database Connection Tests", Ordered, func() {
 		Expect(err).ShouldNot(HaveOccurred())
 
 		// Finally, attempt to batch-write our requests to DynamoDB; this should fail
-		err = conn.BatchWrite(context.Background(), "FAKE_TABLE",
+		_, err = conn.BatchWrite(context.Background(), "FAKE_TABLE",
 			types.WriteRequest{PutRequest: &types.PutRequest{Item: attrs}})
 
 		// Verify the details of the error
@@ -563,10 +563,10 @@ var _ = Describe("Database Connection Tests", Ordered, func() {
 		Expect(err).Should(HaveOccurred())
 		Expect(casted.TableName).Should(Equal("FAKE_TABLE"))
 		testutils.ErrorVerifier("test", "dynamodb", "/goutils/awssvc/dynamodb/conn.go", "DatabaseConnection",
-			"batchWriteInner", 256, testutils.InnerErrorPrefixSuffixVerifier("operation error DynamoDB: BatchWriteItem, "+
+			"batchWriteInner", 338, testutils.InnerErrorPrefixSuffixVerifier("operation error DynamoDB: BatchWriteItem, "+
 				"https response error StatusCode: 400, RequestID: ", ", ResourceNotFoundException: "),
 			"BATCH WRITE request to FAKE_TABLE in DynamoDB failed", "[test] dynamodb.DatabaseConnection.batchWriteInner "+
-				"(/goutils/awssvc/dynamodb/conn.go 256): BATCH WRITE request to FAKE_TABLE in DynamoDB failed, Inner:\n\t"+
+				"(/goutils/awssvc/dynamodb/conn.go 338): BATCH WRITE request to FAKE_TABLE in DynamoDB failed, Inner:\n\t"+
 				"operation error DynamoDB: BatchWriteItem, https response error StatusCode: 400, RequestID: ",
 			", ResourceNotFoundException: .")(casted.GError)
 	})
@@ -602,8 +602,10 @@ var _ = Describe("Database Connection Tests", Ordered, func() {
 		}
 
 		// Now, attempt to batch-write our requests to DynamoDB; this should not fail
-		err := conn.BatchWrite(context.Background(), "TEST_TABLE", requests...)
+		result, err := conn.BatchWrite(context.Background(), "TEST_TABLE", requests...)
 		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.Written).Should(Equal(47))
+		Expect(result.Unprocessed).Should(BeEmpty())
 
 		// Finally, do a query to retrieve all the items we wrote; this should not fail
 		output, err := conn.db.Query(context.Background(), &dynamodb.QueryInput{
@@ -659,10 +661,10 @@ var _ = Describe("Database Connection Tests", Ordered, func() {
 		Expect(err).Should(HaveOccurred())
 		Expect(casted.TableName).Should(Equal("FAKE_TABLE"))
 		testutils.ErrorVerifier("test", "dynamodb", "/goutils/awssvc/dynamodb/conn.go", "DatabaseConnection",
-			"Query", 172, testutils.InnerErrorPrefixSuffixVerifier("operation error DynamoDB: Query, "+
+			"Query", 249, testutils.InnerErrorPrefixSuffixVerifier("operation error DynamoDB: Query, "+
 				"https response error StatusCode: 400, RequestID: ", ", ResourceNotFoundException: "),
 			"QUERY(0) request to FAKE_TABLE in DynamoDB failed", "[test] dynamodb.DatabaseConnection.Query "+
-				"(/goutils/awssvc/dynamodb/conn.go 172): QUERY(0) request to FAKE_TABLE in DynamoDB failed, Inner:\n\t"+
+				"(/goutils/awssvc/dynamodb/conn.go 249): QUERY(0) request to FAKE_TABLE in DynamoDB failed, Inner:\n\t"+
 				"operation error DynamoDB: Query, https response error StatusCode: 400, RequestID: ",
 			", ResourceNotFoundException: .")(casted.GError)
 	})
@@ -699,7 +701,7 @@ var _ = Describe("Database Connection Tests", Ordered, func() {
 		}
 
 		// Now, attempt to batch-write our requests to DynamoDB; this should not fail
-		err := conn.BatchWrite(context.Background(), "TEST_TABLE", requests...)
+		_, err := conn.BatchWrite(context.Background(), "TEST_TABLE", requests...)
 		Expect(err).ShouldNot(HaveOccurred())
 
 		// Finally, attempt to query the data in DynamoDB; this should not fail
@@ -755,10 +757,10 @@ var _ = Describe("Database Connection Tests", Ordered, func() {
 		Expect(err).Should(HaveOccurred())
 		Expect(casted.TableName).Should(Equal("FAKE_TABLE"))
 		testutils.ErrorVerifier("test", "dynamodb", "/goutils/awssvc/dynamodb/conn.go", "DatabaseConnection",
-			"Scan", 211, testutils.InnerErrorPrefixSuffixVerifier("operation error DynamoDB: Scan, "+
+			"Scan", 291, testutils.InnerErrorPrefixSuffixVerifier("operation error DynamoDB: Scan, "+
 				"https response error StatusCode: 400, RequestID: ", ", ResourceNotFoundException: "),
 			"SCAN(0) request to FAKE_TABLE in DynamoDB failed", "[test] dynamodb.DatabaseConnection.Scan "+
-				"(/goutils/awssvc/dynamodb/conn.go 211): SCAN(0) request to FAKE_TABLE in DynamoDB failed, Inner:\n\t"+
+				"(/goutils/awssvc/dynamodb/conn.go 291): SCAN(0) request to FAKE_TABLE in DynamoDB failed, Inner:\n\t"+
 				"operation error DynamoDB: Scan, https response error StatusCode: 400, RequestID: ",
 			", ResourceNotFoundException: .")(casted.GError)
 	})
@@ -795,7 +797,7 @@ var _ = Describe("Database Connection Tests", Ordered, func() {
 		}
 
 		// Now, attempt to batch-write our requests to DynamoDB; this should not fail
-		err := conn.BatchWrite(context.Background(), "TEST_TABLE", requests...)
+		_, err := conn.BatchWrite(context.Background(), "TEST_TABLE", requests...)
 		Expect(err).ShouldNot(HaveOccurred())
 
 		// Finally, attempt to query the data in DynamoDB; this should not fail
@@ -853,7 +855,7 @@ func (client *failureDynamoDBClient) GetItem(ctx context.Context, params *dynamo
 
 // Helper type that we'll use to test DynamoDB functionality
 type testObject struct {
-	ID      string `json:"id"`
-	SortKey string `json:"sort_key"`
+	ID      string `json:"id" dynamo:"pk"`
+	SortKey string `json:"sort_key" dynamo:"sk"`
 	Data    int    `json:"data"`
 }
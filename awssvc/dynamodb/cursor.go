@@ -0,0 +1,367 @@
+package dynamodb
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awskms "github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/xefino/goutils/awssvc/kms"
+)
+
+// CursorInvalid is the error associated with a cursor that could not be decoded, either because it was not
+// produced by a CursorCodec with the same sealer or because it was modified after it was produced
+var CursorInvalid = errors.New("Cursor is invalid")
+
+// CursorExpired is the error associated with a cursor whose time-to-live has passed
+var CursorExpired = errors.New("Cursor has expired")
+
+// CursorMismatch is the error associated with a cursor that was produced for a different query or scan than
+// the one it is being used to resume
+var CursorMismatch = errors.New("Cursor does not match the request")
+
+// The encryption context that will be attached to cursors sealed with KMS
+var cursorEncryptionContext = map[string]string{"purpose": "dynamodb-cursor"}
+
+// ICursorSealer defines the functionality that will protect the contents of a cursor from being read or
+// modified by the client holding it
+type ICursorSealer interface {
+
+	// Seal protects the payload, returning the data that should be given to the client
+	Seal(ctx context.Context, payload []byte) ([]byte, error)
+
+	// Open verifies data produced by Seal and returns the original payload. If the data was not produced by
+	// Seal, or was modified since, then an error wrapping CursorInvalid should be returned
+	Open(ctx context.Context, data []byte) ([]byte, error)
+}
+
+// HMACCursorSealer signs cursors with HMAC-SHA256 so that they can't be forged or modified. Note that the
+// contents of a signed cursor are only encoded, not encrypted, so a client that decodes the cursor will be able
+// to read the key it contains. If this is not acceptable then KMSCursorSealer should be used instead
+type HMACCursorSealer struct {
+	secret   []byte
+	previous [][]byte
+}
+
+// NewHMACCursorSealer creates a new HMACCursorSealer from the secret that will be used to sign cursors. Any
+// previous secrets provided will be accepted when verifying cursors, but will not be used to sign them, so
+// that the secret can be rotated without invalidating cursors that were already issued
+func NewHMACCursorSealer(secret []byte, previous ...[]byte) *HMACCursorSealer {
+	return &HMACCursorSealer{secret: secret, previous: previous}
+}
+
+// Seal appends the HMAC-SHA256 signature of the payload to the payload
+func (sealer *HMACCursorSealer) Seal(ctx context.Context, payload []byte) ([]byte, error) {
+	return append(append([]byte{}, payload...), sign(sealer.secret, payload)...), nil
+}
+
+// Open verifies the signature appended to the payload against the current and previous secrets, returning the
+// payload if any of them match
+func (sealer *HMACCursorSealer) Open(ctx context.Context, data []byte) ([]byte, error) {
+
+	// First, ensure that the data is long enough to contain a signature
+	if len(data) < sha256.Size {
+		return nil, fmt.Errorf("%w: cursor was too short to be signed", CursorInvalid)
+	}
+
+	// Next, split the data into the payload and its signature and check the signature against each of our
+	// secrets, returning the payload if any of them match
+	payload, signature := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	for _, secret := range append([][]byte{sealer.secret}, sealer.previous...) {
+		if hmac.Equal(signature, sign(secret, payload)) {
+			return payload, nil
+		}
+	}
+
+	// Finally, none of the secrets matched so the cursor was forged or modified
+	return nil, fmt.Errorf("%w: signature did not match", CursorInvalid)
+}
+
+// KMSCursorSealer encrypts cursors with a symmetric KMS key so that their contents can't be read or modified.
+// Each cursor requires a call to KMS to encode or decode so this sealer is more expensive than the
+// HMACCursorSealer
+type KMSCursorSealer struct {
+	client kms.KMSAPI
+	keyID  string
+}
+
+// NewKMSCursorSealer creates a new KMSCursorSealer from a KMS client and the ID or ARN of the symmetric key, or
+// an alias for it, that should be used to encrypt cursors
+func NewKMSCursorSealer(client kms.KMSAPI, keyID string) *KMSCursorSealer {
+	return &KMSCursorSealer{client: client, keyID: keyID}
+}
+
+// Seal encrypts the payload with the KMS key
+func (sealer *KMSCursorSealer) Seal(ctx context.Context, payload []byte) ([]byte, error) {
+	output, err := sealer.client.Encrypt(ctx, &awskms.EncryptInput{
+		KeyId:             aws.String(sealer.keyID),
+		Plaintext:         payload,
+		EncryptionContext: cursorEncryptionContext,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt cursor with %s: %w", sealer.keyID, err)
+	}
+
+	return output.CiphertextBlob, nil
+}
+
+// Open decrypts the data with the KMS key. If KMS rejects the data as invalid then an error wrapping
+// CursorInvalid will be returned
+func (sealer *KMSCursorSealer) Open(ctx context.Context, data []byte) ([]byte, error) {
+	output, err := sealer.client.Decrypt(ctx, &awskms.DecryptInput{
+		KeyId:             aws.String(sealer.keyID),
+		CiphertextBlob:    data,
+		EncryptionContext: cursorEncryptionContext,
+	})
+
+	var invalid *kmstypes.InvalidCiphertextException
+	var incorrect *kmstypes.IncorrectKeyException
+	if errors.As(err, &invalid) || errors.As(err, &incorrect) {
+		return nil, fmt.Errorf("%w: %v", CursorInvalid, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to decrypt cursor with %s: %w", sealer.keyID, err)
+	}
+
+	return output.Plaintext, nil
+}
+
+// CursorCodec converts the keys returned by DynamoDB as LastEvaluatedKey to opaque, URL-safe cursors that can be
+// given to the clients of a public API, and converts those cursors back to keys that can be used as the
+// ExclusiveStartKey of the next request. Each cursor is protected by a sealer so that clients can't forge keys,
+// expires after a time-to-live and carries a fingerprint of the request that produced it so that it can't be
+// used to resume a different query or scan
+type CursorCodec struct {
+	sealer ICursorSealer
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// Helper type containing the contents of a cursor before it is sealed
+type cursorPayload struct {
+	Key         json.RawMessage `json:"k"`
+	Expires     int64           `json:"e"`
+	Fingerprint string          `json:"f"`
+}
+
+// NewCursorCodec creates a new CursorCodec from the sealer that will protect cursors and options
+func NewCursorCodec(sealer ICursorSealer, opts ...ICursorOption) *CursorCodec {
+
+	// First, create our codec with default values
+	codec := CursorCodec{
+		sealer: sealer,
+		ttl:    time.Hour,
+		now:    time.Now,
+	}
+
+	// Next, iterate over the options provided and update the associated values in the codec
+	for _, opt := range opts {
+		opt.Apply(&codec)
+	}
+
+	// Finally, return a reference to the codec
+	return &codec
+}
+
+// Encode converts a key to a cursor that is bound to the fingerprint provided. If the key is empty, which
+// DynamoDB uses to indicate that there are no more pages, then an empty cursor will be returned
+func (codec *CursorCodec) Encode(ctx context.Context, key map[string]types.AttributeValue,
+	fingerprint string) (string, error) {
+
+	// First, if we have no key then there's nothing to resume so return an empty cursor
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	// Next, convert the key to lossless JSON so that it can be converted back exactly; if this fails then
+	// return an error
+	data, err := AttributeValuesToJSON(key, WithLosslessJSON(true))
+	if err != nil {
+		return "", fmt.Errorf("failed to convert cursor key to JSON: %w", err)
+	}
+
+	// Now, create the payload from the key, expiry and fingerprint and attempt to seal it; if either fails
+	// then return an error
+	payload, err := json.Marshal(cursorPayload{
+		Key:         data,
+		Expires:     codec.now().Add(codec.ttl).Unix(),
+		Fingerprint: fingerprint,
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("failed to convert cursor to JSON: %w", err)
+	}
+
+	sealed, err := codec.sealer.Seal(ctx, payload)
+	if err != nil {
+		return "", err
+	}
+
+	// Finally, encode the sealed cursor so that it may be used in a URL
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decode converts a cursor produced by Encode back to a key, verifying that it was not modified, that it has
+// not expired and that it was bound to the fingerprint provided. If the cursor is empty then a nil key will be
+// returned so that the request starts from the beginning. Errors caused by the cursor will wrap CursorInvalid,
+// CursorExpired or CursorMismatch so that they can be reported to the client
+func (codec *CursorCodec) Decode(ctx context.Context, cursor string,
+	fingerprint string) (map[string]types.AttributeValue, error) {
+
+	// First, if we have no cursor then we should start from the beginning so return a nil key
+	if cursor == "" {
+		return nil, nil
+	}
+
+	// Next, attempt to decode the cursor and open it; if either fails then return an error
+	sealed, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", CursorInvalid, err)
+	}
+
+	data, err := codec.sealer.Open(ctx, sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	// Now, attempt to parse the payload and verify that it hasn't expired and that it was produced for the
+	// same request; if any of these checks fail then return an error
+	var payload cursorPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", CursorInvalid, err)
+	}
+
+	if !codec.now().Before(time.Unix(payload.Expires, 0)) {
+		return nil, CursorExpired
+	}
+
+	if !hmac.Equal([]byte(payload.Fingerprint), []byte(fingerprint)) {
+		return nil, CursorMismatch
+	}
+
+	// Finally, convert the key back to attribute values; if this fails or the key is empty then the cursor is
+	// invalid so return an error
+	key, err := JSONToAttributeValues(payload.Key, WithLosslessJSON(true))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", CursorInvalid, err)
+	} else if len(key) == 0 {
+		return nil, fmt.Errorf("%w: cursor did not contain a key", CursorInvalid)
+	}
+
+	return key, nil
+}
+
+// EncodeQuery converts the LastEvaluatedKey returned by a query to a cursor that is bound to the query input
+func (codec *CursorCodec) EncodeQuery(ctx context.Context, input *dynamodb.QueryInput,
+	key map[string]types.AttributeValue) (string, error) {
+	fingerprint, err := QueryFingerprint(input)
+	if err != nil {
+		return "", err
+	}
+
+	return codec.Encode(ctx, key, fingerprint)
+}
+
+// DecodeQuery converts a cursor produced by EncodeQuery back to a key and sets it as the ExclusiveStartKey on
+// the query input. The cursor will be rejected if it was produced for a different query
+func (codec *CursorCodec) DecodeQuery(ctx context.Context, input *dynamodb.QueryInput, cursor string) error {
+	fingerprint, err := QueryFingerprint(input)
+	if err != nil {
+		return err
+	}
+
+	key, err := codec.Decode(ctx, cursor, fingerprint)
+	if err != nil {
+		return err
+	}
+
+	input.ExclusiveStartKey = key
+	return nil
+}
+
+// EncodeScan converts the LastEvaluatedKey returned by a scan to a cursor that is bound to the scan input
+func (codec *CursorCodec) EncodeScan(ctx context.Context, input *dynamodb.ScanInput,
+	key map[string]types.AttributeValue) (string, error) {
+	fingerprint, err := ScanFingerprint(input)
+	if err != nil {
+		return "", err
+	}
+
+	return codec.Encode(ctx, key, fingerprint)
+}
+
+// DecodeScan converts a cursor produced by EncodeScan back to a key and sets it as the ExclusiveStartKey on the
+// scan input. The cursor will be rejected if it was produced for a different scan
+func (codec *CursorCodec) DecodeScan(ctx context.Context, input *dynamodb.ScanInput, cursor string) error {
+	fingerprint, err := ScanFingerprint(input)
+	if err != nil {
+		return err
+	}
+
+	key, err := codec.Decode(ctx, cursor, fingerprint)
+	if err != nil {
+		return err
+	}
+
+	input.ExclusiveStartKey = key
+	return nil
+}
+
+// QueryFingerprint creates a fingerprint of the parts of a query input that determine which items it returns
+// and in what order: the table, index, key condition, filter, projection, expression names and values and the
+// scan direction. The exclusive start key, limit and read consistency are not included as these may change
+// between pages
+func QueryFingerprint(input *dynamodb.QueryInput) (string, error) {
+	return fingerprint("QUERY", input.ExpressionAttributeValues, aws.ToString(input.TableName),
+		aws.ToString(input.IndexName), aws.ToString(input.KeyConditionExpression),
+		aws.ToString(input.FilterExpression), aws.ToString(input.ProjectionExpression),
+		input.ExpressionAttributeNames, aws.ToBool(input.ScanIndexForward) || input.ScanIndexForward == nil)
+}
+
+// ScanFingerprint creates a fingerprint of the parts of a scan input that determine which items it returns: the
+// table, index, filter, projection, expression names and values and the segment. The exclusive start key,
+// limit and read consistency are not included as these may change between pages
+func ScanFingerprint(input *dynamodb.ScanInput) (string, error) {
+	return fingerprint("SCAN", input.ExpressionAttributeValues, aws.ToString(input.TableName),
+		aws.ToString(input.IndexName), aws.ToString(input.FilterExpression),
+		aws.ToString(input.ProjectionExpression), input.ExpressionAttributeNames,
+		aws.ToInt32(input.Segment), aws.ToInt32(input.TotalSegments))
+}
+
+// Helper function that creates a fingerprint from the SHA-256 hash of the JSON encoding of the expression
+// attribute values and the other parts of a request provided
+func fingerprint(verb string, values map[string]types.AttributeValue, parts ...interface{}) (string, error) {
+
+	// First, convert the expression attribute values to lossless JSON so that values of different types
+	// produce different fingerprints; if this fails then return an error
+	encoded, err := AttributeValuesToJSON(values, WithLosslessJSON(true))
+	if err != nil {
+		return "", fmt.Errorf("failed to convert expression attribute values to JSON: %w", err)
+	}
+
+	// Next, convert all the parts of the request to JSON; if this fails then return an error
+	data, err := json.Marshal(append([]interface{}{verb, json.RawMessage(encoded)}, parts...))
+	if err != nil {
+		return "", fmt.Errorf("failed to convert %s request to JSON: %w", verb, err)
+	}
+
+	// Finally, hash the request and return the encoded hash
+	hash := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(hash[:]), nil
+}
+
+// Helper function that creates the HMAC-SHA256 signature of the payload with the secret provided
+func sign(secret []byte, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,214 @@
+package dynamodb
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awskms "github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/awssvc/kms"
+)
+
+var _ = Describe("Cursor Tests", func() {
+
+	// Tests that a cursor signed with HMAC can be decoded back to the key it was created from, without losing
+	// any type information
+	It("Encode - HMAC - Round trips", func() {
+
+		// First, create a codec and a key containing values that can't be represented in plain JSON
+		codec := NewCursorCodec(NewHMACCursorSealer([]byte("secret")))
+		key := map[string]types.AttributeValue{
+			"id":   &types.AttributeValueMemberS{Value: "test_id"},
+			"sort": &types.AttributeValueMemberN{Value: "12345678901234567890.5"},
+			"bin":  &types.AttributeValueMemberB{Value: []byte{1, 2, 3}},
+		}
+
+		// Next, encode the key to a cursor; this should not fail and the cursor should be URL-safe
+		cursor, err := codec.Encode(context.Background(), key, "fingerprint")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(cursor).Should(MatchRegexp(`^[A-Za-z0-9_-]+$`))
+
+		// Finally, decode the cursor and verify that we get the original key back
+		decoded, err := codec.Decode(context.Background(), cursor, "fingerprint")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(decoded).Should(Equal(key))
+	})
+
+	// Tests that an empty key will be encoded to an empty cursor, which will be decoded to a nil key
+	It("Encode - Empty - Empty cursor", func() {
+		codec := NewCursorCodec(NewHMACCursorSealer([]byte("secret")))
+
+		cursor, err := codec.Encode(context.Background(), nil, "fingerprint")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(cursor).Should(BeEmpty())
+
+		key, err := codec.Decode(context.Background(), cursor, "fingerprint")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(key).Should(BeNil())
+	})
+
+	// Tests that, if a cursor is modified, then Decode will reject it as invalid
+	It("Decode - Tampered - CursorInvalid", func() {
+
+		// First, create a codec and encode a key to a cursor
+		codec := NewCursorCodec(NewHMACCursorSealer([]byte("secret")))
+		cursor, err := codec.Encode(context.Background(), createFakeKey("a", "0"), "fingerprint")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, decode the cursor and replace the key it contains with another one
+		data, err := base64.RawURLEncoding.DecodeString(cursor)
+		Expect(err).ShouldNot(HaveOccurred())
+		forged := base64.RawURLEncoding.EncodeToString(bytes.Replace(data, []byte(`"a"`), []byte(`"b"`), 1))
+
+		// Finally, verify that the modified cursor, a garbage cursor and a cursor signed with another secret
+		// are all rejected
+		_, err = codec.Decode(context.Background(), forged, "fingerprint")
+		Expect(errors.Is(err, CursorInvalid)).Should(BeTrue())
+
+		_, err = codec.Decode(context.Background(), "not a cursor!", "fingerprint")
+		Expect(errors.Is(err, CursorInvalid)).Should(BeTrue())
+
+		other := NewCursorCodec(NewHMACCursorSealer([]byte("other")))
+		_, err = other.Decode(context.Background(), cursor, "fingerprint")
+		Expect(errors.Is(err, CursorInvalid)).Should(BeTrue())
+	})
+
+	// Tests that cursors signed with a previous secret will still be accepted after the secret is rotated
+	It("Decode - Rotated secret - Works", func() {
+		old := NewCursorCodec(NewHMACCursorSealer([]byte("old")))
+		cursor, err := old.Encode(context.Background(), createFakeKey("a", "0"), "fingerprint")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		rotated := NewCursorCodec(NewHMACCursorSealer([]byte("new"), []byte("old")))
+		key, err := rotated.Decode(context.Background(), cursor, "fingerprint")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(key).Should(Equal(createFakeKey("a", "0")))
+	})
+
+	// Tests that, if a cursor has passed its time-to-live, then Decode will reject it as expired
+	It("Decode - Expired - CursorExpired", func() {
+
+		// First, create a codec with a short time-to-live and encode a key to a cursor
+		codec := NewCursorCodec(NewHMACCursorSealer([]byte("secret")), WithCursorTTL(time.Minute))
+		cursor, err := codec.Encode(context.Background(), createFakeKey("a", "0"), "fingerprint")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, move the clock on the codec past the time-to-live
+		codec.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+
+		// Finally, attempt to decode the cursor; this should fail
+		key, err := codec.Decode(context.Background(), cursor, "fingerprint")
+		Expect(key).Should(BeNil())
+		Expect(err).Should(Equal(CursorExpired))
+	})
+
+	// Tests that a cursor created for one query can't be used to resume a different query
+	It("DecodeQuery - Different query - CursorMismatch", func() {
+
+		// First, create a codec and a query input for one partition
+		codec := NewCursorCodec(NewHMACCursorSealer([]byte("secret")))
+		input := dynamodb.QueryInput{TableName: aws.String("TEST_TABLE")}
+		Expect(NewExpression().KeyCondition(Equals("id", "a")).ApplyQuery(&input)).ShouldNot(HaveOccurred())
+
+		// Next, encode a key for the query and verify that it can be used to resume the same query
+		cursor, err := codec.EncodeQuery(context.Background(), &input, createFakeKey("a", "0"))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		same := dynamodb.QueryInput{TableName: aws.String("TEST_TABLE"), Limit: aws.Int32(5)}
+		Expect(NewExpression().KeyCondition(Equals("id", "a")).ApplyQuery(&same)).ShouldNot(HaveOccurred())
+		Expect(codec.DecodeQuery(context.Background(), &same, cursor)).ShouldNot(HaveOccurred())
+		Expect(same.ExclusiveStartKey).Should(Equal(createFakeKey("a", "0")))
+
+		// Finally, verify that the cursor can't be used for a query on another partition or in another direction
+		other := dynamodb.QueryInput{TableName: aws.String("TEST_TABLE")}
+		Expect(NewExpression().KeyCondition(Equals("id", "b")).ApplyQuery(&other)).ShouldNot(HaveOccurred())
+		Expect(codec.DecodeQuery(context.Background(), &other, cursor)).Should(Equal(CursorMismatch))
+		Expect(other.ExclusiveStartKey).Should(BeNil())
+
+		same.ScanIndexForward = aws.Bool(false)
+		Expect(codec.DecodeQuery(context.Background(), &same, cursor)).Should(Equal(CursorMismatch))
+	})
+
+	// Tests that a cursor created for one scan segment can't be used to resume another segment
+	It("DecodeScan - Different segment - CursorMismatch", func() {
+		codec := NewCursorCodec(NewHMACCursorSealer([]byte("secret")))
+		input := dynamodb.ScanInput{TableName: aws.String("TEST_TABLE"), Segment: aws.Int32(0), TotalSegments: aws.Int32(2)}
+		cursor, err := codec.EncodeScan(context.Background(), &input, createFakeKey("a", "0"))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(codec.DecodeScan(context.Background(), &input, cursor)).ShouldNot(HaveOccurred())
+		Expect(input.ExclusiveStartKey).Should(Equal(createFakeKey("a", "0")))
+
+		other := dynamodb.ScanInput{TableName: aws.String("TEST_TABLE"), Segment: aws.Int32(1), TotalSegments: aws.Int32(2)}
+		Expect(codec.DecodeScan(context.Background(), &other, cursor)).Should(Equal(CursorMismatch))
+	})
+
+	// Tests that a cursor encrypted with KMS can be decoded back to the key it was created from and that the
+	// key can't be read from the cursor
+	It("Encode - KMS - Round trips", func() {
+
+		// First, create a codec that encrypts cursors with KMS and encode a key to a cursor
+		codec := NewCursorCodec(NewKMSCursorSealer(new(fakeKMS), "test_key"))
+		cursor, err := codec.Encode(context.Background(), createFakeKey("a", "0"), "fingerprint")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, verify that the key can't be read from the cursor
+		data, err := base64.RawURLEncoding.DecodeString(cursor)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(data)).ShouldNot(ContainSubstring("sort_key"))
+
+		// Now, decode the cursor and verify that we get the original key back
+		key, err := codec.Decode(context.Background(), cursor, "fingerprint")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(key).Should(Equal(createFakeKey("a", "0")))
+
+		// Finally, verify that a cursor that KMS can't decrypt is rejected as invalid
+		_, err = codec.Decode(context.Background(), base64.RawURLEncoding.EncodeToString([]byte("garbage")),
+			"fingerprint")
+		Expect(errors.Is(err, CursorInvalid)).Should(BeTrue())
+	})
+})
+
+// Helper type that implements the KMS API by "encrypting" data with a reversible transform so that cursors
+// sealed with KMS can be tested without calling AWS
+type fakeKMS struct {
+	kms.KMSAPI
+}
+
+// Encrypt inverts each byte of the plaintext and prepends a header identifying the key
+func (client *fakeKMS) Encrypt(ctx context.Context, params *awskms.EncryptInput,
+	optFns ...func(*awskms.Options)) (*awskms.EncryptOutput, error) {
+	return &awskms.EncryptOutput{
+		CiphertextBlob: append([]byte(*params.KeyId+":"), invert(params.Plaintext)...),
+		KeyId:          params.KeyId,
+	}, nil
+}
+
+// Decrypt reverses Encrypt, returning an InvalidCiphertextException if the ciphertext has the wrong header
+func (client *fakeKMS) Decrypt(ctx context.Context, params *awskms.DecryptInput,
+	optFns ...func(*awskms.Options)) (*awskms.DecryptOutput, error) {
+	header := []byte(*params.KeyId + ":")
+	if !bytes.HasPrefix(params.CiphertextBlob, header) {
+		return nil, &kmstypes.InvalidCiphertextException{Message: aws.String("invalid ciphertext")}
+	}
+
+	return &awskms.DecryptOutput{Plaintext: invert(params.CiphertextBlob[len(header):]), KeyId: params.KeyId}, nil
+}
+
+// Helper function that inverts each byte of the data provided
+func invert(data []byte) []byte {
+	inverted := make([]byte, len(data))
+	for i, b := range data {
+		inverted[i] = ^b
+	}
+
+	return inverted
+}
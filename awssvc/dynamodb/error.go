@@ -1,13 +1,69 @@
 package dynamodb
 
 import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/xefino/goutils/utils"
 )
 
+// Codes that DynamoDB may return to describe why an item caused a transaction to be cancelled
+const (
+	ReasonNone                            = "None"
+	ReasonConditionalCheckFailed          = "ConditionalCheckFailed"
+	ReasonItemCollectionSizeLimitExceeded = "ItemCollectionSizeLimitExceeded"
+	ReasonTransactionConflict             = "TransactionConflict"
+	ReasonProvisionedThroughputExceeded   = "ProvisionedThroughputExceeded"
+	ReasonThrottlingError                 = "ThrottlingError"
+	ReasonValidationError                 = "ValidationError"
+)
+
+// ErrorKind classifies the cause of an Error so that callers can respond to specific failures without
+// having to inspect the inner error returned by DynamoDB
+type ErrorKind int
+
+// Kinds of errors that may be returned by the DynamoDB database connection
+const (
+
+	// The error has not been classified; the inner error should be inspected to determine the cause
+	KindUnclassified ErrorKind = iota
+
+	// The error was caused by a condition expression that was not satisfied by the item in DynamoDB
+	KindConditionFailed
+
+	// The error was caused by a versioned write where the version of the item in DynamoDB did not match the
+	// version of the item being written. This indicates that the item was modified by another writer
+	KindVersionConflict
+)
+
 // Error describes an error returned by the DynamoDB database connection
 type Error struct {
 	*utils.GError
 	TableName string
+
+	// A classification of the cause of the error
+	Kind ErrorKind
+
+	// If the error was caused by a cancelled transaction then this will contain the reason associated with
+	// each item in the transaction, in the same order as the items in the request
+	Reasons []*CancellationReason
+}
+
+// CancellationReason describes why a single item in a transaction caused that transaction to be cancelled
+type CancellationReason struct {
+
+	// The index of the item in the transaction request
+	Index int
+
+	// The code describing why the item caused the transaction to be cancelled. If the item did not cause the
+	// transaction to be cancelled then this will be None
+	Code string
+
+	// A message describing why the item caused the transaction to be cancelled
+	Message string
+
+	// The item associated with the reason, if it was requested
+	Item map[string]types.AttributeValue
 }
 
 // NewError creates a new Error from an inner error, table name, message and arguments
@@ -16,5 +72,60 @@ func (conn *DatabaseConnection) NewError(inner error, tableName string,
 	return &Error{
 		GError:    conn.logger.Error(inner, message, args...),
 		TableName: tableName,
+		Kind:      classifyError(inner),
 	}
 }
+
+// IsConflict returns true if the error was caused by a versioned write that conflicted with another writer
+func (err *Error) IsConflict() bool {
+	return err.Kind == KindVersionConflict
+}
+
+// Failed returns the cancellation reasons for only those items that caused the transaction to be cancelled
+func (err *Error) Failed() []*CancellationReason {
+	failed := make([]*CancellationReason, 0)
+	for _, reason := range err.Reasons {
+		if reason.Code != ReasonNone {
+			failed = append(failed, reason)
+		}
+	}
+
+	return failed
+}
+
+// Helper function that classifies an error based on the exception returned by DynamoDB, if one exists in the
+// chain of errors provided
+func classifyError(err error) ErrorKind {
+	var conditionFailed *types.ConditionalCheckFailedException
+	if err != nil && errors.As(err, &conditionFailed) {
+		return KindConditionFailed
+	}
+
+	return KindUnclassified
+}
+
+// Helper function that decodes the cancellation reasons from a TransactionCanceledException, if one exists
+// in the chain of errors provided. If no such exception exists then nil will be returned
+func cancellationReasons(err error) []*CancellationReason {
+
+	// First, attempt to find the cancellation exception; if we don't have one then return nil
+	var casted *types.TransactionCanceledException
+	if !errors.As(err, &casted) {
+		return nil
+	}
+
+	// Next, convert each of the reasons on the exception to our own reason type
+	reasons := make([]*CancellationReason, len(casted.CancellationReasons))
+	for i, reason := range casted.CancellationReasons {
+		reasons[i] = &CancellationReason{Index: i, Code: ReasonNone, Item: reason.Item}
+		if reason.Code != nil {
+			reasons[i].Code = *reason.Code
+		}
+
+		if reason.Message != nil {
+			reasons[i].Message = *reason.Message
+		}
+	}
+
+	return reasons
+}
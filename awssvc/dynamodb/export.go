@@ -0,0 +1,336 @@
+package dynamodb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/xefino/goutils/awssvc/s3"
+)
+
+// The name of the object, relative to the export prefix, where the manifest of an export will be written
+const manifestName = "manifest.json"
+
+// The number of write requests that will be sent to BatchWrite at a time during an import
+const importBatchSize = 250
+
+// The maximum size of a single line that will be read from an exported part. DynamoDB items are limited to
+// 400 KB but binary values are expanded by base64-encoding, so we allow for a much larger line
+const maxLineSize = 4 * 1024 * 1024
+
+// ExportManifest describes the objects written to S3 by an export. It is written alongside the parts, as
+// manifest.json under the export prefix, so that the export can be imported without listing the bucket
+type ExportManifest struct {
+
+	// The name of the table that was exported
+	TableName string `json:"table_name"`
+
+	// The time at which the export was started
+	StartedAt time.Time `json:"started_at"`
+
+	// The total number of items that were exported
+	Items int `json:"items"`
+
+	// The parts that were written to S3, in the order they were written
+	Parts []*ExportPart `json:"parts"`
+}
+
+// ExportPart describes a single gzipped NDJSON object written to S3 by an export
+type ExportPart struct {
+
+	// The key of the object in S3
+	Key string `json:"key"`
+
+	// The number of items written to the object
+	Items int `json:"items"`
+
+	// The size of the object, in compressed bytes
+	Size int64 `json:"size"`
+}
+
+// Helper type that writes items to gzipped NDJSON parts, uploading each part to S3 when it reaches the part
+// size and recording it on the manifest
+type exporter struct {
+	conn     *DatabaseConnection
+	store    s3.IConnection
+	bucket   string
+	prefix   string
+	options  *exportOptions
+	manifest *ExportManifest
+	buffer   *bytes.Buffer
+	writer   *gzip.Writer
+	items    int
+}
+
+// ExportScan scans a table in DynamoDB and writes the items to S3 as gzipped, newline-delimited JSON. Each item
+// will be written on its own line using AttributeValuesToJSON in lossless mode, so that the export can be
+// imported without losing any type information. Items will be written to parts, named part-00000.ndjson.gz,
+// part-00001.ndjson.gz, etc. under the prefix, and a new part will be started whenever the current part
+// exceeds the part size. When all the items have been written, a manifest describing the parts will be written
+// to manifest.json under the prefix and returned. The input will not be modified by this function
+func (conn *DatabaseConnection) ExportScan(ctx context.Context, store s3.IConnection, bucket string,
+	prefix string, input *dynamodb.ScanInput, opts ...IExportOption) (*ExportManifest, error) {
+	export := conn.newExporter(store, bucket, prefix, *input.TableName, opts...)
+	if _, err := conn.ScanItems(ctx, input, export.handler(ctx)); err != nil {
+		return nil, err
+	}
+
+	return export.finish(ctx)
+}
+
+// ExportQuery queries a table in DynamoDB and writes the items to S3 as gzipped, newline-delimited JSON. This
+// function behaves in the same way as ExportScan
+func (conn *DatabaseConnection) ExportQuery(ctx context.Context, store s3.IConnection, bucket string,
+	prefix string, input *dynamodb.QueryInput, opts ...IExportOption) (*ExportManifest, error) {
+	export := conn.newExporter(store, bucket, prefix, *input.TableName, opts...)
+	if _, err := conn.QueryItems(ctx, input, export.handler(ctx)); err != nil {
+		return nil, err
+	}
+
+	return export.finish(ctx)
+}
+
+// ImportFromS3 reads the manifest written by ExportScan or ExportQuery under the prefix in S3 and imports all
+// the parts it describes into the table provided, which need not be the table that was exported. The number
+// of items that were written will be returned. See ImportParts for more details
+func (conn *DatabaseConnection) ImportFromS3(ctx context.Context, store s3.IConnection, bucket string,
+	prefix string, tableName string) (int, error) {
+
+	// First, attempt to download the manifest from S3; if this fails then return an error
+	key := path.Join(prefix, manifestName)
+	reader, err := conn.download(ctx, store, bucket, key, tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	// Next, attempt to decode the manifest; if this fails then return an error
+	var manifest ExportManifest
+	if err := json.NewDecoder(reader).Decode(&manifest); err != nil {
+		return 0, conn.NewError(err, tableName, "Failed to decode export manifest %s in %s", key, bucket)
+	}
+
+	// Finally, import all the parts described by the manifest
+	keys := make([]string, len(manifest.Parts))
+	for i, part := range manifest.Parts {
+		keys[i] = part.Key
+	}
+
+	return conn.ImportParts(ctx, store, bucket, tableName, keys...)
+}
+
+// ImportParts reads gzipped, newline-delimited JSON objects from S3 and writes the items they contain to the
+// table provided with BatchWrite. Each line is expected to be a JSON object in the lossless format written by
+// ExportScan and ExportQuery, although any JSON object accepted by JSONToAttributeValues may be used so that
+// test fixtures can be written by hand. Existing items with the same keys will be overwritten. The number of
+// items that were written will be returned, even if an error occurs
+func (conn *DatabaseConnection) ImportParts(ctx context.Context, store s3.IConnection, bucket string,
+	tableName string, keys ...string) (int, error) {
+	written := 0
+	for _, key := range keys {
+		if err := conn.importPart(ctx, store, bucket, tableName, key, &written); err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// Helper function that creates a new exporter that will write parts under the prefix in the bucket provided
+func (conn *DatabaseConnection) newExporter(store s3.IConnection, bucket string, prefix string,
+	tableName string, opts ...IExportOption) *exporter {
+	return &exporter{
+		conn:     conn,
+		store:    store,
+		bucket:   bucket,
+		prefix:   prefix,
+		options:  newExportOptions(opts...),
+		manifest: &ExportManifest{TableName: tableName, StartedAt: time.Now().UTC(), Parts: []*ExportPart{}},
+	}
+}
+
+// Helper function that creates an item handler that will write each item to the current part, uploading the
+// part when it exceeds the part size
+func (export *exporter) handler(ctx context.Context) ItemHandler {
+	return func(item map[string]types.AttributeValue) (bool, error) {
+
+		// First, convert the item to lossless JSON; if this fails then return an error
+		data, err := AttributeValuesToJSON(item, WithLosslessJSON(true))
+		if err != nil {
+			return false, export.conn.NewError(err, export.manifest.TableName,
+				"Failed to convert item to JSON for export to %s", export.bucket)
+		}
+
+		// Next, if we don't have a part in progress then start a new one
+		if export.writer == nil {
+			export.buffer = new(bytes.Buffer)
+			export.writer = gzip.NewWriter(export.buffer)
+		}
+
+		// Now, write the item to the part as a single line; if this fails then return an error
+		if _, err := export.writer.Write(append(data, '\n')); err != nil {
+			return false, export.conn.NewError(err, export.manifest.TableName,
+				"Failed to compress item for export to %s", export.bucket)
+		}
+
+		// Finally, if the part has exceeded the part size then upload it
+		export.items++
+		if int64(export.buffer.Len()) >= export.options.partSize {
+			if err := export.flush(ctx); err != nil {
+				return false, err
+			}
+		}
+
+		return true, nil
+	}
+}
+
+// Helper function that uploads the part in progress to S3 and records it on the manifest. If no part is in
+// progress then this function does nothing
+func (export *exporter) flush(ctx context.Context) error {
+	if export.writer == nil {
+		return nil
+	}
+
+	// First, close the writer so that all the compressed data is written to the buffer
+	if err := export.writer.Close(); err != nil {
+		return export.conn.NewError(err, export.manifest.TableName,
+			"Failed to compress part %d for export to %s", len(export.manifest.Parts), export.bucket)
+	}
+
+	// Next, attempt to upload the part to S3; if this fails then return an error
+	part := ExportPart{
+		Key:   path.Join(export.prefix, fmt.Sprintf("part-%05d.ndjson.gz", len(export.manifest.Parts))),
+		Items: export.items,
+		Size:  int64(export.buffer.Len()),
+	}
+
+	if err := export.store.UploadFromStream(ctx, export.bucket, part.Key, export.buffer); err != nil {
+		return export.conn.NewError(err, export.manifest.TableName, "Failed to upload %s to %s",
+			part.Key, export.bucket)
+	}
+
+	// Finally, record the part on the manifest and reset the exporter so that a new part will be started
+	export.manifest.Parts = append(export.manifest.Parts, &part)
+	export.manifest.Items += part.Items
+	export.buffer, export.writer, export.items = nil, nil, 0
+	return nil
+}
+
+// Helper function that uploads the final part in progress and then writes the manifest to S3
+func (export *exporter) finish(ctx context.Context) (*ExportManifest, error) {
+
+	// First, upload any part that is still in progress
+	if err := export.flush(ctx); err != nil {
+		return nil, err
+	}
+
+	// Next, attempt to convert the manifest to JSON; if this fails then return an error
+	data, err := json.Marshal(export.manifest)
+	if err != nil {
+		return nil, export.conn.NewError(err, export.manifest.TableName,
+			"Failed to convert export manifest to JSON")
+	}
+
+	// Finally, attempt to upload the manifest to S3; if this fails then return an error
+	key := path.Join(export.prefix, manifestName)
+	if err := export.store.UploadFromStream(ctx, export.bucket, key, bytes.NewReader(data)); err != nil {
+		return nil, export.conn.NewError(err, export.manifest.TableName, "Failed to upload %s to %s",
+			key, export.bucket)
+	}
+
+	export.conn.logger.Log("Exported %d items from %s to %d parts under %s in %s", export.manifest.Items,
+		export.manifest.TableName, len(export.manifest.Parts), export.prefix, export.bucket)
+	return export.manifest, nil
+}
+
+// Helper function that imports a single gzipped part from S3 into the table, adding the number of items that
+// were written to the total. The decompressor will be closed when the part has been read
+func (conn *DatabaseConnection) importPart(ctx context.Context, store s3.IConnection, bucket string,
+	tableName string, key string, written *int) error {
+
+	// First, attempt to download the part from S3 and decompress it; if this fails then return an error
+	reader, err := conn.download(ctx, store, bucket, key, tableName)
+	if err != nil {
+		return err
+	}
+
+	unzipped, err := gzip.NewReader(reader)
+	if err != nil {
+		return conn.NewError(err, tableName, "Failed to decompress %s in %s", key, bucket)
+	}
+
+	defer unzipped.Close()
+
+	// Next, read each line from the part, convert it to a put request and write the requests to DynamoDB
+	// in batches; if any of this fails then return an error
+	requests := make([]types.WriteRequest, 0, importBatchSize)
+	scanner := bufio.NewScanner(unzipped)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		item, err := JSONToAttributeValues(scanner.Bytes(), WithLosslessJSON(true))
+		if err != nil {
+			return conn.NewError(err, tableName, "Failed to convert line %d of %s in %s to DynamoDB "+
+				"attribute values", line, key, bucket)
+		}
+
+		requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+		if len(requests) == importBatchSize {
+			if err := conn.importBatch(ctx, tableName, requests, written); err != nil {
+				return err
+			}
+
+			requests = requests[:0]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return conn.NewError(err, tableName, "Failed to read %s in %s", key, bucket)
+	}
+
+	// Finally, write any requests remaining from the part to DynamoDB
+	if err := conn.importBatch(ctx, tableName, requests, written); err != nil {
+		return err
+	}
+
+	conn.logger.Log("Imported %s from %s into %s", key, bucket, tableName)
+	return nil
+}
+
+// Helper function that downloads an object from S3 and returns a reader over its contents
+func (conn *DatabaseConnection) download(ctx context.Context, store s3.IConnection, bucket string,
+	key string, tableName string) (io.Reader, error) {
+	data, err := store.Download(ctx, bucket, key)
+	if err != nil {
+		return nil, conn.NewError(err, tableName, "Failed to download %s from %s", key, bucket)
+	}
+
+	return bytes.NewReader(data), nil
+}
+
+// Helper function that writes a batch of put requests to the table, adding the number of requests that were
+// written to the total
+func (conn *DatabaseConnection) importBatch(ctx context.Context, tableName string,
+	requests []types.WriteRequest, written *int) error {
+	if len(requests) == 0 {
+		return nil
+	}
+
+	result, err := conn.BatchWrite(ctx, tableName, requests...)
+	if result != nil {
+		*written += result.Written
+	}
+
+	return err
+}
@@ -0,0 +1,286 @@
+package dynamodb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/awssvc/s3"
+)
+
+// Ensure that our fake store implements the S3 connection so it can be used in place of one
+var _ s3.IConnection = new(fakeStore)
+
+var _ = Describe("Export Tests", func() {
+
+	// Create a new fake with our test table before each test
+	var conn *DatabaseConnection
+	var store *fakeStore
+	BeforeEach(func() {
+		_, conn = createFakeConnection()
+		store = &fakeStore{objects: make(map[string][]byte)}
+	})
+
+	// Tests that ExportScan will write every item in the table to a single part when the part size is large
+	It("ExportScan - Works", func() {
+
+		// First, write a number of items to the table
+		writeFakeItems(conn, "a", 5)
+		writeFakeItems(conn, "b", 5)
+
+		// Next, export the table to S3; this should not fail
+		manifest, err := conn.ExportScan(context.Background(), store, "bucket", "exports/test",
+			&dynamodb.ScanInput{TableName: aws.String("TEST_TABLE")})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Now, verify the manifest that was returned
+		Expect(manifest.TableName).Should(Equal("TEST_TABLE"))
+		Expect(manifest.Items).Should(Equal(10))
+		Expect(manifest.Parts).Should(HaveLen(1))
+		Expect(manifest.Parts[0].Key).Should(Equal("exports/test/part-00000.ndjson.gz"))
+		Expect(manifest.Parts[0].Items).Should(Equal(10))
+		Expect(manifest.Parts[0].Size).Should(Equal(int64(len(store.objects["bucket/exports/test/part-00000.ndjson.gz"]))))
+
+		// Finally, verify that the part and the manifest were written to S3 and that each item was written
+		// on its own line in lossless mode
+		Expect(store.objects).Should(HaveKey("bucket/exports/test/manifest.json"))
+		lines := bytes.Split(bytes.TrimSpace(store.read("bucket/exports/test/part-00000.ndjson.gz")), []byte("\n"))
+		Expect(lines).Should(HaveLen(10))
+		Expect(lines[0]).Should(MatchJSON(`{"id":"a","sort_key":"0","data":0}`))
+	})
+
+	// Tests that ExportScan will split the items into multiple parts when the part size is exceeded
+	It("ExportScan - Part size exceeded - Split", func() {
+
+		// First, write a number of items to the table
+		writeFakeItems(conn, "a", 3)
+
+		// Next, export the table to S3 with a part size small enough that each item will be in its own part;
+		// this should not fail
+		manifest, err := conn.ExportScan(context.Background(), store, "bucket", "",
+			&dynamodb.ScanInput{TableName: aws.String("TEST_TABLE")}, WithPartSize(1))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that each item was written to a separate part
+		Expect(manifest.Items).Should(Equal(3))
+		Expect(manifest.Parts).Should(HaveLen(3))
+		for i, part := range manifest.Parts {
+			Expect(part.Key).Should(Equal(fmt.Sprintf("part-%05d.ndjson.gz", i)))
+			Expect(part.Items).Should(Equal(1))
+			Expect(store.read("bucket/" + part.Key)).Should(MatchJSON(
+				fmt.Sprintf(`{"id":"a","sort_key":"%d","data":%d}`, i, i)))
+		}
+	})
+
+	// Tests that ExportQuery will only write the items returned by the query
+	It("ExportQuery - Works", func() {
+
+		// First, write items to two partitions in the table
+		writeFakeItems(conn, "a", 3)
+		writeFakeItems(conn, "b", 4)
+
+		// Next, export one of the partitions to S3; this should not fail
+		input := dynamodb.QueryInput{TableName: aws.String("TEST_TABLE")}
+		Expect(NewExpression().KeyCondition(Equals("id", "b")).ApplyQuery(&input)).ShouldNot(HaveOccurred())
+		manifest, err := conn.ExportQuery(context.Background(), store, "bucket", "query", &input)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that only the items in that partition were exported
+		Expect(manifest.Items).Should(Equal(4))
+		Expect(bytes.Count(store.read("bucket/query/part-00000.ndjson.gz"), []byte(`"id":"b"`))).Should(Equal(4))
+	})
+
+	// Tests that, if an upload fails, then ExportScan will return an error
+	It("ExportScan - Upload fails - Error", func() {
+		writeFakeItems(conn, "a", 1)
+		store.fail = fmt.Errorf("upload failed")
+
+		manifest, err := conn.ExportScan(context.Background(), store, "bucket", "exports",
+			&dynamodb.ScanInput{TableName: aws.String("TEST_TABLE")})
+
+		Expect(manifest).Should(BeNil())
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("Failed to upload exports/part-00000.ndjson.gz to bucket"))
+	})
+
+	// Tests that an export can be imported into another table without losing any type information
+	It("ImportFromS3 - Works", func() {
+
+		// First, write items containing values that can't be represented in plain JSON to the table
+		items := []map[string]types.AttributeValue{
+			createFakeItem("a", "0", 0),
+			createFakeItem("a", "1", 1),
+			createFakeItem("b", "0", 2),
+		}
+
+		items[0]["set"] = &types.AttributeValueMemberSS{Value: []string{"x", "y"}}
+		items[1]["bin"] = &types.AttributeValueMemberB{Value: []byte{1, 2, 3}}
+		items[2]["big"] = &types.AttributeValueMemberN{Value: "12345678901234567890.123456789"}
+		items[2]["null"] = &types.AttributeValueMemberNULL{Value: true}
+		for _, item := range items {
+			_, err := conn.PutItem(context.Background(),
+				&dynamodb.PutItemInput{TableName: aws.String("TEST_TABLE"), Item: item})
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+
+		// Next, export the table to S3 in multiple parts; this should not fail
+		_, err := conn.ExportScan(context.Background(), store, "bucket", "clone",
+			&dynamodb.ScanInput{TableName: aws.String("TEST_TABLE")}, WithPartSize(1))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Now, import the export into a new table; this should not fail
+		_, target := createFakeConnection()
+		written, err := target.ImportFromS3(context.Background(), store, "bucket", "clone", "TEST_TABLE")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(written).Should(Equal(3))
+
+		// Finally, verify that the items in the new table are identical to the originals
+		for _, item := range items {
+			output, err := target.GetItem(context.Background(), &dynamodb.GetItemInput{
+				TableName: aws.String("TEST_TABLE"),
+				Key:       map[string]types.AttributeValue{"id": item["id"], "sort_key": item["sort_key"]},
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(output.Item).Should(Equal(item))
+		}
+	})
+
+	// Tests that, if the manifest doesn't exist, then ImportFromS3 will return an error
+	It("ImportFromS3 - Manifest missing - Error", func() {
+		written, err := conn.ImportFromS3(context.Background(), store, "bucket", "missing", "TEST_TABLE")
+		Expect(written).Should(Equal(0))
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("Failed to download missing/manifest.json from bucket"))
+	})
+
+	// Tests that ImportParts will import hand-written fixtures, skipping blank lines
+	It("ImportParts - Fixture - Works", func() {
+
+		// First, write a fixture to S3
+		store.write("bucket/fixture.ndjson.gz", "{\"id\":\"a\",\"sort_key\":\"0\",\"data\":7}\n\n"+
+			"{\"id\":\"a\",\"sort_key\":\"1\",\"data\":8,\"tags\":{\"SS\":[\"x\"]}}\n")
+
+		// Next, import the fixture into the table; this should not fail
+		written, err := conn.ImportParts(context.Background(), store, "bucket", "TEST_TABLE", "fixture.ndjson.gz")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(written).Should(Equal(2))
+
+		// Finally, verify that the items were written to the table
+		output, err := conn.GetItem(context.Background(), &dynamodb.GetItemInput{
+			TableName: aws.String("TEST_TABLE"),
+			Key:       createFakeKey("a", "1"),
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(output.Item["data"]).Should(Equal(&types.AttributeValueMemberN{Value: "8"}))
+		Expect(output.Item["tags"]).Should(Equal(&types.AttributeValueMemberSS{Value: []string{"x"}}))
+	})
+
+	// Tests that, if a line in a part isn't a JSON object, then ImportParts will return an error identifying it
+	It("ImportParts - Invalid line - Error", func() {
+
+		// First, write a fixture with an invalid second line to S3
+		store.write("bucket/fixture.ndjson.gz", "{\"id\":\"a\",\"sort_key\":\"0\"}\nnot json\n")
+
+		// Next, attempt to import the fixture into the table; this should fail
+		written, err := conn.ImportParts(context.Background(), store, "bucket", "TEST_TABLE", "fixture.ndjson.gz")
+
+		// Finally, verify the error and that nothing was written
+		Expect(written).Should(Equal(0))
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("Failed to convert line 2 of fixture.ndjson.gz in bucket"))
+	})
+})
+
+// Helper type that implements the S3 connection by storing objects in memory, keyed by bucket and key
+type fakeStore struct {
+	lock    sync.Mutex
+	objects map[string][]byte
+	fail    error
+}
+
+// Delete removes the object with the bucket and key provided
+func (store *fakeStore) Delete(ctx context.Context, bucket string, key string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	delete(store.objects, bucket+"/"+key)
+	return nil
+}
+
+// Download returns a copy of the object with the bucket and key provided
+func (store *fakeStore) Download(ctx context.Context, bucket string, key string) ([]byte, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	data, ok := store.objects[bucket+"/"+key]
+	if !ok {
+		return nil, fmt.Errorf("object %s does not exist in %s", key, bucket)
+	}
+
+	return append([]byte{}, data...), nil
+}
+
+// DownloadToStream returns a buffer containing the object with the bucket and key provided
+func (store *fakeStore) DownloadToStream(ctx context.Context, bucket string, key string) (io.Writer, error) {
+	data, err := store.Download(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewBuffer(data), nil
+}
+
+// UploadFromStream saves the contents of the body as the object with the bucket and key provided
+func (store *fakeStore) UploadFromStream(ctx context.Context, bucket string, key string, body io.Reader) error {
+	if store.fail != nil {
+		return store.fail
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.objects[bucket+"/"+key] = data
+	return nil
+}
+
+// Helper function that decompresses the object stored at the path provided
+func (store *fakeStore) read(path string) []byte {
+	reader, err := gzip.NewReader(bytes.NewReader(store.objects[path]))
+	if err != nil {
+		panic(err)
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		panic(err)
+	}
+
+	return data
+}
+
+// Helper function that compresses the contents provided and stores them at the path provided
+func (store *fakeStore) write(path string, contents string) {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write([]byte(contents)); err != nil {
+		panic(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		panic(err)
+	}
+
+	store.objects[path] = buffer.Bytes()
+}
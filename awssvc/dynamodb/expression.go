@@ -0,0 +1,498 @@
+package dynamodb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/xefino/goutils/collections"
+	"github.com/xefino/goutils/utils"
+)
+
+// Expression allows for the creation of DynamoDB condition, key-condition, filter, projection and update
+// expressions in a programmatic manner. Attribute names and values referenced by the expression are replaced
+// with generated placeholders so that reserved words and special characters can be used safely. Attribute
+// names may refer to nested attributes using dots and list indices, such as a.b[1].c
+type Expression struct {
+	tagKey       string
+	names        map[string]string
+	aliases      map[string]string
+	values       map[string]types.AttributeValue
+	nameIndex    int
+	valueIndex   int
+	condition    string
+	keyCondition string
+	filter       string
+	projection   []string
+	set          []string
+	remove       []string
+	add          []string
+	delete       []string
+	errs         []error
+}
+
+// NewExpression creates a new Expression that will convert values to DynamoDB attribute values using the
+// json field tag
+func NewExpression() *Expression {
+	return newExpression("json")
+}
+
+// NewExpression creates a new Expression that will convert values to DynamoDB attribute values using the
+// tag key associated with the connection
+func (conn *DatabaseConnection) NewExpression() *Expression {
+	return newExpression(conn.tagKey)
+}
+
+// Helper function that creates a new Expression from the tag key that should be used to convert values
+func newExpression(tagKey string) *Expression {
+	return &Expression{
+		tagKey:     tagKey,
+		names:      make(map[string]string),
+		aliases:    make(map[string]string),
+		values:     make(map[string]types.AttributeValue),
+		projection: make([]string, 0),
+		set:        make([]string, 0),
+		remove:     make([]string, 0),
+		add:        make([]string, 0),
+		delete:     make([]string, 0),
+		errs:       make([]error, 0),
+	}
+}
+
+// Names returns the mapping of attribute name placeholders to attribute names referenced by the Expression
+func (exp *Expression) Names() map[string]string {
+	return exp.names
+}
+
+// Values returns the mapping of attribute value placeholders to attribute values referenced by the Expression
+func (exp *Expression) Values() map[string]types.AttributeValue {
+	return exp.values
+}
+
+// Condition sets the condition clauses that must be satisfied for a put, update or delete operation to
+// succeed. The clauses will be joined together with AND; use Any to join clauses with OR instead. This
+// function returns the modified expression so that it can be chained with other functions.
+func (exp *Expression) Condition(clauses ...ConditionClause) *Expression {
+	exp.condition = exp.join(andOp, clauses...)
+	return exp
+}
+
+// KeyCondition sets the key condition clauses that determine which items are read by a query. The clauses
+// will be joined together with AND. This function returns the modified expression so that it can be chained
+// with other functions.
+func (exp *Expression) KeyCondition(clauses ...ConditionClause) *Expression {
+	exp.keyCondition = exp.join(andOp, clauses...)
+	return exp
+}
+
+// Filter sets the filter clauses that determine which items are returned by a query or scan. The clauses
+// will be joined together with AND; use Any to join clauses with OR instead. This function returns the
+// modified expression so that it can be chained with other functions.
+func (exp *Expression) Filter(clauses ...ConditionClause) *Expression {
+	exp.filter = exp.join(andOp, clauses...)
+	return exp
+}
+
+// Project sets the attributes that should be returned by a get, query or scan, returning the modified
+// expression so that this function can be chained with others
+func (exp *Expression) Project(fields ...string) *Expression {
+	exp.projection = collections.Convert(exp.name, fields...)
+	return exp
+}
+
+// Set adds an update action that sets the attribute to the value provided. The value may be an Operand,
+// such as a Name or an arithmetic expression, or any other value that can be converted to a DynamoDB
+// attribute value. This function returns the modified expression so that it can be chained with others
+func (exp *Expression) Set(field string, value any) *Expression {
+	exp.set = append(exp.set, exp.name(field)+" = "+operand(value).ModifyExpression(exp))
+	return exp
+}
+
+// SetIfNotExists adds an update action that sets the attribute to the value provided only if the attribute
+// does not already exist on the item. This function returns the modified expression so that it can be
+// chained with others
+func (exp *Expression) SetIfNotExists(field string, value any) *Expression {
+	return exp.Set(field, IfNotExists(field, value))
+}
+
+// Increment adds an update action that adds the amount provided to a numeric attribute, treating the
+// attribute as zero if it does not exist. The amount may be negative. This function returns the modified
+// expression so that it can be chained with others
+func (exp *Expression) Increment(field string, amount any) *Expression {
+	return exp.Set(field, Plus(IfNotExists(field, 0), amount))
+}
+
+// Append adds an update action that appends the values provided to a list attribute, treating the attribute
+// as an empty list if it does not exist. This function returns the modified expression so that it can be
+// chained with others
+func (exp *Expression) Append(field string, values ...any) *Expression {
+	return exp.Set(field, ListAppend(IfNotExists(field, make([]any, 0)), values))
+}
+
+// Remove adds an update action that removes the attributes provided from the item. This function returns
+// the modified expression so that it can be chained with others
+func (exp *Expression) Remove(fields ...string) *Expression {
+	exp.remove = append(exp.remove, collections.Convert(exp.name, fields...)...)
+	return exp
+}
+
+// Add adds an update action that adds the value provided to a number or set attribute. This function returns
+// the modified expression so that it can be chained with others
+func (exp *Expression) Add(field string, value any) *Expression {
+	exp.add = append(exp.add, exp.name(field)+" "+operand(value).ModifyExpression(exp))
+	return exp
+}
+
+// Delete adds an update action that removes the values provided from a set attribute. This function returns
+// the modified expression so that it can be chained with others
+func (exp *Expression) Delete(field string, value any) *Expression {
+	exp.delete = append(exp.delete, exp.name(field)+" "+operand(value).ModifyExpression(exp))
+	return exp
+}
+
+// ConditionExpression returns the condition expression string, or an empty string if no condition was set
+func (exp *Expression) ConditionExpression() string {
+	return exp.condition
+}
+
+// KeyConditionExpression returns the key condition expression string, or an empty string if no key
+// condition was set
+func (exp *Expression) KeyConditionExpression() string {
+	return exp.keyCondition
+}
+
+// FilterExpression returns the filter expression string, or an empty string if no filter was set
+func (exp *Expression) FilterExpression() string {
+	return exp.filter
+}
+
+// ProjectionExpression returns the projection expression string, or an empty string if no projection was set
+func (exp *Expression) ProjectionExpression() string {
+	return strings.Join(exp.projection, ", ")
+}
+
+// UpdateExpression returns the update expression string, or an empty string if no update actions were added
+func (exp *Expression) UpdateExpression() string {
+
+	// Create a list of our update clauses, adding each one for which we have at least one action
+	clauses := make([]string, 0, 4)
+	for _, clause := range exp.updateClauses() {
+		if len(clause.actions) > 0 {
+			clauses = append(clauses, clause.keyword+" "+strings.Join(clause.actions, ", "))
+		}
+	}
+
+	// Join all the clauses together with a space and return the result
+	return strings.Join(clauses, " ")
+}
+
+// Err returns any error that occurred while converting values referenced by the expression to DynamoDB
+// attribute values, or nil if no such error occurred
+func (exp *Expression) Err() error {
+	if len(exp.errs) == 0 {
+		return nil
+	}
+
+	return utils.FromErrors(exp.errs...)
+}
+
+// ApplyGet sets the projection expression and attribute names on the input
+func (exp *Expression) ApplyGet(input *dynamodb.GetItemInput) error {
+	return exp.apply(&input.ExpressionAttributeNames, nil,
+		&exprField{&input.ProjectionExpression, exp.ProjectionExpression(), ", "})
+}
+
+// ApplyPut sets the condition expression, attribute names and attribute values on the input. If the input
+// already has a condition expression then the two conditions will be combined with AND
+func (exp *Expression) ApplyPut(input *dynamodb.PutItemInput) error {
+	return exp.apply(&input.ExpressionAttributeNames, &input.ExpressionAttributeValues,
+		&exprField{&input.ConditionExpression, exp.condition, " " + andOp + " "})
+}
+
+// ApplyDelete sets the condition expression, attribute names and attribute values on the input. If the
+// input already has a condition expression then the two conditions will be combined with AND
+func (exp *Expression) ApplyDelete(input *dynamodb.DeleteItemInput) error {
+	return exp.apply(&input.ExpressionAttributeNames, &input.ExpressionAttributeValues,
+		&exprField{&input.ConditionExpression, exp.condition, " " + andOp + " "})
+}
+
+// ApplyUpdate sets the update expression, condition expression, attribute names and attribute values on
+// the input. If the input already has a condition expression then the two conditions will be combined with
+// AND. If the input already has an update expression then the update actions will be added to it
+func (exp *Expression) ApplyUpdate(input *dynamodb.UpdateItemInput) error {
+
+	// First, apply our condition, attribute names and values to the input
+	err := exp.apply(&input.ExpressionAttributeNames, &input.ExpressionAttributeValues,
+		&exprField{&input.ConditionExpression, exp.condition, " " + andOp + " "})
+	if err != nil {
+		return err
+	}
+
+	// Next, merge our update actions with any that already exist on the input
+	if input.UpdateExpression != nil {
+		input.UpdateExpression = aws.String(exp.mergeUpdate(*input.UpdateExpression))
+	} else if update := exp.UpdateExpression(); update != "" {
+		input.UpdateExpression = aws.String(update)
+	}
+
+	return nil
+}
+
+// ApplyQuery sets the key condition expression, filter expression, projection expression, attribute names
+// and attribute values on the input. If the input already has a key condition or filter expression then
+// the two expressions will be combined with AND
+func (exp *Expression) ApplyQuery(input *dynamodb.QueryInput) error {
+	return exp.apply(&input.ExpressionAttributeNames, &input.ExpressionAttributeValues,
+		&exprField{&input.KeyConditionExpression, exp.keyCondition, " " + andOp + " "},
+		&exprField{&input.FilterExpression, exp.filter, " " + andOp + " "},
+		&exprField{&input.ProjectionExpression, exp.ProjectionExpression(), ", "})
+}
+
+// ApplyScan sets the filter expression, projection expression, attribute names and attribute values on the
+// input. If the input already has a filter expression then the two expressions will be combined with AND
+func (exp *Expression) ApplyScan(input *dynamodb.ScanInput) error {
+	return exp.apply(&input.ExpressionAttributeNames, &input.ExpressionAttributeValues,
+		&exprField{&input.FilterExpression, exp.filter, " " + andOp + " "},
+		&exprField{&input.ProjectionExpression, exp.ProjectionExpression(), ", "})
+}
+
+// Helper type that associates an expression string on an input with the value we want to merge into it
+// and the connector that should be used to merge them
+type exprField struct {
+	target    **string
+	value     string
+	connector string
+}
+
+// Helper function that merges the attribute names, attribute values and expressions into an input
+func (exp *Expression) apply(names *map[string]string, values *map[string]types.AttributeValue,
+	fields ...*exprField) error {
+
+	// First, if we had any errors when building the expression then return them here
+	if err := exp.Err(); err != nil {
+		return err
+	}
+
+	// Next, merge our attribute names into the names on the input; if any placeholder already exists on
+	// the input with a different name then return an error
+	if len(exp.names) > 0 {
+		if *names == nil {
+			*names = make(map[string]string, len(exp.names))
+		}
+
+		for placeholder, name := range exp.names {
+			if existing, ok := (*names)[placeholder]; ok && existing != name {
+				return fmt.Errorf("attribute name placeholder %s refers to both %s and %s", placeholder, existing, name)
+			}
+
+			(*names)[placeholder] = name
+		}
+	}
+
+	// Now, merge our attribute values into the values on the input; if any placeholder already exists on
+	// the input then return an error as we cannot tell if the values are the same
+	if values != nil && len(exp.values) > 0 {
+		if *values == nil {
+			*values = make(map[string]types.AttributeValue, len(exp.values))
+		}
+
+		for placeholder, value := range exp.values {
+			if existing, ok := (*values)[placeholder]; ok && existing != value {
+				return fmt.Errorf("attribute value placeholder %s is already in use", placeholder)
+			}
+
+			(*values)[placeholder] = value
+		}
+	}
+
+	// Finally, iterate over all the expression fields and merge our expressions with them. If the input
+	// already has an expression then we'll combine the two with the connector
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		} else if *field.target == nil || **field.target == "" {
+			*field.target = aws.String(field.value)
+		} else if field.connector == ", " {
+			*field.target = aws.String(**field.target + field.connector + field.value)
+		} else {
+			*field.target = aws.String("(" + **field.target + ")" + field.connector + "(" + field.value + ")")
+		}
+	}
+
+	return nil
+}
+
+// Helper function that merges our update actions into an existing update expression by adding each of our
+// actions to the clause with the same keyword, or adding a new clause if no such clause exists
+func (exp *Expression) mergeUpdate(existing string) string {
+
+	// First, split the existing update expression into its clauses, keyed by keyword
+	clauses := splitUpdate(existing)
+
+	// Next, add each of our actions to the associated clause and rebuild the update expression from the
+	// clauses in a consistent order
+	parts := make([]string, 0, 4)
+	for _, clause := range exp.updateClauses() {
+		actions := clauses[clause.keyword]
+		if len(clause.actions) > 0 {
+			if actions != "" {
+				actions += ", "
+			}
+
+			actions += strings.Join(clause.actions, ", ")
+		}
+
+		if actions != "" {
+			parts = append(parts, clause.keyword+" "+actions)
+		}
+	}
+
+	// Finally, join all the clauses together with a space and return the result
+	return strings.Join(parts, " ")
+}
+
+// Helper type that associates an update keyword with the actions for that keyword
+type updateClause struct {
+	keyword string
+	actions []string
+}
+
+// Helper function that returns the update actions on the expression, grouped by keyword
+func (exp *Expression) updateClauses() []updateClause {
+	return []updateClause{{"SET", exp.set}, {"REMOVE", exp.remove}, {"ADD", exp.add}, {"DELETE", exp.delete}}
+}
+
+// Helper function that generates the placeholder associated with an attribute path, creating placeholders
+// for any path elements that have not been referenced before
+func (exp *Expression) name(path string) string {
+
+	// Split the path into its elements; each element may have one or more list indices so we'll replace
+	// the name portion of the element with a placeholder and keep the indices
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+
+		// First, separate the name from any list indices on the path element
+		name, indices := part, ""
+		if index := strings.Index(part, "["); index > 0 {
+			name, indices = part[:index], part[index:]
+		}
+
+		// Next, check if we have already generated a placeholder for this name. If we haven't then generate
+		// a new one that doesn't collide with any existing placeholder
+		placeholder, ok := exp.aliases[name]
+		if !ok {
+			for {
+				placeholder = "#n" + strconv.Itoa(exp.nameIndex)
+				exp.nameIndex++
+				if _, ok := exp.names[placeholder]; !ok {
+					break
+				}
+			}
+
+			exp.names[placeholder] = name
+			exp.aliases[name] = placeholder
+		}
+
+		// Finally, replace the path element with the placeholder and its indices
+		parts[i] = placeholder + indices
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// Helper function that converts a value to a DynamoDB attribute value and generates a placeholder for it. If
+// the conversion fails then the error will be recorded on the expression
+func (exp *Expression) value(value any) string {
+
+	// First, attempt to convert the value to an attribute value; if it is already an attribute value then
+	// we'll use it directly. If the conversion fails, or the value has a type that can't be represented in
+	// DynamoDB (such as a channel or function), then record the error
+	attr, ok := value.(types.AttributeValue)
+	if !ok {
+		var err error
+		attr, err = attributevalue.MarshalWithOptions(value,
+			func(options *attributevalue.EncoderOptions) { options.TagKey = exp.tagKey })
+		if err != nil {
+			exp.errs = append(exp.errs, fmt.Errorf("failed to convert %v to a DynamoDB attribute value: %w", value, err))
+		} else if attr == nil {
+			exp.errs = append(exp.errs, fmt.Errorf("failed to convert %v to a DynamoDB attribute value: "+
+				"unsupported type %T", value, value))
+		}
+	}
+
+	// Next, generate a placeholder that doesn't collide with any existing placeholder
+	var placeholder string
+	for {
+		placeholder = ":v" + strconv.Itoa(exp.valueIndex)
+		exp.valueIndex++
+		if _, ok := exp.values[placeholder]; !ok {
+			break
+		}
+	}
+
+	// Finally, save the attribute value to the placeholder and return the placeholder
+	exp.values[placeholder] = attr
+	return placeholder
+}
+
+// Helper function that reserves all the placeholders already in use on an input so that the expression will
+// not generate placeholders that collide with them
+func (exp *Expression) reserve(names map[string]string, values map[string]types.AttributeValue) *Expression {
+	for placeholder, name := range names {
+		exp.names[placeholder] = name
+	}
+
+	for placeholder, value := range values {
+		exp.values[placeholder] = value
+	}
+
+	return exp
+}
+
+// Helper function that modifies the expression with each of the clauses and joins the results together
+// with the operator provided
+func (exp *Expression) join(op string, clauses ...ConditionClause) string {
+	results := make([]string, 0, len(clauses))
+	for _, clause := range clauses {
+		if result := clause.ModifyExpression(exp); result != "" {
+			results = append(results, result)
+		}
+	}
+
+	return strings.Join(results, " "+op+" ")
+}
+
+// Helper function that splits an update expression into its clauses, keyed by keyword
+func splitUpdate(expression string) map[string]string {
+	clauses := make(map[string]string)
+
+	// Iterate over all the tokens in the expression and find the keywords, keeping track of the depth of
+	// any parentheses so that we don't split on a keyword inside a function call
+	var keyword string
+	var start, depth int
+	tokens := strings.Fields(expression)
+	for i, token := range tokens {
+		upper := strings.ToUpper(token)
+		if depth == 0 && (upper == "SET" || upper == "REMOVE" || upper == "ADD" || upper == "DELETE") {
+			if keyword != "" {
+				clauses[keyword] = strings.Join(tokens[start:i], " ")
+			}
+
+			keyword, start = upper, i+1
+			continue
+		}
+
+		depth += strings.Count(token, "(") - strings.Count(token, ")")
+	}
+
+	// Add the final clause and return the result
+	if keyword != "" {
+		clauses[keyword] = strings.Join(tokens[start:], " ")
+	}
+
+	return clauses
+}
@@ -0,0 +1,263 @@
+package dynamodb
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/utils"
+)
+
+var _ = Describe("Expression Tests", func() {
+
+	// Test that the condition clauses generate the expected expression strings, names and values
+	DescribeTable("ConditionClause - ModifyExpression - Works",
+		func(clause ConditionClause, expected string, names map[string]string, values map[string]types.AttributeValue) {
+
+			// First, create a new expression and modify it with the clause
+			exp := NewExpression()
+			result := clause.ModifyExpression(exp)
+
+			// Next, verify the resulting expression string, names and values
+			Expect(result).Should(Equal(expected))
+			Expect(exp.Names()).Should(Equal(names))
+			Expect(exp.Values()).Should(Equal(values))
+			Expect(exp.Err()).ShouldNot(HaveOccurred())
+		},
+		Entry("Equals", Equals("data", 42), "#n0 = :v0",
+			map[string]string{"#n0": "data"},
+			map[string]types.AttributeValue{":v0": &types.AttributeValueMemberN{Value: "42"}}),
+		Entry("NotEquals", NotEquals("status", "active"), "#n0 <> :v0",
+			map[string]string{"#n0": "status"},
+			map[string]types.AttributeValue{":v0": &types.AttributeValueMemberS{Value: "active"}}),
+		Entry("LessThan - Nested path", LessThan("data.counts[1]", 5), "#n0.#n1[1] < :v0",
+			map[string]string{"#n0": "data", "#n1": "counts"},
+			map[string]types.AttributeValue{":v0": &types.AttributeValueMemberN{Value: "5"}}),
+		Entry("LessThanOrEqualTo", LessThanOrEqualTo("a", 1), "#n0 <= :v0",
+			map[string]string{"#n0": "a"},
+			map[string]types.AttributeValue{":v0": &types.AttributeValueMemberN{Value: "1"}}),
+		Entry("GreaterThan - Compare attributes", GreaterThan("a", Name("b")), "#n0 > #n1",
+			map[string]string{"#n0": "a", "#n1": "b"}, map[string]types.AttributeValue{}),
+		Entry("GreaterThanOrEqualTo", GreaterThanOrEqualTo("a", 1), "#n0 >= :v0",
+			map[string]string{"#n0": "a"},
+			map[string]types.AttributeValue{":v0": &types.AttributeValueMemberN{Value: "1"}}),
+		Entry("Between", Between("a", 1, 2), "#n0 BETWEEN :v0 AND :v1",
+			map[string]string{"#n0": "a"},
+			map[string]types.AttributeValue{
+				":v0": &types.AttributeValueMemberN{Value: "1"},
+				":v1": &types.AttributeValueMemberN{Value: "2"}}),
+		Entry("In", In("a", "x", "y"), "#n0 IN (:v0, :v1)",
+			map[string]string{"#n0": "a"},
+			map[string]types.AttributeValue{
+				":v0": &types.AttributeValueMemberS{Value: "x"},
+				":v1": &types.AttributeValueMemberS{Value: "y"}}),
+		Entry("BeginsWith", BeginsWith("sort_key", "test|"), "begins_with(#n0, :v0)",
+			map[string]string{"#n0": "sort_key"},
+			map[string]types.AttributeValue{":v0": &types.AttributeValueMemberS{Value: "test|"}}),
+		Entry("Contains", Contains("tags", "x"), "contains(#n0, :v0)",
+			map[string]string{"#n0": "tags"},
+			map[string]types.AttributeValue{":v0": &types.AttributeValueMemberS{Value: "x"}}),
+		Entry("AttributeExists", AttributeExists("id"), "attribute_exists(#n0)",
+			map[string]string{"#n0": "id"}, map[string]types.AttributeValue{}),
+		Entry("AttributeNotExists", AttributeNotExists("id"), "attribute_not_exists(#n0)",
+			map[string]string{"#n0": "id"}, map[string]types.AttributeValue{}),
+		Entry("AttributeType", AttributeType("id", "S"), "attribute_type(#n0, :v0)",
+			map[string]string{"#n0": "id"},
+			map[string]types.AttributeValue{":v0": &types.AttributeValueMemberS{Value: "S"}}),
+		Entry("Compare - Size", Compare(Size("tags"), ">", 3), "size(#n0) > :v0",
+			map[string]string{"#n0": "tags"},
+			map[string]types.AttributeValue{":v0": &types.AttributeValueMemberN{Value: "3"}}),
+		Entry("Negate", Negate(AttributeExists("id")), "NOT (attribute_exists(#n0))",
+			map[string]string{"#n0": "id"}, map[string]types.AttributeValue{}),
+		Entry("Negate - Empty", Negate(All()), "", map[string]string{}, map[string]types.AttributeValue{}),
+		Entry("All - Empty", All(), "", map[string]string{}, map[string]types.AttributeValue{}),
+		Entry("Any - Name reused", Any(Equals("a", 1), Equals("a", 2)), "(#n0 = :v0 OR #n0 = :v1)",
+			map[string]string{"#n0": "a"},
+			map[string]types.AttributeValue{
+				":v0": &types.AttributeValueMemberN{Value: "1"},
+				":v1": &types.AttributeValueMemberN{Value: "2"}}))
+
+	// Test that the update actions generate the expected update expression
+	It("UpdateExpression - Works", func() {
+
+		// First, create a new expression with all the different update actions
+		exp := NewExpression().
+			Set("name", "test").
+			SetIfNotExists("created", 1).
+			Increment("count", 2).
+			Append("items", "x").
+			Remove("old", "older").
+			Add("total", 3).
+			Delete("tags", &types.AttributeValueMemberSS{Value: []string{"a"}})
+
+		// Next, verify the resulting update expression
+		Expect(exp.Err()).ShouldNot(HaveOccurred())
+		Expect(exp.UpdateExpression()).Should(Equal("SET #n0 = :v0, #n1 = if_not_exists(#n1, :v1), " +
+			"#n2 = if_not_exists(#n2, :v2) + :v3, #n3 = list_append(if_not_exists(#n3, :v4), :v5) " +
+			"REMOVE #n4, #n5 ADD #n6 :v6 DELETE #n7 :v7"))
+
+		// Finally, verify the names and the values that were generated
+		Expect(exp.Names()).Should(Equal(map[string]string{"#n0": "name", "#n1": "created", "#n2": "count",
+			"#n3": "items", "#n4": "old", "#n5": "older", "#n6": "total", "#n7": "tags"}))
+		Expect(exp.Values()).Should(Equal(map[string]types.AttributeValue{
+			":v0": &types.AttributeValueMemberS{Value: "test"},
+			":v1": &types.AttributeValueMemberN{Value: "1"},
+			":v2": &types.AttributeValueMemberN{Value: "0"},
+			":v3": &types.AttributeValueMemberN{Value: "2"},
+			":v4": &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+			":v5": &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: "x"}}},
+			":v6": &types.AttributeValueMemberN{Value: "3"},
+			":v7": &types.AttributeValueMemberSS{Value: []string{"a"}},
+		}))
+	})
+
+	// Test that the ApplyQuery function sets the key condition, filter and projection on a query input
+	It("ApplyQuery - Works", func() {
+
+		// First, create a new expression with a key condition, filter and projection
+		exp := NewExpression().
+			KeyCondition(Equals("id", "test_id"), BeginsWith("sort_key", "test|")).
+			Filter(Any(GreaterThan("data", 1), AttributeNotExists("data"))).
+			Project("id", "data")
+
+		// Next, apply the expression to a query input; this should not fail
+		input := dynamodb.QueryInput{TableName: aws.String("TEST_TABLE")}
+		err := exp.ApplyQuery(&input)
+
+		// Finally, verify the input
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(*input.KeyConditionExpression).Should(Equal("#n0 = :v0 AND begins_with(#n1, :v1)"))
+		Expect(*input.FilterExpression).Should(Equal("(#n2 > :v2 OR attribute_not_exists(#n2))"))
+		Expect(*input.ProjectionExpression).Should(Equal("#n0, #n2"))
+		Expect(input.ExpressionAttributeNames).Should(Equal(map[string]string{
+			"#n0": "id", "#n1": "sort_key", "#n2": "data"}))
+		Expect(input.ExpressionAttributeValues).Should(HaveLen(3))
+	})
+
+	// Test that the ApplyScan and ApplyGet functions set the filter and projection on their inputs
+	It("ApplyScan, ApplyGet - Works", func() {
+
+		// First, create a new expression with a filter and projection
+		exp := NewExpression().Filter(Equals("data", 1)).Project("id")
+
+		// Next, apply the expression to a scan input and a get input; neither should fail
+		scan := dynamodb.ScanInput{TableName: aws.String("TEST_TABLE")}
+		get := dynamodb.GetItemInput{TableName: aws.String("TEST_TABLE")}
+		Expect(exp.ApplyScan(&scan)).ShouldNot(HaveOccurred())
+		Expect(exp.ApplyGet(&get)).ShouldNot(HaveOccurred())
+
+		// Finally, verify the inputs
+		Expect(*scan.FilterExpression).Should(Equal("#n0 = :v0"))
+		Expect(*scan.ProjectionExpression).Should(Equal("#n1"))
+		Expect(scan.ExpressionAttributeNames).Should(Equal(map[string]string{"#n0": "data", "#n1": "id"}))
+		Expect(scan.ExpressionAttributeValues).Should(HaveLen(1))
+		Expect(*get.ProjectionExpression).Should(Equal("#n1"))
+		Expect(get.ExpressionAttributeNames).Should(Equal(map[string]string{"#n0": "data", "#n1": "id"}))
+	})
+
+	// Test that the ApplyPut and ApplyDelete functions combine the condition with any existing condition
+	It("ApplyPut, ApplyDelete - Existing condition - Combined", func() {
+
+		// First, create a new expression with a condition
+		exp := NewExpression().Condition(AttributeNotExists("id"))
+
+		// Next, apply the expression to a put input and a delete input that already have conditions
+		put := dynamodb.PutItemInput{
+			ConditionExpression:       aws.String("#d = :d"),
+			ExpressionAttributeNames:  map[string]string{"#d": "data"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":d": &types.AttributeValueMemberN{Value: "1"}},
+		}
+
+		del := dynamodb.DeleteItemInput{}
+		Expect(exp.ApplyPut(&put)).ShouldNot(HaveOccurred())
+		Expect(exp.ApplyDelete(&del)).ShouldNot(HaveOccurred())
+
+		// Finally, verify the inputs
+		Expect(*put.ConditionExpression).Should(Equal("(#d = :d) AND (attribute_not_exists(#n0))"))
+		Expect(put.ExpressionAttributeNames).Should(Equal(map[string]string{"#d": "data", "#n0": "id"}))
+		Expect(put.ExpressionAttributeValues).Should(HaveLen(1))
+		Expect(*del.ConditionExpression).Should(Equal("attribute_not_exists(#n0)"))
+		Expect(del.ExpressionAttributeNames).Should(Equal(map[string]string{"#n0": "id"}))
+		Expect(del.ExpressionAttributeValues).Should(BeNil())
+	})
+
+	// Test that the ApplyUpdate function merges the update actions into any existing update expression
+	It("ApplyUpdate - Existing update expression - Merged", func() {
+
+		// First, create a new expression with a condition and some update actions
+		exp := NewExpression().
+			Condition(AttributeExists("id")).
+			Set("data", 1).
+			Remove("old")
+
+		// Next, apply the expression to an update input that already has an update expression
+		input := dynamodb.UpdateItemInput{
+			UpdateExpression:          aws.String("set #a = list_append(#a, :a) ADD #b :b"),
+			ExpressionAttributeNames:  map[string]string{"#a": "list", "#b": "count"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":a": nil, ":b": nil},
+		}
+
+		err := exp.ApplyUpdate(&input)
+
+		// Finally, verify the input
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(*input.ConditionExpression).Should(Equal("attribute_exists(#n0)"))
+		Expect(*input.UpdateExpression).Should(Equal("SET #a = list_append(#a, :a), #n1 = :v0 REMOVE #n2 ADD #b :b"))
+		Expect(input.ExpressionAttributeNames).Should(HaveLen(5))
+		Expect(input.ExpressionAttributeValues).Should(HaveLen(3))
+	})
+
+	// Test that, if a placeholder on the input collides with a placeholder on the expression, then the
+	// apply functions will return an error
+	It("ApplyPut - Placeholder collision - Error", func() {
+
+		// First, create a new expression with a condition
+		exp := NewExpression().Condition(Equals("id", "test_id"))
+
+		// Next, apply the expression to a put input with a colliding attribute name; this should fail
+		input := dynamodb.PutItemInput{ExpressionAttributeNames: map[string]string{"#n0": "other"}}
+		err := exp.ApplyPut(&input)
+
+		// Finally, verify the error
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(Equal("attribute name placeholder #n0 refers to both other and id"))
+	})
+
+	// Test that, if a value cannot be converted to an attribute value, then the apply functions will
+	// return an error
+	It("ApplyPut - Value conversion fails - Error", func() {
+
+		// First, create a new expression with a condition on a value that cannot be converted
+		exp := NewExpression().Condition(Equals("id", make(chan int)))
+
+		// Next, attempt to apply the expression to a put input; this should fail
+		input := dynamodb.PutItemInput{}
+		err := exp.ApplyPut(&input)
+
+		// Finally, verify the error
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("failed to convert"))
+		Expect(input.ConditionExpression).Should(BeNil())
+	})
+
+	// Test that an expression created from a connection will convert values with the connection's tag key
+	It("NewExpression - Connection tag key - Used", func() {
+
+		// First, create a connection with a custom tag key and an expression from it
+		logger := utils.NewLogger("testd", "test")
+		logger.Discard()
+		exp := FromClient(nil, logger, WithTagKey("dynamodbav")).NewExpression()
+
+		// Next, create a condition with a struct value
+		exp.Condition(Equals("item", taggedObject{ID: "test_id", SortKey: "test|sort|key", Data: 1}))
+
+		// Finally, verify that the value was converted with the tag key
+		Expect(exp.Err()).ShouldNot(HaveOccurred())
+		Expect(exp.Values()[":v0"]).Should(Equal(&types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"pk":   &types.AttributeValueMemberS{Value: "test_id"},
+			"sk":   &types.AttributeValueMemberS{Value: "test|sort|key"},
+			"data": &types.AttributeValueMemberN{Value: "1"},
+		}}))
+	})
+})
@@ -0,0 +1,77 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/xefino/goutils/awssvc/testing"
+	"github.com/xefino/goutils/utils"
+)
+
+// Ensure that the fake implements the DynamoDB API so it can be used in place of a client
+var _ DynamoDBAPI = testing.NewFakeDynamoDB()
+
+// Helper function that creates a connection backed by a fake containing our test table
+func createFakeConnection() (*testing.FakeDynamoDB, *DatabaseConnection) {
+
+	// First, create the fake and our test table, with a global secondary index on the data field
+	fake := testing.NewFakeDynamoDB()
+	_, err := fake.CreateTable(context.Background(), &dynamodb.CreateTableInput{
+		TableName: aws.String("TEST_TABLE"),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("sort_key"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("data"), AttributeType: types.ScalarAttributeTypeN},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("sort_key"), KeyType: types.KeyTypeRange},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName:  aws.String("data_index"),
+			KeySchema:  []types.KeySchemaElement{{AttributeName: aws.String("data"), KeyType: types.KeyTypeHash}},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+
+	if err != nil {
+		panic(err)
+	}
+
+	// Next, create a connection from the fake with a short backoff
+	logger := utils.NewLogger("testd", "test")
+	logger.Discard()
+	return fake, FromClient(fake, logger, WithBackoffStart(1), WithBackoffEnd(5), WithBackoffMaxElapsed(1000))
+}
+
+// Helper function that writes a number of items to a partition in our test table, with sort keys and data
+// values ranging from 0 to count - 1
+func writeFakeItems(conn *DatabaseConnection, id string, count int) {
+	for i := 0; i < count; i++ {
+		if _, err := conn.PutItem(context.Background(), &dynamodb.PutItemInput{
+			TableName: aws.String("TEST_TABLE"),
+			Item:      createFakeItem(id, fmt.Sprint(i), i),
+		}); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Helper function that creates a test item with the key and data provided
+func createFakeItem(id string, sortKey string, data int) map[string]types.AttributeValue {
+	item := createFakeKey(id, sortKey)
+	item["data"] = &types.AttributeValueMemberN{Value: fmt.Sprint(data)}
+	return item
+}
+
+// Helper function that creates the key of a test item
+func createFakeKey(id string, sortKey string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id":       &types.AttributeValueMemberS{Value: id},
+		"sort_key": &types.AttributeValueMemberS{Value: sortKey},
+	}
+}
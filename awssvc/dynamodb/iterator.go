@@ -0,0 +1,354 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// PageHandler describes a function that will be called with each page of items retrieved from DynamoDB. The
+// function should return true if iteration should continue or false if it should stop after this page. If
+// the function returns an error then iteration will stop and the error will be returned
+type PageHandler func(items []map[string]types.AttributeValue) (bool, error)
+
+// ItemHandler describes a function that will be called with each item retrieved from DynamoDB. The function
+// should return true if iteration should continue or false if it should stop after this item. If the function
+// returns an error then iteration will stop and the error will be returned
+type ItemHandler func(item map[string]types.AttributeValue) (bool, error)
+
+// Helper type describing a function that retrieves a single page of items from DynamoDB, starting at the key
+// provided and retrieving, at most, the number of items provided
+type pager func(ctx context.Context, startKey map[string]types.AttributeValue,
+	limit *int32) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error)
+
+// Helper type describing a function that will be called with each page of items and the key that should be
+// used to retrieve the next page
+type pageVisitor func(items []map[string]types.AttributeValue, next map[string]types.AttributeValue) (bool, error)
+
+// QueryPages queries DynamoDB one page at a time, calling the handler with each page of items as it is
+// retrieved rather than accumulating all the items in memory. Iteration stops when all the pages have been
+// retrieved, when the handler returns false or an error, or when the item limit has been reached. The key
+// that should be used to resume the query will be returned; if this is nil then there are no more items.
+// If an error occurs, the key returned will be the key of the page that was being processed so the query
+// may be resumed from that page. The input will not be modified by this function
+func (conn *DatabaseConnection) QueryPages(ctx context.Context, input *dynamodb.QueryInput,
+	handler PageHandler, opts ...IIteratorOption) (map[string]types.AttributeValue, error) {
+	return conn.iterate(ctx, *input.TableName, "QUERY", input.ExclusiveStartKey, input.Limit,
+		conn.queryPager(input), pageHandler(handler), opts...)
+}
+
+// QueryItems queries DynamoDB one page at a time, calling the handler with each item as it is retrieved
+// rather than accumulating all the items in memory. Iteration stops when all the pages have been retrieved,
+// when the handler returns false or an error, or when the item limit has been reached. The key that should
+// be used to resume the query will be returned; if this is nil then there are no more items. If the handler
+// stops in the middle of a page then the key will be derived from the last item handled. The input will
+// not be modified by this function
+func (conn *DatabaseConnection) QueryItems(ctx context.Context, input *dynamodb.QueryInput,
+	handler ItemHandler, opts ...IIteratorOption) (map[string]types.AttributeValue, error) {
+	return conn.iterateItems(ctx, *input.TableName, input.IndexName, "QUERY", input.ExclusiveStartKey,
+		input.Limit, conn.queryPager(input), handler, opts...)
+}
+
+// ScanPages scans DynamoDB one page at a time, calling the handler with each page of items as it is
+// retrieved rather than accumulating all the items in memory. Iteration stops when all the pages have been
+// retrieved, when the handler returns false or an error, or when the item limit has been reached. The key
+// that should be used to resume the scan will be returned; if this is nil then there are no more items.
+// If an error occurs, the key returned will be the key of the page that was being processed so the scan
+// may be resumed from that page. The input will not be modified by this function
+func (conn *DatabaseConnection) ScanPages(ctx context.Context, input *dynamodb.ScanInput,
+	handler PageHandler, opts ...IIteratorOption) (map[string]types.AttributeValue, error) {
+	return conn.iterate(ctx, *input.TableName, "SCAN", input.ExclusiveStartKey, input.Limit,
+		conn.scanPager(input), pageHandler(handler), opts...)
+}
+
+// ScanItems scans DynamoDB one page at a time, calling the handler with each item as it is retrieved
+// rather than accumulating all the items in memory. Iteration stops when all the pages have been retrieved,
+// when the handler returns false or an error, or when the item limit has been reached. The key that should
+// be used to resume the scan will be returned; if this is nil then there are no more items. If the handler
+// stops in the middle of a page then the key will be derived from the last item handled. The input will
+// not be modified by this function
+func (conn *DatabaseConnection) ScanItems(ctx context.Context, input *dynamodb.ScanInput,
+	handler ItemHandler, opts ...IIteratorOption) (map[string]types.AttributeValue, error) {
+	return conn.iterateItems(ctx, *input.TableName, input.IndexName, "SCAN", input.ExclusiveStartKey,
+		input.Limit, conn.scanPager(input), handler, opts...)
+}
+
+// QueryEach queries DynamoDB one page at a time, converting each item to the type provided and calling the
+// handler with it. This function behaves in the same way as QueryItems
+func QueryEach[T any](ctx context.Context, conn *DatabaseConnection, input *dynamodb.QueryInput,
+	handler func(*T) (bool, error), opts ...IIteratorOption) (map[string]types.AttributeValue, error) {
+	return conn.QueryItems(ctx, input, typedHandler(conn, handler), opts...)
+}
+
+// ScanEach scans DynamoDB one page at a time, converting each item to the type provided and calling the
+// handler with it. This function behaves in the same way as ScanItems
+func ScanEach[T any](ctx context.Context, conn *DatabaseConnection, input *dynamodb.ScanInput,
+	handler func(*T) (bool, error), opts ...IIteratorOption) (map[string]types.AttributeValue, error) {
+	return conn.ScanItems(ctx, input, typedHandler(conn, handler), opts...)
+}
+
+// Helper function that creates a pager that will query DynamoDB using a copy of the input provided
+func (conn *DatabaseConnection) queryPager(input *dynamodb.QueryInput) pager {
+	return func(ctx context.Context, startKey map[string]types.AttributeValue,
+		limit *int32) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+
+		// Copy the input so the caller's input isn't modified and set the start key and limit on it
+		copied := *input
+		copied.ExclusiveStartKey = startKey
+		copied.Limit = limit
+
+		// Attempt to query the page of items from DynamoDB; if this fails then return the error
+		output, err := conn.db.Query(ctx, &copied)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return output.Items, output.LastEvaluatedKey, nil
+	}
+}
+
+// Helper function that creates a pager that will scan DynamoDB using a copy of the input provided
+func (conn *DatabaseConnection) scanPager(input *dynamodb.ScanInput) pager {
+	return func(ctx context.Context, startKey map[string]types.AttributeValue,
+		limit *int32) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+
+		// Copy the input so the caller's input isn't modified and set the start key and limit on it
+		copied := *input
+		copied.ExclusiveStartKey = startKey
+		copied.Limit = limit
+
+		// Attempt to scan the page of items from DynamoDB; if this fails then return the error
+		output, err := conn.db.Scan(ctx, &copied)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return output.Items, output.LastEvaluatedKey, nil
+	}
+}
+
+// Helper function that retrieves pages of items from DynamoDB using the pager, calling the visitor with each
+// page until there are no more pages, the visitor requests that iteration stop or the item limit is reached.
+// The key that should be used to resume iteration will be returned
+func (conn *DatabaseConnection) iterate(ctx context.Context, tableName string, verb string,
+	startKey map[string]types.AttributeValue, pageLimit *int32, fetch pager, visitor pageVisitor,
+	opts ...IIteratorOption) (map[string]types.AttributeValue, error) {
+
+	// First, create our iterator options from the defaults and the options provided and start collecting
+	// metrics for all the pages we'll retrieve
+	options := newIteratorOptions(opts...)
+	ctx, scope := conn.startMetrics(ctx, tableName, verb)
+
+	// We'll start a loop that will retrieve each page of results until we're told to stop
+	key, remaining := startKey, options.limit
+	for index := 0; ; index++ {
+
+		// First, determine how many items we should request. If we have an item limit and the remaining
+		// number of items is less than the page limit then we'll use that instead so that DynamoDB will
+		// return the key of the last item we actually received
+		limit := pageLimit
+		if options.limit > 0 && (limit == nil || int(*limit) > remaining) {
+			limit = aws.Int32(int32(remaining))
+		}
+
+		// Next, attempt to retrieve the page with a backoff-retry loop; if this fails then return the
+		// key of the current page so that the caller may resume from it
+		var items []map[string]types.AttributeValue
+		var next map[string]types.AttributeValue
+		err := conn.doRetry(ctx, tableName, fmt.Sprintf("%s(%d)", verb, index), func() error {
+			var inner error
+			items, next, inner = fetch(ctx, key, limit)
+			return inner
+		})
+
+		if err != nil {
+			scope.finish(err)
+			return key, err
+		}
+
+		// Now, call the visitor with the page of items; if this fails then return the key of the
+		// current page so that the caller may resume from it
+		cont, err := visitor(items, next)
+		if err != nil {
+			scope.finish(err)
+			return key, err
+		}
+
+		// Finally, update the key and the number of items remaining. If the visitor requested that we
+		// stop, there are no more pages or we've reached the item limit then return the key
+		key, remaining = next, remaining-len(items)
+		if !cont || key == nil || (options.limit > 0 && remaining <= 0) {
+			scope.finish(nil)
+			return key, nil
+		}
+	}
+}
+
+// Helper function that retrieves pages of items from DynamoDB using the pager, calling the handler with each
+// item until there are no more items, the handler requests that iteration stop or the item limit is reached.
+// The key that should be used to resume iteration will be returned
+func (conn *DatabaseConnection) iterateItems(ctx context.Context, tableName string, indexName *string,
+	verb string, startKey map[string]types.AttributeValue, pageLimit *int32, fetch pager, handler ItemHandler,
+	opts ...IIteratorOption) (map[string]types.AttributeValue, error) {
+	options := newIteratorOptions(opts...)
+
+	// First, create a visitor that will call the handler with each item on the page. If the handler stops
+	// before the end of the page then we'll need to derive the key from the last item it handled
+	var stopKey map[string]types.AttributeValue
+	visitor := func(items []map[string]types.AttributeValue, next map[string]types.AttributeValue) (bool, error) {
+		for i, item := range items {
+
+			// Call the handler with the item; if it failed or requested that we continue then handle
+			// that here. If this was the last item on the page then the key of the page can be used
+			if cont, err := handler(item); err != nil {
+				return false, err
+			} else if cont {
+				continue
+			} else if i == len(items)-1 {
+				return false, nil
+			}
+
+			// The handler stopped in the middle of the page so derive the key from the item
+			key, err := conn.itemKey(ctx, tableName, indexName, item, next, options.keyAttributes)
+			if err != nil {
+				return false, err
+			}
+
+			stopKey = key
+			return false, nil
+		}
+
+		return true, nil
+	}
+
+	// Next, iterate over all the pages with the visitor
+	key, err := conn.iterate(ctx, tableName, verb, startKey, pageLimit, fetch, visitor, opts...)
+	if err != nil {
+		return key, err
+	}
+
+	// Finally, if the handler stopped in the middle of a page then return the key derived from the item;
+	// otherwise, return the key of the next page
+	if stopKey != nil {
+		return stopKey, nil
+	}
+
+	return key, nil
+}
+
+// Helper function that derives the key of an item so that iteration may be resumed after it. The names of the
+// key attributes will be taken from the options if they were provided, from the key of the next page if there
+// is one or from the key schema of the table and index being read otherwise
+func (conn *DatabaseConnection) itemKey(ctx context.Context, tableName string, indexName *string,
+	item map[string]types.AttributeValue, next map[string]types.AttributeValue,
+	names []string) (map[string]types.AttributeValue, error) {
+
+	// First, determine the names of the key attributes. If they weren't provided then take them from the key
+	// of the next page; if this was the last page then we'll need to get them from the key schema instead
+	if len(names) == 0 {
+		for name := range next {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		var err error
+		if names, err = conn.keyAttributes(ctx, tableName, indexName); err != nil {
+			return nil, err
+		}
+	}
+
+	// Next, copy each key attribute from the item to the key. If any are missing then return an error
+	key := make(map[string]types.AttributeValue, len(names))
+	for _, name := range names {
+		attr, ok := item[name]
+		if !ok {
+			return nil, conn.NewError(nil, tableName, "Key attribute %q was not found on item from %s",
+				name, tableName)
+		}
+
+		key[name] = attr
+	}
+
+	return key, nil
+}
+
+// Helper function that returns the names of the key attributes of the table and, if an index name was provided,
+// the key attributes of that index. The key schema will be retrieved by describing the table, the first time it
+// is needed, and cached on the connection thereafter
+func (conn *DatabaseConnection) keyAttributes(ctx context.Context, tableName string,
+	indexName *string) ([]string, error) {
+
+	// First, check whether we have already cached the key attributes; if we have then return them
+	cacheKey := tableName + "/" + aws.ToString(indexName)
+	conn.keySchemaLock.RLock()
+	names, ok := conn.keySchemas[cacheKey]
+	conn.keySchemaLock.RUnlock()
+	if ok {
+		return names, nil
+	}
+
+	// Next, attempt to describe the table; if this fails or the table doesn't exist then return an error
+	description, err := conn.describeTable(ctx, tableName)
+	if err != nil {
+		return nil, err
+	} else if description == nil {
+		return nil, conn.NewError(nil, tableName, "Unable to determine the key attributes for %s as it "+
+			"does not exist", tableName)
+	}
+
+	// Now, collect the key schemas of the table and the index being read, if there is one
+	schemas := [][]types.KeySchemaElement{description.KeySchema}
+	if indexName != nil {
+		for _, index := range description.GlobalSecondaryIndexes {
+			if aws.ToString(index.IndexName) == *indexName {
+				schemas = append(schemas, index.KeySchema)
+			}
+		}
+
+		for _, index := range description.LocalSecondaryIndexes {
+			if aws.ToString(index.IndexName) == *indexName {
+				schemas = append(schemas, index.KeySchema)
+			}
+		}
+	}
+
+	// Finally, extract the unique attribute names from the key schemas and cache them on the connection
+	seen := make(map[string]bool)
+	for _, schema := range schemas {
+		for _, element := range schema {
+			if name := aws.ToString(element.AttributeName); !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	conn.keySchemaLock.Lock()
+	defer conn.keySchemaLock.Unlock()
+	conn.keySchemas[cacheKey] = names
+	return names, nil
+}
+
+// Helper function that converts a PageHandler to a page visitor
+func pageHandler(handler PageHandler) pageVisitor {
+	return func(items []map[string]types.AttributeValue, _ map[string]types.AttributeValue) (bool, error) {
+		return handler(items)
+	}
+}
+
+// Helper function that converts a typed handler to an ItemHandler that converts each item to the type
+// before calling the handler with it
+func typedHandler[T any](conn *DatabaseConnection, handler func(*T) (bool, error)) ItemHandler {
+	return func(item map[string]types.AttributeValue) (bool, error) {
+		value := new(T)
+		if err := conn.UnmarshalMap(item, value); err != nil {
+			return false, err
+		}
+
+		return handler(value)
+	}
+}
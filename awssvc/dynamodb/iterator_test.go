@@ -0,0 +1,351 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/awssvc/testing"
+)
+
+var _ = Describe("Iterator Tests", Ordered, func() {
+
+	// Ensure that the AWS config is created before each test; this could be set as a global variable
+	var cfg aws.Config
+	BeforeAll(func() {
+		cfg = testing.TestAWSConfig(context.Background(), "us-east-1", 9000)
+	})
+
+	// Create our test table definition that we'll use for all module tests
+	testTable := dynamodb.CreateTableInput{
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("id"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("sort_key"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("id"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String("sort_key"),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		TableName:   aws.String("TEST_TABLE"),
+		BillingMode: types.BillingModeProvisioned,
+		ProvisionedThroughput: &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(1),
+			WriteCapacityUnits: aws.Int64(1),
+		},
+		TableClass: types.TableClassStandard,
+	}
+
+	// Esnure that the table exists and contains our test data before the start of each test
+	BeforeEach(func() {
+		if err := testing.EnsureTableExists(context.Background(), cfg, &testTable); err != nil {
+			panic(err)
+		}
+
+		table := createTable[testObject](createTestConnection(cfg), "TEST_TABLE")
+		for i := 0; i < 50; i++ {
+			err := table.Put(context.Background(),
+				&testObject{ID: "test_id", SortKey: fmt.Sprintf("test|sort|key|%02d", i), Data: i})
+			if err != nil {
+				panic(err)
+			}
+		}
+	})
+
+	// Ensure that the table is empty at the end of each test
+	AfterEach(func() {
+		if err := testing.EmptyTable(context.Background(), cfg, &testTable); err != nil {
+			panic(err)
+		}
+	})
+
+	// Test that, if the query fails, then calling QueryPages will return an error
+	It("QueryPages - Fails - Error", func() {
+
+		// First, create our test database connection from our test config
+		conn := createTestConnection(cfg)
+
+		// Next, attempt to query the pages from a table that doesn't exist; this should fail
+		called := false
+		key, err := conn.QueryPages(context.Background(), createIteratorQuery("FAKE_TABLE", 10),
+			func(items []map[string]types.AttributeValue) (bool, error) {
+				called = true
+				return true, nil
+			})
+
+		// Finally, verify the failure
+		Expect(called).Should(BeFalse())
+		Expect(key).Should(BeNil())
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).TableName).Should(Equal("FAKE_TABLE"))
+		Expect(err.(*Error).Message).Should(Equal("QUERY(0) request to FAKE_TABLE in DynamoDB failed"))
+	})
+
+	// Test that, if no item limit is set, then calling QueryPages will call the handler with every
+	// page and return no key
+	It("QueryPages - No limit - All pages handled", func() {
+
+		// First, create our test database connection from our test config
+		conn := createTestConnection(cfg)
+
+		// Next, attempt to query all the pages from the table; this should not fail
+		input := createIteratorQuery("TEST_TABLE", 10)
+		pages := make([]int, 0)
+		key, err := conn.QueryPages(context.Background(), input,
+			func(items []map[string]types.AttributeValue) (bool, error) {
+				pages = append(pages, len(items))
+				return true, nil
+			})
+
+		// Finally, verify that all the pages were handled and that the input wasn't modified
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(key).Should(BeNil())
+		Expect(pages).Should(Equal([]int{10, 10, 10, 10, 10}))
+		Expect(input.ExclusiveStartKey).Should(BeNil())
+		Expect(*input.Limit).Should(Equal(int32(10)))
+	})
+
+	// Test that, if the handler returns an error, then calling ScanPages will stop and return the error
+	// along with the key of the page that failed
+	It("ScanPages - Handler fails - Error", func() {
+
+		// First, create our test database connection from our test config
+		conn := createTestConnection(cfg)
+
+		// Next, attempt to scan the pages from the table with a handler that fails on the second page
+		count := 0
+		key, err := conn.ScanPages(context.Background(),
+			&dynamodb.ScanInput{TableName: aws.String("TEST_TABLE"), Limit: aws.Int32(10)},
+			func(items []map[string]types.AttributeValue) (bool, error) {
+				count++
+				if count == 2 {
+					return false, fmt.Errorf("handler failed")
+				}
+
+				return true, nil
+			})
+
+		// Finally, verify the failure
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(Equal("handler failed"))
+		Expect(key).ShouldNot(BeNil())
+		Expect(count).Should(Equal(2))
+	})
+
+	// Test that, if an item limit is set, then calling QueryItems will stop once that many items have been
+	// handled and return a key that can be used to resume the query
+	It("QueryItems - Item limit - Resumable", func() {
+
+		// First, create our test database connection from our test config
+		conn := createTestConnection(cfg)
+
+		// Next, attempt to query the first 15 items from the table; this should not fail
+		input := createIteratorQuery("TEST_TABLE", 10)
+		results := make([]string, 0)
+		handler := func(item map[string]types.AttributeValue) (bool, error) {
+			results = append(results, item["sort_key"].(*types.AttributeValueMemberS).Value)
+			return true, nil
+		}
+
+		key, err := conn.QueryItems(context.Background(), input, handler, WithItemLimit(15))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(results).Should(HaveLen(15))
+		Expect(key).Should(Equal(map[string]types.AttributeValue{
+			"id":       &types.AttributeValueMemberS{Value: "test_id"},
+			"sort_key": &types.AttributeValueMemberS{Value: "test|sort|key|14"},
+		}))
+
+		// Now, resume the query from the key; this should not fail
+		input.ExclusiveStartKey = key
+		key, err = conn.QueryItems(context.Background(), input, handler)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(key).Should(BeNil())
+
+		// Finally, verify that every item was handled exactly once and in order
+		Expect(results).Should(HaveLen(50))
+		for i, result := range results {
+			Expect(result).Should(Equal(fmt.Sprintf("test|sort|key|%02d", i)))
+		}
+	})
+
+	// Test that, if the handler stops in the middle of a page, then calling QueryEach will return the key
+	// of the last item handled so that the query can be resumed from it
+	It("QueryEach - Stopped mid-page - Resumable", func() {
+
+		// First, create our test database connection from our test config
+		conn := createTestConnection(cfg)
+
+		// Next, attempt to query the items from the table, stopping on the 23rd item; this should not fail
+		input := createIteratorQuery("TEST_TABLE", 10)
+		results := make([]*testObject, 0)
+		key, err := QueryEach(context.Background(), conn, input, func(item *testObject) (bool, error) {
+			results = append(results, item)
+			return len(results) < 23, nil
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(results).Should(HaveLen(23))
+		Expect(key).Should(Equal(map[string]types.AttributeValue{
+			"id":       &types.AttributeValueMemberS{Value: "test_id"},
+			"sort_key": &types.AttributeValueMemberS{Value: "test|sort|key|22"},
+		}))
+
+		// Now, resume the query from the key; this should not fail
+		input.ExclusiveStartKey = key
+		key, err = QueryEach(context.Background(), conn, input, func(item *testObject) (bool, error) {
+			results = append(results, item)
+			return true, nil
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(key).Should(BeNil())
+
+		// Finally, verify that every item was handled exactly once and in order
+		Expect(results).Should(HaveLen(50))
+		for i, result := range results {
+			Expect(*result).Should(Equal(testObject{
+				ID:      "test_id",
+				SortKey: fmt.Sprintf("test|sort|key|%02d", i),
+				Data:    i,
+			}))
+		}
+	})
+
+	// Test that calling ScanEach on a table will convert every item to the table's type
+	It("Table.ScanEach - No failures - All items handled", func() {
+
+		// First, create our test table from our test config
+		table := createTable[testObject](createTestConnection(cfg), "TEST_TABLE")
+
+		// Next, attempt to scan every item from the table; this should not fail
+		results := make(map[int]*testObject)
+		key, err := table.ScanEach(context.Background(), nil, func(item *testObject) (bool, error) {
+			results[item.Data] = item
+			return true, nil
+		})
+
+		// Finally, verify that every item was handled
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(key).Should(BeNil())
+		Expect(results).Should(HaveLen(50))
+		for i := 0; i < 50; i++ {
+			Expect(results[i].SortKey).Should(Equal(fmt.Sprintf("test|sort|key|%02d", i)))
+		}
+	})
+
+	// Test that, if the handler stops on the last item of the final page, then calling QueryEach on a table
+	// will return no key because there are no more items
+	It("Table.QueryEach - Stopped on last item - No key", func() {
+
+		// First, create our test table from our test config
+		table := createTable[testObject](createTestConnection(cfg), "TEST_TABLE")
+
+		// Next, attempt to query the items from the table, stopping on the last item; this should not fail
+		count := 0
+		key, err := table.QueryEach(context.Background(), createIteratorQuery("TEST_TABLE", 100),
+			func(item *testObject) (bool, error) {
+				count++
+				return item.Data != 49, nil
+			})
+
+		// Finally, verify that all the items were handled and that no key was returned
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(key).Should(BeNil())
+		Expect(count).Should(Equal(50))
+	})
+})
+
+var _ = Describe("Iterator Fake Tests", func() {
+
+	// Test that, if the handler stops in the middle of the final page and no key attributes were provided, then
+	// calling QueryItems will derive the key from the table's key schema so that the query may be resumed
+	It("QueryItems - Stopped early on final page - Key derived from key schema", func() {
+
+		// First, create a fake connection with some test items
+		fake, conn := createFakeConnection()
+		writeFakeItems(conn, "test_id", 5)
+		input := dynamodb.QueryInput{
+			TableName:                 aws.String("TEST_TABLE"),
+			KeyConditionExpression:    aws.String("#id = :id"),
+			ExpressionAttributeNames:  map[string]string{"#id": "id"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":id": &types.AttributeValueMemberS{Value: "test_id"}},
+		}
+
+		// Next, query the items, stopping on the third item; this should not fail
+		key, err := conn.QueryItems(context.Background(), &input, func(item map[string]types.AttributeValue) (bool, error) {
+			return item["sort_key"].(*types.AttributeValueMemberS).Value != "2", nil
+		})
+
+		// Now, verify that the key of the item we stopped on was returned
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(key).Should(Equal(createFakeKey("test_id", "2")))
+
+		// Finally, resume the query from the key and verify that the remaining items are returned and that the
+		// table was only described once
+		input.ExclusiveStartKey = key
+		sortKeys := make([]string, 0)
+		key, err = conn.QueryItems(context.Background(), &input, func(item map[string]types.AttributeValue) (bool, error) {
+			sortKeys = append(sortKeys, item["sort_key"].(*types.AttributeValueMemberS).Value)
+			return len(sortKeys) < 1, nil
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(key).Should(Equal(createFakeKey("test_id", "3")))
+		Expect(sortKeys).Should(Equal([]string{"3"}))
+		Expect(fake.Calls("DescribeTable")).Should(Equal(1))
+	})
+
+	// Test that, if the handler stops in the middle of the final page of a scan on an index, then calling
+	// ScanItems will derive the key from the key schemas of both the table and the index
+	It("ScanItems - Stopped early on final page of index - Key includes index attributes", func() {
+
+		// First, create a fake connection with some test items
+		_, conn := createFakeConnection()
+		writeFakeItems(conn, "test_id", 3)
+
+		// Next, scan the index, stopping on the first item; this should not fail
+		var stopped map[string]types.AttributeValue
+		key, err := conn.ScanItems(context.Background(), &dynamodb.ScanInput{
+			TableName: aws.String("TEST_TABLE"),
+			IndexName: aws.String("data_index"),
+		}, func(item map[string]types.AttributeValue) (bool, error) {
+			stopped = item
+			return false, nil
+		})
+
+		// Finally, verify that the key contains the table and index key attributes of the item
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(key).Should(HaveLen(3))
+		Expect(key).Should(Equal(stopped))
+	})
+})
+
+// Helper function that creates a query input that will retrieve all the test items from a table in pages
+func createIteratorQuery(tableName string, pageSize int32) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName:                aws.String(tableName),
+		ConsistentRead:           aws.Bool(true),
+		Limit:                    aws.Int32(pageSize),
+		KeyConditionExpression:   aws.String("#id = :id AND begins_with(#sk, :sk)"),
+		ExpressionAttributeNames: map[string]string{"#id": "id", "#sk": "sort_key"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: "test_id"},
+			":sk": &types.AttributeValueMemberS{Value: "test|sort|key|"},
+		},
+	}
+}
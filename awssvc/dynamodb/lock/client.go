@@ -0,0 +1,195 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsdynamodb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/xefino/goutils/awssvc/dynamodb"
+	"github.com/xefino/goutils/utils"
+)
+
+// The names of the attributes on the item associated with each lock
+const (
+	idAttribute      = "id"
+	ownerAttribute   = "owner"
+	fenceAttribute   = "fence"
+	expiresAttribute = "expires"
+	ttlAttribute     = "ttl"
+)
+
+// Client acquires leases on named locks stored in a DynamoDB table, allowing a single owner across a number of
+// processes to hold each lock at a time. Each lock is stored as an item, keyed by the name of the lock, that
+// records the ID of the owner currently holding the lease, when the lease expires and a fencing token. Leases
+// are renewed by a heartbeat while they are held and a lease that isn't renewed before it expires may be
+// acquired by another owner. The fencing token increases each time the lock is acquired so that it can be
+// passed to downstream systems to reject writes from an owner whose lease has since been lost
+type Client struct {
+	conn          *dynamodb.DatabaseConnection
+	tableName     string
+	owner         string
+	leaseDuration time.Duration
+	heartbeat     time.Duration
+	pollInterval  time.Duration
+	retention     time.Duration
+	now           func() time.Time
+	logger        *utils.Logger
+}
+
+// NewClient creates a new lock Client from a database connection, the name of the table where locks will be
+// stored, a logger and options
+func NewClient(conn *dynamodb.DatabaseConnection, tableName string, logger *utils.Logger,
+	opts ...IClientOption) *Client {
+
+	// First, create our client with default values
+	client := Client{
+		conn:          conn,
+		tableName:     tableName,
+		owner:         uuid.NewString(),
+		leaseDuration: 20 * time.Second,
+		retention:     24 * time.Hour,
+		now:           time.Now,
+		logger:        logger,
+	}
+
+	// Next, iterate over the options provided and update the associated values in the client
+	for _, opt := range opts {
+		opt.Apply(&client)
+	}
+
+	// Finally, set the heartbeat and poll intervals from the lease duration if they weren't provided and
+	// return a reference to the client
+	if client.heartbeat <= 0 {
+		client.heartbeat = client.leaseDuration / 3
+	}
+
+	if client.pollInterval <= 0 {
+		client.pollInterval = client.heartbeat
+	}
+
+	return &client
+}
+
+// Owner returns the ID that identifies the owner of leases acquired by this Client
+func (client *Client) Owner() string {
+	return client.owner
+}
+
+// Schema returns the schema of the table where locks are stored. The table has a string partition key named
+// id and uses the ttl attribute to delete locks that haven't been held for the retention period
+func (client *Client) Schema() *dynamodb.TableSchema {
+	return &dynamodb.TableSchema{
+		Input: &awsdynamodb.CreateTableInput{
+			TableName:   aws.String(client.tableName),
+			BillingMode: types.BillingModePayPerRequest,
+			AttributeDefinitions: []types.AttributeDefinition{
+				{AttributeName: aws.String(idAttribute), AttributeType: types.ScalarAttributeTypeS},
+			},
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String(idAttribute), KeyType: types.KeyTypeHash},
+			},
+		},
+		PartitionKey: idAttribute,
+		TTLAttribute: ttlAttribute,
+	}
+}
+
+// EnsureTable ensures that the table where locks are stored exists and matches the schema returned by Schema
+func (client *Client) EnsureTable(ctx context.Context) error {
+	_, err := client.conn.EnsureTable(ctx, client.Schema())
+	return err
+}
+
+// TryAcquire makes a single attempt to acquire a lease on the lock with the name provided. If the lock is held
+// by another owner whose lease has not expired then nil will be returned without an error. Otherwise, the
+// lease will be returned and will be renewed in the background until it is released, it is lost or the
+// context is cancelled. Note that if the context is cancelled then the lease will not be released but will
+// instead expire at the end of its duration
+func (client *Client) TryAcquire(ctx context.Context, name string) (*Lease, error) {
+
+	// First, create the update that will take ownership of the lock, conditioned on the lock being free or
+	// its lease having expired. The fencing token will be incremented and, if the lock doesn't exist yet, it
+	// will start from the current time in milliseconds so that tokens continue to increase even if the item
+	// is deleted by the time-to-live settings on the table
+	now := client.now()
+	expires := now.Add(client.leaseDuration)
+	input := awsdynamodb.UpdateItemInput{
+		TableName:    aws.String(client.tableName),
+		Key:          client.key(name),
+		ReturnValues: types.ReturnValueAllNew,
+	}
+
+	if err := dynamodb.NewExpression().
+		Set(ownerAttribute, client.owner).
+		Set(expiresAttribute, expires.UnixMilli()).
+		Set(ttlAttribute, expires.Add(client.retention).Unix()).
+		Set(fenceAttribute, dynamodb.Plus(dynamodb.IfNotExists(fenceAttribute, now.UnixMilli()), 1)).
+		Condition(dynamodb.Any(dynamodb.AttributeNotExists(ownerAttribute),
+			dynamodb.LessThan(expiresAttribute, now.UnixMilli()))).
+		ApplyUpdate(&input); err != nil {
+		return nil, client.logger.Error(err, "Failed to create request to acquire lock %s", name)
+	}
+
+	// Next, attempt to update the lock; if the condition failed then the lock is held by someone else so
+	// return nil. If any other error occurred then return it
+	output, err := client.conn.UpdateItem(ctx, &input)
+	if casted, ok := err.(*dynamodb.Error); ok && casted.Kind == dynamodb.KindConditionFailed {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Now, read the fencing token from the updated lock; if this fails then return an error
+	fence, err := readFence(output.Attributes)
+	if err != nil {
+		return nil, client.logger.Error(err, "Failed to read fencing token for lock %s", name)
+	}
+
+	// Finally, create the lease and start its heartbeat
+	client.logger.Log("Acquired lock %s as %s with fencing token %d", name, client.owner, fence)
+	return newLease(ctx, client, name, fence, expires), nil
+}
+
+// Acquire acquires a lease on the lock with the name provided, waiting for the lock to become available if it
+// is held by another owner. Attempts to acquire the lock will be made at the poll interval associated with the
+// Client until the lock is acquired, an error occurs or the context is cancelled. The lease returned will be
+// renewed in the background until it is released, it is lost or the context is cancelled
+func (client *Client) Acquire(ctx context.Context, name string) (*Lease, error) {
+	ticker := time.NewTicker(client.pollInterval)
+	defer ticker.Stop()
+	for {
+
+		// First, attempt to acquire the lock; if this succeeds or fails then return the result
+		lease, err := client.TryAcquire(ctx, name)
+		if lease != nil || err != nil {
+			return lease, err
+		}
+
+		// Next, since the lock is held by someone else, wait for the next attempt or for the context to finish
+		select {
+		case <-ctx.Done():
+			return nil, client.logger.Error(ctx.Err(), "Context finished before lock %s could be acquired", name)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Helper function that creates the key of the item associated with a lock
+func (client *Client) key(name string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{idAttribute: &types.AttributeValueMemberS{Value: name}}
+}
+
+// Helper function that reads the fencing token from the attributes of a lock
+func readFence(attrs map[string]types.AttributeValue) (int64, error) {
+	attr, ok := attrs[fenceAttribute].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("lock was missing its fencing token")
+	}
+
+	return strconv.ParseInt(attr.Value, 10, 64)
+}
@@ -0,0 +1,240 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsdynamodb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/xefino/goutils/awssvc/dynamodb"
+)
+
+// LeaseLost is the error associated with a lease that expired, or was taken by another owner, before it could
+// be renewed
+var LeaseLost = errors.New("Lease was lost")
+
+// LeaseReleased is the error associated with a lease that was released by its owner
+var LeaseReleased = errors.New("Lease was released")
+
+// Lease describes ownership of a lock that was acquired by a Client. While the lease is held, it will be renewed
+// in the background at the heartbeat interval associated with the Client. The context associated with the
+// lease will be cancelled as soon as the lease is lost or released so work that requires the lock should be
+// done with that context
+type Lease struct {
+	client  *Client
+	name    string
+	fence   int64
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stop    chan struct{}
+	done    chan struct{}
+	halt    sync.Once
+	lock    sync.Mutex
+	expires time.Time
+	err     error
+}
+
+// Helper function that creates a new lease and starts its heartbeat
+func newLease(ctx context.Context, client *Client, name string, fence int64, expires time.Time) *Lease {
+	lease := Lease{
+		client:  client,
+		name:    name,
+		fence:   fence,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		expires: expires,
+	}
+
+	lease.ctx, lease.cancel = context.WithCancel(ctx)
+	go lease.heartbeat()
+	return &lease
+}
+
+// Name returns the name of the lock associated with the lease
+func (lease *Lease) Name() string {
+	return lease.name
+}
+
+// Owner returns the ID of the owner holding the lease
+func (lease *Lease) Owner() string {
+	return lease.client.owner
+}
+
+// Token returns the fencing token associated with the lease. Tokens increase each time the lock is acquired so
+// systems that are modified while the lock is held should reject any request with a lower token than the
+// highest they have seen
+func (lease *Lease) Token() int64 {
+	return lease.fence
+}
+
+// Context returns a context that will be cancelled when the lease is lost or released, or when the context
+// used to acquire the lease is cancelled
+func (lease *Lease) Context() context.Context {
+	return lease.ctx
+}
+
+// Expires returns the time at which the lease will expire if it isn't renewed
+func (lease *Lease) Expires() time.Time {
+	lease.lock.Lock()
+	defer lease.lock.Unlock()
+	return lease.expires
+}
+
+// Err returns LeaseLost if the lease was lost, LeaseReleased if it was released, or nil if it is still held.
+// If the context used to acquire the lease is cancelled while it is held then the lease will be lost
+func (lease *Lease) Err() error {
+	lease.lock.Lock()
+	defer lease.lock.Unlock()
+	return lease.err
+}
+
+// Renew extends the lease by the lease duration associated with the Client. This is done automatically by the
+// heartbeat so it does not need to be called unless the lease must be extended immediately. If the lock has
+// been acquired by another owner then the lease will be lost and LeaseLost will be returned
+func (lease *Lease) Renew(ctx context.Context) error {
+
+	// First, if the lease is no longer held then return the reason
+	if err := lease.Err(); err != nil {
+		return err
+	}
+
+	// Next, create the update that will extend the lease, conditioned on the lock still belonging to us
+	expires := lease.client.now().Add(lease.client.leaseDuration)
+	input := awsdynamodb.UpdateItemInput{
+		TableName: aws.String(lease.client.tableName),
+		Key:       lease.client.key(lease.name),
+	}
+
+	if err := lease.ownership().
+		Set(expiresAttribute, expires.UnixMilli()).
+		Set(ttlAttribute, expires.Add(lease.client.retention).Unix()).
+		ApplyUpdate(&input); err != nil {
+		return lease.client.logger.Error(err, "Failed to create request to renew lock %s", lease.name)
+	}
+
+	// Now, attempt to update the lock. If the condition failed then someone else owns the lock so the lease
+	// has been lost. If any other error occurs then return it
+	if _, err := lease.client.conn.UpdateItem(ctx, &input); err != nil {
+		if casted, ok := err.(*dynamodb.Error); ok && casted.Kind == dynamodb.KindConditionFailed {
+			lease.finish(LeaseLost)
+			return LeaseLost
+		}
+
+		return err
+	}
+
+	// Finally, save the new expiry time on the lease
+	lease.lock.Lock()
+	defer lease.lock.Unlock()
+	lease.expires = expires
+	return nil
+}
+
+// Release stops the heartbeat and gives up the lease so that the lock may be acquired by another owner. The
+// context associated with the lease will be cancelled. If the lease was already lost then LeaseLost will be
+// returned. If the lease was already released then this function does nothing
+func (lease *Lease) Release(ctx context.Context) error {
+
+	// First, stop the heartbeat and wait for it to exit so that it doesn't renew the lease after we release it
+	lease.halt.Do(func() { close(lease.stop) })
+	<-lease.done
+
+	// Next, if the lease is no longer held then there's nothing to release
+	if err := lease.Err(); err == LeaseReleased {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Now, create the update that will remove our ownership of the lock, conditioned on the lock still
+	// belonging to us. The fencing token will be kept so that it continues to increase
+	input := awsdynamodb.UpdateItemInput{
+		TableName: aws.String(lease.client.tableName),
+		Key:       lease.client.key(lease.name),
+	}
+
+	if err := lease.ownership().
+		Remove(ownerAttribute, expiresAttribute).
+		Set(ttlAttribute, lease.client.now().Add(lease.client.retention).Unix()).
+		ApplyUpdate(&input); err != nil {
+		return lease.client.logger.Error(err, "Failed to create request to release lock %s", lease.name)
+	}
+
+	// Finally, attempt to update the lock. If the condition failed then the lease had already been lost.
+	// Otherwise, mark the lease as released
+	if _, err := lease.client.conn.UpdateItem(ctx, &input); err != nil {
+		if casted, ok := err.(*dynamodb.Error); ok && casted.Kind == dynamodb.KindConditionFailed {
+			lease.finish(LeaseLost)
+			return LeaseLost
+		}
+
+		return err
+	}
+
+	lease.client.logger.Log("Released lock %s as %s", lease.name, lease.client.owner)
+	lease.finish(LeaseReleased)
+	return nil
+}
+
+// Helper function that renews the lease at the heartbeat interval until the lease is released or lost, or the
+// context used to acquire it is cancelled, in which case the lease will be lost. If a renewal fails
+// intermittently then it will be retried at the next heartbeat but, if the lease expires before it can be
+// renewed, the lease will be lost
+func (lease *Lease) heartbeat() {
+	defer close(lease.done)
+	ticker := time.NewTicker(lease.client.heartbeat)
+	defer ticker.Stop()
+	for {
+
+		// First, wait for the next heartbeat or for the lease to finish. If the context was cancelled before the
+		// lease was released then it can no longer be renewed so it has been lost
+		select {
+		case <-lease.stop:
+			return
+		case <-lease.ctx.Done():
+			lease.finish(LeaseLost)
+			return
+		case <-ticker.C:
+		}
+
+		// Next, attempt to renew the lease, giving up once the lease has expired. If the renewal succeeded or
+		// the lease was lost then there's nothing else to do for this heartbeat
+		ctx, cancel := context.WithDeadline(lease.ctx, lease.Expires())
+		err := lease.Renew(ctx)
+		cancel()
+		if err == nil || err == LeaseLost {
+			continue
+		}
+
+		// Finally, the renewal failed for some other reason so, if the lease has expired, then it has been
+		// lost. Otherwise, we'll try again at the next heartbeat
+		lease.client.logger.Log("Failed to renew lock %s: %v", lease.name, err)
+		if !lease.client.now().Before(lease.Expires()) {
+			lease.finish(LeaseLost)
+		}
+	}
+}
+
+// Helper function that creates an expression conditioned on the lock still being owned by this lease
+func (lease *Lease) ownership() *dynamodb.Expression {
+	return dynamodb.NewExpression().Condition(dynamodb.All(
+		dynamodb.Equals(ownerAttribute, lease.client.owner),
+		dynamodb.Equals(fenceAttribute, lease.fence)))
+}
+
+// Helper function that records the reason the lease is no longer held and cancels its context. Only the first
+// reason will be recorded
+func (lease *Lease) finish(reason error) {
+	lease.lock.Lock()
+	if lease.err == nil {
+		lease.err = reason
+		if reason == LeaseLost {
+			lease.client.logger.Log("Lost lock %s as %s", lease.name, lease.client.owner)
+		}
+	}
+
+	lease.lock.Unlock()
+	lease.cancel()
+}
@@ -0,0 +1,234 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsdynamodb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/awssvc/dynamodb"
+	"github.com/xefino/goutils/awssvc/testing"
+	"github.com/xefino/goutils/utils"
+)
+
+var _ = Describe("Lease Tests", func() {
+
+	// Create a new fake with our lock table before each test
+	var fake *testing.FakeDynamoDB
+	var conn *dynamodb.DatabaseConnection
+	BeforeEach(func() {
+		fake = testing.NewFakeDynamoDB()
+		logger := utils.NewLogger("testd", "test")
+		logger.Discard()
+		conn = dynamodb.FromClient(fake, logger, dynamodb.WithBackoffStart(1),
+			dynamodb.WithBackoffEnd(5), dynamodb.WithBackoffMaxElapsed(1000))
+		Expect(createLockClient(conn, "first").EnsureTable(context.Background())).ShouldNot(HaveOccurred())
+	})
+
+	// Tests that EnsureTable will create the lock table with time-to-live enabled
+	It("EnsureTable - Works", func() {
+		ttl, err := fake.DescribeTimeToLive(context.Background(),
+			&awsdynamodb.DescribeTimeToLiveInput{TableName: aws.String("LOCK_TABLE")})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ttl.TimeToLiveDescription.TimeToLiveStatus).Should(Equal(types.TimeToLiveStatusEnabled))
+		Expect(*ttl.TimeToLiveDescription.AttributeName).Should(Equal("ttl"))
+	})
+
+	// Tests that, if a lock is held by another owner, then TryAcquire will not acquire it
+	It("TryAcquire - Held - Nil", func() {
+
+		// First, create two clients with different owners
+		first, second := createLockClient(conn, "first"), createLockClient(conn, "second")
+
+		// Next, acquire the lock with the first client; this should not fail
+		lease, err := first.TryAcquire(context.Background(), "test_lock")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(lease).ShouldNot(BeNil())
+		defer lease.Release(context.Background())
+
+		// Now, verify the lease
+		Expect(lease.Name()).Should(Equal("test_lock"))
+		Expect(lease.Owner()).Should(Equal("first"))
+		Expect(lease.Token()).Should(BeNumerically(">", 0))
+		Expect(lease.Err()).ShouldNot(HaveOccurred())
+		Expect(lease.Context().Err()).ShouldNot(HaveOccurred())
+
+		// Finally, attempt to acquire the lock with the second client; this should return nothing
+		other, err := second.TryAcquire(context.Background(), "test_lock")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(other).Should(BeNil())
+	})
+
+	// Tests that, if a lease is released, then it can be acquired by another owner with a higher fencing token
+	It("Release - Works", func() {
+
+		// First, create two clients with different owners and acquire the lock with the first
+		first, second := createLockClient(conn, "first"), createLockClient(conn, "second")
+		lease, err := first.TryAcquire(context.Background(), "test_lock")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, release the lease; this should not fail
+		Expect(lease.Release(context.Background())).ShouldNot(HaveOccurred())
+		Expect(lease.Err()).Should(Equal(LeaseReleased))
+		Expect(lease.Context().Err()).Should(Equal(context.Canceled))
+
+		// Now, verify that releasing the lease again does nothing and that it can't be renewed
+		Expect(lease.Release(context.Background())).ShouldNot(HaveOccurred())
+		Expect(lease.Renew(context.Background())).Should(Equal(LeaseReleased))
+
+		// Finally, acquire the lock with the second client and verify that the fencing token increased
+		other, err := second.TryAcquire(context.Background(), "test_lock")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(other).ShouldNot(BeNil())
+		Expect(other.Token()).Should(Equal(lease.Token() + 1))
+		Expect(other.Release(context.Background())).ShouldNot(HaveOccurred())
+	})
+
+	// Tests that, if a lease expires, then it can be acquired by another owner and the original lease will be
+	// lost when it is next renewed
+	It("Expired - Lost", func() {
+
+		// First, create two clients with different owners where the second client believes that the time
+		// is a minute ahead, so that it sees the first client's lease as expired
+		first, second := createLockClient(conn, "first"), createLockClient(conn, "second")
+		second.now = func() time.Time { return time.Now().Add(time.Minute) }
+
+		// Next, acquire the lock with the first client and then the second; neither should fail
+		lease, err := first.TryAcquire(context.Background(), "test_lock")
+		Expect(err).ShouldNot(HaveOccurred())
+		other, err := second.TryAcquire(context.Background(), "test_lock")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(other).ShouldNot(BeNil())
+		Expect(other.Token()).Should(Equal(lease.Token() + 1))
+
+		// Now, attempt to renew the first lease; this should fail because the lease was lost
+		Expect(lease.Renew(context.Background())).Should(Equal(LeaseLost))
+		Expect(lease.Err()).Should(Equal(LeaseLost))
+		Expect(lease.Context().Err()).Should(Equal(context.Canceled))
+
+		// Finally, verify that releasing the lost lease returns an error but doesn't affect the new lease
+		Expect(lease.Release(context.Background())).Should(Equal(LeaseLost))
+		Expect(other.Renew(context.Background())).ShouldNot(HaveOccurred())
+		Expect(other.Release(context.Background())).ShouldNot(HaveOccurred())
+	})
+
+	// Tests that the heartbeat will renew a lease so that it does not expire while it is held
+	It("Heartbeat - Renews", func() {
+
+		// First, create two clients with a short lease duration and acquire the lock with the first
+		first := createLockClient(conn, "first", WithLeaseDuration(100*time.Millisecond),
+			WithHeartbeat(10*time.Millisecond))
+		second := createLockClient(conn, "second", WithLeaseDuration(100*time.Millisecond))
+		lease, err := first.TryAcquire(context.Background(), "test_lock")
+		Expect(err).ShouldNot(HaveOccurred())
+		expires := lease.Expires()
+
+		// Next, wait for longer than the lease duration
+		time.Sleep(250 * time.Millisecond)
+
+		// Finally, verify that the lease was renewed and is still held
+		Expect(lease.Expires()).Should(BeTemporally(">", expires))
+		other, err := second.TryAcquire(context.Background(), "test_lock")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(other).Should(BeNil())
+		Expect(lease.Release(context.Background())).ShouldNot(HaveOccurred())
+	})
+
+	// Tests that, if the lock is taken by another owner, then the heartbeat will detect that the lease was
+	// lost and cancel its context
+	It("Heartbeat - Lost - Context cancelled", func() {
+
+		// First, create two clients where the second sees the first client's lease as expired
+		first := createLockClient(conn, "first", WithHeartbeat(10*time.Millisecond))
+		second := createLockClient(conn, "second")
+		second.now = func() time.Time { return time.Now().Add(time.Minute) }
+
+		// Next, acquire the lock with both clients
+		lease, err := first.TryAcquire(context.Background(), "test_lock")
+		Expect(err).ShouldNot(HaveOccurred())
+		other, err := second.TryAcquire(context.Background(), "test_lock")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer other.Release(context.Background())
+
+		// Finally, verify that the first lease's context is cancelled by the heartbeat
+		Eventually(lease.Context().Done()).Should(BeClosed())
+		Expect(lease.Err()).Should(Equal(LeaseLost))
+	})
+
+	// Tests that, if the context used to acquire the lease is cancelled, then the lease will be lost
+	It("Heartbeat - Acquire context cancelled - Lost", func() {
+
+		// First, create a client and acquire the lock with a context that we'll cancel
+		client := createLockClient(conn, "first", WithHeartbeat(10*time.Millisecond))
+		ctx, cancel := context.WithCancel(context.Background())
+		lease, err := client.TryAcquire(ctx, "test_lock")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(lease.Err()).ShouldNot(HaveOccurred())
+
+		// Next, cancel the context used to acquire the lease
+		cancel()
+
+		// Finally, verify that the lease was lost
+		Eventually(lease.Context().Done()).Should(BeClosed())
+		Eventually(lease.Err).Should(Equal(LeaseLost))
+	})
+
+	// Tests that Acquire will wait for a lock to be released by another owner before acquiring it
+	It("Acquire - Held - Waits", func() {
+
+		// First, create two clients and acquire the lock with the first
+		first := createLockClient(conn, "first")
+		second := createLockClient(conn, "second", WithPollInterval(5*time.Millisecond))
+		lease, err := first.TryAcquire(context.Background(), "test_lock")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, release the first lease after a short delay
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(50 * time.Millisecond)
+			Expect(lease.Release(context.Background())).ShouldNot(HaveOccurred())
+		}()
+
+		// Finally, acquire the lock with the second client; this should wait for the release
+		other, err := second.Acquire(context.Background(), "test_lock")
+		wg.Wait()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(other).ShouldNot(BeNil())
+		Expect(lease.Err()).Should(Equal(LeaseReleased))
+		Expect(other.Release(context.Background())).ShouldNot(HaveOccurred())
+	})
+
+	// Tests that Acquire will return an error if the context finishes before the lock could be acquired
+	It("Acquire - Context finished - Error", func() {
+
+		// First, create two clients and acquire the lock with the first
+		first := createLockClient(conn, "first")
+		second := createLockClient(conn, "second", WithPollInterval(5*time.Millisecond))
+		lease, err := first.TryAcquire(context.Background(), "test_lock")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer lease.Release(context.Background())
+
+		// Next, attempt to acquire the lock with the second client with a short timeout
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+		other, err := second.Acquire(ctx, "test_lock")
+
+		// Finally, verify that the lock was not acquired
+		Expect(other).Should(BeNil())
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("Context finished before lock test_lock could be acquired"))
+	})
+})
+
+// Helper function that creates a lock client for the owner with the options provided
+func createLockClient(conn *dynamodb.DatabaseConnection, owner string, opts ...IClientOption) *Client {
+	logger := utils.NewLogger("testd", "test")
+	logger.Discard()
+	return NewClient(conn, "LOCK_TABLE", logger, append([]IClientOption{WithOwner(owner)}, opts...)...)
+}
@@ -0,0 +1,55 @@
+package lock
+
+import "time"
+
+// IClientOption defines the functionality that will allow the behavior of a lock Client to be modified
+// at construction
+type IClientOption interface {
+	Apply(*Client)
+}
+
+// WithOwner allows the user to set the ID that will identify the owner of leases acquired by the Client. By
+// default, a random UUID will be generated for each Client
+type WithOwner string
+
+// Apply modifies the Client so that it has the owner ID defined by this object
+func (w WithOwner) Apply(client *Client) {
+	client.owner = string(w)
+}
+
+// WithLeaseDuration allows the user to set how long a lease will be held before it expires if it isn't renewed.
+// By default, this value is 20 seconds
+type WithLeaseDuration time.Duration
+
+// Apply modifies the Client so that it has the lease duration defined by this object
+func (w WithLeaseDuration) Apply(client *Client) {
+	client.leaseDuration = time.Duration(w)
+}
+
+// WithHeartbeat allows the user to set how often a lease will be renewed while it is held. This should be
+// significantly shorter than the lease duration so that a renewal that fails intermittently can be retried
+// before the lease expires. By default, this value is one third of the lease duration
+type WithHeartbeat time.Duration
+
+// Apply modifies the Client so that it has the heartbeat interval defined by this object
+func (w WithHeartbeat) Apply(client *Client) {
+	client.heartbeat = time.Duration(w)
+}
+
+// WithPollInterval allows the user to set how long Acquire will wait between attempts to acquire a lock that
+// is held by another owner. By default, this value is the heartbeat interval
+type WithPollInterval time.Duration
+
+// Apply modifies the Client so that it has the poll interval defined by this object
+func (w WithPollInterval) Apply(client *Client) {
+	client.pollInterval = time.Duration(w)
+}
+
+// WithRetention allows the user to set how long the item associated with a lock will be kept after its lease
+// expires before it is deleted by the time-to-live settings on the table. By default, this value is one day
+type WithRetention time.Duration
+
+// Apply modifies the Client so that it has the retention period defined by this object
+func (w WithRetention) Apply(client *Client) {
+	client.retention = time.Duration(w)
+}
@@ -0,0 +1,331 @@
+package dynamodb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// OperationMetrics describes a single operation made through a DatabaseConnection. For paginated queries and
+// scans, batch operations and parallel scans, the metrics cover every request that was made to DynamoDB to
+// complete the operation, including retries
+type OperationMetrics struct {
+
+	// The name of the table, or tables, the operation was made against
+	TableName string
+
+	// The verb describing the operation, e.g. PUT, GET, QUERY, SCAN or BATCH WRITE
+	Verb string
+
+	// The number of requests that were sent to DynamoDB to complete the operation, including retries
+	Attempts int
+
+	// The amount of time the operation took to complete, including time spent backing off between attempts
+	Duration time.Duration
+
+	// The capacity consumed by the operation, aggregated by table. Each entry includes the capacity consumed
+	// by any indexes on the table if the capacity was requested with INDEXES. This will be empty if capacity
+	// was not requested or if the operation was served without making any requests to DynamoDB
+	Capacity map[string]*types.ConsumedCapacity
+
+	// The error returned by the operation, or nil if the operation succeeded
+	Err error
+}
+
+// IMetricsHook defines the functionality that will allow the metrics for each operation made through a
+// DatabaseConnection to be reported
+type IMetricsHook interface {
+
+	// OnOperation is called once each operation has completed, regardless of whether or not it succeeded
+	OnOperation(metrics *OperationMetrics)
+}
+
+// MetricsHook is a metrics hook that may be created from a function
+type MetricsHook func(metrics *OperationMetrics)
+
+// OnOperation calls the function with the metrics
+func (hook MetricsHook) OnOperation(metrics *OperationMetrics) {
+	hook(metrics)
+}
+
+// Helper type used as the key of the metrics scope associated with a context
+type metricsKey struct{}
+
+// Helper type that accumulates the metrics for a single operation. Operations that call other operations,
+// such as BatchWrite or ParallelScan, will share the scope of the outermost operation so that only that
+// operation is reported
+type metricsScope struct {
+	hook    IMetricsHook
+	metrics OperationMetrics
+	start   time.Time
+	lock    sync.Mutex
+}
+
+// Helper function that starts collecting metrics for an operation. If the connection has no metrics hook
+// then nothing will be collected. If the context already has a scope then the operation is part of a larger
+// operation so the existing scope will be used and nil will be returned so that only the outer operation
+// is reported
+func (conn *DatabaseConnection) startMetrics(ctx context.Context, tableName string,
+	verb string) (context.Context, *metricsScope) {
+	if conn.metricsHook == nil || metricsFromContext(ctx) != nil {
+		return ctx, nil
+	}
+
+	scope := metricsScope{
+		hook:    conn.metricsHook,
+		metrics: OperationMetrics{TableName: tableName, Verb: verb},
+		start:   time.Now(),
+	}
+
+	return context.WithValue(ctx, metricsKey{}, &scope), &scope
+}
+
+// Helper function that retrieves the metrics scope associated with a context, if there is one
+func metricsFromContext(ctx context.Context) *metricsScope {
+	scope, _ := ctx.Value(metricsKey{}).(*metricsScope)
+	return scope
+}
+
+// Helper function that records an attempt to make a request to DynamoDB on the scope
+func (scope *metricsScope) addAttempt() {
+	if scope == nil {
+		return
+	}
+
+	scope.lock.Lock()
+	defer scope.lock.Unlock()
+	scope.metrics.Attempts++
+}
+
+// Helper function that adds capacity consumed by a request to DynamoDB to the totals on the scope
+func (scope *metricsScope) addCapacity(capacities ...types.ConsumedCapacity) {
+	if scope == nil {
+		return
+	}
+
+	scope.lock.Lock()
+	defer scope.lock.Unlock()
+	for _, capacity := range capacities {
+		tableName := aws.ToString(capacity.TableName)
+		if scope.metrics.Capacity == nil {
+			scope.metrics.Capacity = make(map[string]*types.ConsumedCapacity)
+		}
+
+		total, ok := scope.metrics.Capacity[tableName]
+		if !ok {
+			total = &types.ConsumedCapacity{TableName: capacity.TableName}
+			scope.metrics.Capacity[tableName] = total
+		}
+
+		total.CapacityUnits = addUnits(total.CapacityUnits, capacity.CapacityUnits)
+		total.ReadCapacityUnits = addUnits(total.ReadCapacityUnits, capacity.ReadCapacityUnits)
+		total.WriteCapacityUnits = addUnits(total.WriteCapacityUnits, capacity.WriteCapacityUnits)
+		total.Table = addTableCapacity(total.Table, capacity.Table)
+		total.GlobalSecondaryIndexes = addIndexCapacity(total.GlobalSecondaryIndexes, capacity.GlobalSecondaryIndexes)
+		total.LocalSecondaryIndexes = addIndexCapacity(total.LocalSecondaryIndexes, capacity.LocalSecondaryIndexes)
+	}
+}
+
+// Helper function that finishes the operation associated with the scope and reports its metrics to the hook.
+// If the scope is nil then this function does nothing
+func (scope *metricsScope) finish(err error) {
+	if scope == nil {
+		return
+	}
+
+	scope.lock.Lock()
+	scope.metrics.Duration = time.Since(scope.start)
+	scope.metrics.Err = err
+	metrics := scope.metrics
+	scope.lock.Unlock()
+
+	scope.hook.OnOperation(&metrics)
+}
+
+// Helper function that adds two optional capacity unit values together
+func addUnits(total *float64, units *float64) *float64 {
+	if units == nil {
+		return total
+	}
+
+	return aws.Float64(aws.ToFloat64(total) + *units)
+}
+
+// Helper function that adds capacity consumed by a table or index to a running total
+func addTableCapacity(total *types.Capacity, capacity *types.Capacity) *types.Capacity {
+	if capacity == nil {
+		return total
+	} else if total == nil {
+		total = new(types.Capacity)
+	}
+
+	total.CapacityUnits = addUnits(total.CapacityUnits, capacity.CapacityUnits)
+	total.ReadCapacityUnits = addUnits(total.ReadCapacityUnits, capacity.ReadCapacityUnits)
+	total.WriteCapacityUnits = addUnits(total.WriteCapacityUnits, capacity.WriteCapacityUnits)
+	return total
+}
+
+// Helper function that adds capacity consumed by a number of indexes to running totals for each index
+func addIndexCapacity(totals map[string]types.Capacity, capacities map[string]types.Capacity) map[string]types.Capacity {
+	for name, capacity := range capacities {
+		if totals == nil {
+			totals = make(map[string]types.Capacity)
+		}
+
+		total := totals[name]
+		totals[name] = *addTableCapacity(&total, &capacity)
+	}
+
+	return totals
+}
+
+// Helper type that decorates a DynamoDB client so that every data-plane request asks DynamoDB to return the
+// capacity it consumed, which is then recorded on the metrics scope associated with the request's context
+type capacityClient struct {
+	DynamoDBAPI
+	level types.ReturnConsumedCapacity
+}
+
+// GetItem retrieves an item from DynamoDB, recording the capacity it consumed
+func (client *capacityClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	copied := *params
+	copied.ReturnConsumedCapacity = client.requestLevel(params.ReturnConsumedCapacity)
+	output, err := client.DynamoDBAPI.GetItem(ctx, &copied, optFns...)
+	if err == nil && output.ConsumedCapacity != nil {
+		metricsFromContext(ctx).addCapacity(*output.ConsumedCapacity)
+	}
+
+	return output, err
+}
+
+// PutItem writes an item to DynamoDB, recording the capacity it consumed
+func (client *capacityClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	copied := *params
+	copied.ReturnConsumedCapacity = client.requestLevel(params.ReturnConsumedCapacity)
+	output, err := client.DynamoDBAPI.PutItem(ctx, &copied, optFns...)
+	if err == nil && output.ConsumedCapacity != nil {
+		metricsFromContext(ctx).addCapacity(*output.ConsumedCapacity)
+	}
+
+	return output, err
+}
+
+// UpdateItem updates an item in DynamoDB, recording the capacity it consumed
+func (client *capacityClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	copied := *params
+	copied.ReturnConsumedCapacity = client.requestLevel(params.ReturnConsumedCapacity)
+	output, err := client.DynamoDBAPI.UpdateItem(ctx, &copied, optFns...)
+	if err == nil && output.ConsumedCapacity != nil {
+		metricsFromContext(ctx).addCapacity(*output.ConsumedCapacity)
+	}
+
+	return output, err
+}
+
+// DeleteItem deletes an item from DynamoDB, recording the capacity it consumed
+func (client *capacityClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	copied := *params
+	copied.ReturnConsumedCapacity = client.requestLevel(params.ReturnConsumedCapacity)
+	output, err := client.DynamoDBAPI.DeleteItem(ctx, &copied, optFns...)
+	if err == nil && output.ConsumedCapacity != nil {
+		metricsFromContext(ctx).addCapacity(*output.ConsumedCapacity)
+	}
+
+	return output, err
+}
+
+// Query retrieves a page of items from DynamoDB, recording the capacity it consumed
+func (client *capacityClient) Query(ctx context.Context, params *dynamodb.QueryInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	copied := *params
+	copied.ReturnConsumedCapacity = client.requestLevel(params.ReturnConsumedCapacity)
+	output, err := client.DynamoDBAPI.Query(ctx, &copied, optFns...)
+	if err == nil && output.ConsumedCapacity != nil {
+		metricsFromContext(ctx).addCapacity(*output.ConsumedCapacity)
+	}
+
+	return output, err
+}
+
+// Scan retrieves a page of items from DynamoDB, recording the capacity it consumed
+func (client *capacityClient) Scan(ctx context.Context, params *dynamodb.ScanInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	copied := *params
+	copied.ReturnConsumedCapacity = client.requestLevel(params.ReturnConsumedCapacity)
+	output, err := client.DynamoDBAPI.Scan(ctx, &copied, optFns...)
+	if err == nil && output.ConsumedCapacity != nil {
+		metricsFromContext(ctx).addCapacity(*output.ConsumedCapacity)
+	}
+
+	return output, err
+}
+
+// BatchGetItem retrieves a batch of items from DynamoDB, recording the capacity it consumed
+func (client *capacityClient) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	copied := *params
+	copied.ReturnConsumedCapacity = client.requestLevel(params.ReturnConsumedCapacity)
+	output, err := client.DynamoDBAPI.BatchGetItem(ctx, &copied, optFns...)
+	if err == nil {
+		metricsFromContext(ctx).addCapacity(output.ConsumedCapacity...)
+	}
+
+	return output, err
+}
+
+// BatchWriteItem writes a batch of items to DynamoDB, recording the capacity it consumed
+func (client *capacityClient) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	copied := *params
+	copied.ReturnConsumedCapacity = client.requestLevel(params.ReturnConsumedCapacity)
+	output, err := client.DynamoDBAPI.BatchWriteItem(ctx, &copied, optFns...)
+	if err == nil {
+		metricsFromContext(ctx).addCapacity(output.ConsumedCapacity...)
+	}
+
+	return output, err
+}
+
+// TransactGetItems reads a group of items from DynamoDB atomically, recording the capacity it consumed
+func (client *capacityClient) TransactGetItems(ctx context.Context, params *dynamodb.TransactGetItemsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.TransactGetItemsOutput, error) {
+	copied := *params
+	copied.ReturnConsumedCapacity = client.requestLevel(params.ReturnConsumedCapacity)
+	output, err := client.DynamoDBAPI.TransactGetItems(ctx, &copied, optFns...)
+	if err == nil {
+		metricsFromContext(ctx).addCapacity(output.ConsumedCapacity...)
+	}
+
+	return output, err
+}
+
+// TransactWriteItems writes a group of items to DynamoDB atomically, recording the capacity it consumed
+func (client *capacityClient) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	copied := *params
+	copied.ReturnConsumedCapacity = client.requestLevel(params.ReturnConsumedCapacity)
+	output, err := client.DynamoDBAPI.TransactWriteItems(ctx, &copied, optFns...)
+	if err == nil {
+		metricsFromContext(ctx).addCapacity(output.ConsumedCapacity...)
+	}
+
+	return output, err
+}
+
+// Helper function that determines the level of capacity that should be requested. If the caller requested
+// capacity then their level will be kept so that the output contains what they expect
+func (client *capacityClient) requestLevel(requested types.ReturnConsumedCapacity) types.ReturnConsumedCapacity {
+	if requested == "" || requested == types.ReturnConsumedCapacityNone {
+		return client.level
+	}
+
+	return requested
+}
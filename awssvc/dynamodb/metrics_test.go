@@ -0,0 +1,223 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/awssvc/testing"
+	"github.com/xefino/goutils/utils"
+)
+
+var _ = Describe("Metrics Tests", func() {
+
+	// Create a new fake with our test table and a connection that records its metrics before each test
+	var fake *testing.FakeDynamoDB
+	var conn *DatabaseConnection
+	var recorder *metricsRecorder
+	BeforeEach(func() {
+		fake, _ = createFakeConnection()
+		recorder = new(metricsRecorder)
+		conn = createMetricsConnection(fake, WithMetricsHook{Hook: recorder})
+	})
+
+	// Tests that single-item operations will report their attempts, latency and consumed capacity
+	It("PutItem, GetItem - Reported", func() {
+
+		// First, write an item and read it back; neither should fail
+		writeFakeItems(conn, "a", 1)
+		output, err := conn.GetItem(context.Background(), createCacheInput("a", "0"))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, verify that the capacity was returned to us as the hook requested it
+		Expect(output.ConsumedCapacity).ShouldNot(BeNil())
+
+		// Finally, verify the metrics that were reported for each operation
+		metrics := recorder.all()
+		Expect(metrics).Should(HaveLen(2))
+		Expect(metrics[0].Verb).Should(Equal("PUT"))
+		Expect(metrics[1].Verb).Should(Equal("GET"))
+		for _, metric := range metrics {
+			Expect(metric.TableName).Should(Equal("TEST_TABLE"))
+			Expect(metric.Attempts).Should(Equal(1))
+			Expect(metric.Duration).Should(BeNumerically(">", 0))
+			Expect(metric.Err).ShouldNot(HaveOccurred())
+			Expect(*metric.Capacity["TEST_TABLE"].CapacityUnits).Should(Equal(1.0))
+			Expect(*metric.Capacity["TEST_TABLE"].Table.CapacityUnits).Should(Equal(1.0))
+		}
+	})
+
+	// Tests that retried operations will report every attempt that was made
+	It("GetItem - Retried - Attempts reported", func() {
+		fake.ThrottleNext("GetItem", 2)
+
+		_, err := conn.GetItem(context.Background(), createCacheInput("a", "0"))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		metrics := recorder.all()
+		Expect(metrics).Should(HaveLen(1))
+		Expect(metrics[0].Attempts).Should(Equal(3))
+		Expect(*metrics[0].Capacity["TEST_TABLE"].CapacityUnits).Should(Equal(1.0))
+	})
+
+	// Tests that failed operations will report the error that was returned
+	It("GetItem - Fails - Error reported", func() {
+		_, err := conn.GetItem(context.Background(), &dynamodb.GetItemInput{
+			TableName: aws.String("MISSING_TABLE"),
+			Key:       createFakeKey("a", "0"),
+		})
+		Expect(err).Should(HaveOccurred())
+
+		metrics := recorder.all()
+		Expect(metrics).Should(HaveLen(1))
+		Expect(metrics[0].TableName).Should(Equal("MISSING_TABLE"))
+		Expect(metrics[0].Err).Should(Equal(err))
+		Expect(metrics[0].Capacity).Should(BeEmpty())
+	})
+
+	// Tests that a paginated query will report a single set of metrics with totals for all its pages
+	It("QueryItems - Paginated - Aggregated", func() {
+
+		// First, write a number of items to the table and clear the metrics from those writes
+		writeFakeItems(conn, "a", 5)
+		recorder.reset()
+
+		// Next, query the items two at a time; this should not fail
+		input := dynamodb.QueryInput{TableName: aws.String("TEST_TABLE"), Limit: aws.Int32(2)}
+		Expect(NewExpression().KeyCondition(Equals("id", "a")).ApplyQuery(&input)).ShouldNot(HaveOccurred())
+		count := 0
+		_, err := conn.QueryItems(context.Background(), &input, func(map[string]types.AttributeValue) (bool, error) {
+			count++
+			return true, nil
+		})
+
+		// Finally, verify that all the pages were reported together
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(count).Should(Equal(5))
+		metrics := recorder.all()
+		Expect(metrics).Should(HaveLen(1))
+		Expect(metrics[0].Verb).Should(Equal("QUERY"))
+		Expect(metrics[0].Attempts).Should(Equal(3))
+		Expect(*metrics[0].Capacity["TEST_TABLE"].CapacityUnits).Should(Equal(5.0))
+		Expect(input.ReturnConsumedCapacity).Should(BeEmpty())
+	})
+
+	// Tests that a batch write that retries unprocessed items will report a single set of metrics
+	It("BatchWrite - Unprocessed - Aggregated", func() {
+
+		// First, cause the first batch to only write one item
+		fake.UnprocessNext(1)
+
+		// Next, write a number of items in a batch; this should not fail
+		requests := make([]types.WriteRequest, 3)
+		for i := range requests {
+			requests[i] = types.WriteRequest{PutRequest: &types.PutRequest{Item: createFakeItem("a", fmt.Sprint(i), i)}}
+		}
+
+		_, err := conn.BatchWrite(context.Background(), "TEST_TABLE", requests...)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that both attempts were reported together
+		metrics := recorder.all()
+		Expect(metrics).Should(HaveLen(1))
+		Expect(metrics[0].Verb).Should(Equal("BATCH WRITE"))
+		Expect(metrics[0].Attempts).Should(Equal(2))
+		Expect(*metrics[0].Capacity["TEST_TABLE"].CapacityUnits).Should(Equal(3.0))
+	})
+
+	// Tests that, if capacity is not requested, then attempts and latency will still be reported
+	It("Capacity NONE - Not requested", func() {
+
+		// First, create a connection that does not request capacity
+		conn = createMetricsConnection(fake, WithMetricsHook{Hook: recorder,
+			Capacity: types.ReturnConsumedCapacityNone})
+
+		// Next, read an item; this should not fail and no capacity should be returned
+		output, err := conn.GetItem(context.Background(), createCacheInput("a", "0"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(output.ConsumedCapacity).Should(BeNil())
+
+		// Finally, verify the metrics that were reported
+		metrics := recorder.all()
+		Expect(metrics).Should(HaveLen(1))
+		Expect(metrics[0].Attempts).Should(Equal(1))
+		Expect(metrics[0].Capacity).Should(BeEmpty())
+	})
+
+	// Tests that applying a metrics hook without a hook will not modify the connection
+	It("Nil hook - Ignored", func() {
+
+		// First, apply an empty hook on top of our recording hook
+		conn = createMetricsConnection(fake, WithMetricsHook{Hook: recorder}, WithMetricsHook{})
+
+		// Next, verify that the connection still reports to our hook and requests capacity
+		Expect(conn.metricsHook).Should(Equal(recorder))
+		Expect(conn.db.(*capacityClient).DynamoDBAPI).Should(Equal(fake))
+
+		// Finally, read an item and verify that it was reported
+		_, err := conn.GetItem(context.Background(), createCacheInput("a", "0"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(recorder.all()).Should(HaveLen(1))
+	})
+
+	// Tests that applying a metrics hook more than once will replace the capacity level rather than wrapping
+	// the client again
+	It("Applied twice - Not wrapped twice", func() {
+
+		// First, apply the hook twice with different capacity levels and verify that the client was only wrapped once
+		conn = createMetricsConnection(fake, WithMetricsHook{Hook: recorder},
+			WithMetricsHook{Hook: recorder, Capacity: types.ReturnConsumedCapacityTotal})
+		client := conn.db.(*capacityClient)
+		Expect(client.DynamoDBAPI).Should(Equal(fake))
+		Expect(client.level).Should(Equal(types.ReturnConsumedCapacityTotal))
+
+		// Next, apply the hook again without requesting capacity and verify that the wrapper was removed
+		WithMetricsHook{Hook: recorder, Capacity: types.ReturnConsumedCapacityNone}.Apply(conn)
+		Expect(conn.db).Should(Equal(fake))
+
+		// Finally, read an item and verify that it was reported once
+		_, err := conn.GetItem(context.Background(), createCacheInput("a", "0"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(recorder.all()).Should(HaveLen(1))
+	})
+})
+
+// Helper type that records the metrics reported to it
+type metricsRecorder struct {
+	lock    sync.Mutex
+	metrics []*OperationMetrics
+}
+
+// OnOperation records the metrics
+func (recorder *metricsRecorder) OnOperation(metrics *OperationMetrics) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.metrics = append(recorder.metrics, metrics)
+}
+
+// Helper function that returns all the metrics that have been recorded
+func (recorder *metricsRecorder) all() []*OperationMetrics {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	return append([]*OperationMetrics{}, recorder.metrics...)
+}
+
+// Helper function that clears the metrics that have been recorded
+func (recorder *metricsRecorder) reset() {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.metrics = nil
+}
+
+// Helper function that creates a connection from the client with a short backoff and the options provided
+func createMetricsConnection(client DynamoDBAPI, opts ...IDynamoDBOption) *DatabaseConnection {
+	logger := utils.NewLogger("testd", "test")
+	logger.Discard()
+	return FromClient(client, logger, append([]IDynamoDBOption{WithBackoffStart(1), WithBackoffEnd(5),
+		WithBackoffMaxElapsed(1000)}, opts...)...)
+}
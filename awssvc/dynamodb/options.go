@@ -1,6 +1,10 @@
 package dynamodb
 
-import "time"
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
 
 // IDynamoDBOption defines the functionality that will allow the behavior of a
 // DatabaseConnection to be modified at construction
@@ -35,6 +39,41 @@ func (w WithBackoffMaxElapsed) Apply(conn *DatabaseConnection) {
 	conn.maxElapsed = time.Duration(w)
 }
 
+// WithBatchWriteAttempts allows the user to set the maximum number of attempts that should be made to write
+// the requests sent to BatchWrite before the unprocessed requests are returned. By default, this value is 10
+type WithBatchWriteAttempts int
+
+// Apply modifies the DatabaseConnection so that it has the maximum number of batch-write attempts defined by
+// this object
+func (w WithBatchWriteAttempts) Apply(conn *DatabaseConnection) {
+	conn.batchAttempts = int(w)
+}
+
+// WithBatchWriteParallelism allows the user to set the maximum number of chunks that BatchWrite should write
+// to DynamoDB concurrently. By default, this value is 1 so chunks will be written sequentially
+type WithBatchWriteParallelism int
+
+// Apply modifies the DatabaseConnection so that it has the degree of batch-write parallelism defined by this
+// object
+func (w WithBatchWriteParallelism) Apply(conn *DatabaseConnection) {
+	conn.batchParallelism = int(w)
+}
+
+// WithRetryPolicy allows the user to set the policy that determines which errors returned by DynamoDB should
+// be retried, and which will be notified of each attempt made to complete a request. By default, errors will
+// be classified by IsRetryable and attempts will not be recorded. If no policy is provided then the default
+// policy will be kept
+type WithRetryPolicy struct {
+	Policy IRetryPolicy
+}
+
+// Apply modifies the DatabaseConnection so that it has the retry policy defined by this object
+func (w WithRetryPolicy) Apply(conn *DatabaseConnection) {
+	if w.Policy != nil {
+		conn.retryPolicy = w.Policy
+	}
+}
+
 // WithTagKey allows the user to set the field tag that should be used when marshalling data to
 // DynamoDB attribute values or when unmarshalling data from DynamoDB attribute values
 type WithTagKey string
@@ -43,3 +82,348 @@ type WithTagKey string
 func (w WithTagKey) Apply(conn *DatabaseConnection) {
 	conn.tagKey = string(w)
 }
+
+// IIteratorOption defines the functionality that will allow the behavior of a query or scan iterator
+// to be modified when it is called
+type IIteratorOption interface {
+	Apply(*iteratorOptions)
+}
+
+// Helper type containing the options that may be set on a query or scan iterator
+type iteratorOptions struct {
+	limit         int
+	keyAttributes []string
+}
+
+// Helper function that creates the iterator options from the defaults and the options provided
+func newIteratorOptions(opts ...IIteratorOption) *iteratorOptions {
+	options := new(iteratorOptions)
+	for _, opt := range opts {
+		opt.Apply(options)
+	}
+
+	return options
+}
+
+// WithItemLimit allows the user to set the maximum number of items that should be retrieved by an
+// iterator. If this value is zero or negative then all the items will be retrieved
+type WithItemLimit int
+
+// Apply modifies the iterator options so that they have the item limit defined by this object
+func (w WithItemLimit) Apply(options *iteratorOptions) {
+	options.limit = int(w)
+}
+
+// WithKeyAttributes allows the user to set the names of the key attributes that should be used to derive
+// the key to resume from when an iterator is stopped in the middle of a page. If this isn't provided then
+// the names will be taken from the key of the page or, on the last page, from the key schema of the table,
+// which will be retrieved with DescribeTable. For queries on an index, the table's key attributes and the
+// index's key attributes should all be provided
+type WithKeyAttributes []string
+
+// Apply modifies the iterator options so that they have the key attributes defined by this object
+func (w WithKeyAttributes) Apply(options *iteratorOptions) {
+	options.keyAttributes = w
+}
+
+// IParallelScanOption defines the functionality that will allow the behavior of a parallel scan to be
+// modified when it is called
+type IParallelScanOption interface {
+	Apply(*parallelScanOptions)
+}
+
+// Helper type containing the options that may be set on a parallel scan
+type parallelScanOptions struct {
+	checkpoints   []*ScanCheckpoint
+	progress      func(ScanCheckpoint)
+	cancelOnError bool
+}
+
+// WithCheckpoints allows the user to resume a parallel scan from the checkpoints returned by a previous
+// call. Segments that were already finished will be skipped and all other segments will resume from
+// their last evaluated key. The checkpoints will be updated as the scan progresses
+type WithCheckpoints []*ScanCheckpoint
+
+// Apply modifies the parallel scan options so that they have the checkpoints defined by this object
+func (w WithCheckpoints) Apply(options *parallelScanOptions) {
+	options.checkpoints = w
+}
+
+// WithProgress allows the user to set a function that will be called with a copy of the checkpoint for a
+// segment each time that segment finishes handling a page. This function will be called concurrently
+// from multiple segments so it must be safe for concurrent use
+type WithProgress func(ScanCheckpoint)
+
+// Apply modifies the parallel scan options so that they have the progress function defined by this object
+func (w WithProgress) Apply(options *parallelScanOptions) {
+	options.progress = w
+}
+
+// WithCancelOnError allows the user to set whether or not the remaining segments of a parallel scan should
+// be cancelled when one segment fails. By default, this value is true
+type WithCancelOnError bool
+
+// Apply modifies the parallel scan options so that they have the cancellation behavior defined by this object
+func (w WithCancelOnError) Apply(options *parallelScanOptions) {
+	options.cancelOnError = bool(w)
+}
+
+// NullMode describes how NULL attribute values and JSON nulls should be handled when converting between
+// DynamoDB attribute values and JSON
+type NullMode int
+
+// Modes that may be used to handle NULL attribute values and JSON nulls
+const (
+
+	// NULL values will be omitted from objects by default, or kept if the conversion is lossless
+	NullsDefault NullMode = iota
+
+	// NULL values in maps, and null fields in JSON objects, will be omitted from the result
+	NullsOmit
+
+	// NULL values in maps will be written as JSON nulls and JSON nulls will be converted to NULL values
+	NullsKeep
+)
+
+// IJSONOption defines the functionality that will allow the conversion between DynamoDB attribute values and
+// JSON to be modified when it is called
+type IJSONOption interface {
+	Apply(*jsonOptions)
+}
+
+// Helper type containing the options that may be set on a conversion between attribute values and JSON
+type jsonOptions struct {
+	lossless bool
+	nulls    NullMode
+}
+
+// Helper function that creates the JSON options from the defaults and the options provided
+func newJSONOptions(opts ...IJSONOption) *jsonOptions {
+	options := new(jsonOptions)
+	for _, opt := range opts {
+		opt.Apply(options)
+	}
+
+	return options
+}
+
+// Helper function that determines whether or not NULL values should be kept when converting
+func (options *jsonOptions) keepNulls() bool {
+	return options.nulls == NullsKeep || (options.nulls == NullsDefault && options.lossless)
+}
+
+// WithLosslessJSON allows the user to set whether or not attribute values should be converted to and from
+// JSON without any loss of type information. In lossless mode, numbers will be written as JSON numbers with
+// their exact text, NULL values will be kept as JSON nulls and sets and binary values will be written as
+// objects tagged with their type, e.g. {"SS":["a","b"]}, {"NS":[1,2]}, {"BS":["AQ=="]} or {"B":"AQ=="}. Maps
+// whose only key is one of these tags will be tagged themselves, as {"M":{...}}, so they are not mistaken
+// for sets or binary values. By default, this value is false
+type WithLosslessJSON bool
+
+// Apply modifies the JSON options so that they have the lossless setting defined by this object
+func (w WithLosslessJSON) Apply(options *jsonOptions) {
+	options.lossless = bool(w)
+}
+
+// WithJSONNulls allows the user to set how NULL attribute values and JSON nulls should be handled during the
+// conversion. By default, NULL values will be omitted from objects unless the conversion is lossless
+type WithJSONNulls NullMode
+
+// Apply modifies the JSON options so that they have the null handling defined by this object
+func (w WithJSONNulls) Apply(options *jsonOptions) {
+	options.nulls = NullMode(w)
+}
+
+// IExportOption defines the functionality that will allow the behavior of an export to S3 to be modified when
+// it is called
+type IExportOption interface {
+	Apply(*exportOptions)
+}
+
+// Helper type containing the options that may be set on an export to S3
+type exportOptions struct {
+	partSize int64
+}
+
+// Helper function that creates the export options from the defaults and the options provided
+func newExportOptions(opts ...IExportOption) *exportOptions {
+	options := exportOptions{partSize: 64 * 1024 * 1024}
+	for _, opt := range opts {
+		opt.Apply(&options)
+	}
+
+	return &options
+}
+
+// WithPartSize allows the user to set the size, in compressed bytes, at which an export will stop writing to
+// the current part and upload it to S3. Each part may exceed this size by up to one item. By default, this
+// value is 64 MiB
+type WithPartSize int64
+
+// Apply modifies the export options so that they have the part size defined by this object
+func (w WithPartSize) Apply(options *exportOptions) {
+	options.partSize = int64(w)
+}
+
+// IEnsureTableOption defines the functionality that will allow the behavior of EnsureTable to be modified when
+// it is called
+type IEnsureTableOption interface {
+	Apply(*ensureTableOptions)
+}
+
+// Helper type containing the options that may be set when ensuring that a table matches its schema
+type ensureTableOptions struct {
+	dropUnknownIndexes bool
+}
+
+// Helper function that creates the ensure-table options from the defaults and the options provided
+func newEnsureTableOptions(opts ...IEnsureTableOption) *ensureTableOptions {
+	var options ensureTableOptions
+	for _, opt := range opts {
+		opt.Apply(&options)
+	}
+
+	return &options
+}
+
+// WithDropUnknownIndexes allows the user to have EnsureTable delete global secondary indexes on the table that
+// are not in the schema or whose key schema differs from the one in the schema, recreating the latter. By
+// default, these indexes will be left in place and the difference will only be logged
+type WithDropUnknownIndexes bool
+
+// Apply modifies the ensure-table options so that they drop unknown indexes if this object is true
+func (w WithDropUnknownIndexes) Apply(options *ensureTableOptions) {
+	options.dropUnknownIndexes = bool(w)
+}
+
+// ICursorOption defines the functionality that will allow the behavior of a CursorCodec to be modified at
+// construction
+type ICursorOption interface {
+	Apply(*CursorCodec)
+}
+
+// WithCursorTTL allows the user to set how long a cursor will remain valid after it has been encoded. By
+// default, this value is one hour
+type WithCursorTTL time.Duration
+
+// Apply modifies the CursorCodec so that it has the time-to-live defined by this object
+func (w WithCursorTTL) Apply(codec *CursorCodec) {
+	codec.ttl = time.Duration(w)
+}
+
+// WithItemCache allows the user to set a read-through cache that will be used to serve GetItem requests made
+// through the DatabaseConnection. Writes made through the connection will invalidate the cached items they
+// affect. By default, no cache is used
+type WithItemCache struct {
+	Cache *ItemCache
+}
+
+// Apply modifies the DatabaseConnection so that its GetItem requests are served through the cache defined by
+// this object
+func (w WithItemCache) Apply(conn *DatabaseConnection) {
+	conn.db = &cachingClient{DynamoDBAPI: conn.db, cache: w.Cache, logger: conn.logger}
+}
+
+// IItemCacheOption defines the functionality that will allow the behavior of an ItemCache to be modified at
+// construction
+type IItemCacheOption interface {
+	Apply(*ItemCache)
+}
+
+// WithCacheTTL allows the user to set how long an item will be cached after it has been read from DynamoDB. By
+// default, this value is one minute
+type WithCacheTTL time.Duration
+
+// Apply modifies the ItemCache so that it has the time-to-live defined by this object
+func (w WithCacheTTL) Apply(cache *ItemCache) {
+	cache.ttl = time.Duration(w)
+}
+
+// WithNegativeCacheTTL allows the user to set how long the absence of an item will be cached after a GetItem
+// request found nothing. If this value is zero or negative then missing items will not be cached. By default,
+// this value is zero
+type WithNegativeCacheTTL time.Duration
+
+// Apply modifies the ItemCache so that it has the negative time-to-live defined by this object
+func (w WithNegativeCacheTTL) Apply(cache *ItemCache) {
+	cache.negativeTTL = time.Duration(w)
+}
+
+// WithCacheFetchTimeout allows the user to set how long a request to DynamoDB for an uncached item may take.
+// Since this request is shared between all the callers requesting the item, it isn't cancelled with the context
+// of any one caller so this timeout bounds it instead. By default, this value is 30 seconds
+type WithCacheFetchTimeout time.Duration
+
+// Apply modifies the ItemCache so that it has the fetch timeout defined by this object
+func (w WithCacheFetchTimeout) Apply(cache *ItemCache) {
+	cache.timeout = time.Duration(w)
+}
+
+// WithCachedTables allows the user to set the names of the tables whose items should be cached. If this isn't
+// provided then items from all tables will be cached
+type WithCachedTables []string
+
+// Apply modifies the ItemCache so that it only caches items from the tables defined by this object
+func (w WithCachedTables) Apply(cache *ItemCache) {
+	cache.tables = make(map[string]bool, len(w))
+	for _, table := range w {
+		cache.tables[table] = true
+	}
+}
+
+// WithMaxCachedItemSize allows the user to set the maximum size, in bytes of lossless JSON, of an item that will
+// be cached. Larger items will always be read from DynamoDB. If this value is zero or negative then items of
+// any size will be cached. By default, this value is zero
+type WithMaxCachedItemSize int
+
+// Apply modifies the ItemCache so that it has the maximum item size defined by this object
+func (w WithMaxCachedItemSize) Apply(cache *ItemCache) {
+	cache.maxItemSize = int(w)
+}
+
+// WithCacheKeyPrefix allows the user to set the prefix that will be added to the key of every cache entry so
+// that a shared backend may be used by multiple caches. By default, this value is "dynamodb"
+type WithCacheKeyPrefix string
+
+// Apply modifies the ItemCache so that it has the key prefix defined by this object
+func (w WithCacheKeyPrefix) Apply(cache *ItemCache) {
+	cache.prefix = string(w)
+}
+
+// WithMetricsHook allows the user to set a hook that will be called with the metrics for each data-plane
+// operation made through the DatabaseConnection, including the number of attempts, latency and consumed
+// capacity. Paginated queries and scans, batch operations and parallel scans will be reported once, with
+// totals for all the requests they made. Every request will ask DynamoDB to return consumed capacity at the
+// level provided which, if not set, will be INDEXES. If the level is NONE then capacity will not be requested
+// but attempts and latency will still be reported. By default, no metrics are reported
+type WithMetricsHook struct {
+	Hook     IMetricsHook
+	Capacity types.ReturnConsumedCapacity
+}
+
+// Apply modifies the DatabaseConnection so that it reports metrics to the hook defined by this object. If no
+// hook was set then the DatabaseConnection will not be modified
+func (w WithMetricsHook) Apply(conn *DatabaseConnection) {
+
+	// First, if we have no hook then there's nothing to report to so leave the connection as it is
+	if w.Hook == nil {
+		return
+	}
+
+	// Next, set the hook and remove any capacity decorator added by a previous hook so that the client
+	// isn't wrapped more than once
+	conn.metricsHook = w.Hook
+	if client, ok := conn.db.(*capacityClient); ok {
+		conn.db = client.DynamoDBAPI
+	}
+
+	// Finally, if capacity should be requested then decorate the client so that it's requested at our level
+	level := w.Capacity
+	if level == "" {
+		level = types.ReturnConsumedCapacityIndexes
+	}
+
+	if level != types.ReturnConsumedCapacityNone {
+		conn.db = &capacityClient{DynamoDBAPI: conn.db, level: level}
+	}
+}
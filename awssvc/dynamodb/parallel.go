@@ -0,0 +1,181 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/xefino/goutils/concurrency"
+)
+
+// ScanCheckpoint records the progress of a single segment of a parallel scan. A list of checkpoints returned
+// from ParallelScan may be provided to a later call with WithCheckpoints so that the scan will resume from
+// where it left off rather than starting over
+type ScanCheckpoint struct {
+
+	// The segment of the scan this checkpoint refers to
+	Segment int32
+
+	// The total number of segments in the scan
+	TotalSegments int32
+
+	// The key that should be used to resume this segment. If this is nil and Done is false then the
+	// segment has not yet started
+	LastEvaluatedKey map[string]types.AttributeValue
+
+	// The number of pages that have been handled for this segment
+	Pages int
+
+	// The number of items that have been handled for this segment
+	Items int
+
+	// Whether or not every item in this segment has been handled
+	Done bool
+}
+
+// SegmentHandler describes a function that will be called with each page of items retrieved by a segment of
+// a parallel scan. This function will be called concurrently from multiple segments so it must be safe for
+// concurrent use. If the function returns an error then the segment will stop without advancing its checkpoint
+type SegmentHandler func(ctx context.Context, segment int32, items []map[string]types.AttributeValue) error
+
+// ParallelScan scans a DynamoDB table by splitting it into a number of segments and scanning each segment
+// concurrently, calling the handler with each page of items as it is retrieved. Each page request will be
+// retried in the same way as Scan. A checkpoint will be returned for each segment that records how far that
+// segment progressed, regardless of whether or not an error occurred. If any segment fails then the first
+// error will be returned and, by default, the remaining segments will be cancelled. The input will not be
+// modified by this function
+func (conn *DatabaseConnection) ParallelScan(ctx context.Context, input *dynamodb.ScanInput, segments int,
+	handler SegmentHandler, opts ...IParallelScanOption) ([]*ScanCheckpoint, error) {
+	tableName := *input.TableName
+
+	// First, create our parallel scan options from the defaults and the options provided
+	options := parallelScanOptions{cancelOnError: true}
+	for _, opt := range opts {
+		opt.Apply(&options)
+	}
+
+	// Next, create the checkpoints for each segment. If we were given checkpoints then we'll verify that
+	// they match the number of segments and resume from them; otherwise, we'll start each segment fresh
+	checkpoints := options.checkpoints
+	if checkpoints == nil {
+		if segments < 1 {
+			return nil, conn.NewError(nil, tableName, "Parallel scan of %s requires at least one segment, "+
+				"but %d were requested", tableName, segments)
+		}
+
+		checkpoints = make([]*ScanCheckpoint, segments)
+		for i := range checkpoints {
+			checkpoints[i] = &ScanCheckpoint{Segment: int32(i), TotalSegments: int32(segments)}
+		}
+	} else if err := conn.verifyCheckpoints(tableName, segments, checkpoints); err != nil {
+		return nil, err
+	}
+
+	// Now, scan each of the segments concurrently, updating the checkpoint for each page that is handled
+	// successfully and reporting the progress of each segment if it was requested. Metrics for all the
+	// segments will be collected together
+	ctx, scope := conn.startMetrics(ctx, tableName, "PARALLEL SCAN")
+	err := concurrency.ForAllAsync(ctx, len(checkpoints), options.cancelOnError,
+		func(ctx context.Context, index int, _ context.CancelFunc) error {
+
+			// If the segment was already finished then we have nothing to do here
+			checkpoint := checkpoints[index]
+			if checkpoint.Done {
+				return nil
+			}
+
+			// Create a copy of the input that will only scan this segment
+			segmented := *input
+			segmented.Segment = aws.Int32(checkpoint.Segment)
+			segmented.TotalSegments = aws.Int32(checkpoint.TotalSegments)
+
+			// Scan every page in the segment, calling the handler with each page and then updating the
+			// checkpoint and reporting our progress
+			_, err := conn.iterate(ctx, tableName,
+				fmt.Sprintf("SCAN[%d/%d]", checkpoint.Segment, checkpoint.TotalSegments),
+				checkpoint.LastEvaluatedKey, input.Limit, conn.scanPager(&segmented),
+				func(items []map[string]types.AttributeValue, next map[string]types.AttributeValue) (bool, error) {
+					if err := handler(ctx, checkpoint.Segment, items); err != nil {
+						return false, err
+					}
+
+					checkpoint.LastEvaluatedKey = next
+					checkpoint.Pages++
+					checkpoint.Items += len(items)
+					checkpoint.Done = next == nil
+					if options.progress != nil {
+						options.progress(*checkpoint)
+					}
+
+					return true, nil
+				})
+
+			return err
+		})
+
+	// Finally, return the checkpoints and any error that occurred
+	scope.finish(err)
+	return checkpoints, err
+}
+
+// ParallelScanEach scans a DynamoDB table in parallel, converting each item to the type provided and calling
+// the handler with it. The handler will be called concurrently from multiple segments so it must be safe for
+// concurrent use. This function behaves in the same way as ParallelScan
+func ParallelScanEach[T any](ctx context.Context, conn *DatabaseConnection, input *dynamodb.ScanInput,
+	segments int, handler func(context.Context, *T) error, opts ...IParallelScanOption) ([]*ScanCheckpoint, error) {
+	return conn.ParallelScan(ctx, input, segments,
+		func(ctx context.Context, _ int32, items []map[string]types.AttributeValue) error {
+
+			// First, attempt to convert the page of items to our output type
+			values := make([]*T, 0, len(items))
+			if err := conn.UnmarshalList(items, &values); err != nil {
+				return err
+			}
+
+			// Next, call the handler with each item, returning the first error that occurs
+			for _, value := range values {
+				if err := handler(ctx, value); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}, opts...)
+}
+
+// SerializeSegmentHandler wraps a SegmentHandler so that it will only be called by one segment at a time. This
+// allows a handler that is not safe for concurrent use to be used with ParallelScan, at the cost of only
+// handling one page at a time
+func SerializeSegmentHandler(handler SegmentHandler) SegmentHandler {
+	lock := new(sync.Mutex)
+	return func(ctx context.Context, segment int32, items []map[string]types.AttributeValue) error {
+		lock.Lock()
+		defer lock.Unlock()
+		return handler(ctx, segment, items)
+	}
+}
+
+// Helper function that verifies that a list of checkpoints can be used to resume a parallel scan with the
+// number of segments provided
+func (conn *DatabaseConnection) verifyCheckpoints(tableName string, segments int,
+	checkpoints []*ScanCheckpoint) error {
+
+	// First, ensure that we have a checkpoint for every segment
+	if len(checkpoints) != segments {
+		return conn.NewError(nil, tableName, "Parallel scan of %s was requested with %d segments, but %d "+
+			"checkpoints were provided", tableName, segments, len(checkpoints))
+	}
+
+	// Next, ensure that each checkpoint refers to its own segment of a scan with the same number of segments
+	for i, checkpoint := range checkpoints {
+		if checkpoint.Segment != int32(i) || checkpoint.TotalSegments != int32(segments) {
+			return conn.NewError(nil, tableName, "Checkpoint %d of %d refers to segment %d of %d for %s",
+				i, segments, checkpoint.Segment, checkpoint.TotalSegments, tableName)
+		}
+	}
+
+	return nil
+}
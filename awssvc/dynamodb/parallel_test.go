@@ -0,0 +1,261 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/awssvc/testing"
+)
+
+var _ = Describe("Parallel Scan Tests", Ordered, func() {
+
+	// Ensure that the AWS config is created before each test; this could be set as a global variable
+	var cfg aws.Config
+	BeforeAll(func() {
+		cfg = testing.TestAWSConfig(context.Background(), "us-east-1", 9000)
+	})
+
+	// Create our test table definition that we'll use for all module tests
+	testTable := dynamodb.CreateTableInput{
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("id"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("sort_key"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("id"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String("sort_key"),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		TableName:   aws.String("TEST_TABLE"),
+		BillingMode: types.BillingModeProvisioned,
+		ProvisionedThroughput: &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(1),
+			WriteCapacityUnits: aws.Int64(1),
+		},
+		TableClass: types.TableClassStandard,
+	}
+
+	// Esnure that the table exists and contains our test data before the start of each test. We'll use a
+	// different partition key for each item so that the items are spread across the segments
+	BeforeEach(func() {
+		if err := testing.EnsureTableExists(context.Background(), cfg, &testTable); err != nil {
+			panic(err)
+		}
+
+		table := createTable[testObject](createTestConnection(cfg), "TEST_TABLE")
+		for i := 0; i < 50; i++ {
+			err := table.Put(context.Background(),
+				&testObject{ID: fmt.Sprintf("test_id_%d", i), SortKey: "test|sort|key", Data: i})
+			if err != nil {
+				panic(err)
+			}
+		}
+	})
+
+	// Ensure that the table is empty at the end of each test
+	AfterEach(func() {
+		if err := testing.EmptyTable(context.Background(), cfg, &testTable); err != nil {
+			panic(err)
+		}
+	})
+
+	// Test that, if the number of segments is invalid, then calling ParallelScan will return an error
+	It("ParallelScan - No segments - Error", func() {
+
+		// First, create our test database connection from our test config
+		conn := createTestConnection(cfg)
+
+		// Next, attempt to scan the table with no segments; this should fail
+		checkpoints, err := conn.ParallelScan(context.Background(),
+			&dynamodb.ScanInput{TableName: aws.String("TEST_TABLE")}, 0,
+			func(context.Context, int32, []map[string]types.AttributeValue) error { return nil })
+
+		// Finally, verify the failure
+		Expect(checkpoints).Should(BeNil())
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).TableName).Should(Equal("TEST_TABLE"))
+		Expect(err.(*Error).Message).Should(Equal("Parallel scan of TEST_TABLE requires at least one segment, " +
+			"but 0 were requested"))
+	})
+
+	// Test that, if the checkpoints don't match the number of segments, then calling ParallelScan will
+	// return an error
+	It("ParallelScan - Checkpoints invalid - Error", func() {
+
+		// First, create our test database connection from our test config
+		conn := createTestConnection(cfg)
+
+		// Next, attempt to scan the table with checkpoints from a scan with a different number of
+		// segments; this should fail
+		checkpoints, err := conn.ParallelScan(context.Background(),
+			&dynamodb.ScanInput{TableName: aws.String("TEST_TABLE")}, 2,
+			func(context.Context, int32, []map[string]types.AttributeValue) error { return nil },
+			WithCheckpoints{{Segment: 0, TotalSegments: 3}, {Segment: 1, TotalSegments: 3}})
+
+		// Finally, verify the failure
+		Expect(checkpoints).Should(BeNil())
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).Message).Should(Equal("Checkpoint 0 of 2 refers to segment 0 of 3 for TEST_TABLE"))
+	})
+
+	// Test that, if the table does not exist, then calling ParallelScan will return an error and checkpoints
+	// showing that no segment has finished
+	It("ParallelScan - Scan fails - Error", func() {
+
+		// First, create our test database connection from our test config
+		conn := createTestConnection(cfg)
+
+		// Next, attempt to scan a table that doesn't exist; this should fail
+		checkpoints, err := conn.ParallelScan(context.Background(),
+			&dynamodb.ScanInput{TableName: aws.String("FAKE_TABLE")}, 2,
+			func(context.Context, int32, []map[string]types.AttributeValue) error { return nil })
+
+		// Finally, verify the failure
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).TableName).Should(Equal("FAKE_TABLE"))
+		Expect(err.(*Error).Message).Should(MatchRegexp(`SCAN\[[01]/2\]\(0\) request to FAKE_TABLE in DynamoDB failed`))
+		Expect(checkpoints).Should(HaveLen(2))
+		for _, checkpoint := range checkpoints {
+			Expect(checkpoint.Done).Should(BeFalse())
+			Expect(checkpoint.Items).Should(BeZero())
+		}
+	})
+
+	// Test that, if no failure occurs, then calling ParallelScan will call the handler with every item in the
+	// table and report the progress of each segment
+	It("ParallelScan - No failures - All items handled", func() {
+
+		// First, create our test database connection from our test config
+		conn := createTestConnection(cfg)
+
+		// Next, attempt to scan the table with four segments; this should not fail
+		lock := new(sync.Mutex)
+		handled := make(map[string]int32)
+		progress := make([]ScanCheckpoint, 0)
+		checkpoints, err := conn.ParallelScan(context.Background(),
+			&dynamodb.ScanInput{TableName: aws.String("TEST_TABLE"), Limit: aws.Int32(5)}, 4,
+			SerializeSegmentHandler(func(_ context.Context, segment int32, items []map[string]types.AttributeValue) error {
+				for _, item := range items {
+					handled[item["id"].(*types.AttributeValueMemberS).Value] = segment
+				}
+
+				return nil
+			}), WithProgress(func(checkpoint ScanCheckpoint) {
+				lock.Lock()
+				defer lock.Unlock()
+				progress = append(progress, checkpoint)
+			}))
+
+		// Finally, verify that every item was handled and that each segment finished
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(handled).Should(HaveLen(50))
+		Expect(checkpoints).Should(HaveLen(4))
+		items, pages := 0, 0
+		for i, checkpoint := range checkpoints {
+			Expect(checkpoint.Segment).Should(Equal(int32(i)))
+			Expect(checkpoint.TotalSegments).Should(Equal(int32(4)))
+			Expect(checkpoint.Done).Should(BeTrue())
+			Expect(checkpoint.LastEvaluatedKey).Should(BeNil())
+			items += checkpoint.Items
+			pages += checkpoint.Pages
+		}
+
+		Expect(items).Should(Equal(50))
+		Expect(progress).Should(HaveLen(pages))
+	})
+
+	// Test that, if a segment fails, then the checkpoints returned by ParallelScan can be used to resume the
+	// scan so that every item is handled exactly once
+	It("ParallelScan - Segment fails - Resumable", func() {
+
+		// First, create our test database connection from our test config
+		conn := createTestConnection(cfg)
+
+		// Next, create a handler that will record every item it handles but fail the first time it is called
+		lock := new(sync.Mutex)
+		handled := make(map[string]int)
+		failed := false
+		handler := func(_ context.Context, _ int32, items []map[string]types.AttributeValue) error {
+			lock.Lock()
+			defer lock.Unlock()
+
+			if !failed {
+				failed = true
+				return fmt.Errorf("handler failed")
+			}
+
+			for _, item := range items {
+				handled[item["id"].(*types.AttributeValueMemberS).Value]++
+			}
+
+			return nil
+		}
+
+		// Now, attempt to scan the table; this should fail but the other segments should finish
+		input := dynamodb.ScanInput{TableName: aws.String("TEST_TABLE"), Limit: aws.Int32(5)}
+		checkpoints, err := conn.ParallelScan(context.Background(), &input, 4, handler, WithCancelOnError(false))
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(Equal("handler failed"))
+		Expect(checkpoints).Should(HaveLen(4))
+		Expect(len(handled)).Should(BeNumerically("<", 50))
+
+		// Finally, resume the scan from the checkpoints and verify that every item was handled exactly once
+		checkpoints, err = conn.ParallelScan(context.Background(), &input, 4, handler, WithCheckpoints(checkpoints))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(handled).Should(HaveLen(50))
+		for _, count := range handled {
+			Expect(count).Should(Equal(1))
+		}
+
+		for _, checkpoint := range checkpoints {
+			Expect(checkpoint.Done).Should(BeTrue())
+		}
+	})
+
+	// Test that calling ParallelScanEach will convert every item in the table to the type provided
+	It("ParallelScanEach - No failures - All items handled", func() {
+
+		// First, create our test database connection from our test config
+		conn := createTestConnection(cfg)
+
+		// Next, attempt to scan the table with three segments; this should not fail
+		lock := new(sync.Mutex)
+		results := make(map[int]*testObject)
+		_, err := ParallelScanEach(context.Background(), conn,
+			&dynamodb.ScanInput{TableName: aws.String("TEST_TABLE")}, 3,
+			func(_ context.Context, item *testObject) error {
+				lock.Lock()
+				defer lock.Unlock()
+				results[item.Data] = item
+				return nil
+			})
+
+		// Finally, verify that every item was converted
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(results).Should(HaveLen(50))
+		for i := 0; i < 50; i++ {
+			Expect(*results[i]).Should(Equal(testObject{
+				ID:      fmt.Sprintf("test_id_%d", i),
+				SortKey: "test|sort|key",
+				Data:    i,
+			}))
+		}
+	})
+})
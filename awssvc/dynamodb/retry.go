@@ -0,0 +1,118 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+// RetryAttempt describes a single attempt that was made to complete a request to DynamoDB
+type RetryAttempt struct {
+
+	// The name of the table, or tables, the request was made against
+	TableName string
+
+	// The verb describing the request that was made
+	Verb string
+
+	// The number of the attempt, starting from 1
+	Attempt int
+
+	// The amount of time the attempt took to complete
+	Duration time.Duration
+
+	// The error returned by the attempt, or nil if the attempt succeeded
+	Err error
+
+	// Whether or not the request will be retried after this attempt
+	Retry bool
+}
+
+// IRetryPolicy defines the functionality that determines which errors returned by DynamoDB will be retried by
+// a DatabaseConnection and allows each attempt to be recorded
+type IRetryPolicy interface {
+
+	// ShouldRetry returns true if the request that returned the error should be retried
+	ShouldRetry(err error) bool
+
+	// OnAttempt is called after each attempt to complete a request, regardless of whether or not it succeeded
+	OnAttempt(attempt *RetryAttempt)
+}
+
+// RetryPolicy is a retry policy that may be created from a classifier and a metrics function. If no classifier
+// is provided then IsRetryable will be used to classify errors and, if no metrics function is provided, then
+// attempts will not be recorded
+type RetryPolicy struct {
+
+	// A function that returns true if the request that returned the error should be retried
+	Classifier func(err error) bool
+
+	// A function that will be called with each attempt to complete a request
+	Metrics func(attempt *RetryAttempt)
+}
+
+// ShouldRetry returns true if the request that returned the error should be retried
+func (policy *RetryPolicy) ShouldRetry(err error) bool {
+	if policy.Classifier == nil {
+		return IsRetryable(err)
+	}
+
+	return policy.Classifier(err)
+}
+
+// OnAttempt records the attempt with the metrics function, if one was provided
+func (policy *RetryPolicy) OnAttempt(attempt *RetryAttempt) {
+	if policy.Metrics != nil {
+		policy.Metrics(attempt)
+	}
+}
+
+// IsRetryable is the default retry classifier, which returns true if the error may be resolved by waiting and
+// retrying the request. This will be the case for throughput, request limit and throttling errors, internal
+// server errors, transaction conflicts, transactions cancelled only by conflicts or throttling and transient
+// network errors such as connection resets or timeouts. Context cancellation is never retried
+func IsRetryable(err error) bool {
+
+	// First, if we have no error or the context was cancelled or timed out then we shouldn't retry
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	// Next, check for the DynamoDB exceptions that we know are retryable. For throughput, request limit or
+	// throttling exceptions, waiting a bit may allow the request to succeed. For internal server errors, since
+	// we're not sure of the cause, we'll wait to see if the service manages to fix itself. For transaction
+	// conflicts, waiting will allow the other transaction to finish
+	var cancelled *types.TransactionCanceledException
+	var apiErr smithy.APIError
+	switch {
+	case errors.As(err, new(*types.ProvisionedThroughputExceededException)),
+		errors.As(err, new(*types.RequestLimitExceeded)),
+		errors.As(err, new(*types.InternalServerError)),
+		errors.As(err, new(*types.TransactionConflictException)):
+		return true
+	case errors.As(err, &cancelled):
+		return isRetryableCancellation(cancelled)
+	case errors.As(err, &apiErr):
+		_, throttled := retry.DefaultThrottleErrorCodes[apiErr.ErrorCode()]
+		_, retryable := retry.DefaultRetryableErrorCodes[apiErr.ErrorCode()]
+		return throttled || retryable
+	}
+
+	// Finally, check if the error was caused by a transient network failure, which may be retried
+	return retry.RetryableConnectionError{}.IsErrorRetryable(err) == aws.TrueTernary
+}
+
+// Helper function that extracts a message describing an error for logging
+func retryMessage(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorMessage() != "" {
+		return apiErr.ErrorMessage()
+	}
+
+	return err.Error()
+}
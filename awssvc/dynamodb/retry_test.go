@@ -0,0 +1,148 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/utils"
+)
+
+var _ = Describe("Retry Tests", func() {
+
+	// Tests the conditions under which IsRetryable will classify an error as retryable
+	DescribeTable("IsRetryable - Conditions",
+		func(err error, retryable bool) {
+			Expect(IsRetryable(err)).Should(Equal(retryable))
+		},
+		Entry("Nil - False", nil, false),
+		Entry("Non-AWS error - False", errors.New("failed"), false),
+		Entry("Context cancelled - False", &smithy.OperationError{Err: context.Canceled}, false),
+		Entry("Context deadline exceeded - False", &smithy.OperationError{Err: context.DeadlineExceeded}, false),
+		Entry("ProvisionedThroughputExceededException - True",
+			&smithy.OperationError{Err: &types.ProvisionedThroughputExceededException{}}, true),
+		Entry("RequestLimitExceeded - True", &smithy.OperationError{Err: &types.RequestLimitExceeded{}}, true),
+		Entry("InternalServerError - True", &smithy.OperationError{Err: &types.InternalServerError{}}, true),
+		Entry("TransactionConflictException - True",
+			&smithy.OperationError{Err: &types.TransactionConflictException{}}, true),
+		Entry("ThrottlingException - True",
+			&smithy.OperationError{Err: &smithy.GenericAPIError{Code: "ThrottlingException"}}, true),
+		Entry("ResourceNotFoundException - False",
+			&smithy.OperationError{Err: &types.ResourceNotFoundException{}}, false),
+		Entry("ConditionalCheckFailedException - False",
+			&smithy.OperationError{Err: &types.ConditionalCheckFailedException{}}, false),
+		Entry("Connection refused - True", &smithy.OperationError{Err: &net.OpError{Op: "dial", Err: errors.New("refused")}}, true),
+		Entry("Connection reset - True", &smithy.OperationError{Err: errors.New("read: connection reset by peer")}, true))
+
+	// Test that, if the operation returns an error that is not from the AWS SDK, then doRetry will not panic
+	// and will not retry the operation
+	It("doRetry - Non-AWS error - Not retried", func() {
+
+		// First, create our test connection with a default retry policy
+		conn := createRetryConnection()
+
+		// Next, attempt to retry an operation that returns a generic error
+		count := 0
+		err := conn.doRetry(context.Background(), "TEST_TABLE", "GET", func() error {
+			count++
+			return errors.New("failed")
+		})
+
+		// Finally, verify that the operation was not retried and that the error was returned
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).Inner).Should(MatchError("failed"))
+		Expect(count).Should(Equal(1))
+	})
+
+	// Test that, if the context is cancelled, then doRetry will not retry the operation even if the error
+	// returned would otherwise be retried
+	It("doRetry - Context cancelled - Not retried", func() {
+
+		// First, create our test connection with a default retry policy and a context that we'll cancel
+		conn := createRetryConnection()
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// Next, attempt to retry an operation that cancels the context and returns a retryable error
+		count := 0
+		err := conn.doRetry(ctx, "TEST_TABLE", "GET", func() error {
+			count++
+			cancel()
+			return &smithy.OperationError{Err: &types.InternalServerError{Message: aws.String("failed")}}
+		})
+
+		// Finally, verify that the operation was not retried
+		Expect(err).Should(HaveOccurred())
+		Expect(count).Should(Equal(1))
+	})
+
+	// Test that, if the retry policy option is provided without a policy, then the default policy will be kept
+	// and doRetry will not panic
+	It("doRetry - No policy provided - Default used", func() {
+
+		// First, create our test connection with a retry policy option that has no policy
+		conn := createRetryConnection(WithRetryPolicy{})
+
+		// Next, attempt to retry an operation that fails with a generic error
+		count := 0
+		err := conn.doRetry(context.Background(), "TEST_TABLE", "GET", func() error {
+			count++
+			return errors.New("failed")
+		})
+
+		// Finally, verify that the operation was not retried and that the error was returned
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).Inner).Should(MatchError("failed"))
+		Expect(count).Should(Equal(1))
+	})
+
+	// Test that, if a custom retry policy is provided, then doRetry will use it to classify errors and will
+	// record each attempt with it
+	It("doRetry - Custom policy - Used", func() {
+
+		// First, create our test connection with a retry policy that retries every error and records attempts
+		attempts := make([]*RetryAttempt, 0)
+		conn := createRetryConnection(WithRetryPolicy{Policy: &RetryPolicy{
+			Classifier: func(err error) bool { return true },
+			Metrics:    func(attempt *RetryAttempt) { attempts = append(attempts, attempt) },
+		}})
+
+		// Next, attempt to retry an operation that fails twice with a generic error before succeeding
+		inner := errors.New("failed")
+		err := conn.doRetry(context.Background(), "TEST_TABLE", "GET", func() error {
+			if len(attempts) < 2 {
+				return inner
+			}
+
+			return nil
+		})
+
+		// Finally, verify the attempts that were recorded
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(attempts).Should(HaveLen(3))
+		for i, attempt := range attempts {
+			Expect(attempt.TableName).Should(Equal("TEST_TABLE"))
+			Expect(attempt.Verb).Should(Equal("GET"))
+			Expect(attempt.Attempt).Should(Equal(i + 1))
+		}
+
+		Expect(attempts[0].Err).Should(Equal(inner))
+		Expect(attempts[0].Retry).Should(BeTrue())
+		Expect(attempts[1].Err).Should(Equal(inner))
+		Expect(attempts[1].Retry).Should(BeTrue())
+		Expect(attempts[2].Err).ShouldNot(HaveOccurred())
+		Expect(attempts[2].Retry).Should(BeFalse())
+	})
+})
+
+// Helper function that creates a test connection without a client, with a short backoff and the options provided
+func createRetryConnection(opts ...IDynamoDBOption) *DatabaseConnection {
+	logger := utils.NewLogger("testd", "test")
+	logger.Discard()
+	return FromClient(nil, logger, append([]IDynamoDBOption{WithBackoffStart(1),
+		WithBackoffEnd(5), WithBackoffMaxElapsed(1000)}, opts...)...)
+}
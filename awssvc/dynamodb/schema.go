@@ -0,0 +1,596 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cenkalti/backoff/v4"
+)
+
+// The options that may be set on the dynamo tag of a field to describe the schema of a table. A field tagged
+// with `dynamo:"pk"` or `dynamo:"sk"` will be the partition or sort key of the table, respectively. A field
+// tagged with `dynamo:"gsi=name"` will be the partition key of the global secondary index with that name and
+// a field tagged with `dynamo:"gsi=name:sk"` will be its sort key. A field tagged with `dynamo:"lsi=name"`
+// will be the sort key of the local secondary index with that name. Finally, a field tagged with
+// `dynamo:"ttl"` will be used as the time-to-live attribute of the table. Multiple options may be combined
+// on a single field by separating them with commas, e.g. `dynamo:"sk,gsi=by_date"`
+const (
+	partitionKeyTag = "pk"
+	sortKeyTag      = "sk"
+	gsiTag          = "gsi"
+	lsiTag          = "lsi"
+	ttlTag          = "ttl"
+)
+
+// TableSchema describes a DynamoDB table derived from the dynamo tags on the fields of a struct
+type TableSchema struct {
+
+	// The request that will be used to create the table. This may be modified before the table is created,
+	// for example to set the billing mode, provisioned throughput or stream settings. By default, the table
+	// will use on-demand billing and all indexes will project all attributes
+	Input *dynamodb.CreateTableInput
+
+	// The name of the partition key attribute of the table
+	PartitionKey string
+
+	// The name of the sort key attribute of the table. This will be empty if the table has no sort key
+	SortKey string
+
+	// The name of the time-to-live attribute of the table. This will be empty if the table has no TTL
+	TTLAttribute string
+
+	// The name of the version attribute used for optimistic locking. This will be empty if items stored in
+	// the table are not versioned
+	VersionAttribute string
+}
+
+// Helper type that collects the key schema of a table or index while the fields of a struct are being searched
+type schemaIndex struct {
+	partitionKey string
+	sortKey      string
+}
+
+// Helper type that collects the roles of the fields of a struct while it is being searched. The names of the
+// attributes and indexes are recorded in the order they were found so that the derived schema is deterministic
+type schemaBuilder struct {
+	schema     *TableSchema
+	table      schemaIndex
+	globals    map[string]*schemaIndex
+	locals     map[string]*schemaIndex
+	indexes    []string
+	attrTypes  map[string]types.ScalarAttributeType
+	attributes []string
+}
+
+// DeriveTableSchema creates a table schema from the dynamo tags on the fields of an item, which should be a
+// struct or a pointer to a struct. The names of the attributes will be derived from the tag key associated
+// with the connection, or the name of the field if it has no such tag. The types of the key attributes will
+// be derived from the types of the fields: strings and times will be S, numbers will be N and byte slices
+// will be B. Fields in embedded structs will also be searched. If the item has no partition key, has more
+// than one field with the same role or has a key field of an unsupported type then an error will be returned
+func (conn *DatabaseConnection) DeriveTableSchema(tableName string, item interface{}) (*TableSchema, error) {
+
+	// First, ensure that the item is a struct or a pointer to one
+	itemType := reflect.TypeOf(item)
+	for itemType != nil && itemType.Kind() == reflect.Pointer {
+		itemType = itemType.Elem()
+	}
+
+	if itemType == nil || itemType.Kind() != reflect.Struct {
+		return nil, conn.NewError(nil, tableName, "Table schema must be derived from a struct but was %T", item)
+	}
+
+	// Next, search the struct for all the fields that have a role in the schema of the table; if this fails
+	// then return an error
+	schema := TableSchema{Input: &dynamodb.CreateTableInput{
+		TableName:   aws.String(tableName),
+		BillingMode: types.BillingModePayPerRequest,
+	}}
+
+	builder := schemaBuilder{
+		schema:    &schema,
+		globals:   make(map[string]*schemaIndex),
+		locals:    make(map[string]*schemaIndex),
+		attrTypes: make(map[string]types.ScalarAttributeType),
+	}
+
+	if err := conn.searchSchema(itemType, &builder); err != nil {
+		return nil, conn.NewError(err, tableName, "Failed to derive table schema from %T", item)
+	} else if builder.table.partitionKey == "" {
+		return nil, conn.NewError(nil, tableName, "No field on %T was tagged with `%s:\"%s\"`",
+			item, dynamoTag, partitionKeyTag)
+	}
+
+	// Now, set the key schema of the table and each of its indexes on the input
+	table := &builder.table
+	schema.PartitionKey, schema.SortKey = table.partitionKey, table.sortKey
+	schema.Input.KeySchema = table.keySchema()
+	for _, name := range builder.indexes {
+		if index, ok := builder.globals[name]; ok {
+			if index.partitionKey == "" {
+				return nil, conn.NewError(nil, tableName, "Global secondary index %s on %T has no partition key",
+					name, item)
+			}
+
+			schema.Input.GlobalSecondaryIndexes = append(schema.Input.GlobalSecondaryIndexes,
+				types.GlobalSecondaryIndex{
+					IndexName:  aws.String(name),
+					KeySchema:  index.keySchema(),
+					Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+				})
+		} else if index, ok := builder.locals[name]; ok {
+			if table.sortKey == "" {
+				return nil, conn.NewError(nil, tableName, "Local secondary index %s on %T requires the table to "+
+					"have a sort key", name, item)
+			}
+
+			index.partitionKey = table.partitionKey
+			schema.Input.LocalSecondaryIndexes = append(schema.Input.LocalSecondaryIndexes,
+				types.LocalSecondaryIndex{
+					IndexName:  aws.String(name),
+					KeySchema:  index.keySchema(),
+					Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+				})
+		}
+	}
+
+	// Finally, add an attribute definition for every attribute used in a key schema
+	for _, name := range builder.attributes {
+		schema.Input.AttributeDefinitions = append(schema.Input.AttributeDefinitions,
+			types.AttributeDefinition{AttributeName: aws.String(name), AttributeType: builder.attrTypes[name]})
+	}
+
+	return &schema, nil
+}
+
+// EnsureTable ensures that the table described by the schema exists and matches it. If the table does not
+// exist then it will be created. Otherwise, global secondary indexes in the schema that are missing from the
+// table will be created and time-to-live will be enabled on the attribute in the schema. Indexes on the table
+// that are not in the schema or whose key schema differs will be logged and left in place unless the
+// WithDropUnknownIndexes option is provided, in which case they will be deleted and, if they're in the schema,
+// recreated. Since DynamoDB won't allow the time-to-live attribute to be changed while time-to-live is enabled,
+// if the table uses a different time-to-live attribute from the schema then time-to-live will be disabled and
+// an error will be returned; EnsureTable should be called again once DynamoDB allows time-to-live to be
+// re-enabled, which may take up to an hour. Note that local secondary indexes, billing mode and key schema
+// cannot be changed on an existing table so they will not be reconciled. This function will wait for the table
+// and all its indexes to become ACTIVE before each change is made and before it returns the final description
+// of the table
+func (conn *DatabaseConnection) EnsureTable(ctx context.Context, schema *TableSchema,
+	opts ...IEnsureTableOption) (*types.TableDescription, error) {
+	tableName := aws.ToString(schema.Input.TableName)
+	options := newEnsureTableOptions(opts...)
+
+	// First, attempt to describe the table. If it doesn't exist then create it; if this fails then return
+	// an error. Either way, wait for the table to become active
+	description, err := conn.describeTable(ctx, tableName)
+	if err != nil {
+		return nil, err
+	} else if description == nil {
+		if err := conn.doRetry(ctx, tableName, "CREATE TABLE", func() error {
+			_, inner := conn.db.CreateTable(ctx, withIndexThroughput(schema.Input))
+			return inner
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if description, err = conn.waitForActive(ctx, tableName); err != nil {
+		return nil, err
+	}
+
+	// Next, find any global secondary indexes on the table that aren't in the schema or that have a different
+	// key schema from the one in the schema. If we've been asked to drop these then delete them; otherwise,
+	// log the difference and leave them in place
+	desired := make(map[string]types.GlobalSecondaryIndex)
+	for _, index := range schema.Input.GlobalSecondaryIndexes {
+		desired[aws.ToString(index.IndexName)] = index
+	}
+
+	existing := make(map[string]bool)
+	for _, index := range description.GlobalSecondaryIndexes {
+		name := aws.ToString(index.IndexName)
+		wanted, ok := desired[name]
+		if ok && sameKeySchema(wanted.KeySchema, index.KeySchema) {
+			existing[name] = true
+			continue
+		} else if !options.dropUnknownIndexes {
+			conn.logger.Log("Index %s on %s does not match the schema and will not be dropped", name, tableName)
+			existing[name] = true
+			continue
+		}
+
+		if description, err = conn.updateIndexes(ctx, tableName, &dynamodb.UpdateTableInput{
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+				{Delete: &types.DeleteGlobalSecondaryIndexAction{IndexName: index.IndexName}},
+			},
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Now, create any global secondary indexes in the schema that don't exist on the table. DynamoDB only
+	// allows one index to be created at a time so we'll wait for each to become active before continuing
+	for _, index := range withIndexThroughput(schema.Input).GlobalSecondaryIndexes {
+		if existing[aws.ToString(index.IndexName)] {
+			continue
+		}
+
+		if description, err = conn.updateIndexes(ctx, tableName, &dynamodb.UpdateTableInput{
+			AttributeDefinitions: attributeDefinitions(schema.Input.AttributeDefinitions, index.KeySchema),
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+				{Create: &types.CreateGlobalSecondaryIndexAction{
+					IndexName:             index.IndexName,
+					KeySchema:             index.KeySchema,
+					Projection:            index.Projection,
+					ProvisionedThroughput: index.ProvisionedThroughput,
+				}},
+			},
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Finally, update the time-to-live settings on the table to match the schema
+	if err := conn.reconcileTTL(ctx, tableName, schema.TTLAttribute); err != nil {
+		return nil, err
+	}
+
+	return description, nil
+}
+
+// Schema derives the schema of the table from the dynamo tags on the fields of the item type associated
+// with this Table. See DatabaseConnection.DeriveTableSchema for more details
+func (table *Table[T]) Schema() (*TableSchema, error) {
+	return table.conn.DeriveTableSchema(table.name, new(T))
+}
+
+// Helper function that searches a struct type, and any structs embedded in it, for the fields that have a role
+// in the schema of the table and records them on the builder
+func (conn *DatabaseConnection) searchSchema(structType reflect.Type, builder *schemaBuilder) error {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		// First, if the field is an embedded struct without a name of its own then search it for fields
+		name, hasName := field.Tag.Lookup(conn.tagKey)
+		name = strings.Split(name, ",")[0]
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			if err := conn.searchSchema(field.Type, builder); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		// Next, get the options on the field's dynamo tag; if it has none then skip it
+		options := dynamoOptions(field)
+		if len(options) == 0 {
+			continue
+		} else if !field.IsExported() || (hasName && name == "-") {
+			return fmt.Errorf("field %s must be exported and written to DynamoDB", field.Name)
+		}
+
+		// Now, derive the name of the attribute from the tag or the field name
+		if name == "" {
+			name = field.Name
+		}
+
+		// Finally, iterate over each of the options and record the role of the attribute associated with each.
+		// If the attribute is part of a key schema then ensure that its type is supported
+		for _, option := range options {
+			kind, value, _ := strings.Cut(option, "=")
+			var err error
+			isKey := true
+			switch kind {
+			case partitionKeyTag:
+				err = setSchemaKey(&builder.table.partitionKey, name, "partition key of the table")
+			case sortKeyTag:
+				err = setSchemaKey(&builder.table.sortKey, name, "sort key of the table")
+			case gsiTag, lsiTag:
+				err = builder.addIndexKey(kind, value, name)
+			case ttlTag:
+				if attrType, ok := scalarType(field.Type); !ok || attrType != types.ScalarAttributeTypeN {
+					return fmt.Errorf("time-to-live field %s must be a number but was %s", field.Name, field.Type)
+				}
+
+				err, isKey = setSchemaKey(&builder.schema.TTLAttribute, name, "time-to-live attribute"), false
+			case versionTag:
+				err, isKey = setSchemaKey(&builder.schema.VersionAttribute, name, "version attribute"), false
+			default:
+				return fmt.Errorf("field %s has unknown %s tag option %q", field.Name, dynamoTag, option)
+			}
+
+			if err != nil {
+				return err
+			} else if !isKey {
+				continue
+			}
+
+			attrType, ok := scalarType(field.Type)
+			if !ok {
+				return fmt.Errorf("key field %s must be a string, number or binary but was %s",
+					field.Name, field.Type)
+			}
+
+			if _, ok := builder.attrTypes[name]; !ok {
+				builder.attributes = append(builder.attributes, name)
+			}
+
+			builder.attrTypes[name] = attrType
+		}
+	}
+
+	return nil
+}
+
+// Helper function that records the attribute as a key of a global or local secondary index
+func (builder *schemaBuilder) addIndexKey(kind string, option string, name string) error {
+
+	// First, split the index name from its role and ensure that both are valid
+	indexName, role, _ := strings.Cut(option, ":")
+	if indexName == "" || (role != "" && (kind == lsiTag || role != sortKeyTag)) {
+		return fmt.Errorf("invalid %s option %q on attribute %s", kind, option, name)
+	}
+
+	// Next, get the index from the appropriate collection, creating it if it doesn't exist yet. An index
+	// name may only be used by one type of index
+	collection, other := builder.globals, builder.locals
+	if kind == lsiTag {
+		collection, other = builder.locals, builder.globals
+	}
+
+	if _, ok := other[indexName]; ok {
+		return fmt.Errorf("index %s was declared as both a global and local secondary index", indexName)
+	}
+
+	index, ok := collection[indexName]
+	if !ok {
+		index = new(schemaIndex)
+		collection[indexName] = index
+		builder.indexes = append(builder.indexes, indexName)
+	}
+
+	// Finally, set the attribute as the partition or sort key of the index
+	if kind == lsiTag || role == sortKeyTag {
+		return setSchemaKey(&index.sortKey, name, "sort key of index "+indexName)
+	}
+
+	return setSchemaKey(&index.partitionKey, name, "partition key of index "+indexName)
+}
+
+// Helper function that sets the name of an attribute with a role in the schema, returning an error if another
+// attribute has already been assigned that role
+func setSchemaKey(target *string, name string, role string) error {
+	if *target != "" && *target != name {
+		return fmt.Errorf("both %s and %s were tagged as the %s", *target, name, role)
+	}
+
+	*target = name
+	return nil
+}
+
+// Helper function that creates the key schema associated with a table or index
+func (index *schemaIndex) keySchema() []types.KeySchemaElement {
+	schema := []types.KeySchemaElement{{AttributeName: aws.String(index.partitionKey), KeyType: types.KeyTypeHash}}
+	if index.sortKey != "" {
+		schema = append(schema, types.KeySchemaElement{AttributeName: aws.String(index.sortKey),
+			KeyType: types.KeyTypeRange})
+	}
+
+	return schema
+}
+
+// Helper function that determines the DynamoDB scalar attribute type associated with a Go type. Strings and
+// times will be S, numbers will be N and byte slices or arrays will be B. If the type is not supported as
+// a key attribute then false will be returned
+func scalarType(fieldType reflect.Type) (types.ScalarAttributeType, bool) {
+	for fieldType.Kind() == reflect.Pointer {
+		fieldType = fieldType.Elem()
+	}
+
+	if fieldType == reflect.TypeOf(time.Time{}) {
+		return types.ScalarAttributeTypeS, true
+	}
+
+	switch fieldType.Kind() {
+	case reflect.String:
+		return types.ScalarAttributeTypeS, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return types.ScalarAttributeTypeN, true
+	case reflect.Slice, reflect.Array:
+		if fieldType.Elem().Kind() == reflect.Uint8 {
+			return types.ScalarAttributeTypeB, true
+		}
+	}
+
+	return "", false
+}
+
+// Helper function that describes a table, returning nil if the table does not exist
+func (conn *DatabaseConnection) describeTable(ctx context.Context, tableName string) (*types.TableDescription, error) {
+	var output *dynamodb.DescribeTableOutput
+	err := conn.doRetry(ctx, tableName, "DESCRIBE TABLE", func() error {
+		var inner error
+		output, inner = conn.db.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+		return inner
+	})
+
+	var notFound *types.ResourceNotFoundException
+	if casted, ok := err.(*Error); ok && errors.As(casted.Inner, &notFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return output.Table, nil
+}
+
+// Helper function that waits for a table and all its global secondary indexes to become ACTIVE, polling the
+// table's status with the backoff associated with the connection. The final description will be returned
+func (conn *DatabaseConnection) waitForActive(ctx context.Context, tableName string) (*types.TableDescription, error) {
+	var description *types.TableDescription
+	err := backoff.Retry(func() error {
+
+		// First, describe the table; if this fails or the table doesn't exist then we can't continue
+		var err error
+		description, err = conn.describeTable(ctx, tableName)
+		if err != nil {
+			return backoff.Permanent(err)
+		} else if description == nil {
+			return backoff.Permanent(conn.NewError(nil, tableName, "Table %s does not exist", tableName))
+		}
+
+		// Next, check the status of the table and each of its indexes; if any aren't active then try again
+		if description.TableStatus != types.TableStatusActive {
+			return fmt.Errorf("table %s has a status of %s", tableName, description.TableStatus)
+		}
+
+		for _, index := range description.GlobalSecondaryIndexes {
+			if index.IndexStatus != types.IndexStatusActive {
+				return fmt.Errorf("index %s has a status of %s", aws.ToString(index.IndexName), index.IndexStatus)
+			}
+		}
+
+		return nil
+	}, backoff.WithContext(conn.createExponentialBackoff(), ctx))
+
+	// If the table never became active then return an error; otherwise, return the description
+	if casted, ok := err.(*Error); ok {
+		return nil, casted
+	} else if err != nil {
+		return nil, conn.NewError(err, tableName, "Table %s did not become ACTIVE", tableName)
+	}
+
+	return description, nil
+}
+
+// Helper function that makes an update to the global secondary indexes on a table and waits for the table to
+// become active again
+func (conn *DatabaseConnection) updateIndexes(ctx context.Context, tableName string,
+	input *dynamodb.UpdateTableInput) (*types.TableDescription, error) {
+	input.TableName = aws.String(tableName)
+	if err := conn.doRetry(ctx, tableName, "UPDATE TABLE", func() error {
+		_, inner := conn.db.UpdateTable(ctx, input)
+		return inner
+	}); err != nil {
+		return nil, err
+	}
+
+	return conn.waitForActive(ctx, tableName)
+}
+
+// Helper function that updates the time-to-live settings on a table so that the attribute provided is used
+// as the time-to-live attribute. If the attribute is empty then time-to-live will be disabled. If time-to-live
+// is enabled for a different attribute then it will be disabled and an error will be returned
+func (conn *DatabaseConnection) reconcileTTL(ctx context.Context, tableName string, attribute string) error {
+
+	// First, get the current time-to-live settings on the table; if this fails then return an error
+	var output *dynamodb.DescribeTimeToLiveOutput
+	if err := conn.doRetry(ctx, tableName, "DESCRIBE TTL", func() error {
+		var inner error
+		output, inner = conn.db.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{
+			TableName: aws.String(tableName),
+		})
+
+		return inner
+	}); err != nil {
+		return err
+	}
+
+	// Next, determine which attribute is currently being used for time-to-live, if any
+	current := ""
+	if ttl := output.TimeToLiveDescription; ttl != nil && (ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabled ||
+		ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabling) {
+		current = aws.ToString(ttl.AttributeName)
+	}
+
+	// Now, if the current settings already match then there's nothing to do. Otherwise, if time-to-live is
+	// enabled then disable it. Since DynamoDB won't allow time-to-live to be re-enabled until it has finished
+	// disabling it, return an error if we need it on a different attribute
+	if current == attribute {
+		return nil
+	} else if current != "" {
+		if err := conn.updateTTL(ctx, tableName, current, false); err != nil {
+			return err
+		} else if attribute != "" {
+			return conn.NewError(nil, tableName, "Time-to-live on %s was disabled for attribute %q so it can "+
+				"be enabled for attribute %q; ensure the table again once DynamoDB has finished disabling it",
+				tableName, current, attribute)
+		}
+
+		return nil
+	}
+
+	// Finally, enable time-to-live on the new attribute
+	return conn.updateTTL(ctx, tableName, attribute, true)
+}
+
+// Helper function that enables or disables time-to-live on a table for the attribute provided
+func (conn *DatabaseConnection) updateTTL(ctx context.Context, tableName string, attribute string, enabled bool) error {
+	return conn.doRetry(ctx, tableName, "UPDATE TTL", func() error {
+		_, inner := conn.db.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+			TableName: aws.String(tableName),
+			TimeToLiveSpecification: &types.TimeToLiveSpecification{
+				AttributeName: aws.String(attribute),
+				Enabled:       aws.Bool(enabled),
+			},
+		})
+
+		return inner
+	})
+}
+
+// Helper function that returns a copy of the create-table request where, if the table uses provisioned
+// billing, every global secondary index without provisioned throughput uses the throughput of the table
+func withIndexThroughput(input *dynamodb.CreateTableInput) *dynamodb.CreateTableInput {
+	if input.BillingMode != types.BillingModeProvisioned || input.ProvisionedThroughput == nil {
+		return input
+	}
+
+	copied := *input
+	copied.GlobalSecondaryIndexes = append([]types.GlobalSecondaryIndex{}, input.GlobalSecondaryIndexes...)
+	for i, index := range copied.GlobalSecondaryIndexes {
+		if index.ProvisionedThroughput == nil {
+			copied.GlobalSecondaryIndexes[i].ProvisionedThroughput = input.ProvisionedThroughput
+		}
+	}
+
+	return &copied
+}
+
+// Helper function that returns the attribute definitions for the attributes in the key schema provided
+func attributeDefinitions(definitions []types.AttributeDefinition,
+	schema []types.KeySchemaElement) []types.AttributeDefinition {
+	result := make([]types.AttributeDefinition, 0, len(schema))
+	for _, element := range schema {
+		for _, definition := range definitions {
+			if aws.ToString(definition.AttributeName) == aws.ToString(element.AttributeName) {
+				result = append(result, definition)
+			}
+		}
+	}
+
+	return result
+}
+
+// Helper function that determines whether two key schemas are the same
+func sameKeySchema(left []types.KeySchemaElement, right []types.KeySchemaElement) bool {
+	if len(left) != len(right) {
+		return false
+	}
+
+	for i := range left {
+		if aws.ToString(left[i].AttributeName) != aws.ToString(right[i].AttributeName) || left[i].KeyType != right[i].KeyType {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,301 @@
+package dynamodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/awssvc/testing"
+	"github.com/xefino/goutils/utils"
+)
+
+var _ = Describe("Table Schema Tests", func() {
+
+	// Tests that DeriveTableSchema will create the table definition from the dynamo tags on a struct
+	It("DeriveTableSchema - Works", func() {
+
+		// First, create our test connection
+		conn := createSchemaConnection(nil)
+
+		// Next, attempt to derive the schema from our test object; this should not fail
+		schema, err := conn.DeriveTableSchema("TEST_TABLE", new(schemaObject))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify the schema that was derived
+		Expect(schema.PartitionKey).Should(Equal("id"))
+		Expect(schema.SortKey).Should(Equal("sort_key"))
+		Expect(schema.TTLAttribute).Should(Equal("expires"))
+		Expect(schema.VersionAttribute).Should(Equal("version"))
+		Expect(schema.Input).Should(Equal(&dynamodb.CreateTableInput{
+			TableName:   aws.String("TEST_TABLE"),
+			BillingMode: types.BillingModePayPerRequest,
+			AttributeDefinitions: []types.AttributeDefinition{
+				{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("sort_key"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("owner"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("created"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("data"), AttributeType: types.ScalarAttributeTypeN},
+			},
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("sort_key"), KeyType: types.KeyTypeRange},
+			},
+			GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+				{
+					IndexName: aws.String("owner_index"),
+					KeySchema: []types.KeySchemaElement{
+						{AttributeName: aws.String("owner"), KeyType: types.KeyTypeHash},
+						{AttributeName: aws.String("created"), KeyType: types.KeyTypeRange},
+					},
+					Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+				},
+			},
+			LocalSecondaryIndexes: []types.LocalSecondaryIndex{
+				{
+					IndexName: aws.String("data_index"),
+					KeySchema: []types.KeySchemaElement{
+						{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash},
+						{AttributeName: aws.String("data"), KeyType: types.KeyTypeRange},
+					},
+					Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+				},
+			},
+		}))
+	})
+
+	// Tests the conditions under which DeriveTableSchema will fail
+	DescribeTable("DeriveTableSchema - Failures",
+		func(item interface{}, message string) {
+			conn := createSchemaConnection(nil)
+			schema, err := conn.DeriveTableSchema("TEST_TABLE", item)
+			Expect(schema).Should(BeNil())
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(ContainSubstring(message))
+		},
+		Entry("Not a struct - Error", 42, "Table schema must be derived from a struct but was int"),
+		Entry("No partition key - Error", &struct {
+			ID string `json:"id" dynamo:"sk"`
+		}{}, "was tagged with `dynamo:\"pk\"`"),
+		Entry("Duplicate partition key - Error", &struct {
+			ID    string `json:"id" dynamo:"pk"`
+			Other string `json:"other" dynamo:"pk"`
+		}{}, "both id and other were tagged as the partition key of the table"),
+		Entry("Unsupported key type - Error", &struct {
+			ID []string `json:"id" dynamo:"pk"`
+		}{}, "key field ID must be a string, number or binary but was []string"),
+		Entry("String TTL - Error", &struct {
+			ID      string `json:"id" dynamo:"pk"`
+			Expires string `json:"expires" dynamo:"ttl"`
+		}{}, "time-to-live field Expires must be a number but was string"),
+		Entry("Unknown option - Error", &struct {
+			ID string `json:"id" dynamo:"pk,hash"`
+		}{}, "field ID has unknown dynamo tag option \"hash\""),
+		Entry("GSI without partition key - Error", &struct {
+			ID   string `json:"id" dynamo:"pk"`
+			Date string `json:"date" dynamo:"gsi=by_date:sk"`
+		}{}, "Global secondary index by_date on"),
+		Entry("LSI without sort key - Error", &struct {
+			ID   string `json:"id" dynamo:"pk"`
+			Date string `json:"date" dynamo:"lsi=by_date"`
+		}{}, "Local secondary index by_date on"))
+
+	// Tests that EnsureTable will create the table if it does not exist, with the time-to-live settings
+	// described by the schema
+	It("EnsureTable - Not exists - Created", func() {
+
+		// First, create our test connection and derive the schema from our test object
+		fake := testing.NewFakeDynamoDB()
+		conn := createSchemaConnection(fake)
+		schema, err := conn.DeriveTableSchema("TEST_TABLE", new(schemaObject))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, ensure that the table exists; this should not fail
+		description, err := conn.EnsureTable(context.Background(), schema)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that the table was created with its indexes and time-to-live settings
+		Expect(description.TableStatus).Should(Equal(types.TableStatusActive))
+		Expect(description.GlobalSecondaryIndexes).Should(HaveLen(1))
+		Expect(*description.GlobalSecondaryIndexes[0].IndexName).Should(Equal("owner_index"))
+		Expect(description.LocalSecondaryIndexes).Should(HaveLen(1))
+
+		ttl, err := fake.DescribeTimeToLive(context.Background(),
+			&dynamodb.DescribeTimeToLiveInput{TableName: aws.String("TEST_TABLE")})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ttl.TimeToLiveDescription.TimeToLiveStatus).Should(Equal(types.TimeToLiveStatusEnabled))
+		Expect(*ttl.TimeToLiveDescription.AttributeName).Should(Equal("expires"))
+	})
+
+	// Tests that EnsureTable will create the global secondary indexes and time-to-live settings in the schema
+	// on an existing table, leaving indexes that aren't in the schema in place
+	It("EnsureTable - Exists - Reconciled", func() {
+
+		// First, create our test connection and a table with an index that isn't in the schema of our test object
+		fake := testing.NewFakeDynamoDB()
+		conn := createSchemaConnection(fake)
+		createLegacyTable(fake)
+
+		// Next, derive the schema from our test object and ensure the table matches it; this should not fail
+		schema, err := conn.DeriveTableSchema("TEST_TABLE", new(schemaObject))
+		Expect(err).ShouldNot(HaveOccurred())
+		description, err := conn.EnsureTable(context.Background(), schema)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Now, verify that the index in the schema was created and that the legacy index was not deleted
+		Expect(description.GlobalSecondaryIndexes).Should(HaveLen(2))
+		Expect(*description.GlobalSecondaryIndexes[0].IndexName).Should(Equal("legacy_index"))
+		Expect(*description.GlobalSecondaryIndexes[1].IndexName).Should(Equal("owner_index"))
+		Expect(fake.Calls("CreateTable")).Should(Equal(1))
+		Expect(fake.Calls("UpdateTable")).Should(Equal(1))
+
+		// Finally, verify that time-to-live was enabled on the attribute in the schema
+		ttl, err := fake.DescribeTimeToLive(context.Background(),
+			&dynamodb.DescribeTimeToLiveInput{TableName: aws.String("TEST_TABLE")})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(*ttl.TimeToLiveDescription.AttributeName).Should(Equal("expires"))
+		Expect(fake.Calls("UpdateTimeToLive")).Should(Equal(1))
+	})
+
+	// Tests that, if the WithDropUnknownIndexes option is provided, then EnsureTable will delete indexes on the
+	// table that aren't in the schema
+	It("EnsureTable - WithDropUnknownIndexes - Dropped", func() {
+
+		// First, create our test connection and a table with an index that isn't in the schema of our test object
+		fake := testing.NewFakeDynamoDB()
+		conn := createSchemaConnection(fake)
+		createLegacyTable(fake)
+
+		// Next, derive the schema from our test object and ensure the table matches it, dropping unknown
+		// indexes; this should not fail
+		schema, err := conn.DeriveTableSchema("TEST_TABLE", new(schemaObject))
+		Expect(err).ShouldNot(HaveOccurred())
+		description, err := conn.EnsureTable(context.Background(), schema, WithDropUnknownIndexes(true))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that the legacy index was replaced by the one in the schema
+		Expect(description.GlobalSecondaryIndexes).Should(HaveLen(1))
+		Expect(*description.GlobalSecondaryIndexes[0].IndexName).Should(Equal("owner_index"))
+		Expect(fake.Calls("UpdateTable")).Should(Equal(2))
+	})
+
+	// Tests that, if time-to-live is enabled on the table for a different attribute from the one in the schema,
+	// then EnsureTable will disable it and return an error, only enabling it on the new attribute when the table
+	// is ensured again
+	It("EnsureTable - TTL attribute changed - Disabled, then enabled", func() {
+
+		// First, create our test connection and a table with time-to-live enabled on an attribute that isn't in
+		// the schema of our test object
+		fake := testing.NewFakeDynamoDB()
+		conn := createSchemaConnection(fake)
+		createLegacyTable(fake)
+		_, err := fake.UpdateTimeToLive(context.Background(), &dynamodb.UpdateTimeToLiveInput{
+			TableName: aws.String("TEST_TABLE"),
+			TimeToLiveSpecification: &types.TimeToLiveSpecification{
+				AttributeName: aws.String("legacy_expiry"),
+				Enabled:       aws.Bool(true),
+			},
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, derive the schema from our test object and ensure the table matches it; this should fail
+		schema, err := conn.DeriveTableSchema("TEST_TABLE", new(schemaObject))
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = conn.EnsureTable(context.Background(), schema)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(HaveSuffix("Time-to-live on TEST_TABLE was disabled for attribute " +
+			"\"legacy_expiry\" so it can be enabled for attribute \"expires\"; ensure the table again once " +
+			"DynamoDB has finished disabling it."))
+
+		// Now, verify that time-to-live was disabled but not enabled on the new attribute
+		ttl, err := fake.DescribeTimeToLive(context.Background(),
+			&dynamodb.DescribeTimeToLiveInput{TableName: aws.String("TEST_TABLE")})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ttl.TimeToLiveDescription.TimeToLiveStatus).Should(Equal(types.TimeToLiveStatusDisabled))
+		Expect(fake.Calls("UpdateTimeToLive")).Should(Equal(2))
+
+		// Finally, ensure the table again and verify that time-to-live was enabled on the new attribute
+		_, err = conn.EnsureTable(context.Background(), schema)
+		Expect(err).ShouldNot(HaveOccurred())
+		ttl, err = fake.DescribeTimeToLive(context.Background(),
+			&dynamodb.DescribeTimeToLiveInput{TableName: aws.String("TEST_TABLE")})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ttl.TimeToLiveDescription.TimeToLiveStatus).Should(Equal(types.TimeToLiveStatusEnabled))
+		Expect(*ttl.TimeToLiveDescription.AttributeName).Should(Equal("expires"))
+		Expect(fake.Calls("UpdateTimeToLive")).Should(Equal(3))
+	})
+
+	// Tests that, if the table already matches the schema, then EnsureTable will not modify it
+	It("EnsureTable - Matches - Unchanged", func() {
+
+		// First, create our test connection and derive the schema from our test object
+		fake := testing.NewFakeDynamoDB()
+		conn := createSchemaConnection(fake)
+		table := createTable[schemaObject](conn, "TEST_TABLE")
+		schema, err := table.Schema()
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, ensure that the table exists twice; neither call should fail
+		for i := 0; i < 2; i++ {
+			_, err = conn.EnsureTable(context.Background(), schema)
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+
+		// Finally, verify that the table was only created and updated once
+		Expect(fake.Calls("CreateTable")).Should(Equal(1))
+		Expect(fake.Calls("UpdateTable")).Should(Equal(0))
+		Expect(fake.Calls("UpdateTimeToLive")).Should(Equal(1))
+	})
+})
+
+// Helper type used to test that table schemas can be derived from struct tags
+type schemaObject struct {
+	schemaKey
+	Owner   string    `json:"owner" dynamo:"gsi=owner_index"`
+	Created time.Time `json:"created" dynamo:"gsi=owner_index:sk"`
+	Data    int       `json:"data" dynamo:"lsi=data_index"`
+	Expires int64     `json:"expires" dynamo:"ttl"`
+	Version int       `json:"version" dynamo:"version"`
+	Ignored string    `json:"ignored"`
+}
+
+// Helper type that contains the key of a schemaObject, used to test that embedded structs are searched
+type schemaKey struct {
+	ID      string `json:"id" dynamo:"pk"`
+	SortKey string `json:"sort_key" dynamo:"sk"`
+}
+
+// Helper function that creates our test table on the fake with an index that isn't in the schema of our test
+// object
+func createLegacyTable(fake *testing.FakeDynamoDB) {
+	if _, err := fake.CreateTable(context.Background(), &dynamodb.CreateTableInput{
+		TableName: aws.String("TEST_TABLE"),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("sort_key"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("legacy"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("sort_key"), KeyType: types.KeyTypeRange},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName:  aws.String("legacy_index"),
+			KeySchema:  []types.KeySchemaElement{{AttributeName: aws.String("legacy"), KeyType: types.KeyTypeHash}},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+		BillingMode: types.BillingModePayPerRequest,
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// Helper function that creates a test connection from a client with a short backoff
+func createSchemaConnection(client DynamoDBAPI) *DatabaseConnection {
+	logger := utils.NewLogger("testd", "test")
+	logger.Discard()
+	return FromClient(client, logger, WithBackoffStart(1), WithBackoffEnd(5), WithBackoffMaxElapsed(1000))
+}
@@ -0,0 +1,345 @@
+package dynamodb
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	xstr "github.com/xefino/goutils/strings"
+)
+
+// Table provides typed access to the items stored in a single DynamoDB table. Items are converted to and
+// from DynamoDB attribute values using the tag key associated with the underlying connection and the key
+// of each item is derived from the fields of the item tagged with `dynamo:"pk"` and `dynamo:"sk"`
+type Table[T any] struct {
+	conn         *DatabaseConnection
+	name         string
+	partitionKey string
+	sortKey      string
+	versioned    bool
+}
+
+// NewTable creates a new Table from a database connection and the name of the table. The names of the partition
+// and sort key attributes will be derived from the fields of the item type tagged with `dynamo:"pk"` and
+// `dynamo:"sk"`, respectively. If the item type has no partition key field, or its dynamo tags are otherwise
+// invalid, then an error will be returned. See DatabaseConnection.DeriveTableSchema for more details
+func NewTable[T any](conn *DatabaseConnection, name string) (*Table[T], error) {
+
+	// First, attempt to derive the schema of the table from the item type; if this fails then return an error
+	schema, err := conn.DeriveTableSchema(name, new(T))
+	if err != nil {
+		return nil, err
+	}
+
+	// Finally, create the table from the key attributes in the schema
+	return &Table[T]{
+		conn:         conn,
+		name:         name,
+		partitionKey: schema.PartitionKey,
+		sortKey:      schema.SortKey,
+	}, nil
+}
+
+// WithVersioning returns a copy of the Table that uses optimistic locking when writing items. Items written
+// by Put and Update on the copy must have an integer field tagged with `dynamo:"version"`, which will be
+// incremented on each write. If the version of the item in DynamoDB does not match then the write will fail
+// with an Error with a kind of KindVersionConflict. See DatabaseConnection.PutItemVersioned for more details
+func (table *Table[T]) WithVersioning() *Table[T] {
+	versioned := *table
+	versioned.versioned = true
+	return &versioned
+}
+
+// Name returns the name of the DynamoDB table associated with this Table
+func (table *Table[T]) Name() string {
+	return table.name
+}
+
+// Key extracts the primary key of the item from the fields tagged with `dynamo:"pk"` and `dynamo:"sk"`. The item
+// will be converted to DynamoDB attribute values and the key attributes will be taken from the result. If either
+// key attribute is missing from the result, for example because the field was omitted, then an error will be
+// returned
+func (table *Table[T]) Key(item *T) (map[string]types.AttributeValue, error) {
+
+	// First, attempt to convert the item to a mapping of attribute values; if this fails then return an error
+	attrs, err := table.conn.MarshalMap(item)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, extract the partition and sort key names from the table definition
+	names := table.keyNames()
+
+	// Finally, iterate over all the key attribute names and copy each from the item to the key. If any
+	// key attribute is missing then return an error
+	key := make(map[string]types.AttributeValue, len(names))
+	for _, name := range names {
+		attr, ok := attrs[name]
+		if !ok {
+			return nil, table.conn.NewError(nil, table.name,
+				"Key attribute %q was not found on %T for table %s", name, item, table.name)
+		}
+
+		key[name] = attr
+	}
+
+	return key, nil
+}
+
+// Get retrieves the item with the same key as the item provided from DynamoDB. If no such item exists
+// then nil will be returned. If consistent is true then a strongly consistent read will be performed
+func (table *Table[T]) Get(ctx context.Context, key *T, consistent bool) (*T, error) {
+
+	// First, attempt to derive the key from the item provided; if this fails then return an error
+	attrs, err := table.Key(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, attempt to get the item from DynamoDB; if this fails then return an error
+	output, err := table.conn.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(table.name),
+		Key:            attrs,
+		ConsistentRead: aws.Bool(consistent),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Finally, attempt to convert the item to our output type and return it
+	return table.unmarshal(output.Item)
+}
+
+// Put writes the item to DynamoDB, overwriting an existing item if there is one. If versioning is enabled on
+// the table then the version on the item will be incremented and the write will only succeed if the version of
+// the item in DynamoDB matches the version of the item before it was incremented
+func (table *Table[T]) Put(ctx context.Context, item *T) error {
+
+	// First, if versioning is enabled then write the item with optimistic locking and return any error
+	if table.versioned {
+		_, err := table.conn.PutItemVersioned(ctx, &dynamodb.PutItemInput{TableName: aws.String(table.name)}, item)
+		return err
+	}
+
+	// Next, attempt to convert the item to a mapping of attribute values; if this fails then return an error
+	attrs, err := table.conn.MarshalMap(item)
+	if err != nil {
+		return err
+	}
+
+	// Now, attempt to write the item to DynamoDB; return any error that occurs
+	_, err = table.conn.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(table.name),
+		Item:      attrs,
+	})
+
+	return err
+}
+
+// Delete removes the item with the same key as the item provided from DynamoDB, returning the item that
+// was removed. If no such item existed then nil will be returned
+func (table *Table[T]) Delete(ctx context.Context, key *T) (*T, error) {
+
+	// First, attempt to derive the key from the item provided; if this fails then return an error
+	attrs, err := table.Key(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, attempt to delete the item from DynamoDB; if this fails then return an error
+	output, err := table.conn.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:    aws.String(table.name),
+		Key:          attrs,
+		ReturnValues: types.ReturnValueAllOld,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Finally, attempt to convert the old item to our output type and return it
+	return table.unmarshal(output.Attributes)
+}
+
+// Update modifies the item with the same key as the item provided in DynamoDB and returns the updated item.
+// The input should contain the update expression and any associated attribute names, values and conditions;
+// the table name and key will be set on a copy of the input by this function. If the input does not request specific
+// return values then all the attributes of the updated item will be returned. If versioning is enabled on the
+// table then the key item should contain the version expected to be in DynamoDB; the update will increment the
+// version and will only succeed if the versions match
+func (table *Table[T]) Update(ctx context.Context, key *T, input *dynamodb.UpdateItemInput) (*T, error) {
+
+	// First, attempt to derive the key from the item provided; if this fails then return an error
+	attrs, err := table.Key(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, copy the input so the caller's input isn't modified, set the table name and key on the copy and
+	// ensure that we get the updated item back
+	copied := *input
+	copied.ExpressionAttributeNames, copied.ExpressionAttributeValues =
+		copyPlaceholders(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	copied.TableName = aws.String(table.name)
+	copied.Key = attrs
+	if copied.ReturnValues == "" || copied.ReturnValues == types.ReturnValueNone {
+		copied.ReturnValues = types.ReturnValueAllNew
+	}
+
+	// Now, attempt to update the item in DynamoDB, with optimistic locking if versioning is enabled; if this
+	// fails then return an error
+	var output *dynamodb.UpdateItemOutput
+	if table.versioned {
+		output, err = table.conn.UpdateItemVersioned(ctx, &copied, key)
+	} else {
+		output, err = table.conn.UpdateItem(ctx, &copied)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Finally, attempt to convert the updated item to our output type and return it
+	return table.unmarshal(output.Attributes)
+}
+
+// BatchGet retrieves the items with the same keys as the items provided from DynamoDB. Items that do not exist
+// will not be returned and the items returned will be in no particular order. If consistent is true then
+// strongly consistent reads will be performed
+func (table *Table[T]) BatchGet(ctx context.Context, keys []*T, consistent bool) ([]*T, error) {
+
+	// First, attempt to derive the key from each of the items provided; if any fail then return an error
+	attrs := make([]map[string]types.AttributeValue, len(keys))
+	for i, key := range keys {
+		attr, err := table.Key(key)
+		if err != nil {
+			return nil, err
+		}
+
+		attrs[i] = attr
+	}
+
+	// Next, attempt to read all the items from DynamoDB and return them
+	return BatchGetItems[T](ctx, table.conn, table.name,
+		types.KeysAndAttributes{ConsistentRead: aws.Bool(consistent)}, attrs...)
+}
+
+// Query retrieves all the items from the table matching the query input provided. The table name will be
+// set on a copy of the input by this function
+func (table *Table[T]) Query(ctx context.Context, input *dynamodb.QueryInput) ([]*T, error) {
+
+	// First, set the table name on a copy of the input and attempt to query all the matching items from DynamoDB
+	copied := *input
+	copied.TableName = aws.String(table.name)
+	items, err := table.conn.Query(ctx, &copied)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, attempt to convert the items to our output type and return them
+	return table.unmarshalList(items)
+}
+
+// Scan retrieves all the items from the table matching the scan input provided. The table name will be
+// set on a copy of the input by this function. If the input is nil then every item in the table will be returned
+func (table *Table[T]) Scan(ctx context.Context, input *dynamodb.ScanInput) ([]*T, error) {
+
+	// First, copy the input so the caller's input isn't modified; if we have no input then we'll scan the
+	// entire table
+	copied := scanInput(input)
+
+	// Next, set the table name on the copy and attempt to scan all the matching items from DynamoDB
+	copied.TableName = aws.String(table.name)
+	items, err := table.conn.Scan(ctx, copied)
+	if err != nil {
+		return nil, err
+	}
+
+	// Finally, attempt to convert the items to our output type and return them
+	return table.unmarshalList(items)
+}
+
+// QueryEach queries the items from the table matching the query input provided one page at a time, calling
+// the handler with each item as it is retrieved. The table name will be set on a copy of the input by this
+// function. The key that should be used to resume the query will be returned; if this is nil then there are no
+// more items. See DatabaseConnection.QueryItems for more details
+func (table *Table[T]) QueryEach(ctx context.Context, input *dynamodb.QueryInput,
+	handler func(*T) (bool, error), opts ...IIteratorOption) (map[string]types.AttributeValue, error) {
+	copied := *input
+	copied.TableName = aws.String(table.name)
+	return QueryEach(ctx, table.conn, &copied, handler, table.iteratorOptions(copied.IndexName, opts)...)
+}
+
+// ScanEach scans the items from the table matching the scan input provided one page at a time, calling the
+// handler with each item as it is retrieved. The table name will be set on a copy of the input by this function
+// and, if the input is nil, every item in the table will be scanned. The key that should be used to resume the
+// scan will be returned; if this is nil then there are no more items. See DatabaseConnection.ScanItems for
+// more details
+func (table *Table[T]) ScanEach(ctx context.Context, input *dynamodb.ScanInput,
+	handler func(*T) (bool, error), opts ...IIteratorOption) (map[string]types.AttributeValue, error) {
+	copied := scanInput(input)
+	copied.TableName = aws.String(table.name)
+	return ScanEach(ctx, table.conn, copied, handler, table.iteratorOptions(copied.IndexName, opts)...)
+}
+
+// Helper function that returns the names of the key attributes associated with the table
+func (table *Table[T]) keyNames() []string {
+	names := []string{table.partitionKey}
+	if !xstr.IsEmpty(table.sortKey) {
+		names = append(names, table.sortKey)
+	}
+
+	return names
+}
+
+// Helper function that adds the table's key attributes to the iterator options so that iteration may be
+// resumed from any item. If an index is being read then we don't know its key attributes so they will be
+// derived from the key of each page or the key schema of the table instead
+func (table *Table[T]) iteratorOptions(index *string, opts []IIteratorOption) []IIteratorOption {
+	if index != nil {
+		return opts
+	}
+
+	return append([]IIteratorOption{WithKeyAttributes(table.keyNames())}, opts...)
+}
+
+// Helper function that copies a scan input so that it can be modified without affecting the caller. If the
+// input is nil then an empty input will be returned
+func scanInput(input *dynamodb.ScanInput) *dynamodb.ScanInput {
+	if input == nil {
+		return new(dynamodb.ScanInput)
+	}
+
+	copied := *input
+	return &copied
+}
+
+// Helper function that converts a mapping of attribute values to an item, returning nil if no attributes
+// were provided
+func (table *Table[T]) unmarshal(attrs map[string]types.AttributeValue) (*T, error) {
+
+	// If we have no attributes then the item didn't exist so return nil
+	if len(attrs) == 0 {
+		return nil, nil
+	}
+
+	// Otherwise, attempt to convert the attributes to our item type and return it
+	item := new(T)
+	if err := table.conn.UnmarshalMap(attrs, item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// Helper function that converts a list of attribute value mappings to a list of items
+func (table *Table[T]) unmarshalList(attrs []map[string]types.AttributeValue) ([]*T, error) {
+	items := make([]*T, 0, len(attrs))
+	if err := table.conn.UnmarshalList(attrs, &items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
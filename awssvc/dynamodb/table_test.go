@@ -0,0 +1,329 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/awssvc/testing"
+	"github.com/xefino/goutils/utils"
+)
+
+var _ = Describe("Table Tests", Ordered, func() {
+
+	// Ensure that the AWS config is created before each test; this could be set as a global variable
+	var cfg aws.Config
+	BeforeAll(func() {
+		cfg = testing.TestAWSConfig(context.Background(), "us-east-1", 9000)
+	})
+
+	// Create our test table definition that we'll use for all module tests
+	testTable := dynamodb.CreateTableInput{
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("id"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("sort_key"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("id"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String("sort_key"),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		TableName:   aws.String("TEST_TABLE"),
+		BillingMode: types.BillingModeProvisioned,
+		ProvisionedThroughput: &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(1),
+			WriteCapacityUnits: aws.Int64(1),
+		},
+		TableClass: types.TableClassStandard,
+	}
+
+	// Esnure that the table exists before the start of each test
+	BeforeEach(func() {
+		if err := testing.EnsureTableExists(context.Background(), cfg, &testTable); err != nil {
+			panic(err)
+		}
+	})
+
+	// Ensure that the table is empty at the end of each test
+	AfterEach(func() {
+		if err := testing.EmptyTable(context.Background(), cfg, &testTable); err != nil {
+			panic(err)
+		}
+	})
+
+	// Tests that items written with Put can be read back with Get, updated with Update and removed with Delete
+	It("Put, Get, Update, Delete - No failures - Works", func() {
+
+		// First, create our test table and a test item
+		table := createTable[testObject](createTestConnection(cfg), "TEST_TABLE")
+		item := testObject{ID: "test_id", SortKey: "test|sort|key", Data: 1}
+
+		// Next, attempt to get the item before it has been written; this should return nothing
+		result, err := table.Get(context.Background(), &item, true)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result).Should(BeNil())
+
+		// Now, write the item and then read it back; neither operation should fail
+		Expect(table.Put(context.Background(), &item)).ShouldNot(HaveOccurred())
+		result, err = table.Get(context.Background(), &testObject{ID: "test_id", SortKey: "test|sort|key"}, true)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(*result).Should(Equal(item))
+
+		// Update the item and verify that the updated item was returned
+		result, err = table.Update(context.Background(), &item, &dynamodb.UpdateItemInput{
+			UpdateExpression:          aws.String("SET #data = #data + :inc"),
+			ExpressionAttributeNames:  map[string]string{"#data": "data"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":inc": &types.AttributeValueMemberN{Value: "41"}},
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.Data).Should(Equal(42))
+
+		// Finally, delete the item and verify that the deleted item was returned and that it no longer exists
+		result, err = table.Delete(context.Background(), &item)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.Data).Should(Equal(42))
+		result, err = table.Get(context.Background(), &item, true)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result).Should(BeNil())
+	})
+
+	// Tests that, if the table does not exist, then calling Get will return an error
+	It("Get - Fails - Error", func() {
+
+		// First, create our test table with a table name that does not exist
+		table := createTable[testObject](createTestConnection(cfg), "FAKE_TABLE")
+
+		// Next, attempt to get an item from the table; this should fail
+		result, err := table.Get(context.Background(), &testObject{ID: "test_id", SortKey: "test|sort|key"}, false)
+
+		// Finally, verify the failure
+		Expect(result).Should(BeNil())
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).TableName).Should(Equal("FAKE_TABLE"))
+		Expect(err.(*Error).Message).Should(Equal("GET request to FAKE_TABLE in DynamoDB failed"))
+	})
+
+	// Tests that the Query and Scan functions will return typed items
+	It("Query, Scan - No failures - Data returned", func() {
+
+		// First, create our test table and write some test items to it
+		table := createTable[testObject](createTestConnection(cfg), "TEST_TABLE")
+		for i := 0; i < 10; i++ {
+			err := table.Put(context.Background(),
+				&testObject{ID: "test_id", SortKey: fmt.Sprintf("test|sort|key|%d", i), Data: i})
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+
+		// Next, query the items from the table; this should not fail
+		queried, err := table.Query(context.Background(), &dynamodb.QueryInput{
+			ConsistentRead:            aws.Bool(true),
+			KeyConditionExpression:    aws.String("#id = :id"),
+			ExpressionAttributeNames:  map[string]string{"#id": "id"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":id": &types.AttributeValueMemberS{Value: "test_id"}},
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Now, scan the items from the table; this should not fail
+		scanned, err := table.Scan(context.Background(), nil)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that both operations returned all the items
+		for _, results := range [][]*testObject{queried, scanned} {
+			sort.Slice(results, func(i, j int) bool { return results[i].Data < results[j].Data })
+			Expect(results).Should(HaveLen(10))
+			for i, result := range results {
+				Expect(result.ID).Should(Equal("test_id"))
+				Expect(result.SortKey).Should(Equal(fmt.Sprintf("test|sort|key|%d", i)))
+				Expect(result.Data).Should(Equal(i))
+			}
+		}
+	})
+})
+
+var _ = Describe("Table Fake Tests", func() {
+
+	// Tests that the Key function will derive the key attributes from the tagged fields on the item
+	It("Key - Works", func() {
+
+		// First, create our test table from a connection with a custom tag key
+		logger := utils.NewLogger("testd", "test")
+		logger.Discard()
+		conn := FromClient(nil, logger, WithTagKey("dynamodbav"))
+		table := createTable[taggedObject](conn, "TEST_TABLE")
+
+		// Next, attempt to derive the key from a test item; this should not fail
+		key, err := table.Key(&taggedObject{ID: "test_id", SortKey: "test|sort|key", Data: 42})
+
+		// Finally, verify that only the key attributes were extracted
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(key).Should(HaveLen(2))
+		Expect(key["pk"]).Should(Equal(&types.AttributeValueMemberS{Value: "test_id"}))
+		Expect(key["sk"]).Should(Equal(&types.AttributeValueMemberS{Value: "test|sort|key"}))
+	})
+
+	// Tests that the Key function will return an error if a key field is omitted from the item
+	It("Key - Attribute missing - Error", func() {
+
+		// First, create our test table from an item type whose sort key may be omitted
+		_, conn := createFakeConnection()
+		table := createTable[sparseObject](conn, "TEST_TABLE")
+
+		// Next, attempt to derive the key from a test item without a sort key; this should fail
+		key, err := table.Key(&sparseObject{ID: "test_id"})
+
+		// Finally, verify the failure
+		Expect(key).Should(BeNil())
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).TableName).Should(Equal("TEST_TABLE"))
+		Expect(err.(*Error).Message).Should(Equal("Key attribute \"sort_key\" was not found on " +
+			"*dynamodb.sparseObject for table TEST_TABLE"))
+	})
+
+	// Tests that, if the item type has no field tagged as the partition key, then NewTable will return an error
+	It("NewTable - No partition key - Error", func() {
+
+		// First, attempt to create a table from an item type without any dynamo tags; this should fail
+		_, conn := createFakeConnection()
+		table, err := NewTable[untaggedObject](conn, "TEST_TABLE")
+
+		// Finally, verify the failure
+		Expect(table).Should(BeNil())
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).TableName).Should(Equal("TEST_TABLE"))
+		Expect(err.(*Error).Message).Should(Equal("No field on *dynamodb.untaggedObject was tagged with " +
+			"`dynamo:\"pk\"`"))
+	})
+
+	// Tests that the table name and key are set on copies of the inputs provided to Update, Query, Scan,
+	// QueryEach and ScanEach so that the caller's inputs are not modified
+	It("Update, Query, Scan, QueryEach, ScanEach - Inputs not modified", func() {
+
+		// First, create our test table from a fake connection and write some test items to it
+		_, conn := createFakeConnection()
+		writeFakeItems(conn, "test_id", 3)
+		table := createTable[testObject](conn, "TEST_TABLE")
+
+		// Next, update an item and verify that the update input was not modified
+		update := dynamodb.UpdateItemInput{
+			UpdateExpression:          aws.String("SET #data = :data"),
+			ExpressionAttributeNames:  map[string]string{"#data": "data"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":data": &types.AttributeValueMemberN{Value: "42"}},
+		}
+
+		result, err := table.Update(context.Background(), &testObject{ID: "test_id", SortKey: "0"}, &update)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.Data).Should(Equal(42))
+		Expect(update.TableName).Should(BeNil())
+		Expect(update.Key).Should(BeNil())
+		Expect(update.ReturnValues).Should(BeEmpty())
+
+		// Now, query and scan the items and verify that neither input was modified
+		query := dynamodb.QueryInput{
+			KeyConditionExpression:    aws.String("#id = :id"),
+			ExpressionAttributeNames:  map[string]string{"#id": "id"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":id": &types.AttributeValueMemberS{Value: "test_id"}},
+		}
+
+		scan := dynamodb.ScanInput{}
+		queried, err := table.Query(context.Background(), &query)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(queried).Should(HaveLen(3))
+		scanned, err := table.Scan(context.Background(), &scan)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(scanned).Should(HaveLen(3))
+		Expect(query.TableName).Should(BeNil())
+		Expect(scan.TableName).Should(BeNil())
+
+		// Finally, iterate over the items and verify that neither input was modified
+		count := 0
+		handler := func(*testObject) (bool, error) {
+			count++
+			return true, nil
+		}
+
+		_, err = table.QueryEach(context.Background(), &query, handler)
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = table.ScanEach(context.Background(), &scan, handler)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(count).Should(Equal(6))
+		Expect(query.TableName).Should(BeNil())
+		Expect(scan.TableName).Should(BeNil())
+	})
+
+	// Tests that, when versioning is enabled, the attribute names and values on the input provided to Update
+	// are not modified when the version condition and increment are added
+	It("Update - Versioned - Input maps not modified", func() {
+
+		// First, create our versioned test table from a fake connection and write the item we'll update
+		_, conn := createFakeConnection()
+		table := createTable[versionedObject](conn, "TEST_TABLE").WithVersioning()
+		item := versionedObject{ID: "test_id", SortKey: "test|sort|key", Data: 42}
+		_, err := conn.PutItemVersioned(context.Background(),
+			&dynamodb.PutItemInput{TableName: aws.String("TEST_TABLE")}, &item)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, update the item with an input that has its own attribute names and values; this should not fail
+		update := dynamodb.UpdateItemInput{
+			UpdateExpression:          aws.String("SET #data = :data"),
+			ExpressionAttributeNames:  map[string]string{"#data": "data"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":data": &types.AttributeValueMemberN{Value: "50"}},
+		}
+
+		result, err := table.Update(context.Background(), &item, &update)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.Data).Should(Equal(50))
+		Expect(result.Version).Should(Equal(2))
+
+		// Finally, verify that the input was not modified
+		Expect(update.ConditionExpression).Should(BeNil())
+		Expect(*update.UpdateExpression).Should(Equal("SET #data = :data"))
+		Expect(update.ExpressionAttributeNames).Should(Equal(map[string]string{"#data": "data"}))
+		Expect(update.ExpressionAttributeValues).Should(HaveLen(1))
+	})
+})
+
+// Helper type that we'll use to test tag key functionality
+type taggedObject struct {
+	ID      string `dynamodbav:"pk" dynamo:"pk"`
+	SortKey string `dynamodbav:"sk" dynamo:"sk"`
+	Data    int    `dynamodbav:"data"`
+}
+
+// Helper type that we'll use to test items whose key fields may be omitted
+type sparseObject struct {
+	ID      string  `json:"id" dynamo:"pk"`
+	SortKey *string `json:"sort_key,omitempty" dynamo:"sk"`
+}
+
+// Helper type that we'll use to test items without any dynamo tags
+type untaggedObject struct {
+	ID string `json:"id"`
+}
+
+// Helper function that creates a table from the connection and table name provided, panicking on failure
+func createTable[T any](conn *DatabaseConnection, name string) *Table[T] {
+	table, err := NewTable[T](conn, name)
+	if err != nil {
+		panic(err)
+	}
+
+	return table
+}
@@ -0,0 +1,148 @@
+package dynamodb
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/xefino/goutils/collections"
+)
+
+// TransactWriteItems writes a group of items to one or more tables in DynamoDB atomically, so that either all
+// the writes succeed or none of them do. If the input does not have a client request token then one will be
+// generated, and set on a copy of the input, so that retries of the request are idempotent without the token
+// being reused if the caller reuses the input for another transaction. The request will be retried if it was
+// cancelled due to a conflict with another transaction. If the transaction is cancelled for any other reason
+// then the error returned will contain the reason associated with each item in the request
+func (conn *DatabaseConnection) TransactWriteItems(ctx context.Context,
+	input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+
+	// First, ensure that we have a client request token so that retrying the request won't cause the
+	// writes to be applied more than once. The token is set on a copy of the input so that it won't be
+	// reused if the caller reuses their input for a different transaction
+	if input.ClientRequestToken == nil {
+		copied := *input
+		copied.ClientRequestToken = aws.String(uuid.NewString())
+		input = &copied
+	}
+
+	// Next, get the names of all the tables associated with the request
+	tableNames := transactTableNames(collections.Convert(func(item types.TransactWriteItem) *string {
+		switch {
+		case item.ConditionCheck != nil:
+			return item.ConditionCheck.TableName
+		case item.Delete != nil:
+			return item.Delete.TableName
+		case item.Put != nil:
+			return item.Put.TableName
+		case item.Update != nil:
+			return item.Update.TableName
+		default:
+			return nil
+		}
+	}, input.TransactItems...)...)
+
+	// Now, attempt to retry the operation to write the items to DynamoDB
+	ctx, scope := conn.startMetrics(ctx, tableNames, "TRANSACT WRITE")
+	var output *dynamodb.TransactWriteItemsOutput
+	err := conn.doRetry(ctx, tableNames, "TRANSACT WRITE", func() error {
+		var inner error
+		output, inner = conn.db.TransactWriteItems(ctx, input)
+		return inner
+	})
+
+	scope.finish(err)
+
+	// Finally, if the operation failed then decode the cancellation reasons and return the error;
+	// otherwise, return the output
+	if err != nil {
+		return nil, withCancellationReasons(err)
+	}
+
+	return output, nil
+}
+
+// TransactGetItems reads a group of items from one or more tables in DynamoDB atomically, so that the items
+// returned reflect a consistent snapshot. The request will be retried if it was cancelled due to a conflict
+// with an ongoing transaction. If the transaction is cancelled for any other reason then the error returned
+// will contain the reason associated with each item in the request
+func (conn *DatabaseConnection) TransactGetItems(ctx context.Context,
+	input *dynamodb.TransactGetItemsInput) (*dynamodb.TransactGetItemsOutput, error) {
+
+	// First, get the names of all the tables associated with the request
+	tableNames := transactTableNames(collections.Convert(func(item types.TransactGetItem) *string {
+		if item.Get != nil {
+			return item.Get.TableName
+		}
+
+		return nil
+	}, input.TransactItems...)...)
+
+	// Next, attempt to retry the operation to read the items from DynamoDB
+	ctx, scope := conn.startMetrics(ctx, tableNames, "TRANSACT GET")
+	var output *dynamodb.TransactGetItemsOutput
+	err := conn.doRetry(ctx, tableNames, "TRANSACT GET", func() error {
+		var inner error
+		output, inner = conn.db.TransactGetItems(ctx, input)
+		return inner
+	})
+
+	scope.finish(err)
+
+	// Finally, if the operation failed then decode the cancellation reasons and return the error;
+	// otherwise, return the output
+	if err != nil {
+		return nil, withCancellationReasons(err)
+	}
+
+	return output, nil
+}
+
+// Helper function that adds the cancellation reasons to an error returned by a transaction, if it has any
+func withCancellationReasons(err error) error {
+	if casted, ok := err.(*Error); ok {
+		casted.Reasons = cancellationReasons(casted.Inner)
+	}
+
+	return err
+}
+
+// Helper function that determines whether or not a cancelled transaction may be retried. This will be the case
+// if every item that caused the cancellation did so because of a conflict or throttling, which may be resolved
+// by waiting. If any item was cancelled for another reason, such as a failed condition, then retrying the
+// transaction will not help
+func isRetryableCancellation(err *types.TransactionCanceledException) bool {
+	retryable := false
+	for _, reason := range cancellationReasons(err) {
+		switch reason.Code {
+		case ReasonNone:
+		case ReasonTransactionConflict, ReasonProvisionedThroughputExceeded, ReasonThrottlingError:
+			retryable = true
+		default:
+			return false
+		}
+	}
+
+	return retryable
+}
+
+// Helper function that creates a single name describing all the distinct tables in a transaction
+func transactTableNames(names ...*string) string {
+
+	// First, collect the distinct table names from the list provided
+	distinct := make(map[string]struct{})
+	for _, name := range names {
+		if name != nil {
+			distinct[*name] = struct{}{}
+		}
+	}
+
+	// Next, sort the table names so that the result is deterministic and join them together
+	tables := collections.Keys(distinct)
+	sort.Strings(tables)
+	return strings.Join(tables, ", ")
+}
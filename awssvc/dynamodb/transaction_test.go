@@ -0,0 +1,304 @@
+package dynamodb
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/awssvc/testing"
+	"github.com/xefino/goutils/utils"
+)
+
+var _ = Describe("Transaction Tests", Ordered, func() {
+
+	// Ensure that the AWS config is created before each test; this could be set as a global variable
+	var cfg aws.Config
+	BeforeAll(func() {
+		cfg = testing.TestAWSConfig(context.Background(), "us-east-1", 9000)
+	})
+
+	// Create our test table definitions that we'll use for all module tests; we'll use two tables so that
+	// we can verify transactions against multiple tables
+	tables := make([]*dynamodb.CreateTableInput, 2)
+	for i, name := range []string{"TEST_TABLE", "TEST_TABLE_2"} {
+		tables[i] = &dynamodb.CreateTableInput{
+			AttributeDefinitions: []types.AttributeDefinition{
+				{
+					AttributeName: aws.String("id"),
+					AttributeType: types.ScalarAttributeTypeS,
+				},
+				{
+					AttributeName: aws.String("sort_key"),
+					AttributeType: types.ScalarAttributeTypeS,
+				},
+			},
+			KeySchema: []types.KeySchemaElement{
+				{
+					AttributeName: aws.String("id"),
+					KeyType:       types.KeyTypeHash,
+				},
+				{
+					AttributeName: aws.String("sort_key"),
+					KeyType:       types.KeyTypeRange,
+				},
+			},
+			TableName:   aws.String(name),
+			BillingMode: types.BillingModeProvisioned,
+			ProvisionedThroughput: &types.ProvisionedThroughput{
+				ReadCapacityUnits:  aws.Int64(1),
+				WriteCapacityUnits: aws.Int64(1),
+			},
+			TableClass: types.TableClassStandard,
+		}
+	}
+
+	// Esnure that the tables exist before the start of each test
+	BeforeEach(func() {
+		for _, table := range tables {
+			if err := testing.EnsureTableExists(context.Background(), cfg, table); err != nil {
+				panic(err)
+			}
+		}
+	})
+
+	// Ensure that the tables are empty at the end of each test
+	AfterEach(func() {
+		for _, table := range tables {
+			if err := testing.EmptyTable(context.Background(), cfg, table); err != nil {
+				panic(err)
+			}
+		}
+	})
+
+	// Test that, if no failure occurs, then calling TransactWriteItems will write items to multiple tables
+	// and calling TransactGetItems will read them back
+	It("TransactWriteItems, TransactGetItems - No failures - Works", func() {
+
+		// First, create our test database connection from our test config
+		conn := createTestConnection(cfg)
+
+		// Next, attempt to write an item to each table in a single transaction; this should not fail
+		input := dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				{Put: &types.Put{TableName: aws.String("TEST_TABLE"), Item: createTransactItem("test|sort|key|0", 0)}},
+				{Put: &types.Put{TableName: aws.String("TEST_TABLE_2"), Item: createTransactItem("test|sort|key|1", 1)}},
+			},
+		}
+
+		_, err := conn.TransactWriteItems(context.Background(), &input)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(input.ClientRequestToken).Should(BeNil())
+
+		// Now, attempt to read both items back in a single transaction; this should not fail
+		output, err := conn.TransactGetItems(context.Background(), &dynamodb.TransactGetItemsInput{
+			TransactItems: []types.TransactGetItem{
+				{Get: &types.Get{TableName: aws.String("TEST_TABLE"), Key: createTransactKey("test|sort|key|0")}},
+				{Get: &types.Get{TableName: aws.String("TEST_TABLE_2"), Key: createTransactKey("test|sort|key|1")}},
+			},
+		})
+
+		// Finally, verify the items that were read
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(output.Responses).Should(HaveLen(2))
+		for i, response := range output.Responses {
+			var item testObject
+			Expect(conn.UnmarshalMap(response.Item, &item)).ShouldNot(HaveOccurred())
+			Expect(item.Data).Should(Equal(i))
+		}
+	})
+
+	// Test that, if a condition on one of the items fails, then calling TransactWriteItems will return an
+	// error containing the reason associated with each item and none of the items will be written
+	It("TransactWriteItems - Condition fails - Reasons returned", func() {
+
+		// First, create our test database connection from our test config and write an item to the
+		// second table that will cause our condition to fail
+		conn := createTestConnection(cfg)
+		_, err := conn.PutItem(context.Background(), &dynamodb.PutItemInput{
+			TableName: aws.String("TEST_TABLE_2"),
+			Item:      createTransactItem("test|sort|key|1", 1),
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, attempt to write an item to each table, on the condition that neither exists; this should fail
+		_, err = conn.TransactWriteItems(context.Background(), &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				{Put: &types.Put{
+					TableName:           aws.String("TEST_TABLE"),
+					Item:                createTransactItem("test|sort|key|0", 0),
+					ConditionExpression: aws.String("attribute_not_exists(id)"),
+				}},
+				{Put: &types.Put{
+					TableName:           aws.String("TEST_TABLE_2"),
+					Item:                createTransactItem("test|sort|key|1", 2),
+					ConditionExpression: aws.String("attribute_not_exists(id)"),
+				}},
+			},
+		})
+
+		// Now, verify the error and the reasons associated with it
+		casted := err.(*Error)
+		Expect(err).Should(HaveOccurred())
+		Expect(casted.TableName).Should(Equal("TEST_TABLE, TEST_TABLE_2"))
+		Expect(casted.Message).Should(Equal("TRANSACT WRITE request to TEST_TABLE, TEST_TABLE_2 in DynamoDB failed"))
+		Expect(casted.Reasons).Should(HaveLen(2))
+		Expect(casted.Reasons[0].Code).Should(Equal(ReasonNone))
+		Expect(casted.Reasons[1].Code).Should(Equal(ReasonConditionalCheckFailed))
+		Expect(casted.Failed()).Should(Equal([]*CancellationReason{casted.Reasons[1]}))
+
+		// Finally, verify that the item was not written to the first table
+		output, err := conn.GetItem(context.Background(), &dynamodb.GetItemInput{
+			TableName:      aws.String("TEST_TABLE"),
+			Key:            createTransactKey("test|sort|key|0"),
+			ConsistentRead: aws.Bool(true),
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(output.Item).Should(BeEmpty())
+	})
+})
+
+var _ = Describe("Transaction Retry Tests", func() {
+
+	// Test that, if the transaction is cancelled due to a conflict, then calling TransactWriteItems will retry
+	// the request with the same client request token until it succeeds
+	It("TransactWriteItems - Conflict - Retried", func() {
+
+		// First, create a test connection from a client that will fail with a conflict twice
+		logger := utils.NewLogger("testd", "test")
+		logger.Discard()
+		client := &transactionDynamoDBClient{failures: 2, code: ReasonTransactionConflict}
+		conn := FromClient(client, logger, WithBackoffStart(1), WithBackoffEnd(5), WithBackoffMaxElapsed(1000))
+
+		// Next, attempt to write the transaction; this should not fail
+		_, err := conn.TransactWriteItems(context.Background(), &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				{Put: &types.Put{TableName: aws.String("TEST_TABLE"), Item: createTransactItem("test|sort|key|0", 0)}},
+				{Put: &types.Put{TableName: aws.String("TEST_TABLE"), Item: createTransactItem("test|sort|key|1", 1)}},
+			},
+		})
+
+		// Finally, verify that the request was retried with the same token
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(client.tokens).Should(HaveLen(3))
+		Expect(client.tokens[0]).ShouldNot(BeEmpty())
+		Expect(client.tokens[1]).Should(Equal(client.tokens[0]))
+		Expect(client.tokens[2]).Should(Equal(client.tokens[0]))
+	})
+
+	// Test that, if the caller reuses an input without a client request token, then calling TransactWriteItems
+	// will generate a new token for each transaction rather than setting one on the caller's input
+	It("TransactWriteItems - Input reused - New token generated", func() {
+
+		// First, create a test connection from a client that will succeed immediately
+		logger := utils.NewLogger("testd", "test")
+		logger.Discard()
+		client := &transactionDynamoDBClient{}
+		conn := FromClient(client, logger, WithBackoffStart(1), WithBackoffEnd(5), WithBackoffMaxElapsed(1000))
+
+		// Next, write two transactions with the same input; neither should fail
+		input := dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				{Put: &types.Put{TableName: aws.String("TEST_TABLE"), Item: createTransactItem("test|sort|key|0", 0)}},
+			},
+		}
+
+		_, err := conn.TransactWriteItems(context.Background(), &input)
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = conn.TransactWriteItems(context.Background(), &input)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that each transaction had its own token and that the input was not modified
+		Expect(input.ClientRequestToken).Should(BeNil())
+		Expect(client.tokens).Should(HaveLen(2))
+		Expect(client.tokens[0]).ShouldNot(BeEmpty())
+		Expect(client.tokens[1]).ShouldNot(Equal(client.tokens[0]))
+	})
+
+	// Test that, if the transaction is cancelled due to a failed condition, then calling TransactWriteItems
+	// will not retry the request and will return the reasons associated with each item
+	It("TransactWriteItems - Condition fails - Not retried", func() {
+
+		// First, create a test connection from a client that will fail with a failed condition
+		logger := utils.NewLogger("testd", "test")
+		logger.Discard()
+		client := &transactionDynamoDBClient{failures: 2, code: ReasonConditionalCheckFailed}
+		conn := FromClient(client, logger, WithBackoffStart(1), WithBackoffEnd(5), WithBackoffMaxElapsed(1000))
+
+		// Next, attempt to write the transaction with a client request token; this should fail
+		_, err := conn.TransactWriteItems(context.Background(), &dynamodb.TransactWriteItemsInput{
+			ClientRequestToken: aws.String("test-token"),
+			TransactItems: []types.TransactWriteItem{
+				{ConditionCheck: &types.ConditionCheck{TableName: aws.String("TEST_TABLE_2")}},
+				{Put: &types.Put{TableName: aws.String("TEST_TABLE"), Item: createTransactItem("test|sort|key|1", 1)}},
+			},
+		})
+
+		// Finally, verify that the request was not retried and that the reasons were decoded
+		casted := err.(*Error)
+		Expect(err).Should(HaveOccurred())
+		Expect(client.tokens).Should(Equal([]string{"test-token"}))
+		Expect(casted.TableName).Should(Equal("TEST_TABLE, TEST_TABLE_2"))
+		Expect(casted.Reasons).Should(Equal([]*CancellationReason{
+			{Index: 0, Code: ReasonNone},
+			{Index: 1, Code: ReasonConditionalCheckFailed, Message: "The conditional request failed"},
+		}))
+	})
+})
+
+// Helper function that creates a test item with the sort key and data provided
+func createTransactItem(sortKey string, data int) map[string]types.AttributeValue {
+	item := createTransactKey(sortKey)
+	item["data"] = &types.AttributeValueMemberN{Value: strconv.Itoa(data)}
+	return item
+}
+
+// Helper function that creates the key of a test item with the sort key provided
+func createTransactKey(sortKey string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id":       &types.AttributeValueMemberS{Value: "test_id"},
+		"sort_key": &types.AttributeValueMemberS{Value: sortKey},
+	}
+}
+
+// Helper type that mocks out TransactWriteItems so that it is cancelled a number of times before succeeding
+type transactionDynamoDBClient struct {
+	DynamoDBAPI
+	failures int
+	code     string
+	tokens   []string
+}
+
+// Mocks out the TransactWriteItems function so that it records the client request token and returns a
+// TransactionCanceledException, where the last item is associated with the client's code, until the
+// client has failed the requested number of times
+func (client *transactionDynamoDBClient) TransactWriteItems(ctx context.Context,
+	params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	client.tokens = append(client.tokens, *params.ClientRequestToken)
+	if len(client.tokens) > client.failures {
+		return &dynamodb.TransactWriteItemsOutput{}, nil
+	}
+
+	reasons := make([]types.CancellationReason, len(params.TransactItems))
+	for i := range reasons {
+		reasons[i].Code = aws.String(ReasonNone)
+	}
+
+	reasons[len(reasons)-1].Code = aws.String(client.code)
+	if client.code == ReasonConditionalCheckFailed {
+		reasons[len(reasons)-1].Message = aws.String("The conditional request failed")
+	}
+
+	return nil, &smithy.OperationError{
+		Err: &types.TransactionCanceledException{
+			Message:             aws.String("Transaction cancelled"),
+			CancellationReasons: reasons,
+		},
+	}
+}
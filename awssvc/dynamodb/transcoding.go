@@ -1,12 +1,16 @@
 package dynamodb
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/xefino/goutils/collections"
 )
 
 // MarshalMap converts the object to a mapping of DynamoDB attribute values
@@ -40,26 +44,87 @@ func (conn *DatabaseConnection) UnmarshalList(attrs []map[string]types.Attribute
 	return nil
 }
 
-// AttributeValuesToJSON attempts to convert a mapping of attribute values to a properly-formatted JSON string
-func AttributeValuesToJSON(attrs map[string]types.AttributeValue) ([]byte, error) {
+// AttributeValuesToJSON attempts to convert a mapping of attribute values to a properly-formatted JSON string.
+// By default, numbers and number sets will be written as strings, string, number and binary sets will be
+// written as arrays, binary values will be written as base64-encoded strings and NULL values will be omitted
+// from maps. This conversion cannot be reversed exactly so, if the JSON must be converted back to attribute
+// values, the WithLosslessJSON option should be used. See WithLosslessJSON for more details
+func AttributeValuesToJSON(attrs map[string]types.AttributeValue, opts ...IJSONOption) ([]byte, error) {
 
 	// Attempt to map the DynamoDB attribute value mapping to a map[string]interface{}
 	// If this fails then return an error
-	keys := make([]string, 0)
-	mapping := toJSONInner(attrs, keys...)
+	options := newJSONOptions(opts...)
+	mapping, err := options.toJSONInner(attrs)
+	if err != nil {
+		return nil, err
+	}
 
 	// Attempt to convert this mapping to JSON and return the result
 	return json.Marshal(mapping)
 }
 
+// JSONToAttributeValues attempts to convert a JSON object to a mapping of DynamoDB attribute values. Strings
+// will be converted to S, numbers to N (without any loss of precision), booleans to BOOL, arrays to L and
+// objects to M. Nulls in arrays will be converted to NULL while nulls in objects will be handled according to
+// the WithJSONNulls option. If the WithLosslessJSON option is provided then the tagged forms written by
+// AttributeValuesToJSON for sets, binary values and ambiguous maps will be converted back to the associated
+// attribute values, so that the conversion round-trips exactly
+func JSONToAttributeValues(data []byte, opts ...IJSONOption) (map[string]types.AttributeValue, error) {
+
+	// First, decode the JSON data into a generic mapping, preserving the exact text of all numbers. If this
+	// fails then return an error
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var mapping map[string]interface{}
+	if err := decoder.Decode(&mapping); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON object: %w", err)
+	} else if mapping == nil {
+		return nil, fmt.Errorf("failed to decode JSON object: data was null")
+	}
+
+	// Next, if the conversion is lossless and the object was tagged then convert it as a tagged value; since
+	// we can only return a map, the tag must be for a map
+	options := newJSONOptions(opts...)
+	if options.lossless && isTagged(mapping) {
+		attr, err := options.fromTagged(mapping)
+		if err != nil {
+			return nil, err
+		}
+
+		casted, ok := attr.(*types.AttributeValueMemberM)
+		if !ok {
+			return nil, fmt.Errorf("failed to convert JSON object: expected a map but found %T", attr)
+		}
+
+		return casted.Value, nil
+	}
+
+	// Finally, convert the mapping to attribute values and return them
+	return options.fromJSONInner(mapping)
+}
+
+// The keys used to tag values that cannot otherwise be represented in JSON when converting attribute values
+// to JSON in lossless mode
+const (
+	jsonTagBinary    = "B"
+	jsonTagBinarySet = "BS"
+	jsonTagMap       = "M"
+	jsonTagNumberSet = "NS"
+	jsonTagStringSet = "SS"
+)
+
 // Helper function that converts a struct to JSON field-mapping
-func toJSONInner(attrs map[string]types.AttributeValue, keys ...string) map[string]interface{} {
+func (options *jsonOptions) toJSONInner(attrs map[string]types.AttributeValue,
+	keys ...string) (map[string]interface{}, error) {
 	jsonStr := make(map[string]interface{})
 	for key, attr := range attrs {
 
-		// Attempt to convert the field to a JSON mapping; if the value is nil then we'll ignore it and continue
-		casted := toJSONField(attr, append(keys, key)...)
-		if casted == nil {
+		// Attempt to convert the field to a JSON mapping; if the value is nil and we're omitting null values
+		// then we'll ignore it and continue
+		casted, err := options.toJSONField(attr, append(keys, key)...)
+		if err != nil {
+			return nil, err
+		} else if casted == nil && !options.keepNulls() {
 			continue
 		}
 
@@ -67,40 +132,249 @@ func toJSONInner(attrs map[string]types.AttributeValue, keys ...string) map[stri
 		jsonStr[key] = casted
 	}
 
-	return jsonStr
+	// If we're in lossless mode and the mapping could be confused with a tagged value then tag it as a map
+	if options.lossless && isTagged(jsonStr) {
+		return map[string]interface{}{jsonTagMap: jsonStr}, nil
+	}
+
+	return jsonStr, nil
 }
 
 // Helper function that converts a specific DynamoDB attribute value to its JSON value equivalent
-func toJSONField(attr types.AttributeValue, keys ...string) interface{} {
+func (options *jsonOptions) toJSONField(attr types.AttributeValue, keys ...string) (interface{}, error) {
 	switch casted := attr.(type) {
 	case *types.AttributeValueMemberB:
-		return casted.Value
+		if options.lossless {
+			return map[string]interface{}{jsonTagBinary: casted.Value}, nil
+		}
+
+		return casted.Value, nil
 	case *types.AttributeValueMemberBOOL:
-		return casted.Value
+		return casted.Value, nil
 	case *types.AttributeValueMemberBS:
-		return casted.Value
+		if options.lossless {
+			return map[string]interface{}{jsonTagBinarySet: casted.Value}, nil
+		}
+
+		return casted.Value, nil
 	case *types.AttributeValueMemberL:
 		data := make([]interface{}, len(casted.Value))
 		for i, item := range casted.Value {
-			casted := toJSONField(item, keys...)
+			casted, err := options.toJSONField(item, keys...)
+			if err != nil {
+				return nil, err
+			}
+
 			data[i] = casted
 		}
 
-		return data
+		return data, nil
 	case *types.AttributeValueMemberM:
-		return toJSONInner(casted.Value, keys...)
+		return options.toJSONInner(casted.Value, keys...)
 	case *types.AttributeValueMemberN:
-		return casted.Value
+		if options.lossless {
+			return json.Number(casted.Value), nil
+		}
+
+		return casted.Value, nil
 	case *types.AttributeValueMemberNS:
-		return casted.Value
+		if options.lossless {
+			numbers := make([]json.Number, len(casted.Value))
+			for i, number := range casted.Value {
+				numbers[i] = json.Number(number)
+			}
+
+			return map[string]interface{}{jsonTagNumberSet: numbers}, nil
+		}
+
+		return casted.Value, nil
 	case *types.AttributeValueMemberNULL:
-		return nil
+		return nil, nil
 	case *types.AttributeValueMemberS:
-		return casted.Value
+		return casted.Value, nil
 	case *types.AttributeValueMemberSS:
-		return casted.Value
+		if options.lossless {
+			return map[string]interface{}{jsonTagStringSet: casted.Value}, nil
+		}
+
+		return casted.Value, nil
+	default:
+		return nil, fmt.Errorf("attribute at %s had unknown attribute type of %T", strings.Join(keys, "."), attr)
+	}
+}
+
+// Helper function that converts a JSON field-mapping to a mapping of attribute values
+func (options *jsonOptions) fromJSONInner(mapping map[string]interface{},
+	keys ...string) (map[string]types.AttributeValue, error) {
+	attrs := make(map[string]types.AttributeValue, len(mapping))
+	for key, value := range mapping {
+
+		// If the value is null and we're omitting null values then ignore it and continue
+		if value == nil && !options.keepNulls() {
+			continue
+		}
+
+		// Otherwise, attempt to convert the value to an attribute value and set it on the mapping
+		attr, err := options.fromJSONField(value, append(keys, key)...)
+		if err != nil {
+			return nil, err
+		}
+
+		attrs[key] = attr
+	}
+
+	return attrs, nil
+}
+
+// Helper function that converts a JSON value to its DynamoDB attribute value equivalent
+func (options *jsonOptions) fromJSONField(value interface{}, keys ...string) (types.AttributeValue, error) {
+	switch casted := value.(type) {
+	case nil:
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	case bool:
+		return &types.AttributeValueMemberBOOL{Value: casted}, nil
+	case json.Number:
+		return &types.AttributeValueMemberN{Value: casted.String()}, nil
+	case string:
+		return &types.AttributeValueMemberS{Value: casted}, nil
+	case []interface{}:
+		list := make([]types.AttributeValue, len(casted))
+		for i, item := range casted {
+			attr, err := options.fromJSONField(item, append(keys, strconv.Itoa(i))...)
+			if err != nil {
+				return nil, err
+			}
+
+			list[i] = attr
+		}
+
+		return &types.AttributeValueMemberL{Value: list}, nil
+	case map[string]interface{}:
+		if options.lossless && isTagged(casted) {
+			return options.fromTagged(casted, keys...)
+		}
+
+		mapping, err := options.fromJSONInner(casted, keys...)
+		if err != nil {
+			return nil, err
+		}
+
+		return &types.AttributeValueMemberM{Value: mapping}, nil
 	default:
-		panic(fmt.Sprintf("Attribute at %s had unknown attribute type of %T",
-			strings.Join(keys, "."), attr))
+		return nil, fmt.Errorf("value at %s had unsupported JSON type of %T", strings.Join(keys, "."), value)
+	}
+}
+
+// Helper function that converts a tagged JSON value, written in lossless mode, to its DynamoDB attribute
+// value equivalent
+func (options *jsonOptions) fromTagged(mapping map[string]interface{}, keys ...string) (types.AttributeValue, error) {
+	for tag, value := range mapping {
+		path := strings.Join(append(keys, tag), ".")
+		switch tag {
+		case jsonTagBinary:
+			data, err := decodeBinary(value, path)
+			if err != nil {
+				return nil, err
+			}
+
+			return &types.AttributeValueMemberB{Value: data}, nil
+		case jsonTagBinarySet:
+			items, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("binary set at %s was %T, not an array", path, value)
+			}
+
+			set := make([][]byte, len(items))
+			for i, item := range items {
+				data, err := decodeBinary(item, path)
+				if err != nil {
+					return nil, err
+				}
+
+				set[i] = data
+			}
+
+			return &types.AttributeValueMemberBS{Value: set}, nil
+		case jsonTagMap:
+			inner, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("map at %s was %T, not an object", path, value)
+			}
+
+			attrs, err := options.fromJSONInner(inner, keys...)
+			if err != nil {
+				return nil, err
+			}
+
+			return &types.AttributeValueMemberM{Value: attrs}, nil
+		case jsonTagNumberSet:
+			set, err := decodeSet[json.Number](value, path)
+			if err != nil {
+				return nil, err
+			}
+
+			return &types.AttributeValueMemberNS{Value: collections.Convert(json.Number.String, set...)}, nil
+		case jsonTagStringSet:
+			set, err := decodeSet[string](value, path)
+			if err != nil {
+				return nil, err
+			}
+
+			return &types.AttributeValueMemberSS{Value: set}, nil
+		}
 	}
+
+	return nil, fmt.Errorf("value at %s was not a tagged value", strings.Join(keys, "."))
+}
+
+// Helper function that determines whether a JSON object has the form of a tagged value, which is the case
+// if it has exactly one key and that key is one of the tags used in lossless mode
+func isTagged(mapping map[string]interface{}) bool {
+	if len(mapping) != 1 {
+		return false
+	}
+
+	for key := range mapping {
+		switch key {
+		case jsonTagBinary, jsonTagBinarySet, jsonTagMap, jsonTagNumberSet, jsonTagStringSet:
+			return true
+		}
+	}
+
+	return false
+}
+
+// Helper function that decodes a base64-encoded JSON string to binary data
+func decodeBinary(value interface{}, path string) ([]byte, error) {
+	encoded, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("binary value at %s was %T, not a string", path, value)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("binary value at %s could not be decoded: %w", path, err)
+	}
+
+	return data, nil
+}
+
+// Helper function that converts a JSON array to a list of values of the type provided
+func decodeSet[T any](value interface{}, path string) ([]T, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("set at %s was %T, not an array", path, value)
+	}
+
+	set := make([]T, len(items))
+	for i, item := range items {
+		casted, ok := item.(T)
+		if !ok {
+			return nil, fmt.Errorf("set at %s contained %T but expected %T", path, item, *new(T))
+		}
+
+		set[i] = casted
+	}
+
+	return set, nil
 }
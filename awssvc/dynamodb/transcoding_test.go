@@ -210,6 +210,120 @@ var _ = Describe("Transcoding Tests", func() {
 			"\"MDAxMQ==\",\"MDAwMA==\"],\"l\":[true,false,true],\"m\":{\"n\":\"42\",\"s\":\"test\"}," +
 			"\"ns\":[\"42\",\"556\",\"72.99\",\"-14\"],\"ss\":[\"a\",\"b\",\"c\"]}"))
 	})
+
+	// Tests that, if the conversion is lossless, then the AttributeValuesToJSON function will write numbers as
+	// JSON numbers, keep NULL values and tag sets, binary values and ambiguous maps with their types
+	It("AttributeValuesToJSON - Lossless - Works", func() {
+
+		// First, create a collection of DynamoDB attributes with all our test data
+		attrs := map[string]types.AttributeValue{
+			"ss":   &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+			"null": &types.AttributeValueMemberNULL{Value: true},
+			"n":    &types.AttributeValueMemberN{Value: "12345678901234567890.123"},
+			"ns":   &types.AttributeValueMemberNS{Value: []string{"42", "-14"}},
+			"b":    &types.AttributeValueMemberB{Value: []byte("01")},
+			"bb":   &types.AttributeValueMemberBS{Value: [][]byte{[]byte("11"), []byte("00")}},
+			"m": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"SS": &types.AttributeValueMemberS{Value: "test"},
+			}},
+		}
+
+		// Next, attempt to convert this data to JSON; this should not fail
+		data, err := AttributeValuesToJSON(attrs, WithLosslessJSON(true))
+
+		// Finally, verify the JSON data
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(data)).Should(Equal("{\"b\":{\"B\":\"MDE=\"},\"bb\":{\"BS\":[\"MTE=\",\"MDA=\"]}," +
+			"\"m\":{\"M\":{\"SS\":\"test\"}},\"n\":12345678901234567890.123,\"ns\":{\"NS\":[42,-14]}," +
+			"\"null\":null,\"ss\":{\"SS\":[\"a\",\"b\"]}}"))
+	})
+
+	// Tests that, if the NULL handling is set to keep, then the AttributeValuesToJSON function will write
+	// NULL values as JSON nulls even if the conversion is not lossless
+	It("AttributeValuesToJSON - Keep NULLs - Works", func() {
+		data, err := AttributeValuesToJSON(map[string]types.AttributeValue{
+			"null": &types.AttributeValueMemberNULL{Value: true},
+			"n":    &types.AttributeValueMemberN{Value: "42"},
+		}, WithJSONNulls(NullsKeep))
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(data)).Should(Equal("{\"n\":\"42\",\"null\":null}"))
+	})
+
+	// Tests that the JSONToAttributeValues function converts each JSON type to its attribute value equivalent
+	It("JSONToAttributeValues - Works", func() {
+
+		// First, attempt to convert our test JSON to attribute values; this should not fail
+		attrs, err := JSONToAttributeValues([]byte("{\"s\":\"test\",\"n\":12345678901234567890.123,\"b\":true," +
+			"\"l\":[1,null,\"a\"],\"m\":{\"SS\":[\"a\"]},\"null\":null}"))
+
+		// Finally, verify the attribute values; the map should not have been treated as a tagged set and the
+		// null field should have been omitted
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(attrs).Should(Equal(map[string]types.AttributeValue{
+			"s": &types.AttributeValueMemberS{Value: "test"},
+			"n": &types.AttributeValueMemberN{Value: "12345678901234567890.123"},
+			"b": &types.AttributeValueMemberBOOL{Value: true},
+			"l": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+				&types.AttributeValueMemberN{Value: "1"},
+				&types.AttributeValueMemberNULL{Value: true},
+				&types.AttributeValueMemberS{Value: "a"},
+			}},
+			"m": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"SS": &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: "a"}}},
+			}},
+		}))
+	})
+
+	// Tests that, if the conversion is lossless, then converting attribute values to JSON and back again will
+	// produce the original attribute values
+	It("JSONToAttributeValues - Lossless - Round-trips", func() {
+
+		// First, create a collection of DynamoDB attributes with all our test data
+		attrs := map[string]types.AttributeValue{
+			"s":    &types.AttributeValueMemberS{Value: "test"},
+			"ss":   &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+			"null": &types.AttributeValueMemberNULL{Value: true},
+			"n":    &types.AttributeValueMemberN{Value: "12345678901234567890.123"},
+			"ns":   &types.AttributeValueMemberNS{Value: []string{"42", "-14", "1e10"}},
+			"b":    &types.AttributeValueMemberB{Value: []byte{0, 1, 255}},
+			"bb":   &types.AttributeValueMemberBS{Value: [][]byte{[]byte("11"), []byte("00")}},
+			"l": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+				&types.AttributeValueMemberBOOL{Value: true},
+				&types.AttributeValueMemberNULL{Value: true}}},
+			"m": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"M": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+					"B": &types.AttributeValueMemberS{Value: "test"},
+				}},
+			}},
+		}
+
+		// Next, attempt to convert the data to JSON and back again; this should not fail
+		data, err := AttributeValuesToJSON(attrs, WithLosslessJSON(true))
+		Expect(err).ShouldNot(HaveOccurred())
+		result, err := JSONToAttributeValues(data, WithLosslessJSON(true))
+
+		// Finally, verify that we have the original attribute values
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result).Should(Equal(attrs))
+	})
+
+	// Tests the conditions under which the JSONToAttributeValues function will return an error
+	DescribeTable("JSONToAttributeValues - Failures",
+		func(data string, message string) {
+			attrs, err := JSONToAttributeValues([]byte(data), WithLosslessJSON(true))
+			Expect(attrs).Should(BeNil())
+			Expect(err).Should(MatchError(message))
+		},
+		Entry("Invalid JSON - Error", "{", "failed to decode JSON object: unexpected EOF"),
+		Entry("Not an object - Error", "[1]", "failed to decode JSON object: json: cannot unmarshal "+
+			"array into Go value of type map[string]interface {}"),
+		Entry("Null - Error", "null", "failed to decode JSON object: data was null"),
+		Entry("Bad binary - Error", "{\"b\":{\"B\":\"!!\"}}",
+			"binary value at b.B could not be decoded: illegal base64 data at input byte 0"),
+		Entry("Bad string set - Error", "{\"s\":{\"SS\":[1]}}", "set at s.SS contained json.Number but expected string"),
+		Entry("Tagged top-level - Error", "{\"SS\":[\"a\"]}",
+			"failed to convert JSON object: expected a map but found *types.AttributeValueMemberSS"))
 })
 
 // Define a test type that we'll use to test marshal failures
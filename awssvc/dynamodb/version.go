@@ -0,0 +1,335 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// The struct tag used to mark fields with DynamoDB-specific behavior, and the value that marks a field as the
+// version attribute of an item. For example, a field tagged with `dynamo:"version"` will be used for
+// optimistic locking when the item is written with PutItemVersioned or UpdateItemVersioned
+const (
+	dynamoTag  = "dynamo"
+	versionTag = "version"
+)
+
+// Helper type that describes the version field of an item
+type versionField struct {
+	name    string
+	value   reflect.Value
+	current int64
+}
+
+// PutItemVersioned writes an item to DynamoDB using optimistic locking. The item must be a pointer to a struct
+// with an integer field tagged with `dynamo:"version"`. The version on the item will be incremented and the
+// item will be converted to attribute values and set on the input. The write will be conditioned on the
+// version of the item in DynamoDB matching the version of the item before it was incremented or, if the
+// version was zero, on the item not existing in DynamoDB. Any condition already on the input will be combined
+// with the version condition. If the versions do not match then an Error with a kind of KindVersionConflict
+// will be returned whereas, if the versions match but the existing condition failed, then the Error will have
+// a kind of KindConditionFailed. On any failure, the version on the item will be restored
+func (conn *DatabaseConnection) PutItemVersioned(ctx context.Context,
+	input *dynamodb.PutItemInput, item interface{}) (*dynamodb.PutItemOutput, error) {
+
+	// First, find the version field on the item; if this fails then return an error
+	tableName := aws.ToString(input.TableName)
+	version, err := conn.findVersion(tableName, item)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, increment the version on the item and convert it to attribute values on a copy of the input so
+	// that the caller's input can be reused; if this fails then restore the version and return an error
+	copied := *input
+	copied.ExpressionAttributeNames, copied.ExpressionAttributeValues =
+		copyPlaceholders(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	version.set(version.current + 1)
+	copied.Item, err = conn.MarshalMap(item)
+	if err != nil {
+		version.set(version.current)
+		return nil, err
+	}
+
+	// Now, add the version condition to the copy, combining it with any existing condition
+	combined := copied.ConditionExpression != nil
+	if err := conn.versionExpression(version, copied.ExpressionAttributeNames,
+		copied.ExpressionAttributeValues).ApplyPut(&copied); err != nil {
+		version.set(version.current)
+		return nil, conn.NewError(err, tableName, "Failed to add version condition to PUT request to %s", tableName)
+	}
+
+	// Finally, attempt to write the item to DynamoDB; if this fails then restore the version on the item and
+	// classify the error as a conflict if the version condition failed
+	output, err := conn.PutItem(ctx, &copied)
+	if err != nil {
+		version.set(version.current)
+		return nil, conn.versionConflict(ctx, tableName, copied.Item, combined, version, err)
+	}
+
+	return output, nil
+}
+
+// UpdateItemVersioned updates an item in DynamoDB using optimistic locking. The item must be a pointer to a
+// struct with an integer field tagged with `dynamo:"version"`, which should contain the version of the item
+// that is expected to be in DynamoDB. The update expression on the input will be modified to increment the
+// version and the update will be conditioned on the version of the item in DynamoDB matching the version on
+// the item or, if the version was zero, on the item not existing in DynamoDB. Any condition or update actions
+// already on the input will be combined with those for the version. If the update succeeds then the version
+// on the item will be incremented. If the versions do not match then an Error with a kind of
+// KindVersionConflict will be returned whereas, if the versions match but the existing condition failed, then
+// the Error will have a kind of KindConditionFailed
+func (conn *DatabaseConnection) UpdateItemVersioned(ctx context.Context,
+	input *dynamodb.UpdateItemInput, item interface{}) (*dynamodb.UpdateItemOutput, error) {
+
+	// First, find the version field on the item; if this fails then return an error
+	tableName := aws.ToString(input.TableName)
+	version, err := conn.findVersion(tableName, item)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, add the version condition and the action to increment the version to a copy of the input so that
+	// the caller's input can be reused, combining them with any existing condition or update actions
+	copied := *input
+	copied.ExpressionAttributeNames, copied.ExpressionAttributeValues =
+		copyPlaceholders(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	combined := copied.ConditionExpression != nil
+	if err := conn.versionExpression(version, copied.ExpressionAttributeNames, copied.ExpressionAttributeValues).
+		Set(version.name, version.current+1).ApplyUpdate(&copied); err != nil {
+		return nil, conn.NewError(err, tableName, "Failed to add version condition to UPDATE request to %s", tableName)
+	}
+
+	// Now, attempt to update the item in DynamoDB; if this fails then classify the error as a conflict if the
+	// version condition failed
+	output, err := conn.UpdateItem(ctx, &copied)
+	if err != nil {
+		return nil, conn.versionConflict(ctx, tableName, copied.Key, combined, version, err)
+	}
+
+	// Finally, since the update succeeded, increment the version on the item and return the output
+	version.set(version.current + 1)
+	return output, nil
+}
+
+// Helper function that creates shallow copies of the attribute names and values on an input so that they can
+// be modified without affecting the caller. Maps that were nil will remain nil
+func copyPlaceholders(names map[string]string,
+	values map[string]types.AttributeValue) (map[string]string, map[string]types.AttributeValue) {
+
+	var copiedNames map[string]string
+	if names != nil {
+		copiedNames = make(map[string]string, len(names))
+		for placeholder, name := range names {
+			copiedNames[placeholder] = name
+		}
+	}
+
+	var copiedValues map[string]types.AttributeValue
+	if values != nil {
+		copiedValues = copyItem(values)
+	}
+
+	return copiedNames, copiedValues
+}
+
+// Helper function that creates an expression containing the condition that the version of the item in
+// DynamoDB must match the current version. Any placeholders already in use on the input will be reserved
+func (conn *DatabaseConnection) versionExpression(version *versionField, names map[string]string,
+	values map[string]types.AttributeValue) *Expression {
+	exp := conn.NewExpression().reserve(names, values)
+	if version.current == 0 {
+		return exp.Condition(AttributeNotExists(version.name))
+	}
+
+	return exp.Condition(Equals(version.name, version.current))
+}
+
+// Helper function that finds the field tagged as the version on an item. The item must be a pointer to a
+// struct and the version field must be an integer. The name of the version attribute will be derived from
+// the tag key associated with the connection, or the name of the field if it has no such tag
+func (conn *DatabaseConnection) findVersion(tableName string, item interface{}) (*versionField, error) {
+
+	// First, ensure that the item is a non-nil pointer to a struct so that we can modify its version
+	value := reflect.ValueOf(item)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return nil, conn.NewError(nil, tableName, "Versioned item must be a pointer to a struct but was %T", item)
+	}
+
+	// Next, search the struct for the version field; if none was found then return an error
+	version, err := conn.searchVersion(value.Elem())
+	if err != nil {
+		return nil, conn.NewError(err, tableName, "Failed to find version field on %T", item)
+	} else if version == nil {
+		return nil, conn.NewError(nil, tableName, "No field on %T was tagged with `%s:\"%s\"`",
+			item, dynamoTag, versionTag)
+	}
+
+	// Finally, read the current version from the field and return it
+	switch version.value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		version.current = version.value.Int()
+	default:
+		version.current = int64(version.value.Uint())
+	}
+
+	return version, nil
+}
+
+// Helper function that searches a struct value, and any structs embedded in it, for the field tagged as the
+// version. If no such field was found then nil will be returned
+func (conn *DatabaseConnection) searchVersion(value reflect.Value) (*versionField, error) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+
+		// First, if the field is an embedded struct without a name of its own then search it for the version
+		name, hasName := field.Tag.Lookup(conn.tagKey)
+		name = strings.Split(name, ",")[0]
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			if version, err := conn.searchVersion(value.Field(i)); version != nil || err != nil {
+				return version, err
+			}
+
+			continue
+		}
+
+		// Next, check whether the field has been tagged as the version; if it hasn't then skip it
+		if !hasDynamoTag(field, versionTag) {
+			continue
+		}
+
+		// Now, ensure that the version field can be set, is an integer and will be written to DynamoDB
+		switch field.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			return nil, fmt.Errorf("version field %s must be an integer but was %s", field.Name, field.Type)
+		}
+
+		if !field.IsExported() || (hasName && name == "-") {
+			return nil, fmt.Errorf("version field %s must be exported and written to DynamoDB", field.Name)
+		}
+
+		// Finally, derive the name of the version attribute from the tag or the field name and return it
+		if name == "" {
+			name = field.Name
+		}
+
+		return &versionField{name: name, value: value.Field(i)}, nil
+	}
+
+	return nil, nil
+}
+
+// Helper function that sets the version on the field to the value provided
+func (version *versionField) set(value int64) {
+	switch version.value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		version.value.SetInt(value)
+	default:
+		version.value.SetUint(uint64(value))
+	}
+}
+
+// Helper function that determines whether or not a struct field has the option provided on its dynamo tag
+func hasDynamoTag(field reflect.StructField, option string) bool {
+	for _, part := range dynamoOptions(field) {
+		if part == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Helper function that returns the non-empty options on the dynamo tag of a struct field
+func dynamoOptions(field reflect.StructField) []string {
+	options := make([]string, 0)
+	for _, part := range strings.Split(field.Tag.Get(dynamoTag), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			options = append(options, part)
+		}
+	}
+
+	return options
+}
+
+// Helper function that classifies an error returned from a versioned write as a conflict if it was caused
+// by the version condition failing. If the version condition was combined with another condition then we can't
+// tell which one failed from the error alone so the version stored in DynamoDB will be read and the error will
+// only be classified as a conflict if that version differs from the current version. If the stored version
+// can't be read then the error will be left as a failed condition
+func (conn *DatabaseConnection) versionConflict(ctx context.Context, tableName string,
+	item map[string]types.AttributeValue, combined bool, version *versionField, err error) error {
+
+	// First, if the write didn't fail because of a condition then there's nothing to classify
+	casted, ok := err.(*Error)
+	if !ok || casted.Kind != KindConditionFailed {
+		return err
+	}
+
+	// Next, if the version condition was the only condition on the write then it must be the one that failed
+	if !combined {
+		casted.Kind = KindVersionConflict
+		return err
+	}
+
+	// Finally, read the version stored in DynamoDB and classify the error as a conflict if it differs
+	if stored, ok := conn.storedVersion(ctx, tableName, item, version.name); ok && stored != version.current {
+		casted.Kind = KindVersionConflict
+	}
+
+	return err
+}
+
+// Helper function that reads the version of an item stored in DynamoDB with a strongly consistent read. The
+// key will be extracted from the item using the key schema of the table. If the item or its version attribute
+// doesn't exist then the version will be zero. If the version couldn't be read then false will be returned
+func (conn *DatabaseConnection) storedVersion(ctx context.Context, tableName string,
+	item map[string]types.AttributeValue, name string) (int64, bool) {
+
+	// First, get the names of the key attributes of the table and extract the key from the item; if this
+	// fails then we can't read the version
+	names, err := conn.keyAttributes(ctx, tableName, nil)
+	if err != nil {
+		return 0, false
+	}
+
+	key := make(map[string]types.AttributeValue, len(names))
+	for _, keyName := range names {
+		attr, ok := item[keyName]
+		if !ok {
+			return 0, false
+		}
+
+		key[keyName] = attr
+	}
+
+	// Next, attempt to read the version attribute of the item from DynamoDB; if this fails then return false
+	output, err := conn.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:                aws.String(tableName),
+		Key:                      key,
+		ConsistentRead:           aws.Bool(true),
+		ProjectionExpression:     aws.String("#v"),
+		ExpressionAttributeNames: map[string]string{"#v": name},
+	})
+
+	if err != nil {
+		return 0, false
+	}
+
+	// Finally, if the item or its version doesn't exist then the version is zero; otherwise, parse the version
+	number, ok := output.Item[name].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, true
+	}
+
+	stored, err := strconv.ParseInt(number.Value, 10, 64)
+	return stored, err == nil
+}
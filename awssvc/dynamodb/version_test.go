@@ -0,0 +1,359 @@
+package dynamodb
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/utils"
+)
+
+var _ = Describe("Version Tests", func() {
+
+	// Test that, if the item has no version, then calling PutItemVersioned will increment the version on the
+	// item and condition the write on the item not existing
+	It("PutItemVersioned - New item - Works", func() {
+
+		// First, create our test connection from a client that will record the request
+		client, conn := createVersionConnection(false)
+
+		// Next, attempt to write a new item; this should not fail
+		item := versionedObject{ID: "test_id", SortKey: "test|sort|key", Data: 42}
+		_, err := conn.PutItemVersioned(context.Background(),
+			&dynamodb.PutItemInput{TableName: aws.String("TEST_TABLE")}, &item)
+
+		// Finally, verify the item and the request that was sent
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(item.Version).Should(Equal(1))
+		Expect(client.put.Item).Should(HaveKeyWithValue("version", &types.AttributeValueMemberN{Value: "1"}))
+		Expect(*client.put.ConditionExpression).Should(Equal("attribute_not_exists(#n0)"))
+		Expect(client.put.ExpressionAttributeNames).Should(Equal(map[string]string{"#n0": "version"}))
+	})
+
+	// Test that, if the input already has a condition, then calling PutItemVersioned will combine the version
+	// condition with it without reusing any of its placeholders
+	It("PutItemVersioned - Existing condition - Combined", func() {
+
+		// First, create our test connection from a client that will record the request
+		client, conn := createVersionConnection(false)
+
+		// Next, attempt to write an existing item with a condition on its data; this should not fail
+		item := versionedObject{ID: "test_id", SortKey: "test|sort|key", Data: 42, Version: 3}
+		_, err := conn.PutItemVersioned(context.Background(), &dynamodb.PutItemInput{
+			TableName:                 aws.String("TEST_TABLE"),
+			ConditionExpression:       aws.String("#n0 < :v0"),
+			ExpressionAttributeNames:  map[string]string{"#n0": "data"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":v0": &types.AttributeValueMemberN{Value: "50"}},
+		}, &item)
+
+		// Finally, verify the item and the request that was sent
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(item.Version).Should(Equal(4))
+		Expect(client.put.Item).Should(HaveKeyWithValue("version", &types.AttributeValueMemberN{Value: "4"}))
+		Expect(*client.put.ConditionExpression).Should(Equal("(#n0 < :v0) AND (#n1 = :v1)"))
+		Expect(client.put.ExpressionAttributeNames).Should(Equal(map[string]string{"#n0": "data", "#n1": "version"}))
+		Expect(client.put.ExpressionAttributeValues).Should(HaveKeyWithValue(":v1", &types.AttributeValueMemberN{Value: "3"}))
+	})
+
+	// Test that, if the version condition fails, then calling PutItemVersioned will return a conflict error
+	// and restore the version on the item
+	It("PutItemVersioned - Version mismatch - Conflict", func() {
+
+		// First, create our test connection from a client that will fail the condition
+		_, conn := createVersionConnection(true)
+
+		// Next, attempt to write the item; this should fail
+		item := versionedObject{ID: "test_id", SortKey: "test|sort|key", Data: 42, Version: 3}
+		_, err := conn.PutItemVersioned(context.Background(),
+			&dynamodb.PutItemInput{TableName: aws.String("TEST_TABLE")}, &item)
+
+		// Finally, verify the error and that the version was restored
+		casted := err.(*Error)
+		Expect(err).Should(HaveOccurred())
+		Expect(casted.Kind).Should(Equal(KindVersionConflict))
+		Expect(casted.IsConflict()).Should(BeTrue())
+		Expect(casted.TableName).Should(Equal("TEST_TABLE"))
+		Expect(item.Version).Should(Equal(3))
+	})
+
+	// Test that, if the version on the item matches the stored version but the condition already on the input
+	// fails, then calling PutItemVersioned will return a failed condition error rather than a conflict
+	It("PutItemVersioned - Existing condition fails, version matches - Condition failed", func() {
+
+		// First, create our test connection from a fake and write the item we'll overwrite
+		_, conn := createFakeConnection()
+		item := versionedObject{ID: "test_id", SortKey: "test|sort|key", Data: 42}
+		_, err := conn.PutItemVersioned(context.Background(),
+			&dynamodb.PutItemInput{TableName: aws.String("TEST_TABLE")}, &item)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, attempt to overwrite the item with a condition on its data that will fail; this should fail
+		_, err = conn.PutItemVersioned(context.Background(), &dynamodb.PutItemInput{
+			TableName:                 aws.String("TEST_TABLE"),
+			ConditionExpression:       aws.String("#n0 = :v0"),
+			ExpressionAttributeNames:  map[string]string{"#n0": "data"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":v0": &types.AttributeValueMemberN{Value: "99"}},
+		}, &item)
+
+		// Finally, verify the error and that the version was restored
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).Kind).Should(Equal(KindConditionFailed))
+		Expect(err.(*Error).IsConflict()).Should(BeFalse())
+		Expect(item.Version).Should(Equal(1))
+	})
+
+	// Test that, if the version on the item differs from the stored version and the input already has a
+	// condition, then calling PutItemVersioned will return a conflict error
+	It("PutItemVersioned - Existing condition, version mismatch - Conflict", func() {
+
+		// First, create our test connection from a fake and write the item we'll overwrite
+		_, conn := createFakeConnection()
+		item := versionedObject{ID: "test_id", SortKey: "test|sort|key", Data: 42}
+		_, err := conn.PutItemVersioned(context.Background(),
+			&dynamodb.PutItemInput{TableName: aws.String("TEST_TABLE")}, &item)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, attempt to overwrite the item with a stale version and a condition that would pass; this should fail
+		stale := versionedObject{ID: "test_id", SortKey: "test|sort|key", Data: 43, Version: 5}
+		_, err = conn.PutItemVersioned(context.Background(), &dynamodb.PutItemInput{
+			TableName:                 aws.String("TEST_TABLE"),
+			ConditionExpression:       aws.String("#n0 = :v0"),
+			ExpressionAttributeNames:  map[string]string{"#n0": "data"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":v0": &types.AttributeValueMemberN{Value: "42"}},
+		}, &stale)
+
+		// Finally, verify the error and that the version was restored
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).Kind).Should(Equal(KindVersionConflict))
+		Expect(stale.Version).Should(Equal(5))
+	})
+
+	// Test that, if the item has no version field, then calling PutItemVersioned will return an error
+	It("PutItemVersioned - No version field - Error", func() {
+
+		// First, create our test connection from a client that will record the request
+		client, conn := createVersionConnection(false)
+
+		// Next, attempt to write an item without a version field; this should fail
+		_, err := conn.PutItemVersioned(context.Background(),
+			&dynamodb.PutItemInput{TableName: aws.String("TEST_TABLE")}, &testObject{ID: "test_id"})
+
+		// Finally, verify the error and that no request was sent
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(HaveSuffix("No field on *dynamodb.testObject was tagged with `dynamo:\"version\"`."))
+		Expect(client.put).Should(BeNil())
+	})
+
+	// Test that calling UpdateItemVersioned will add the version increment to the update expression, condition
+	// the update on the version and increment the version on the item if the update succeeds
+	It("UpdateItemVersioned - No failures - Works", func() {
+
+		// First, create our test connection from a client that will record the request
+		client, conn := createVersionConnection(false)
+
+		// Next, attempt to update the data on the item; this should not fail
+		item := versionedObject{ID: "test_id", SortKey: "test|sort|key", Version: 7}
+		_, err := conn.UpdateItemVersioned(context.Background(), &dynamodb.UpdateItemInput{
+			TableName:                 aws.String("TEST_TABLE"),
+			UpdateExpression:          aws.String("SET #n0 = :v0"),
+			ExpressionAttributeNames:  map[string]string{"#n0": "data"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":v0": &types.AttributeValueMemberN{Value: "50"}},
+		}, &item)
+
+		// Finally, verify the item and the request that was sent
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(item.Version).Should(Equal(8))
+		Expect(*client.update.UpdateExpression).Should(Equal("SET #n0 = :v0, #n1 = :v2"))
+		Expect(*client.update.ConditionExpression).Should(Equal("#n1 = :v1"))
+		Expect(client.update.ExpressionAttributeValues).Should(Equal(map[string]types.AttributeValue{
+			":v0": &types.AttributeValueMemberN{Value: "50"},
+			":v1": &types.AttributeValueMemberN{Value: "7"},
+			":v2": &types.AttributeValueMemberN{Value: "8"},
+		}))
+	})
+
+	// Test that, if the version on the item matches the stored version but the condition already on the input
+	// fails, then calling UpdateItemVersioned will return a failed condition error rather than a conflict
+	It("UpdateItemVersioned - Existing condition fails, version matches - Condition failed", func() {
+
+		// First, create our test connection from a fake and write the item we'll update
+		_, conn := createFakeConnection()
+		item := versionedObject{ID: "test_id", SortKey: "test|sort|key", Data: 42}
+		_, err := conn.PutItemVersioned(context.Background(),
+			&dynamodb.PutItemInput{TableName: aws.String("TEST_TABLE")}, &item)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, attempt to update the item with a condition on its data that will fail; this should fail
+		_, err = conn.UpdateItemVersioned(context.Background(), &dynamodb.UpdateItemInput{
+			TableName:                aws.String("TEST_TABLE"),
+			Key:                      createFakeKey("test_id", "test|sort|key"),
+			UpdateExpression:         aws.String("SET #n0 = :v0"),
+			ConditionExpression:      aws.String("#n0 = :v1"),
+			ExpressionAttributeNames: map[string]string{"#n0": "data"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":v0": &types.AttributeValueMemberN{Value: "50"},
+				":v1": &types.AttributeValueMemberN{Value: "99"},
+			},
+		}, &item)
+
+		// Finally, verify the error and that the version was not modified
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).Kind).Should(Equal(KindConditionFailed))
+		Expect(item.Version).Should(Equal(1))
+	})
+
+	// Test that, if PutItemVersioned fails with a version conflict, then the same input can be reused to retry
+	// the write once the version on the item has been refreshed
+	It("PutItemVersioned - Retry same input after conflict - Works", func() {
+
+		// First, create our test connection from a fake and write the item we'll overwrite
+		_, conn := createFakeConnection()
+		item := versionedObject{ID: "test_id", SortKey: "test|sort|key", Data: 42}
+		_, err := conn.PutItemVersioned(context.Background(),
+			&dynamodb.PutItemInput{TableName: aws.String("TEST_TABLE")}, &item)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, attempt to overwrite the item with a stale version; this should fail with a conflict
+		input := dynamodb.PutItemInput{
+			TableName:                 aws.String("TEST_TABLE"),
+			ConditionExpression:       aws.String("#n0 = :v0"),
+			ExpressionAttributeNames:  map[string]string{"#n0": "data"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":v0": &types.AttributeValueMemberN{Value: "42"}},
+		}
+
+		stale := versionedObject{ID: "test_id", SortKey: "test|sort|key", Data: 43}
+		_, err = conn.PutItemVersioned(context.Background(), &input, &stale)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).Kind).Should(Equal(KindVersionConflict))
+
+		// Now, verify that the input was not modified by the failed attempt
+		Expect(input.Item).Should(BeNil())
+		Expect(*input.ConditionExpression).Should(Equal("#n0 = :v0"))
+		Expect(input.ExpressionAttributeNames).Should(Equal(map[string]string{"#n0": "data"}))
+		Expect(input.ExpressionAttributeValues).Should(HaveLen(1))
+
+		// Finally, refresh the version on the item and retry with the same input; this should not fail
+		stale.Version = 1
+		_, err = conn.PutItemVersioned(context.Background(), &input, &stale)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(stale.Version).Should(Equal(2))
+	})
+
+	// Test that, if UpdateItemVersioned fails with a version conflict, then the same input can be reused to
+	// retry the update once the version on the item has been refreshed
+	It("UpdateItemVersioned - Retry same input after conflict - Works", func() {
+
+		// First, create our test connection from a fake and write the item we'll update
+		_, conn := createFakeConnection()
+		item := versionedObject{ID: "test_id", SortKey: "test|sort|key", Data: 42}
+		_, err := conn.PutItemVersioned(context.Background(),
+			&dynamodb.PutItemInput{TableName: aws.String("TEST_TABLE")}, &item)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, attempt to update the item with a stale version; this should fail with a conflict
+		input := dynamodb.UpdateItemInput{
+			TableName:                 aws.String("TEST_TABLE"),
+			Key:                       createFakeKey("test_id", "test|sort|key"),
+			UpdateExpression:          aws.String("SET #n0 = :v0"),
+			ExpressionAttributeNames:  map[string]string{"#n0": "data"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":v0": &types.AttributeValueMemberN{Value: "50"}},
+		}
+
+		stale := versionedObject{ID: "test_id", SortKey: "test|sort|key", Version: 5}
+		_, err = conn.UpdateItemVersioned(context.Background(), &input, &stale)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).Kind).Should(Equal(KindVersionConflict))
+
+		// Now, verify that the input was not modified by the failed attempt
+		Expect(input.ConditionExpression).Should(BeNil())
+		Expect(*input.UpdateExpression).Should(Equal("SET #n0 = :v0"))
+		Expect(input.ExpressionAttributeNames).Should(Equal(map[string]string{"#n0": "data"}))
+		Expect(input.ExpressionAttributeValues).Should(HaveLen(1))
+
+		// Finally, refresh the version on the item and retry with the same input; this should not fail
+		stale.Version = 1
+		_, err = conn.UpdateItemVersioned(context.Background(), &input, &stale)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(stale.Version).Should(Equal(2))
+	})
+
+	// Test that, if the version condition fails, then calling Update on a versioned table will return a
+	// conflict error and will not modify the version on the item
+	It("Table.Update - Versioned, version mismatch - Conflict", func() {
+
+		// First, create our versioned test table from a client that will fail the condition
+		_, conn := createVersionConnection(true)
+		table := createTable[versionedObject](conn, "TEST_TABLE").WithVersioning()
+
+		// Next, create an update input that will modify the data on the item
+		var input dynamodb.UpdateItemInput
+		Expect(conn.NewExpression().Set("data", 50).ApplyUpdate(&input)).ShouldNot(HaveOccurred())
+
+		// Now, attempt to update the item; this should fail
+		item := versionedObject{ID: "test_id", SortKey: "test|sort|key", Version: 7}
+		_, err := table.Update(context.Background(), &item, &input)
+
+		// Finally, verify the error and that the version was not modified
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).IsConflict()).Should(BeTrue())
+		Expect(item.Version).Should(Equal(7))
+	})
+})
+
+// Helper type that we'll use to test versioned writes
+type versionedObject struct {
+	ID      string `json:"id" dynamo:"pk"`
+	SortKey string `json:"sort_key" dynamo:"sk"`
+	Data    int    `json:"data"`
+	Version int    `json:"version" dynamo:"version"`
+}
+
+// Helper function that creates a test connection from a client that records versioned writes
+func createVersionConnection(fail bool) (*versionDynamoDBClient, *DatabaseConnection) {
+	logger := utils.NewLogger("testd", "test")
+	logger.Discard()
+	client := &versionDynamoDBClient{fail: fail}
+	return client, FromClient(client, logger, WithBackoffStart(1), WithBackoffEnd(5), WithBackoffMaxElapsed(100))
+}
+
+// Helper type that mocks out PutItem and UpdateItem so that the requests are recorded and the condition on
+// each request either succeeds or fails
+type versionDynamoDBClient struct {
+	DynamoDBAPI
+	fail   bool
+	put    *dynamodb.PutItemInput
+	update *dynamodb.UpdateItemInput
+}
+
+// Mocks out the PutItem function so that it records the input and fails the condition if requested
+func (client *versionDynamoDBClient) PutItem(ctx context.Context,
+	params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	client.put = params
+	if client.fail {
+		return nil, conditionFailedError()
+	}
+
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// Mocks out the UpdateItem function so that it records the input and fails the condition if requested
+func (client *versionDynamoDBClient) UpdateItem(ctx context.Context,
+	params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	client.update = params
+	if client.fail {
+		return nil, conditionFailedError()
+	}
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+// Helper function that creates the error DynamoDB returns when a condition fails
+func conditionFailedError() error {
+	return &smithy.OperationError{
+		Err: &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")},
+	}
+}
@@ -0,0 +1,122 @@
+package lambda
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/xefino/goutils/awssvc/dynamodb"
+	"github.com/xefino/goutils/utils"
+)
+
+// StreamRecord contains the typed contents of a single record from a DynamoDB stream
+type StreamRecord[T any] struct {
+
+	// The type of modification that was made to the item; one of INSERT, MODIFY or REMOVE
+	EventName events.DynamoDBOperationType
+
+	// The primary key of the item that was modified
+	Keys map[string]types.AttributeValue
+
+	// The item as it appeared before it was modified. This will be nil for INSERT events or if the stream
+	// was not configured to include old images
+	OldImage *T
+
+	// The item as it appeared after it was modified. This will be nil for REMOVE events or if the stream
+	// was not configured to include new images
+	NewImage *T
+
+	// The raw record from the stream event
+	Raw events.DynamoDBEventRecord
+}
+
+// StreamRecordHandler defines the type of function that will be called with each record in a DynamoDB stream
+type StreamRecordHandler[T any] func(context.Context, *StreamRecord[T]) error
+
+// UnmarshalStreamImage converts an image from a DynamoDB stream record to a new value of type T. The image will
+// be decoded with the same rules as DatabaseConnection.UnmarshalMap, using the tag key associated with the
+// connection. If the image is empty then nil will be returned
+func UnmarshalStreamImage[T any](conn *dynamodb.DatabaseConnection,
+	image map[string]events.DynamoDBAttributeValue) (*T, error) {
+
+	// First, if the image is empty then there's nothing to decode so return nil
+	if len(image) == 0 {
+		return nil, nil
+	}
+
+	// Next, attempt to convert the image to DynamoDB attribute values; if this fails then return an error
+	attrs, err := toAttributeValues(image)
+	if err != nil {
+		return nil, err
+	}
+
+	// Finally, attempt to unmarshal the attribute values into a new value and return it
+	out := new(T)
+	if err := conn.UnmarshalMap(attrs, out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// NewStreamRecord converts a record from a DynamoDB stream to a typed StreamRecord, decoding its keys and images
+// with the rules associated with the connection
+func NewStreamRecord[T any](conn *dynamodb.DatabaseConnection, record events.DynamoDBEventRecord) (*StreamRecord[T], error) {
+
+	// First, convert the keys of the record to DynamoDB attribute values; if this fails then return an error
+	keys, err := toAttributeValues(record.Change.Keys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, decode the old and new images of the record; if either fails then return an error
+	oldImage, err := UnmarshalStreamImage[T](conn, record.Change.OldImage)
+	if err != nil {
+		return nil, err
+	}
+
+	newImage, err := UnmarshalStreamImage[T](conn, record.Change.NewImage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Finally, create the typed record from the decoded values and return it
+	return &StreamRecord[T]{
+		EventName: events.DynamoDBOperationType(record.EventName),
+		Keys:      keys,
+		OldImage:  oldImage,
+		NewImage:  newImage,
+		Raw:       record,
+	}, nil
+}
+
+// NewStreamHandler creates a Lambda handler for DynamoDB stream events that decodes each record and calls the
+// handler provided with it, in order. If a record can't be decoded or the handler returns an error then no
+// further records will be processed and the record will be reported as a batch item failure so that Lambda
+// retries the batch from that record. For this to work, the event source mapping must have the
+// ReportBatchItemFailures function response type enabled
+func NewStreamHandler[T any](conn *dynamodb.DatabaseConnection, logger *utils.Logger,
+	handler StreamRecordHandler[T]) func(context.Context, events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	return func(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+		var response events.DynamoDBEventResponse
+		for _, record := range event.Records {
+
+			// First, attempt to decode the record and handle it. If either fails then log the error
+			typed, err := NewStreamRecord[T](conn, record)
+			if err != nil {
+				err = logger.Error(err, "Failed to decode %s record %s", record.EventName, record.EventID)
+			} else if err = handler(ctx, typed); err != nil {
+				err = logger.Error(err, "Failed to handle %s record %s", record.EventName, record.EventID)
+			}
+
+			// Next, if the record failed then report it as a failure and stop so that it will be retried
+			if err != nil {
+				response.BatchItemFailures = append(response.BatchItemFailures,
+					events.DynamoDBBatchItemFailure{ItemIdentifier: record.Change.SequenceNumber})
+				break
+			}
+		}
+
+		return response, nil
+	}
+}
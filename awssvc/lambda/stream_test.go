@@ -0,0 +1,183 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/awssvc/dynamodb"
+	"github.com/xefino/goutils/utils"
+)
+
+var _ = Describe("Stream Tests", func() {
+
+	// Tests that ToAttributeValue will convert a stream attribute value to its SDK equivalent
+	It("ToAttributeValue - Works", func() {
+		attr, err := ToAttributeValue(events.NewMapAttribute(map[string]events.DynamoDBAttributeValue{
+			"n": events.NewNumberAttribute("42"),
+			"l": events.NewListAttribute([]events.DynamoDBAttributeValue{events.NewStringAttribute("a")}),
+		}))
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(attr).Should(Equal(&types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"n": &types.AttributeValueMemberN{Value: "42"},
+			"l": &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: "a"}}},
+		}}))
+	})
+
+	// Tests that UnmarshalStreamImage will decode an image using the tag key associated with the connection
+	It("UnmarshalStreamImage - Works", func() {
+
+		// First, create a connection with a custom tag key
+		conn := createStreamConnection()
+
+		// Next, attempt to decode a stream image; this should not fail
+		item, err := UnmarshalStreamImage[streamObject](conn, map[string]events.DynamoDBAttributeValue{
+			"pk":   events.NewStringAttribute("test_id"),
+			"sk":   events.NewStringAttribute("test_sort"),
+			"data": events.NewNumberAttribute("42"),
+		})
+
+		// Finally, verify the decoded item
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(item).Should(Equal(&streamObject{ID: "test_id", SortKey: "test_sort", Data: 42}))
+	})
+
+	// Tests that, if the image is empty, then UnmarshalStreamImage will return nil
+	It("UnmarshalStreamImage - Empty - Nil", func() {
+		item, err := UnmarshalStreamImage[streamObject](createStreamConnection(), nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(item).Should(BeNil())
+	})
+
+	// Tests that, if the image can't be decoded into the type, then UnmarshalStreamImage will return an error
+	It("UnmarshalStreamImage - Decode fails - Error", func() {
+		item, err := UnmarshalStreamImage[streamObject](createStreamConnection(), map[string]events.DynamoDBAttributeValue{
+			"data": events.NewStringAttribute("not a number"),
+		})
+
+		Expect(item).Should(BeNil())
+		Expect(err).Should(HaveOccurred())
+	})
+
+	// Tests that the stream handler will deliver each record with its typed images and event name
+	It("NewStreamHandler - Works", func() {
+
+		// First, create a handler that records every record it receives
+		records := make([]*StreamRecord[streamObject], 0)
+		handler := NewStreamHandler(createStreamConnection(), createStreamLogger(),
+			func(ctx context.Context, record *StreamRecord[streamObject]) error {
+				records = append(records, record)
+				return nil
+			})
+
+		// Next, call the handler with an insert and a modification; this should not fail
+		response, err := handler(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+			createStreamRecord("INSERT", "1", nil, &streamObject{ID: "a", SortKey: "b", Data: 1}),
+			createStreamRecord("MODIFY", "2", &streamObject{ID: "a", SortKey: "b", Data: 1},
+				&streamObject{ID: "a", SortKey: "b", Data: 2}),
+		}})
+
+		// Finally, verify the records that were delivered
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(response.BatchItemFailures).Should(BeEmpty())
+		Expect(records).Should(HaveLen(2))
+		Expect(records[0].EventName).Should(Equal(events.DynamoDBOperationTypeInsert))
+		Expect(records[0].OldImage).Should(BeNil())
+		Expect(records[0].NewImage).Should(Equal(&streamObject{ID: "a", SortKey: "b", Data: 1}))
+		Expect(records[0].Keys).Should(Equal(map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: "a"},
+			"sk": &types.AttributeValueMemberS{Value: "b"},
+		}))
+		Expect(records[1].EventName).Should(Equal(events.DynamoDBOperationTypeModify))
+		Expect(records[1].OldImage.Data).Should(Equal(1))
+		Expect(records[1].NewImage.Data).Should(Equal(2))
+	})
+
+	// Tests that, if the handler fails on a record, then the stream handler will report that record as a failure
+	// and will not process any further records
+	It("NewStreamHandler - Handler fails - Reported", func() {
+
+		// First, create a handler that fails on the second record
+		count := 0
+		handler := NewStreamHandler(createStreamConnection(), createStreamLogger(),
+			func(ctx context.Context, record *StreamRecord[streamObject]) error {
+				count++
+				if record.NewImage.Data == 2 {
+					return errors.New("failed")
+				}
+
+				return nil
+			})
+
+		// Next, call the handler with three records; this should not fail
+		response, err := handler(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+			createStreamRecord("INSERT", "1", nil, &streamObject{ID: "a", Data: 1}),
+			createStreamRecord("INSERT", "2", nil, &streamObject{ID: "b", Data: 2}),
+			createStreamRecord("INSERT", "3", nil, &streamObject{ID: "c", Data: 3}),
+		}})
+
+		// Finally, verify that the second record was reported and that the third was not processed
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(count).Should(Equal(2))
+		Expect(response.BatchItemFailures).Should(Equal([]events.DynamoDBBatchItemFailure{{ItemIdentifier: "2"}}))
+	})
+})
+
+// Helper type used to test that stream images are decoded with the tag key of the connection
+type streamObject struct {
+	ID      string `dynamodbav:"pk"`
+	SortKey string `dynamodbav:"sk"`
+	Data    int    `dynamodbav:"data"`
+}
+
+// Helper function that creates a stream record with the event name, sequence number and images provided
+func createStreamRecord(name string, sequence string, oldImage *streamObject,
+	newImage *streamObject) events.DynamoDBEventRecord {
+	toImage := func(item *streamObject) map[string]events.DynamoDBAttributeValue {
+		if item == nil {
+			return nil
+		}
+
+		return map[string]events.DynamoDBAttributeValue{
+			"pk":   events.NewStringAttribute(item.ID),
+			"sk":   events.NewStringAttribute(item.SortKey),
+			"data": events.NewNumberAttribute(fmt.Sprint(item.Data)),
+		}
+	}
+
+	image := newImage
+	if image == nil {
+		image = oldImage
+	}
+
+	return events.DynamoDBEventRecord{
+		EventID:   "event_" + sequence,
+		EventName: name,
+		Change: events.DynamoDBStreamRecord{
+			SequenceNumber: sequence,
+			Keys: map[string]events.DynamoDBAttributeValue{
+				"pk": events.NewStringAttribute(image.ID),
+				"sk": events.NewStringAttribute(image.SortKey),
+			},
+			OldImage: toImage(oldImage),
+			NewImage: toImage(newImage),
+		},
+	}
+}
+
+// Helper function that creates a connection with a custom tag key, used to decode stream images
+func createStreamConnection() *dynamodb.DatabaseConnection {
+	return dynamodb.FromClient(nil, createStreamLogger(), dynamodb.WithTagKey("dynamodbav"))
+}
+
+// Helper function that creates a logger that discards its output
+func createStreamLogger() *utils.Logger {
+	logger := utils.NewLogger("testd", "test")
+	logger.Discard()
+	return logger
+}
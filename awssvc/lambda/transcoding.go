@@ -1,81 +1,107 @@
 package lambda
 
 import (
-	"encoding/json"
 	"fmt"
 	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/xefino/goutils/awssvc/dynamodb"
 )
 
-// AttributesToJSON attempts to convert a mapping of DynamoDB attribute values to a properly-formatted JSON string
-func AttributesToJSON(attrs map[string]events.DynamoDBAttributeValue) ([]byte, error) {
+// AttributesToJSON attempts to convert a mapping of DynamoDB attribute values to a properly-formatted JSON string.
+// The conversion behaves in the same way as dynamodb.AttributeValuesToJSON and accepts the same options so, if
+// the JSON must be converted back to attribute values, the dynamodb.WithLosslessJSON option should be used
+func AttributesToJSON(attrs map[string]events.DynamoDBAttributeValue, opts ...dynamodb.IJSONOption) ([]byte, error) {
 
-	// Attempt to map the DynamoDB attribute value mapping to a map[string]interface{}
-	// If this fails then return an error
+	// Attempt to map the stream attribute values to DynamoDB attribute values; if this fails then return an error
 	keys := make([]string, 0)
-	mapping := toJSONInner(attrs, keys...)
+	mapping, err := toAttributeValues(attrs, keys...)
+	if err != nil {
+		return nil, err
+	}
 
 	// Attempt to convert this mapping to JSON and return the result
-	return json.Marshal(mapping)
+	return dynamodb.AttributeValuesToJSON(mapping, opts...)
+}
+
+// ToAttributeValues converts a mapping of DynamoDB stream attribute values, such as the keys or an image from a
+// stream record, to a mapping of DynamoDB attribute values that can be used with the DynamoDB SDK
+func ToAttributeValues(attrs map[string]events.DynamoDBAttributeValue) (map[string]types.AttributeValue, error) {
+	return toAttributeValues(attrs)
+}
+
+// ToAttributeValue converts a single DynamoDB stream attribute value to its DynamoDB attribute value equivalent
+func ToAttributeValue(attr events.DynamoDBAttributeValue) (types.AttributeValue, error) {
+	return toAttributeValue(attr)
 }
 
-// Helper function that converts a struct to JSON field-mapping
-func toJSONInner(attrs map[string]events.DynamoDBAttributeValue, keys ...string) map[string]interface{} {
-	jsonStr := make(map[string]interface{})
+// Helper function that converts a mapping of stream attribute values to a mapping of DynamoDB attribute values
+func toAttributeValues(attrs map[string]events.DynamoDBAttributeValue,
+	keys ...string) (map[string]types.AttributeValue, error) {
+	mapping := make(map[string]types.AttributeValue, len(attrs))
 	for key, attr := range attrs {
 
-		// Attempt to convert the field to a JSON mapping; if the value is nil then we'll ignore it and continue
-		casted := toJSONField(attr, append(keys, key)...)
-		if casted == nil {
-			continue
+		// Attempt to convert the field to a DynamoDB attribute value; if this fails then return an error
+		casted, err := toAttributeValue(attr, append(keys, key)...)
+		if err != nil {
+			return nil, err
 		}
 
 		// Set the field to its associated key in our mapping
-		jsonStr[key] = casted
+		mapping[key] = casted
 	}
 
-	return jsonStr
+	return mapping, nil
 }
 
-// Helper function that converts a specific DynamoDB attribute value to its JSON value equivalent
-func toJSONField(attr events.DynamoDBAttributeValue, keys ...string) interface{} {
+// Helper function that converts a specific stream attribute value to its DynamoDB attribute value equivalent
+func toAttributeValue(attr events.DynamoDBAttributeValue, keys ...string) (types.AttributeValue, error) {
 	attrType := attr.DataType()
 	switch attrType {
 	case events.DataTypeBinary:
-		return attr.Binary()
+		return &types.AttributeValueMemberB{Value: attr.Binary()}, nil
 	case events.DataTypeBinarySet:
-		return attr.BinarySet()
+		return &types.AttributeValueMemberBS{Value: attr.BinarySet()}, nil
 	case events.DataTypeBoolean:
-		return attr.Boolean()
+		return &types.AttributeValueMemberBOOL{Value: attr.Boolean()}, nil
 	case events.DataTypeList:
 
 		// Get the list of items from the attribute value
 		list := attr.List()
 
-		// Attempt to convert each item in the list to a JSON mapping
-		data := make([]interface{}, len(list))
+		// Attempt to convert each item in the list to a DynamoDB attribute value
+		data := make([]types.AttributeValue, len(list))
 		for i, item := range list {
-			casted := toJSONField(item, keys...)
+			casted, err := toAttributeValue(item, keys...)
+			if err != nil {
+				return nil, err
+			}
+
 			data[i] = casted
 		}
 
 		// Return the list we created
-		return data
+		return &types.AttributeValueMemberL{Value: data}, nil
 	case events.DataTypeMap:
-		return toJSONInner(attr.Map(), keys...)
+		mapping, err := toAttributeValues(attr.Map(), keys...)
+		if err != nil {
+			return nil, err
+		}
+
+		return &types.AttributeValueMemberM{Value: mapping}, nil
 	case events.DataTypeNull:
-		return nil
+		return &types.AttributeValueMemberNULL{Value: true}, nil
 	case events.DataTypeNumber:
-		return attr.Number()
+		return &types.AttributeValueMemberN{Value: attr.Number()}, nil
 	case events.DataTypeNumberSet:
-		return attr.NumberSet()
+		return &types.AttributeValueMemberNS{Value: attr.NumberSet()}, nil
 	case events.DataTypeString:
-		return attr.String()
+		return &types.AttributeValueMemberS{Value: attr.String()}, nil
 	case events.DataTypeStringSet:
-		return attr.StringSet()
+		return &types.AttributeValueMemberSS{Value: attr.StringSet()}, nil
 	default:
-		panic(fmt.Sprintf("Attribute at %s had unknown attribute type of %d",
-			strings.Join(keys, "."), attrType))
+		return nil, fmt.Errorf("attribute at %s had unknown attribute type of %d",
+			strings.Join(keys, "."), attrType)
 	}
 }
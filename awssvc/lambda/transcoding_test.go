@@ -2,8 +2,10 @@ package lambda
 
 import (
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/awssvc/dynamodb"
 )
 
 var _ = Describe("Transcoding Tests", func() {
@@ -35,4 +37,42 @@ var _ = Describe("Transcoding Tests", func() {
 			"\"MDAxMQ==\",\"MDAwMA==\"],\"l\":[true,false,true],\"m\":{\"n\":\"42\",\"s\":\"test\"}," +
 			"\"ns\":[\"42\",\"556\",\"72.99\",\"-14\"],\"ss\":[\"a\",\"b\",\"c\"]}"))
 	})
+	// Tests that, if the conversion is lossless, then the AttributesToJSON function will produce JSON that can
+	// be converted back to the original attribute values
+	It("AttributesToJSON - Lossless - Round-trips", func() {
+
+		// First, create a collection of DynamoDB attributes with all our test data
+		attrs := map[string]events.DynamoDBAttributeValue{
+			"ss":   events.NewStringSetAttribute([]string{"a", "b"}),
+			"null": events.NewNullAttribute(),
+			"n":    events.NewNumberAttribute("12345678901234567890.123"),
+			"ns":   events.NewNumberSetAttribute([]string{"42", "-14"}),
+			"b":    events.NewBinaryAttribute([]byte("01")),
+			"l":    events.NewListAttribute([]events.DynamoDBAttributeValue{events.NewBooleanAttribute(true)}),
+			"m": events.NewMapAttribute(map[string]events.DynamoDBAttributeValue{
+				"s": events.NewStringAttribute("test"),
+			}),
+		}
+
+		// Next, attempt to convert this data to JSON; this should not fail
+		data, err := AttributesToJSON(attrs, dynamodb.WithLosslessJSON(true))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(data)).Should(Equal("{\"b\":{\"B\":\"MDE=\"},\"l\":[true],\"m\":{\"s\":\"test\"}," +
+			"\"n\":12345678901234567890.123,\"ns\":{\"NS\":[42,-14]},\"null\":null,\"ss\":{\"SS\":[\"a\",\"b\"]}}"))
+
+		// Finally, convert the JSON back to attribute values and verify them
+		result, err := dynamodb.JSONToAttributeValues(data, dynamodb.WithLosslessJSON(true))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result).Should(Equal(map[string]types.AttributeValue{
+			"ss":   &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+			"null": &types.AttributeValueMemberNULL{Value: true},
+			"n":    &types.AttributeValueMemberN{Value: "12345678901234567890.123"},
+			"ns":   &types.AttributeValueMemberNS{Value: []string{"42", "-14"}},
+			"b":    &types.AttributeValueMemberB{Value: []byte("01")},
+			"l":    &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberBOOL{Value: true}}},
+			"m": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"s": &types.AttributeValueMemberS{Value: "test"},
+			}},
+		}))
+	})
 })
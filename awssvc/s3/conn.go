@@ -14,6 +14,8 @@ import (
 
 // IConnection describes the functionality encapsulated in an S3 connection
 type IConnection interface {
+	Delete(ctx context.Context, bucket string, key string) error
+	Download(ctx context.Context, bucket string, key string) ([]byte, error)
 	DownloadToStream(ctx context.Context, bucket string, key string) (io.Writer, error)
 	UploadFromStream(ctx context.Context, bucket string, key string, body io.Reader) error
 }
@@ -32,8 +34,8 @@ func NewConnection(cfg aws.Config, logger *utils.Logger) *Connection {
 	}
 }
 
-// DownloadToStream retrieves a file from S3 and downloads it to a stream so we can work with it
-func (conn *Connection) DownloadToStream(ctx context.Context, bucket string, key string) (io.Writer, error) {
+// Download retrieves a file from S3 and returns its contents
+func (conn *Connection) Download(ctx context.Context, bucket string, key string) ([]byte, error) {
 	conn.logger.Log("Attempting to download %s from %s in S3...", key, bucket)
 
 	// First, create a new S3 manager from our inner S3 connection
@@ -51,8 +53,19 @@ func (conn *Connection) DownloadToStream(ctx context.Context, bucket string, key
 		return nil, conn.logger.Error(err, "Failed to download from %s in %s in S3", key, bucket)
 	}
 
-	// Finally, write the data in the buffer to a new buffer and return it
-	return bytes.NewBuffer(buffer.Bytes()), nil
+	// Finally, return the data in the buffer
+	return buffer.Bytes(), nil
+}
+
+// DownloadToStream retrieves a file from S3 and downloads it to a stream so we can work with it. The stream
+// returned is a *bytes.Buffer so it may also be read from
+func (conn *Connection) DownloadToStream(ctx context.Context, bucket string, key string) (io.Writer, error) {
+	data, err := conn.Download(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewBuffer(data), nil
 }
 
 // UploadFromStream writes data in a stream to a file in S3
@@ -77,3 +90,21 @@ func (conn *Connection) UploadFromStream(ctx context.Context, bucket string, key
 
 	return nil
 }
+
+// Delete removes a file from S3
+func (conn *Connection) Delete(ctx context.Context, bucket string, key string) error {
+	conn.logger.Log("Attempting to delete %s from %s in S3...", key, bucket)
+
+	// First, create a new delete input with our bucket name and key
+	input := s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+
+	// Finally, delete the file from S3; if this fails then generate an error
+	if _, err := conn.inner.DeleteObject(ctx, &input); err != nil {
+		return conn.logger.Error(err, "Failed to delete %s from %s in S3", key, bucket)
+	}
+
+	return nil
+}
@@ -0,0 +1,254 @@
+package sqs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// CodecAttribute is the message attribute used to record the name of the codec that was used to encode the body of
+// a message so that consumers can detect how the message should be decoded
+const CodecAttribute = "MessageCodec"
+
+// ICodec describes the functionality necessary to convert values to and from the bodies of SQS messages. Encoded
+// bodies must only contain the characters SQS allows in messages so binary encodings should be base-64 encoded
+type ICodec interface {
+
+	// Name returns the name of the codec, which will be recorded in the CodecAttribute of messages it encodes
+	Name() string
+
+	// Encode converts the value to a message body
+	Encode(value any) (string, error)
+
+	// Decode converts the message body into the value provided
+	Decode(body string, value any) error
+}
+
+var (
+
+	// RawJSONCodec encodes messages as JSON without any further encoding
+	RawJSONCodec ICodec = rawJSONCodec{}
+
+	// Base64JSONCodec encodes messages as JSON, which is then base-64 encoded. This is the default codec
+	Base64JSONCodec ICodec = base64JSONCodec{}
+
+	// GzipJSONCodec encodes messages as JSON, which is then compressed with gzip and base-64 encoded
+	GzipJSONCodec ICodec = gzipJSONCodec{}
+
+	// ProtobufCodec encodes messages, which must implement proto.Message, in the protobuf binary format, which is
+	// then base-64 encoded
+	ProtobufCodec ICodec = protobufCodec{}
+)
+
+// Helper variable containing the built-in codecs, by name
+var builtinCodecs = map[string]ICodec{
+	RawJSONCodec.Name():    RawJSONCodec,
+	Base64JSONCodec.Name(): Base64JSONCodec,
+	GzipJSONCodec.Name():   GzipJSONCodec,
+	ProtobufCodec.Name():   ProtobufCodec,
+}
+
+// Helper type that encodes messages as raw JSON
+type rawJSONCodec struct{}
+
+// Name returns the name of the raw JSON codec
+func (rawJSONCodec) Name() string {
+	return "json"
+}
+
+// Encode converts the value to JSON
+func (rawJSONCodec) Encode(value any) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// Decode reads the value from JSON
+func (rawJSONCodec) Decode(body string, value any) error {
+	return json.Unmarshal([]byte(body), value)
+}
+
+// Helper type that encodes messages as base-64 encoded JSON
+type base64JSONCodec struct{}
+
+// Name returns the name of the base-64 JSON codec
+func (base64JSONCodec) Name() string {
+	return "base64+json"
+}
+
+// Encode converts the value to JSON and then encodes the JSON data as a base-64 string
+func (base64JSONCodec) Encode(value any) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Decode decodes the body from a base-64 string and then reads the value from the resulting JSON data
+func (base64JSONCodec) Decode(body string, value any) error {
+
+	// First, attempt to decode the body from a base-64 string; if this fails then return an error
+	data, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return err
+	}
+
+	// Finally, attempt to unmarshal the JSON data into the value
+	return json.Unmarshal(data, value)
+}
+
+// Helper type that encodes messages as gzipped JSON, encoded as a base-64 string
+type gzipJSONCodec struct{}
+
+// Name returns the name of the gzip JSON codec
+func (gzipJSONCodec) Name() string {
+	return "gzip+json"
+}
+
+// Encode converts the value to JSON, compresses the JSON data and then encodes it as a base-64 string
+func (gzipJSONCodec) Encode(value any) (string, error) {
+
+	// First, attempt to marshal the value to JSON; if this fails then return an error
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	// Next, compress the JSON data; if this fails then return an error
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		return "", err
+	} else if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	// Finally, encode the compressed data as a base-64 string
+	return base64.StdEncoding.EncodeToString(buffer.Bytes()), nil
+}
+
+// Decode decodes the body from a base-64 string, decompresses it and then reads the value from the resulting JSON
+func (gzipJSONCodec) Decode(body string, value any) error {
+
+	// First, attempt to decode the body from a base-64 string; if this fails then return an error
+	data, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return err
+	}
+
+	// Next, attempt to decompress the data; if this fails then return an error
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	defer reader.Close()
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	// Finally, attempt to unmarshal the JSON data into the value
+	return json.Unmarshal(decompressed, value)
+}
+
+// Helper type that encodes messages in the protobuf binary format, encoded as a base-64 string
+type protobufCodec struct{}
+
+// Name returns the name of the protobuf codec
+func (protobufCodec) Name() string {
+	return "protobuf"
+}
+
+// Encode converts the value to the protobuf binary format and then encodes it as a base-64 string. The value must
+// implement proto.Message
+func (protobufCodec) Encode(value any) (string, error) {
+
+	// First, ensure that the value is a protobuf message; if it isn't then return an error
+	message, ok := value.(proto.Message)
+	if !ok {
+		return "", fmt.Errorf("value of type %T does not implement proto.Message", value)
+	}
+
+	// Next, attempt to marshal the message; if this fails then return an error
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+
+	// Finally, encode the data as a base-64 string
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Decode decodes the body from a base-64 string and then reads the value from the resulting protobuf data. The
+// value must implement proto.Message or be a pointer to a proto.Message, in which case the message will be
+// allocated if it is nil
+func (protobufCodec) Decode(body string, value any) error {
+
+	// First, ensure that the value is a protobuf message, allocating it if we were given a pointer to a nil
+	// message; if it isn't a protobuf message then return an error
+	message, ok := value.(proto.Message)
+	if !ok {
+		pointer := reflect.ValueOf(value)
+		if pointer.Kind() != reflect.Pointer || pointer.IsNil() || pointer.Elem().Kind() != reflect.Pointer ||
+			!pointer.Elem().Type().Implements(reflect.TypeOf((*proto.Message)(nil)).Elem()) {
+			return fmt.Errorf("value of type %T does not implement proto.Message", value)
+		}
+
+		if pointer.Elem().IsNil() {
+			pointer.Elem().Set(reflect.New(pointer.Elem().Type().Elem()))
+		}
+
+		message = pointer.Elem().Interface().(proto.Message)
+	}
+
+	// Next, attempt to decode the body from a base-64 string; if this fails then return an error
+	data, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return err
+	}
+
+	// Finally, attempt to unmarshal the protobuf data into the message
+	return proto.Unmarshal(data, message)
+}
+
+// Helper function that finds the codec that should be used to decode a message given the name of the codec that was
+// recorded on it. The codecs provided will be checked before the built-in codecs. If no name was recorded then the
+// first codec provided will be used or, if none were provided, the default codec
+func findCodec(name string, codecs ...ICodec) (ICodec, error) {
+
+	// First, if no name was recorded then we don't know how the message was encoded so use the first codec provided
+	// or the default codec if none were provided
+	if name == "" {
+		if len(codecs) > 0 {
+			return codecs[0], nil
+		}
+
+		return Base64JSONCodec, nil
+	}
+
+	// Next, check if any of the codecs provided have the name; if one does then return it
+	for _, codec := range codecs {
+		if codec.Name() == name {
+			return codec, nil
+		}
+	}
+
+	// Finally, check if any of the built-in codecs have the name; if none do then return an error
+	if codec, ok := builtinCodecs[name]; ok {
+		return codec, nil
+	}
+
+	return nil, fmt.Errorf("message was encoded with unknown codec %q", name)
+}
@@ -0,0 +1,205 @@
+package sqs
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var _ = Describe("SQS Codec Tests", func() {
+
+	// Tests that each of the JSON codecs can decode the values they encode
+	DescribeTable("JSON Codecs - Encode, Decode - Round trip",
+		func(codec ICodec, name string) {
+
+			// First, encode a value with the codec; this should not fail
+			body, err := codec.Encode(testPayload{Name: "test", Value: 42})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			// Next, decode the body with the codec; this should not fail
+			var decoded testPayload
+			err = codec.Decode(body, &decoded)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			// Finally, verify the name of the codec and the value that was decoded
+			Expect(codec.Name()).Should(Equal(name))
+			Expect(decoded).Should(Equal(testPayload{Name: "test", Value: 42}))
+		},
+		Entry("Raw JSON", RawJSONCodec, "json"),
+		Entry("Base-64 JSON", Base64JSONCodec, "base64+json"),
+		Entry("Gzip JSON", GzipJSONCodec, "gzip+json"))
+
+	// Tests that the gzip codec compresses repetitive payloads
+	It("GzipJSONCodec - Encode - Compressed", func() {
+		payload := strings.Repeat("compress me ", 1000)
+		plain, err := Base64JSONCodec.Encode(payload)
+		Expect(err).ShouldNot(HaveOccurred())
+		compressed, err := GzipJSONCodec.Encode(payload)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(len(compressed)).Should(BeNumerically("<", len(plain)/10))
+	})
+
+	// Tests that the protobuf codec can decode the messages it encodes, whether it is given a message or a pointer to
+	// a message that hasn't been allocated yet
+	It("ProtobufCodec - Encode, Decode - Round trip", func() {
+
+		// First, encode a protobuf message; this should not fail
+		body, err := ProtobufCodec.Encode(wrapperspb.String("test"))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, decode the body into a message and verify it
+		var message wrapperspb.StringValue
+		Expect(ProtobufCodec.Decode(body, &message)).ShouldNot(HaveOccurred())
+		Expect(message.GetValue()).Should(Equal("test"))
+
+		// Finally, decode the body into a pointer to a nil message and verify that it was allocated
+		var pointer *wrapperspb.StringValue
+		Expect(ProtobufCodec.Decode(body, &pointer)).ShouldNot(HaveOccurred())
+		Expect(pointer).ShouldNot(BeNil())
+		Expect(pointer.GetValue()).Should(Equal("test"))
+	})
+
+	// Tests that the protobuf codec will reject values that aren't protobuf messages
+	It("ProtobufCodec - Not a protobuf message - Error", func() {
+		_, err := ProtobufCodec.Encode(testPayload{Name: "test"})
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(Equal("value of type sqs.testPayload does not implement proto.Message"))
+
+		var value testPayload
+		err = ProtobufCodec.Decode("", &value)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(Equal("value of type *sqs.testPayload does not implement proto.Message"))
+	})
+
+	// Tests that the codec used to decode a message is found from its name, preferring the codecs provided over the
+	// built-in codecs and falling back to a default if the message didn't record a codec
+	DescribeTable("findCodec",
+		func(name string, codecs []ICodec, expected ICodec, message string) {
+			codec, err := findCodec(name, codecs...)
+			if message != "" {
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(Equal(message))
+			} else {
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(codec).Should(Equal(expected))
+			}
+		},
+		Entry("No name, no codecs - Default", "", nil, Base64JSONCodec, ""),
+		Entry("No name, codecs - First codec", "", []ICodec{RawJSONCodec, GzipJSONCodec}, RawJSONCodec, ""),
+		Entry("Provided codec", "custom", []ICodec{renamedCodec{RawJSONCodec, "custom"}},
+			renamedCodec{RawJSONCodec, "custom"}, ""),
+		Entry("Built-in codec", "gzip+json", []ICodec{RawJSONCodec}, GzipJSONCodec, ""),
+		Entry("Unknown codec - Error", "derp", []ICodec{RawJSONCodec}, nil,
+			"message was encoded with unknown codec \"derp\""))
+
+	// Tests that SendMessage records the codec it used on the message and that the message can be received and
+	// decoded by a connection using a different codec
+	It("SendMessage - WithCodec - Codec recorded and decoded", func() {
+
+		// First, create a connection to a fake queue that will encode messages with gzip
+		fake := newFakeSQS()
+		conn := createConsumerConnection(fake, WithCodec{Codec: GzipJSONCodec})
+
+		// Next, send a message to the queue; this should not fail
+		_, err := conn.SendMessage(context.Background(), "test-queue", testPayload{Name: "test", Value: 42})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Now, verify that the codec was recorded on the message
+		Expect(fake.messages).Should(HaveLen(1))
+		Expect(*fake.messages[0].MessageAttributes[CodecAttribute].DataType).Should(Equal("String"))
+		Expect(*fake.messages[0].MessageAttributes[CodecAttribute].StringValue).Should(Equal("gzip+json"))
+
+		// Finally, receive the message with a connection using the default codec and verify that it was decoded
+		// with the codec recorded on it
+		messages, err := ReceiveMessages[testPayload](context.Background(), createConsumerConnection(fake),
+			"test-queue")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(messages).Should(HaveLen(1))
+		Expect(messages[0].Err).ShouldNot(HaveOccurred())
+		Expect(messages[0].Body).Should(Equal(testPayload{Name: "test", Value: 42}))
+	})
+
+	// Tests that messages that don't record a codec, such as those sent by other producers, will be decoded with the
+	// codec associated with the connection that received them
+	It("ReceiveMessages - No codec recorded - Connection codec used", func() {
+
+		// First, create a fake queue with a raw JSON message that doesn't record its codec
+		fake := newFakeSQS()
+		fake.addRaw("raw", `{"Name":"test","Value":42}`, 1)
+
+		// Next, receive the message with a connection that uses the raw JSON codec; this should not fail
+		messages, err := ReceiveMessages[testPayload](context.Background(),
+			createConsumerConnection(fake, WithCodec{Codec: RawJSONCodec}), "test-queue")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that the message was decoded
+		Expect(messages).Should(HaveLen(1))
+		Expect(messages[0].Err).ShouldNot(HaveOccurred())
+		Expect(messages[0].Body).Should(Equal(testPayload{Name: "test", Value: 42}))
+	})
+
+	// Tests that messages recording a codec that isn't known will fail to decode
+	It("ReceiveMessages - Unknown codec - Error reported on message", func() {
+
+		// First, create a fake queue with a message recording an unknown codec
+		fake := newFakeSQS()
+		fake.addRaw("unknown", "{}", 1)
+		fake.messages[0].MessageAttributes = map[string]types.MessageAttributeValue{
+			CodecAttribute: {DataType: aws.String("String"), StringValue: aws.String("derp")},
+		}
+
+		// Next, receive the message from the queue; this should not fail
+		messages, err := ReceiveMessages[testPayload](context.Background(), createConsumerConnection(fake),
+			"test-queue")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that the message failed to decode
+		Expect(messages).Should(HaveLen(1))
+		Expect(messages[0].Err).Should(HaveOccurred())
+		Expect(messages[0].Err.Error()).Should(ContainSubstring("message was encoded with unknown codec \"derp\""))
+	})
+
+	// Tests that SendMessages records the codec on each of the messages it sends and that protobuf messages can be
+	// sent and then decoded
+	It("SendMessages - ProtobufCodec - Codec recorded on each entry", func() {
+
+		// First, create a connection to a fake queue that will encode messages as protobuf
+		fake := newFakeSQS()
+		conn := createConsumerConnection(fake, WithCodec{Codec: ProtobufCodec})
+
+		// Next, send some messages to the queue; this should not fail
+		_, err := conn.SendMessages(context.Background(), "test-queue",
+			[]any{wrapperspb.String("a"), wrapperspb.String("b")})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that each entry recorded the codec and can be decoded
+		batches := fake.sentBatches()
+		Expect(batches).Should(HaveLen(1))
+		Expect(batches[0].Entries).Should(HaveLen(2))
+		for i, expected := range []string{"a", "b"} {
+			entry := batches[0].Entries[i]
+			Expect(*entry.MessageAttributes[CodecAttribute].StringValue).Should(Equal("protobuf"))
+
+			var value *wrapperspb.StringValue
+			Expect(ProtobufCodec.Decode(*entry.MessageBody, &value)).ShouldNot(HaveOccurred())
+			Expect(proto.Equal(value, wrapperspb.String(expected))).Should(BeTrue())
+		}
+	})
+})
+
+// Helper type that wraps a codec with a different name
+type renamedCodec struct {
+	ICodec
+	name string
+}
+
+// Name returns the name of the codec
+func (codec renamedCodec) Name() string {
+	return codec.name
+}
@@ -2,23 +2,31 @@ package sqs
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
+	"sort"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
-	"github.com/xefino/goutils/collections"
+	"github.com/cenkalti/backoff/v4"
 	"github.com/xefino/goutils/concurrency"
 	"github.com/xefino/goutils/utils"
 )
 
+// maxBatchPayloadSize is the largest total payload, in bytes, that SQS will accept in a single batch
+const maxBatchPayloadSize = 262144
+
 // SQSConnection contains functinoality allowing for systemical access to SQS
 type SQSConnection struct {
 	sqs           SQSAPI
 	logger        *utils.Logger
 	sendBatchSize int
+	sendAttempts  int
+	sendBackoff   WithSendRetryBackoff
+	extended      *WithExtendedClient
+	codec         ICodec
 }
 
 // NewSQSConnection creates a new SQS connection from an AWS session and logger
@@ -34,6 +42,12 @@ func FromClient(inner SQSAPI, logger *utils.Logger, options ...SQSOption) *SQSCo
 		sqs:           inner,
 		logger:        logger,
 		sendBatchSize: 10,
+		sendAttempts:  3,
+		codec:         Base64JSONCodec,
+		sendBackoff: WithSendRetryBackoff{
+			Initial: 100 * time.Millisecond,
+			Max:     5 * time.Second,
+		},
 	}
 
 	// Next, iterate over all the options and apply each to the connection
@@ -50,17 +64,17 @@ func FromClient(inner SQSAPI, logger *utils.Logger, options ...SQSOption) *SQSCo
 func (conn *SQSConnection) SendMessage(ctx context.Context, url string, item any,
 	options ...SendMessageOption) (*sqs.SendMessageOutput, error) {
 
-	// First, attempt to marshal the item to JSON; if this fails then return an error
-	data, err := json.Marshal(item)
+	// First, attempt to encode the item with the connection's codec; if this fails then return an error
+	body, err := conn.codec.Encode(item)
 	if err != nil {
 		return nil, conn.logger.Error(err, "Failed to convert payload to JSON")
 	}
 
-	// Next, encode the JSON data as a base-64 string and then embed it into a send-message input
-	body := base64.StdEncoding.EncodeToString(data)
+	// Next, embed the body into a send-message input, recording the codec that was used to encode it
 	input := sqs.SendMessageInput{
-		MessageBody: aws.String(body),
-		QueueUrl:    aws.String(url),
+		MessageAttributes: conn.codecAttributes(),
+		MessageBody:       aws.String(body),
+		QueueUrl:          aws.String(url),
 	}
 
 	// Now, iterate over all the options and apply them to the input
@@ -68,6 +82,12 @@ func (conn *SQSConnection) SendMessage(ctx context.Context, url string, item any
 		option.Apply(&input)
 	}
 
+	// If the message is too large to send to SQS then offload its body to S3; if this fails then return an error
+	input.MessageBody, input.MessageAttributes, err = conn.offloadPayload(ctx, input.MessageBody, input.MessageAttributes)
+	if err != nil {
+		return nil, conn.logger.Error(err, "Failed to offload SQS message payload to S3")
+	}
+
 	// Finally, attempt to send the message to SQS; if this fails then return an error
 	output, err := conn.sqs.SendMessage(ctx, &input)
 	if err != nil {
@@ -78,16 +98,22 @@ func (conn *SQSConnection) SendMessage(ctx context.Context, url string, item any
 }
 
 // SendMessages attempts to convert the list of items to a batched message and send it to the SQS queue
-// indicated by the URL. The options provided may be used to modify the request
+// indicated by the URL. The options provided may be used to modify the request. Items are paged so that each
+// batch contains no more than the batch size and no more than the maximum payload size SQS allows. The ID of
+// each result entry will be the index of the associated item. Items that cannot be encoded, or whose payloads
+// cannot be offloaded to S3, will not be sent and will be returned as failed. Entries that fail for reasons that
+// were not the fault of the sender will be retried with backoff before being returned as failed
 func (conn *SQSConnection) SendMessages(ctx context.Context, url string, items []any,
 	options ...SendMessageBatchOption) (*sqs.SendMessageBatchOutput, error) {
 
-	// First, page the items into a number of slices
-	pages := collections.Page(items, conn.sendBatchSize)
+	// First, encode the items and page them into a number of batches, recording the items that couldn't be sent
+	pages, failed := conn.pageMessages(ctx, items, options...)
 
-	// Next, create our combined output with failed and successful result entries
+	// Next, create our combined output with failed and successful result entries and a lock that will be used to
+	// synchronize access to it
+	var lock sync.Mutex
 	output := sqs.SendMessageBatchOutput{
-		Failed:     make([]types.BatchResultErrorEntry, 0),
+		Failed:     failed,
 		Successful: make([]types.SendMessageBatchResultEntry, 0),
 	}
 
@@ -96,12 +122,14 @@ func (conn *SQSConnection) SendMessages(ctx context.Context, url string, items [
 		func(ctx context.Context, index int, cancel context.CancelFunc) error {
 
 			// First, attempt to send the batched message; collect the output and error
-			pageOut, err := conn.sendMessagesInner(ctx, url, pages[index], index*conn.sendBatchSize, options...)
+			pageOut, err := conn.sendMessagesInner(ctx, url, pages[index].entries)
 
 			// Next, add the failed and successful results to the output if it exists
 			if pageOut != nil {
+				lock.Lock()
 				output.Failed = append(output.Failed, pageOut.Failed...)
 				output.Successful = append(output.Successful, pageOut.Successful...)
+				lock.Unlock()
 			}
 
 			// Finally, if we received an error then wrap, log and return it
@@ -112,7 +140,16 @@ func (conn *SQSConnection) SendMessages(ctx context.Context, url string, items [
 			return nil
 		})
 
-	// Finally, return the combined output and error (if any)
+	// Finally, sort the results so they're in the same order as the items and return the combined output and
+	// error (if any)
+	sort.Slice(output.Failed, func(i, j int) bool {
+		return entryIndex(output.Failed[i].Id) < entryIndex(output.Failed[j].Id)
+	})
+
+	sort.Slice(output.Successful, func(i, j int) bool {
+		return entryIndex(output.Successful[i].Id) < entryIndex(output.Successful[j].Id)
+	})
+
 	return &output, err
 }
 
@@ -141,38 +178,170 @@ func (conn *SQSConnection) GetURL(ctx context.Context, queueName string,
 	return *output.QueueUrl, nil
 }
 
-// Helper function that sends a page of messages to the SQS queue
-func (conn *SQSConnection) sendMessagesInner(ctx context.Context, url string, items []any, offset int,
-	options ...SendMessageBatchOption) (*sqs.SendMessageBatchOutput, error) {
+// Helper type containing a page of entries that will be sent together with SQS.SendMessageBatch
+type messagePage struct {
+	entries []types.SendMessageBatchRequestEntry
+	size    int
+}
 
-	// First, create the base send-message-batch input from the URL and a list of entries we'll fill in
-	input := sqs.SendMessageBatchInput{
-		QueueUrl: aws.String(url),
-		Entries:  make([]types.SendMessageBatchRequestEntry, len(items)),
-	}
+// Helper function that encodes each item as a send-message batch entry and pages the entries so that no page
+// exceeds the batch size or the maximum payload size. Entries that are too large to send to SQS will be offloaded
+// to S3 if the connection was configured to do so. If an item cannot be encoded, or its payload cannot be
+// offloaded, then it will be skipped and a failed result entry will be returned for it instead
+func (conn *SQSConnection) pageMessages(ctx context.Context, items []any,
+	options ...SendMessageBatchOption) ([]*messagePage, []types.BatchResultErrorEntry) {
+
+	// First, create the list of pages, the page we'll be filling and the list of items that failed
+	pages := make([]*messagePage, 0)
+	current := new(messagePage)
+	failed := make([]types.BatchResultErrorEntry, 0)
 
-	// Next, attempt to fill in the message batch entries with the items provided
+	// Next, iterate over all the items and add each to a page
 	for i, item := range items {
 
-		// First, attempt to marshal the item to JSON; if this fails then return an error
-		data, err := json.Marshal(item)
+		// First, attempt to encode the item with the connection's codec; if this fails then the item was at fault
+		// so record it as failed and skip it
+		body, err := conn.codec.Encode(item)
 		if err != nil {
-			return nil, err
+			failed = append(failed, failedEntry(i, "InvalidMessageContents", true, err))
+			continue
 		}
 
-		// Next, encode the JSON data as a base-64 string and then embed it into a send-message input
-		body := base64.StdEncoding.EncodeToString(data)
-		input.Entries[i] = types.SendMessageBatchRequestEntry{
-			Id:          aws.String(strconv.FormatInt(int64(i), 10)),
-			MessageBody: aws.String(body),
+		// Next, embed the body into a batch entry with the index of the item as its ID, recording the codec that
+		// was used to encode it
+		entry := types.SendMessageBatchRequestEntry{
+			Id:                aws.String(strconv.Itoa(i)),
+			MessageAttributes: conn.codecAttributes(),
+			MessageBody:       aws.String(body),
 		}
 
-		// Finally, iterate over all the options and apply each to this entry
+		// Now, iterate over all the options and apply each to this entry. Then, if the entry is too large to send
+		// to SQS then offload its body to S3; if this fails then record the item as failed and skip it
 		for _, option := range options {
-			option.ApplyBatch(i+offset, &input.Entries[i])
+			option.ApplyBatch(i, &entry)
+		}
+
+		entry.MessageBody, entry.MessageAttributes, err = conn.offloadPayload(ctx, entry.MessageBody,
+			entry.MessageAttributes)
+		if err != nil {
+			failed = append(failed, failedEntry(i, "PayloadOffloadFailed", false, err))
+			continue
 		}
+
+		// Finally, if adding the entry would make the current page too large then start a new page. After that,
+		// add the entry to the current page
+		size := messageSize(entry.MessageBody, entry.MessageAttributes)
+		if len(current.entries) > 0 && (len(current.entries) >= conn.sendBatchSize ||
+			current.size+size > maxBatchPayloadSize) {
+			pages = append(pages, current)
+			current = new(messagePage)
+		}
+
+		current.entries = append(current.entries, entry)
+		current.size += size
+	}
+
+	// Finally, add the last page if it has any entries and return the pages and failures
+	if len(current.entries) > 0 {
+		pages = append(pages, current)
+	}
+
+	return pages, failed
+}
+
+// Helper function that creates a failed result entry for an item that could not be sent, with the index of the
+// item as its ID and the error as its message
+func failedEntry(index int, code string, senderFault bool, err error) types.BatchResultErrorEntry {
+	return types.BatchResultErrorEntry{
+		Id:          aws.String(strconv.Itoa(index)),
+		Code:        aws.String(code),
+		Message:     aws.String(err.Error()),
+		SenderFault: senderFault,
 	}
+}
+
+// Helper function that sends a page of messages to the SQS queue, retrying entries that failed for reasons that
+// were not the fault of the sender until they succeed or we run out of attempts
+func (conn *SQSConnection) sendMessagesInner(ctx context.Context, url string,
+	entries []types.SendMessageBatchRequestEntry) (*sqs.SendMessageBatchOutput, error) {
+
+	// First, create the output we'll return and the backoff we'll use to wait between attempts
+	output := sqs.SendMessageBatchOutput{}
+	timer := conn.createExponentialBackoff()
+
+	// Next, index the entries by their ID so we can find the entries that failed
+	byID := make(map[string]types.SendMessageBatchRequestEntry, len(entries))
+	for _, entry := range entries {
+		byID[*entry.Id] = entry
+	}
+
+	// Now, send the entries until none are left to retry or we run out of attempts
+	pending := entries
+	for attempt := 1; ; attempt++ {
+
+		// First, attempt to send the batched message to SQS; if this fails then return an error
+		pageOut, err := conn.sqs.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+			QueueUrl: aws.String(url),
+			Entries:  pending,
+		})
+
+		if err != nil {
+			return &output, err
+		}
+
+		// Next, add the successful entries to our output and collect the failed entries that can be retried
+		output.Successful = append(output.Successful, pageOut.Successful...)
+		retry := make([]types.BatchResultErrorEntry, 0)
+		for _, failed := range pageOut.Failed {
+			if failed.SenderFault || attempt >= conn.sendAttempts {
+				output.Failed = append(output.Failed, failed)
+			} else {
+				retry = append(retry, failed)
+			}
+		}
+
+		// Now, if we have no entries to retry then we're done
+		if len(retry) == 0 {
+			return &output, nil
+		}
+
+		// Finally, wait for the backoff before retrying the failed entries. If the context is cancelled while we're
+		// waiting then return the entries we didn't retry as failed
+		sleep(ctx, timer.NextBackOff())
+		if err := ctx.Err(); err != nil {
+			output.Failed = append(output.Failed, retry...)
+			return &output, err
+		}
+
+		pending = make([]types.SendMessageBatchRequestEntry, len(retry))
+		for i, failed := range retry {
+			pending[i] = byID[aws.ToString(failed.Id)]
+		}
+	}
+}
+
+// Helper function that creates an exponential backoff timer from the values stored on the connection
+func (conn *SQSConnection) createExponentialBackoff() *backoff.ExponentialBackOff {
+	timer := backoff.NewExponentialBackOff()
+	timer.InitialInterval = conn.sendBackoff.Initial
+	timer.MaxInterval = conn.sendBackoff.Max
+	timer.MaxElapsedTime = 0
+	timer.Reset()
+	return timer
+}
+
+// Helper function that creates the message attributes recording the codec used by the connection
+func (conn *SQSConnection) codecAttributes() map[string]types.MessageAttributeValue {
+	return map[string]types.MessageAttributeValue{
+		CodecAttribute: {
+			DataType:    aws.String("String"),
+			StringValue: aws.String(conn.codec.Name()),
+		},
+	}
+}
 
-	// Finally, attempt to send the batched message to SQS; if this fails then return an error
-	return conn.sqs.SendMessageBatch(ctx, &input)
+// Helper function that extracts the item index from the ID of a batch result entry
+func entryIndex(id *string) int {
+	index, _ := strconv.Atoi(aws.ToString(id))
+	return index
 }
@@ -5,6 +5,9 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
@@ -103,11 +106,11 @@ var _ = Describe("SQS Connection Tests", Ordered, func() {
 
 		// Finally, verify the error that was returned
 		Expect(url).Should(BeEmpty())
-		testutils.ErrorVerifier("test", "sqs", "/goutils/awssvc/sqs/conn.go", "SQSConnection", "GetURL", 137,
+		testutils.ErrorVerifier("test", "sqs", "/goutils/awssvc/sqs/conn.go", "SQSConnection", "GetURL", 174,
 			testutils.InnerErrorVerifier("operation error SQS: GetQueueUrl, https response error StatusCode: "+
 				"400, RequestID: 00000000-0000-0000-0000-000000000000, AWS.SimpleQueueService.NonExistentQueue: "),
 			"Failed to retrieve SQS queue URL for queue \"test-fail\"", "[test] sqs.SQSConnection.GetURL "+
-				"(/goutils/awssvc/sqs/conn.go 137): Failed to retrieve SQS queue URL for queue \"test-fail\", "+
+				"(/goutils/awssvc/sqs/conn.go 174): Failed to retrieve SQS queue URL for queue \"test-fail\", "+
 				"Inner:\n\toperation error SQS: GetQueueUrl, https response error StatusCode: 400, RequestID: "+
 				"00000000-0000-0000-0000-000000000000, AWS.SimpleQueueService.NonExistentQueue: .")(err.(*utils.GError))
 	})
@@ -155,10 +158,10 @@ var _ = Describe("SQS Connection Tests", Ordered, func() {
 
 		// Finally, verify the error we received
 		Expect(output).Should(BeNil())
-		testutils.ErrorVerifier("test", "sqs", "/goutils/awssvc/sqs/conn.go", "SQSConnection", "SendMessage", 56,
+		testutils.ErrorVerifier("test", "sqs", "/goutils/awssvc/sqs/conn.go", "SQSConnection", "SendMessage", 70,
 			testutils.InnerErrorVerifier("json: unsupported type: chan error"),
 			"Failed to convert payload to JSON",
-			"[test] sqs.SQSConnection.SendMessage (/goutils/awssvc/sqs/conn.go 56): Failed to convert "+
+			"[test] sqs.SQSConnection.SendMessage (/goutils/awssvc/sqs/conn.go 70): Failed to convert "+
 				"payload to JSON, Inner:\n\tjson: unsupported type: chan error.")(err.(*utils.GError))
 	})
 
@@ -187,12 +190,12 @@ var _ = Describe("SQS Connection Tests", Ordered, func() {
 
 		// Finally, verify the error we received
 		Expect(output).Should(BeNil())
-		testutils.ErrorVerifier("test", "sqs", "/goutils/awssvc/sqs/conn.go", "SQSConnection", "SendMessage", 74,
+		testutils.ErrorVerifier("test", "sqs", "/goutils/awssvc/sqs/conn.go", "SQSConnection", "SendMessage", 94,
 			testutils.InnerErrorVerifier("operation error SQS: SendMessage, https response error StatusCode: "+
 				"400, RequestID: 00000000-0000-0000-0000-000000000000, api error AWS.SimpleQueueService."+
 				"NonExistentQueue: The specified queue does not exist for this wsdl version."),
 			"Failed to send SQS message to \"fail-queue\"",
-			"[test] sqs.SQSConnection.SendMessage (/goutils/awssvc/sqs/conn.go 74): Failed to send SQS "+
+			"[test] sqs.SQSConnection.SendMessage (/goutils/awssvc/sqs/conn.go 94): Failed to send SQS "+
 				"message to \"fail-queue\", Inner:\n\toperation error SQS: SendMessage, https response "+
 				"error StatusCode: 400, RequestID: 00000000-0000-0000-0000-000000000000, api error "+
 				"AWS.SimpleQueueService.NonExistentQueue: The specified queue does not exist for this "+
@@ -255,8 +258,9 @@ var _ = Describe("SQS Connection Tests", Ordered, func() {
 		Expect(check.Value).Should(Equal("test-value"))
 	})
 
-	// Tests that, if the payload cannot be converted to JSON, then calling SendMessages will result in an error
-	It("SendMessages - JSON marshal fails - Error", func() {
+	// Tests that, if the payloads cannot be converted to JSON, then calling SendMessages will return them as
+	// failed entries without an error
+	It("SendMessages - JSON marshal fails - Failed entries", func() {
 
 		// First, create a new logger and discard its output
 		logger := utils.NewLogger("testd", "test")
@@ -283,7 +287,7 @@ var _ = Describe("SQS Connection Tests", Ordered, func() {
 			},
 		}
 
-		// Now, attempt to send the messages to SQS; this should fail
+		// Now, attempt to send the messages to SQS; this should not fail
 		output, err := client.SendMessages(context.Background(), queueUrl, items,
 			WithBatchDeduplicationID(func(i int) string { return "derp1" }),
 			WithBatchMessageGroupID(func(i int) string { return "derp2" }),
@@ -291,15 +295,16 @@ var _ = Describe("SQS Connection Tests", Ordered, func() {
 				return &types.MessageAttributeValue{DataType: aws.String("string"), StringValue: aws.String("derp3")}
 			}))
 
-		// Finally, verify the error we received
-		Expect(output.Failed).Should(BeEmpty())
+		// Finally, verify that both items were returned as failed
+		Expect(err).ShouldNot(HaveOccurred())
 		Expect(output.Successful).Should(BeEmpty())
-		testutils.ErrorVerifier("test", "sqs", "/goutils/awssvc/sqs/conn.go", "SQSConnection", "SendMessages", 109,
-			testutils.InnerErrorVerifier("json: unsupported type: chan error"),
-			"Failed to send page 0 of batched message to \"http://us-east-1.goaws.com:4100/100010001000/test-queue\"",
-			"[test] sqs.SQSConnection.SendMessages (/goutils/awssvc/sqs/conn.go 109): Failed to send "+
-				"page 0 of batched message to \"http://us-east-1.goaws.com:4100/100010001000/test-queue\", "+
-				"Inner:\n\tjson: unsupported type: chan error.")(err.(*utils.GError))
+		Expect(output.Failed).Should(HaveLen(2))
+		for i, entry := range output.Failed {
+			Expect(*entry.Id).Should(Equal(strconv.Itoa(i)))
+			Expect(*entry.Code).Should(Equal("InvalidMessageContents"))
+			Expect(*entry.Message).Should(Equal("json: unsupported type: chan error"))
+			Expect(entry.SenderFault).Should(BeTrue())
+		}
 	})
 
 	// Tests that, if the the call to SendMessageBatch fails, then calling SendMessages will result in an error
@@ -341,12 +346,12 @@ var _ = Describe("SQS Connection Tests", Ordered, func() {
 		// Finally, verify the error we received
 		Expect(output.Failed).Should(BeEmpty())
 		Expect(output.Successful).Should(BeEmpty())
-		testutils.ErrorVerifier("test", "sqs", "/goutils/awssvc/sqs/conn.go", "SQSConnection", "SendMessages", 109,
+		testutils.ErrorVerifier("test", "sqs", "/goutils/awssvc/sqs/conn.go", "SQSConnection", "SendMessages", 137,
 			testutils.InnerErrorVerifier("operation error SQS: SendMessageBatch, https response error "+
 				"StatusCode: 400, RequestID: 00000000-0000-0000-0000-000000000000, api error AWS.SimpleQueueService."+
 				"NonExistentQueue: The specified queue does not exist for this wsdl version."),
 			"Failed to send page 0 of batched message to \"fail-queue\"", "[test] sqs.SQSConnection.SendMessages "+
-				"(/goutils/awssvc/sqs/conn.go 109): Failed to send page 0 of batched message to \"fail-queue\", "+
+				"(/goutils/awssvc/sqs/conn.go 137): Failed to send page 0 of batched message to \"fail-queue\", "+
 				"Inner:\n\toperation error SQS: SendMessageBatch, https response error StatusCode: 400, "+
 				"RequestID: 00000000-0000-0000-0000-000000000000, api error AWS.SimpleQueueService.NonExistentQueue: "+
 				"The specified queue does not exist for this wsdl version.")(err.(*utils.GError))
@@ -423,3 +428,154 @@ var _ = Describe("SQS Connection Tests", Ordered, func() {
 		}
 	})
 })
+
+var _ = Describe("SQS SendMessages Batching Tests", func() {
+
+	// Tests that SendMessages will page items so that no batch exceeds the batch size
+	It("SendMessages - Many items - Paged by batch size", func() {
+
+		// First, create a connection to a fake queue and some small items to send
+		fake := newFakeSQS()
+		conn := createConsumerConnection(fake)
+		items := make([]any, 25)
+		for i := range items {
+			items[i] = i
+		}
+
+		// Next, attempt to send the items; this should not fail
+		output, err := conn.SendMessages(context.Background(), "test-queue", items,
+			WithBatchMessageGroupID(func(i int) string { return fmt.Sprintf("group-%d", i) }))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Now, verify that the items were sent in pages of ten
+		batches := fake.sentBatches()
+		sizes := make([]int, len(batches))
+		for i, batch := range batches {
+			sizes[i] = len(batch.Entries)
+			for _, entry := range batch.Entries {
+				Expect(*entry.MessageGroupId).Should(Equal("group-" + *entry.Id))
+			}
+		}
+
+		Expect(sizes).Should(ConsistOf(10, 10, 5))
+
+		// Finally, verify that all the items were sent and that the results are in the order of the items
+		Expect(output.Failed).Should(BeEmpty())
+		Expect(output.Successful).Should(HaveLen(25))
+		for i, entry := range output.Successful {
+			Expect(*entry.Id).Should(Equal(fmt.Sprint(i)))
+		}
+	})
+
+	// Tests that SendMessages will page items so that no batch exceeds the maximum payload size
+	It("SendMessages - Large items - Paged by payload size", func() {
+
+		// First, create a connection to a fake queue and some items that are large enough that only three will fit
+		// into a single batch
+		fake := newFakeSQS()
+		conn := createConsumerConnection(fake)
+		items := make([]any, 7)
+		for i := range items {
+			items[i] = strings.Repeat("a", 60000)
+		}
+
+		// Next, attempt to send the items with an attribute; this should not fail
+		output, err := conn.SendMessages(context.Background(), "test-queue", items,
+			WithBatchMessageAttribute("source", func(i int) *types.MessageAttributeValue {
+				return &types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String("test")}
+			}))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(output.Successful).Should(HaveLen(7))
+
+		// Finally, verify that the items were sent in batches that fit within the payload limit
+		batches := fake.sentBatches()
+		sizes := make([]int, len(batches))
+		for i, batch := range batches {
+			sizes[i] = len(batch.Entries)
+
+			total := 0
+			for _, entry := range batch.Entries {
+				total += messageSize(entry.MessageBody, entry.MessageAttributes)
+			}
+
+			Expect(total).Should(BeNumerically("<=", 262144))
+		}
+
+		Expect(sizes).Should(ConsistOf(3, 3, 1))
+	})
+
+	// Tests that SendMessages will retry entries that failed through no fault of the sender until they succeed
+	// or the attempts are exhausted, and that entries failed by the sender will not be retried
+	It("SendMessages - Entries fail - Retryable entries retried", func() {
+
+		// First, create a connection to a fake queue where one entry fails once, one entry fails because of the
+		// sender and one entry always fails
+		fake := newFakeSQS()
+		fake.failures["1"] = []bool{false}
+		fake.failures["2"] = []bool{true}
+		fake.failures["3"] = []bool{false, false, false, false}
+		conn := createConsumerConnection(fake,
+			WithSendRetryBackoff{Initial: time.Millisecond, Max: time.Millisecond})
+
+		// Next, attempt to send the items; this should not fail
+		output, err := conn.SendMessages(context.Background(), "test-queue", []any{0, 1, 2, 3, 4})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Now, verify the entries that succeeded and failed
+		Expect(output.Successful).Should(HaveLen(3))
+		Expect(*output.Successful[0].Id).Should(Equal("0"))
+		Expect(*output.Successful[1].Id).Should(Equal("1"))
+		Expect(*output.Successful[2].Id).Should(Equal("4"))
+		Expect(output.Failed).Should(HaveLen(2))
+		Expect(*output.Failed[0].Id).Should(Equal("2"))
+		Expect(output.Failed[0].SenderFault).Should(BeTrue())
+		Expect(*output.Failed[1].Id).Should(Equal("3"))
+		Expect(output.Failed[1].SenderFault).Should(BeFalse())
+
+		// Finally, verify that only the retryable entries were retried and that they were retried no more than
+		// the maximum number of attempts
+		batches := fake.sentBatches()
+		Expect(batches).Should(HaveLen(3))
+		Expect(batches[0].Entries).Should(HaveLen(5))
+		Expect(entryIDs(batches[1].Entries)).Should(Equal([]string{"1", "3"}))
+		Expect(entryIDs(batches[2].Entries)).Should(Equal([]string{"3"}))
+	})
+
+	// Tests that, if an item cannot be converted to JSON, then SendMessages will return it as a failed entry but
+	// will still send the other items, including those on the same page
+	It("SendMessages - JSON marshal fails - Other items sent", func() {
+
+		// First, create a connection to a fake queue with a batch size of two
+		fake := newFakeSQS()
+		conn := createConsumerConnection(fake, WithSendMessagesBatchSize(2))
+
+		// Next, attempt to send items, one of which cannot be converted to JSON; this should not fail
+		output, err := conn.SendMessages(context.Background(), "test-queue", []any{0, make(chan error), 2, 3})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that the item was returned as failed and that the remaining items were sent
+		Expect(output.Failed).Should(HaveLen(1))
+		Expect(*output.Failed[0].Id).Should(Equal("1"))
+		Expect(*output.Failed[0].Message).Should(Equal("json: unsupported type: chan error"))
+		Expect(output.Failed[0].SenderFault).Should(BeTrue())
+		Expect(output.Successful).Should(HaveLen(3))
+		Expect(*output.Successful[0].Id).Should(Equal("0"))
+		Expect(*output.Successful[1].Id).Should(Equal("2"))
+		Expect(*output.Successful[2].Id).Should(Equal("3"))
+
+		batches := fake.sentBatches()
+		Expect(batches).Should(HaveLen(2))
+		Expect([][]string{entryIDs(batches[0].Entries), entryIDs(batches[1].Entries)}).Should(
+			ConsistOf([]string{"0", "2"}, []string{"3"}))
+	})
+})
+
+// Helper function that returns the IDs of the batch entries
+func entryIDs(entries []types.SendMessageBatchRequestEntry) []string {
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = *entry.Id
+	}
+
+	return ids
+}
@@ -0,0 +1,236 @@
+package sqs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/xefino/goutils/concurrency"
+	"github.com/xefino/goutils/math"
+)
+
+// maxVisibilityTimeout is the largest visibility timeout, in seconds, that SQS will accept
+const maxVisibilityTimeout = 43200
+
+// MessageHandler describes a function that will be called with each message received by a Consumer. If the
+// handler returns nil then the message will be deleted from the queue. Otherwise, the message will be left on
+// the queue so that it can be redelivered
+type MessageHandler[T any] func(context.Context, *Message[T]) error
+
+// Consumer long-polls an SQS queue and dispatches the messages it receives to a handler function through a
+// bounded pool of workers. Message bodies are expected to be in the format written by SendMessage
+type Consumer[T any] struct {
+	conn      *SQSConnection
+	url       string
+	handler   MessageHandler[T]
+	heartbeat *VisibilityHeartbeat
+	config    consumerConfig
+}
+
+// NewConsumer creates a new Consumer that will receive messages from the SQS queue indicated by the URL, using the
+// connection provided, and dispatch them to the handler. The options provided may be used to modify the consumer
+func NewConsumer[T any](conn *SQSConnection, url string, handler MessageHandler[T],
+	options ...ConsumerOption) *Consumer[T] {
+
+	// First, create our consumer with default values
+	consumer := Consumer[T]{
+		conn:    conn,
+		url:     url,
+		handler: handler,
+		config: consumerConfig{
+			workers:     10,
+			maxMessages: 10,
+			waitTime:    20,
+			errorDelay:  time.Second,
+		},
+	}
+
+	// Next, iterate over all the options and apply each to the consumer
+	for _, option := range options {
+		option.Apply(&consumer.config)
+	}
+
+	// Now, if the consumer should extend the visibility of the messages it's handling then create its heartbeat
+	if consumer.config.heartbeat != nil {
+		consumer.heartbeat = NewVisibilityHeartbeat(conn, url, consumer.config.heartbeat...)
+	}
+
+	// Finally, return a reference to the consumer
+	return &consumer
+}
+
+// Run receives messages from the queue and dispatches them to the handler until the context is cancelled. When
+// this happens, no more messages will be received and Run will wait for any messages currently being handled
+// to finish before returning. Handlers are called with a context that is not cancelled along with the context
+// provided here so that in-flight messages may finish; if a shutdown timeout was set then this context will be
+// cancelled once the timeout has elapsed after shutdown has started
+func (consumer *Consumer[T]) Run(ctx context.Context) error {
+
+	// First, create the context our workers will use; this context will carry the values from the parent context
+	// but will not be cancelled with it
+	workCtx, cancel := context.WithCancel(concurrency.WithoutCancel(ctx))
+	defer cancel()
+
+	// If we have a heartbeat then run it alongside the workers so that it stops when they do
+	if consumer.heartbeat != nil {
+		go consumer.heartbeat.Run(workCtx)
+	}
+
+	// Next, create a semaphore that limits the number of messages being handled at any one time and a wait group
+	// we'll use to wait for in-flight messages during shutdown
+	slots := make(chan struct{}, consumer.config.workers)
+	var wg sync.WaitGroup
+
+	// Now, receive messages until the context is cancelled
+	for ctx.Err() == nil {
+
+		// First, wait until we have at least one free worker; if the context is cancelled while we're waiting
+		// then stop receiving messages
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+
+		// Next, reserve as many additional workers as are free, up to the maximum number of messages we can
+		// receive at once, so that we don't receive messages we can't handle immediately
+		reserved := 1
+	Reserve:
+		for reserved < int(consumer.config.maxMessages) {
+			select {
+			case slots <- struct{}{}:
+				reserved++
+			default:
+				break Reserve
+			}
+		}
+
+		// Now, attempt to receive messages from the queue. If this fails because we're shutting down then stop
+		// receiving. Otherwise, log the error and wait a while before trying again
+		messages, err := consumer.receive(ctx, reserved)
+		if err != nil && ctx.Err() == nil {
+			consumer.conn.logger.Error(err, "Failed to receive SQS messages from %q", consumer.url)
+			sleep(ctx, consumer.config.errorDelay)
+		}
+
+		// Finally, release any workers we didn't use and dispatch each message to a worker
+		for i := len(messages); i < reserved; i++ {
+			<-slots
+		}
+
+		for _, message := range messages {
+			wg.Add(1)
+			go func(message *Message[T]) {
+				defer func() {
+					<-slots
+					wg.Done()
+				}()
+
+				consumer.process(workCtx, message)
+			}(message)
+		}
+	}
+
+	// If we have a shutdown timeout then cancel the workers' context once it has elapsed
+	if consumer.config.shutdownTimeout > 0 {
+		timer := time.AfterFunc(consumer.config.shutdownTimeout, cancel)
+		defer timer.Stop()
+	}
+
+	// Finally, wait for all the in-flight messages to be handled before returning
+	wg.Wait()
+	return nil
+}
+
+// Helper function that receives up to the number of messages provided from the queue
+func (consumer *Consumer[T]) receive(ctx context.Context, count int) ([]*Message[T], error) {
+	return receiveMessages[T](ctx, consumer.conn, &sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String(consumer.url),
+		AttributeNames:        []types.QueueAttributeName{types.QueueAttributeNameAll},
+		MaxNumberOfMessages:   int32(count),
+		MessageAttributeNames: []string{"All"},
+		VisibilityTimeout:     consumer.config.visibilityTimeout,
+		WaitTimeSeconds:       consumer.config.waitTime,
+	})
+}
+
+// Helper function that passes a message to the handler and then deletes the message if the handler succeeded or
+// changes its visibility if the handler failed and a retry backoff was set. Messages that could not be decoded
+// will not be passed to the handler and will be left on the queue. Payloads offloaded to S3 will be deleted along
+// with their messages if the connection was configured to do so
+func (consumer *Consumer[T]) process(ctx context.Context, message *Message[T]) {
+
+	// First, if we decoded the message then pass it to the handler
+	err := message.Err
+	if err == nil {
+		if err = consumer.handle(ctx, message); err != nil {
+			consumer.conn.logger.Error(err, "Failed to handle SQS message %q from %q", message.ID, consumer.url)
+		}
+	}
+
+	// Next, if the message was handled successfully then delete it from the queue
+	if err == nil {
+		DeleteMessage(ctx, consumer.conn, consumer.url, message)
+		return
+	}
+
+	// Finally, the message failed so, if we have a retry backoff, change its visibility so that it will be
+	// redelivered after the backoff has elapsed
+	if consumer.config.backoff == nil || message.Err != nil {
+		return
+	}
+
+	if _, err := consumer.conn.sqs.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(consumer.url),
+		ReceiptHandle:     aws.String(message.ReceiptHandle),
+		VisibilityTimeout: consumer.config.backoff.timeout(message.ReceiveCount),
+	}); err != nil {
+		consumer.conn.logger.Error(err, "Failed to change visibility of SQS message %q from %q", message.ID, consumer.url)
+	}
+}
+
+// Helper function that passes a message to the handler, extending its visibility while the handler is running if
+// the consumer has a heartbeat
+func (consumer *Consumer[T]) handle(ctx context.Context, message *Message[T]) error {
+	if consumer.heartbeat != nil {
+		stop := consumer.heartbeat.Track(message.ReceiptHandle)
+		defer stop()
+	}
+
+	return consumer.handler(ctx, message)
+}
+
+// Helper function that calculates the visibility timeout, in seconds, to use for a message that has been received
+// the number of times provided. The timeout doubles with each receive, up to the maximum
+func (backoff *WithRetryBackoff) timeout(receiveCount int) int32 {
+
+	// First, determine the maximum interval; if none was set or it exceeds what SQS will accept then use the
+	// largest timeout SQS will accept
+	limit := maxVisibilityTimeout * time.Second
+	if backoff.Max > 0 {
+		limit = math.Min(backoff.Max, limit)
+	}
+
+	// Next, calculate the backoff by doubling the initial interval for each receive after the first, stopping
+	// once we reach the maximum so that we don't overflow
+	interval := backoff.Initial
+	for i := 1; i < receiveCount && interval < limit; i++ {
+		interval *= 2
+	}
+
+	// Finally, ensure the interval is within the limit and convert it to seconds
+	return int32(math.Max(math.Min(interval, limit), 0).Seconds())
+}
+
+// Helper function that waits for the duration to elapse or for the context to finish, whichever happens first
+func sleep(ctx context.Context, duration time.Duration) {
+	wait := time.NewTimer(duration)
+	defer wait.Stop()
+	select {
+	case <-ctx.Done():
+	case <-wait.C:
+	}
+}
@@ -0,0 +1,478 @@
+package sqs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/math"
+	"github.com/xefino/goutils/utils"
+)
+
+var _ = Describe("SQS Consumer Tests", func() {
+
+	// Tests that messages received by the consumer will be handled and then deleted from the queue
+	It("Run - Handler succeeds - Messages deleted", func() {
+
+		// First, create a fake queue with some messages and a consumer that records the messages it handles
+		fake := newFakeSQS()
+		fake.add(3, 1)
+		fake.add(4, 1)
+		var lock sync.Mutex
+		handled := make([]int, 0)
+		consumer := NewConsumer(createConsumerConnection(fake), "test-queue",
+			func(ctx context.Context, message *Message[int]) error {
+				lock.Lock()
+				defer lock.Unlock()
+				handled = append(handled, message.Body)
+				return nil
+			})
+
+		// Next, run the consumer until both messages have been deleted
+		ctx, cancel := context.WithCancel(context.Background())
+		done := runConsumer(ctx, consumer)
+		Eventually(fake.deletedHandles).Should(ConsistOf("3", "4"))
+
+		// Finally, shut the consumer down and verify the messages that were handled
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(handled).Should(ConsistOf(3, 4))
+		Expect(fake.visibilityChanges()).Should(BeEmpty())
+	})
+
+	// Tests that, if the handler fails, then the message will not be deleted and its visibility will not be
+	// changed if no retry backoff was set
+	It("Run - Handler fails, no backoff - Message left on queue", func() {
+
+		// First, create a fake queue with a message and a consumer whose handler fails
+		fake := newFakeSQS()
+		fake.add(3, 1)
+		called := make(chan int, 1)
+		consumer := NewConsumer(createConsumerConnection(fake), "test-queue",
+			func(ctx context.Context, message *Message[int]) error {
+				called <- message.Body
+				return fmt.Errorf("handler failed")
+			})
+
+		// Next, run the consumer until the message has been handled and then shut it down
+		ctx, cancel := context.WithCancel(context.Background())
+		done := runConsumer(ctx, consumer)
+		Eventually(called).Should(Receive(Equal(3)))
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+
+		// Finally, verify that the message was neither deleted nor had its visibility changed
+		Expect(fake.deletedHandles()).Should(BeEmpty())
+		Expect(fake.visibilityChanges()).Should(BeEmpty())
+	})
+
+	// Tests that, if the handler fails and a retry backoff was set, then the message's visibility will be changed
+	// based on the number of times it has been received
+	It("Run - Handler fails, backoff set - Visibility changed", func() {
+
+		// First, create a fake queue with messages that have been received a number of times and a consumer whose
+		// handler fails and which has a retry backoff
+		fake := newFakeSQS()
+		fake.add(1, 1)
+		fake.add(2, 3)
+		fake.add(3, 10)
+		consumer := NewConsumer(createConsumerConnection(fake), "test-queue",
+			func(ctx context.Context, message *Message[int]) error {
+				return fmt.Errorf("handler failed")
+			}, WithRetryBackoff{Initial: 10 * time.Second, Max: time.Minute})
+
+		// Next, run the consumer until all the messages have had their visibility changed and then shut it down
+		ctx, cancel := context.WithCancel(context.Background())
+		done := runConsumer(ctx, consumer)
+		Eventually(fake.visibilityChanges).Should(HaveLen(3))
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+
+		// Finally, verify the visibility timeouts that were set
+		Expect(fake.visibilityChanges()).Should(Equal(map[string]int32{"1": 10, "2": 40, "3": 60}))
+		Expect(fake.deletedHandles()).Should(BeEmpty())
+	})
+
+	// Tests that, if a message cannot be decoded, then the handler will not be called and the message will be
+	// left on the queue
+	It("Run - Decode fails - Message left on queue", func() {
+
+		// First, create a fake queue with a message that isn't base-64 encoded and a consumer that records
+		// whether its handler was called
+		fake := newFakeSQS()
+		fake.addRaw("bad", "not-base64!", 1)
+		called := false
+		consumer := NewConsumer(createConsumerConnection(fake), "test-queue",
+			func(ctx context.Context, message *Message[int]) error {
+				called = true
+				return nil
+			})
+
+		// Next, run the consumer until the message has been received and then shut it down
+		ctx, cancel := context.WithCancel(context.Background())
+		done := runConsumer(ctx, consumer)
+		Eventually(fake.empty).Should(BeTrue())
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+
+		// Finally, verify that the handler wasn't called and that the message wasn't deleted
+		Expect(called).Should(BeFalse())
+		Expect(fake.deletedHandles()).Should(BeEmpty())
+	})
+
+	// Tests that the consumer will not handle more messages at once than it has workers
+	It("Run - Many messages - Concurrency bounded", func() {
+
+		// First, create a fake queue with many messages and a consumer, with a small number of workers, that
+		// records the greatest number of messages being handled at once
+		fake := newFakeSQS()
+		for i := 0; i < 20; i++ {
+			fake.add(i, 1)
+		}
+
+		var lock sync.Mutex
+		current, highest := 0, 0
+		consumer := NewConsumer(createConsumerConnection(fake), "test-queue",
+			func(ctx context.Context, message *Message[int]) error {
+				lock.Lock()
+				current++
+				highest = math.Max(highest, current)
+				lock.Unlock()
+
+				time.Sleep(5 * time.Millisecond)
+
+				lock.Lock()
+				current--
+				lock.Unlock()
+				return nil
+			}, WithWorkers(3))
+
+		// Next, run the consumer until all the messages have been deleted and then shut it down
+		ctx, cancel := context.WithCancel(context.Background())
+		done := runConsumer(ctx, consumer)
+		Eventually(fake.deletedHandles).Should(HaveLen(20))
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+
+		// Finally, verify that no more than three messages were handled at once and that no receive requested
+		// more messages than there were free workers
+		Expect(highest).Should(BeNumerically("<=", 3))
+		Expect(fake.maxRequested()).Should(BeNumerically("<=", 3))
+	})
+
+	// Tests that, when the consumer is shut down, it will wait for in-flight messages to finish and delete them
+	It("Run - Shutdown with message in flight - Message finished", func() {
+
+		// First, create a fake queue with a message and a consumer whose handler waits until it is released
+		fake := newFakeSQS()
+		fake.add(3, 1)
+		started, release := make(chan struct{}), make(chan struct{})
+		var handlerErr error
+		consumer := NewConsumer(createConsumerConnection(fake), "test-queue",
+			func(ctx context.Context, message *Message[int]) error {
+				close(started)
+				<-release
+				handlerErr = ctx.Err()
+				return nil
+			})
+
+		// Next, run the consumer until the handler has started and then shut the consumer down
+		ctx, cancel := context.WithCancel(context.Background())
+		done := runConsumer(ctx, consumer)
+		Eventually(started).Should(BeClosed())
+		cancel()
+
+		// Now, verify that the consumer doesn't stop until the handler has been released
+		Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+		close(release)
+		Eventually(done).Should(Receive(BeNil()))
+
+		// Finally, verify that the handler's context was not cancelled and that the message was deleted
+		Expect(handlerErr).ShouldNot(HaveOccurred())
+		Expect(fake.deletedHandles()).Should(ConsistOf("3"))
+	})
+
+	// Tests that, if a shutdown timeout was set, then the handler's context will be cancelled once it elapses
+	It("Run - Shutdown timeout elapses - Handler cancelled", func() {
+
+		// First, create a fake queue with a message and a consumer whose handler waits for its context to finish
+		fake := newFakeSQS()
+		fake.add(3, 1)
+		started := make(chan struct{})
+		consumer := NewConsumer(createConsumerConnection(fake), "test-queue",
+			func(ctx context.Context, message *Message[int]) error {
+				close(started)
+				<-ctx.Done()
+				return ctx.Err()
+			}, WithShutdownTimeout(10*time.Millisecond))
+
+		// Next, run the consumer until the handler has started and then shut the consumer down
+		ctx, cancel := context.WithCancel(context.Background())
+		done := runConsumer(ctx, consumer)
+		Eventually(started).Should(BeClosed())
+		cancel()
+
+		// Finally, verify that the consumer stops and that the message was not deleted
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(fake.deletedHandles()).Should(BeEmpty())
+	})
+
+	// Tests that the retry backoff will calculate the visibility timeout correctly
+	DescribeTable("WithRetryBackoff - Timeout",
+		func(backoff WithRetryBackoff, receiveCount int, expected int32) {
+			Expect(backoff.timeout(receiveCount)).Should(Equal(expected))
+		},
+		Entry("First receive", WithRetryBackoff{Initial: 5 * time.Second}, 1, int32(5)),
+		Entry("Unknown receive count", WithRetryBackoff{Initial: 5 * time.Second}, 0, int32(5)),
+		Entry("Doubled", WithRetryBackoff{Initial: 5 * time.Second}, 3, int32(20)),
+		Entry("Capped by maximum", WithRetryBackoff{Initial: 5 * time.Second, Max: 15 * time.Second}, 3, int32(15)),
+		Entry("Capped by SQS", WithRetryBackoff{Initial: time.Hour}, 10, int32(43200)))
+})
+
+// Helper type that fakes an SQS queue for testing consumers
+type fakeSQS struct {
+	SQSAPI
+	lock      sync.Mutex
+	messages  []types.Message
+	deleted   []string
+	changes   map[string]int32
+	requested int32
+	input     *sqs.ReceiveMessageInput
+	err       error
+	batches   [][]types.ChangeMessageVisibilityBatchRequestEntry
+	rejected  map[string]bool
+	sent      []*sqs.SendMessageBatchInput
+	failures  map[string][]bool
+}
+
+// Helper function that creates a new, empty fake SQS queue
+func newFakeSQS() *fakeSQS {
+	return &fakeSQS{
+		changes:  make(map[string]int32),
+		rejected: make(map[string]bool),
+		failures: make(map[string][]bool),
+	}
+}
+
+// Helper function that adds a message to the fake queue, encoded in the same way as SendMessage
+func (fake *fakeSQS) add(value int, receiveCount int) {
+	data, err := json.Marshal(value)
+	Expect(err).ShouldNot(HaveOccurred())
+	fake.addRaw(strconv.Itoa(value), base64.StdEncoding.EncodeToString(data), receiveCount)
+}
+
+// Helper function that adds a message with the ID and body provided to the fake queue. The ID is also used as
+// the message's receipt handle
+func (fake *fakeSQS) addRaw(id string, body string, receiveCount int) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.messages = append(fake.messages, types.Message{
+		MessageId:     aws.String(id),
+		ReceiptHandle: aws.String(id),
+		Body:          aws.String(body),
+		Attributes: map[string]string{
+			string(types.MessageSystemAttributeNameApproximateReceiveCount): strconv.Itoa(receiveCount),
+		},
+	})
+}
+
+// ReceiveMessage returns up to the maximum number of messages from the fake queue. If the queue is empty then
+// this function will wait briefly, or until the context is cancelled, to simulate long-polling. If an error
+// has been set on the fake then it will be returned instead
+func (fake *fakeSQS) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput,
+	optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	fake.lock.Lock()
+	if fake.err != nil {
+		defer fake.lock.Unlock()
+		return nil, fake.err
+	}
+
+	fake.input = params
+	fake.requested = math.Max(fake.requested, params.MaxNumberOfMessages)
+	count := math.Min(int(params.MaxNumberOfMessages), len(fake.messages))
+	messages := fake.messages[:count]
+	fake.messages = fake.messages[count:]
+	fake.lock.Unlock()
+
+	if count == 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+// DeleteMessage records the receipt handle of the message that was deleted
+func (fake *fakeSQS) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput,
+	optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.deleted = append(fake.deleted, *params.ReceiptHandle)
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+// ChangeMessageVisibility records the visibility timeout set for the message
+func (fake *fakeSQS) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput,
+	optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.changes[*params.ReceiptHandle] = params.VisibilityTimeout
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+// ChangeMessageVisibilityBatch records the entries in the batch. Entries whose receipt handles have been rejected
+// will be returned as failed with the sender at fault if they were rejected permanently
+func (fake *fakeSQS) ChangeMessageVisibilityBatch(ctx context.Context, params *sqs.ChangeMessageVisibilityBatchInput,
+	optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if fake.err != nil {
+		return nil, fake.err
+	}
+
+	fake.batches = append(fake.batches, params.Entries)
+	output := sqs.ChangeMessageVisibilityBatchOutput{}
+	for _, entry := range params.Entries {
+		if permanent, ok := fake.rejected[*entry.ReceiptHandle]; ok {
+			output.Failed = append(output.Failed, types.BatchResultErrorEntry{
+				Id:          entry.Id,
+				Code:        aws.String("ReceiptHandleIsInvalid"),
+				Message:     aws.String("rejected"),
+				SenderFault: permanent,
+			})
+		} else {
+			output.Successful = append(output.Successful,
+				types.ChangeMessageVisibilityBatchResultEntry{Id: entry.Id})
+		}
+	}
+
+	return &output, nil
+}
+
+// SendMessage adds the message that was sent to the fake queue, using the number of messages sent so far as its ID
+func (fake *fakeSQS) SendMessage(ctx context.Context, params *sqs.SendMessageInput,
+	optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if fake.err != nil {
+		return nil, fake.err
+	}
+
+	id := fmt.Sprintf("sent-%d", len(fake.messages))
+	fake.messages = append(fake.messages, types.Message{
+		MessageId:         aws.String(id),
+		ReceiptHandle:     aws.String(id),
+		Body:              params.MessageBody,
+		MessageAttributes: params.MessageAttributes,
+	})
+
+	return &sqs.SendMessageOutput{MessageId: aws.String(id)}, nil
+}
+
+// SendMessageBatch records the batch that was sent. Entries with failures queued against their ID will fail, with
+// the sender at fault if the queued failure says so, until their failures have been used up
+func (fake *fakeSQS) SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput,
+	optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if fake.err != nil {
+		return nil, fake.err
+	}
+
+	fake.sent = append(fake.sent, params)
+	output := sqs.SendMessageBatchOutput{}
+	for _, entry := range params.Entries {
+		if failures := fake.failures[*entry.Id]; len(failures) > 0 {
+			fake.failures[*entry.Id] = failures[1:]
+			output.Failed = append(output.Failed, types.BatchResultErrorEntry{
+				Id:          entry.Id,
+				Code:        aws.String("InternalError"),
+				SenderFault: failures[0],
+			})
+		} else {
+			output.Successful = append(output.Successful, types.SendMessageBatchResultEntry{
+				Id:        entry.Id,
+				MessageId: aws.String("message-" + *entry.Id),
+			})
+		}
+	}
+
+	return &output, nil
+}
+
+// Helper function that returns the batches of messages that have been sent
+func (fake *fakeSQS) sentBatches() []*sqs.SendMessageBatchInput {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([]*sqs.SendMessageBatchInput{}, fake.sent...)
+}
+
+// Helper function that returns the batches of visibility changes that have been requested
+func (fake *fakeSQS) visibilityBatches() [][]types.ChangeMessageVisibilityBatchRequestEntry {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([][]types.ChangeMessageVisibilityBatchRequestEntry{}, fake.batches...)
+}
+
+// Helper function that returns the receipt handles of the messages that have been deleted
+func (fake *fakeSQS) deletedHandles() []string {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([]string{}, fake.deleted...)
+}
+
+// Helper function that returns the visibility timeouts that have been set, by receipt handle
+func (fake *fakeSQS) visibilityChanges() map[string]int32 {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	changes := make(map[string]int32, len(fake.changes))
+	for handle, timeout := range fake.changes {
+		changes[handle] = timeout
+	}
+
+	return changes
+}
+
+// Helper function that returns whether all the messages have been received from the fake queue
+func (fake *fakeSQS) empty() bool {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return len(fake.messages) == 0
+}
+
+// Helper function that returns the largest number of messages requested in a single receive
+func (fake *fakeSQS) maxRequested() int32 {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return fake.requested
+}
+
+// Helper function that creates a new SQS connection from the client and options with a logger that discards its
+// output
+func createConsumerConnection(client SQSAPI, options ...SQSOption) *SQSConnection {
+	logger := utils.NewLogger("testd", "test")
+	logger.Discard()
+	return FromClient(client, logger, options...)
+}
+
+// Helper function that runs the consumer in the background and returns a channel that will receive its result
+func runConsumer[T any](ctx context.Context, consumer *Consumer[T]) chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- consumer.Run(ctx)
+	}()
+
+	return done
+}
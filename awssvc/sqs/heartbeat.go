@@ -0,0 +1,203 @@
+package sqs
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/xefino/goutils/collections"
+	"github.com/xefino/goutils/math"
+)
+
+// VisibilityHeartbeat periodically extends the visibility timeout of messages that are still being processed so
+// that they are not redelivered to another consumer while a long-running handler is working on them. Messages are
+// extended together using SQS.ChangeMessageVisibilityBatch. The total time a message may be kept invisible, from
+// the moment it starts being tracked, is capped so that a stuck handler cannot hold a message forever
+type VisibilityHeartbeat struct {
+	conn         *SQSConnection
+	url          string
+	interval     time.Duration
+	timeout      time.Duration
+	maxExtension time.Duration
+	tracked      map[string]time.Time
+	beating      map[string]int
+	beatDone     *sync.Cond
+	now          func() time.Time
+	lock         sync.Mutex
+}
+
+// NewVisibilityHeartbeat creates a new VisibilityHeartbeat that will extend the visibility of messages received
+// from the SQS queue indicated by the URL. By default, the visibility of each tracked message will be set to 30
+// seconds every 10 seconds, for at most 12 hours. The options provided may be used to modify these values
+func NewVisibilityHeartbeat(conn *SQSConnection, url string, options ...HeartbeatOption) *VisibilityHeartbeat {
+
+	// First, create our heartbeat with default values
+	heartbeat := VisibilityHeartbeat{
+		conn:         conn,
+		url:          url,
+		interval:     10 * time.Second,
+		timeout:      30 * time.Second,
+		maxExtension: maxVisibilityTimeout * time.Second,
+		tracked:      make(map[string]time.Time),
+		beating:      make(map[string]int),
+		now:          time.Now,
+	}
+
+	heartbeat.beatDone = sync.NewCond(&heartbeat.lock)
+
+	// Next, iterate over all the options and apply each to the heartbeat
+	for _, option := range options {
+		option.Apply(&heartbeat)
+	}
+
+	// Finally, return a reference to the heartbeat
+	return &heartbeat
+}
+
+// Track begins extending the visibility of the message associated with the receipt handle. The function returned
+// should be called when the message has finished processing to stop extending its visibility. It will wait for any
+// beat that is extending the message to finish so that the visibility of the message may be changed safely once it
+// returns
+func (heartbeat *VisibilityHeartbeat) Track(receiptHandle string) func() {
+	heartbeat.lock.Lock()
+	defer heartbeat.lock.Unlock()
+	heartbeat.tracked[receiptHandle] = heartbeat.now()
+
+	return func() {
+		heartbeat.lock.Lock()
+		defer heartbeat.lock.Unlock()
+		delete(heartbeat.tracked, receiptHandle)
+		for heartbeat.beating[receiptHandle] > 0 {
+			heartbeat.beatDone.Wait()
+		}
+	}
+}
+
+// Run extends the visibility of the tracked messages on every interval until the context is cancelled
+func (heartbeat *VisibilityHeartbeat) Run(ctx context.Context) {
+	ticker := time.NewTicker(heartbeat.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			heartbeat.Beat(ctx)
+		}
+	}
+}
+
+// Beat extends the visibility of all the messages currently being tracked. Messages that have reached the maximum
+// extension, or whose visibility could not be changed because of a problem with the request, will no longer be
+// tracked. This function is called by Run on each interval but may also be called directly
+func (heartbeat *VisibilityHeartbeat) Beat(ctx context.Context) {
+
+	// First, collect the entries we need to extend; we'll extend each message by the timeout unless this would take
+	// it beyond the maximum extension, in which case we'll extend it to the maximum and then stop tracking it
+	entries := heartbeat.collect()
+	if len(entries) == 0 {
+		return
+	}
+
+	defer heartbeat.finish(entries)
+
+	// Next, page the entries into batches and attempt to send each batch to SQS
+	for _, page := range collections.Page(entries, 10) {
+
+		// First, set the ID of each entry in the batch to its index so we can find failed entries later
+		for i := range page {
+			page[i].Id = aws.String(strconv.Itoa(i))
+		}
+
+		// Next, attempt to change the visibility of the messages in the batch; if this fails then log the error
+		// and keep tracking the messages so that we can try again on the next beat
+		output, err := heartbeat.conn.sqs.ChangeMessageVisibilityBatch(ctx, &sqs.ChangeMessageVisibilityBatchInput{
+			QueueUrl: aws.String(heartbeat.url),
+			Entries:  page,
+		})
+
+		if err != nil {
+			heartbeat.conn.logger.Error(err, "Failed to extend visibility of %d SQS messages from %q",
+				len(page), heartbeat.url)
+			continue
+		}
+
+		// Finally, stop tracking any entries that failed because of the request as they won't succeed if we
+		// retry them; this typically happens when the message has already been deleted or its receipt
+		// handle has expired
+		for _, failed := range output.Failed {
+			index, err := strconv.Atoi(aws.ToString(failed.Id))
+			if err != nil || index < 0 || index >= len(page) {
+				continue
+			}
+
+			heartbeat.conn.logger.Log("Failed to extend visibility of SQS message from %q, Code: %s, Message: %s",
+				heartbeat.url, aws.ToString(failed.Code), aws.ToString(failed.Message))
+			if failed.SenderFault {
+				heartbeat.untrack(aws.ToString(page[index].ReceiptHandle))
+			}
+		}
+	}
+}
+
+// Helper function that creates a change-visibility entry for each tracked message, removing messages that have
+// reached their maximum extension. Each message included will be marked as being extended until finish is called
+func (heartbeat *VisibilityHeartbeat) collect() []types.ChangeMessageVisibilityBatchRequestEntry {
+	heartbeat.lock.Lock()
+	defer heartbeat.lock.Unlock()
+
+	now := heartbeat.now()
+	entries := make([]types.ChangeMessageVisibilityBatchRequestEntry, 0, len(heartbeat.tracked))
+	for handle, started := range heartbeat.tracked {
+
+		// First, calculate how much longer the message may be kept invisible; if this is less than the timeout
+		// then this will be the last time we extend the message so stop tracking it
+		remaining := heartbeat.maxExtension - now.Sub(started)
+		if remaining < heartbeat.timeout {
+			delete(heartbeat.tracked, handle)
+		}
+
+		// Next, if the message has no time remaining then don't extend it
+		seconds := int32(math.Min(remaining, heartbeat.timeout).Seconds())
+		if seconds <= 0 {
+			continue
+		}
+
+		// Finally, add an entry extending the message and mark the message as being extended
+		entries = append(entries, types.ChangeMessageVisibilityBatchRequestEntry{
+			ReceiptHandle:     aws.String(handle),
+			VisibilityTimeout: seconds,
+		})
+
+		heartbeat.beating[handle]++
+	}
+
+	return entries
+}
+
+// Helper function that marks the messages associated with the entries as no longer being extended and wakes any
+// callers that are waiting to stop tracking them
+func (heartbeat *VisibilityHeartbeat) finish(entries []types.ChangeMessageVisibilityBatchRequestEntry) {
+	heartbeat.lock.Lock()
+	defer heartbeat.lock.Unlock()
+	for _, entry := range entries {
+		handle := aws.ToString(entry.ReceiptHandle)
+		if heartbeat.beating[handle]--; heartbeat.beating[handle] <= 0 {
+			delete(heartbeat.beating, handle)
+		}
+	}
+
+	heartbeat.beatDone.Broadcast()
+}
+
+// Helper function that stops tracking the message associated with the receipt handle
+func (heartbeat *VisibilityHeartbeat) untrack(receiptHandle string) {
+	heartbeat.lock.Lock()
+	defer heartbeat.lock.Unlock()
+	delete(heartbeat.tracked, receiptHandle)
+}
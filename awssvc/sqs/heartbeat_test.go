@@ -0,0 +1,234 @@
+package sqs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SQS Visibility Heartbeat Tests", func() {
+
+	// Tests that, if no messages are being tracked, then calling Beat will not call SQS
+	It("Beat - Nothing tracked - No requests", func() {
+		fake := newFakeSQS()
+		heartbeat := NewVisibilityHeartbeat(createConsumerConnection(fake), "test-queue")
+
+		heartbeat.Beat(context.Background())
+
+		Expect(fake.visibilityBatches()).Should(BeEmpty())
+	})
+
+	// Tests that calling Beat will extend the visibility of all the tracked messages in batches of ten
+	It("Beat - Many messages tracked - Extended in batches", func() {
+
+		// First, create a heartbeat and track a number of messages with it
+		fake := newFakeSQS()
+		heartbeat := NewVisibilityHeartbeat(createConsumerConnection(fake), "test-queue",
+			WithHeartbeatTimeout(45*time.Second))
+		for i := 0; i < 12; i++ {
+			heartbeat.Track(fmt.Sprint(i))
+		}
+
+		// Next, extend the visibility of the tracked messages
+		heartbeat.Beat(context.Background())
+
+		// Finally, verify that the messages were extended in two batches by the timeout
+		batches := fake.visibilityBatches()
+		Expect(batches).Should(HaveLen(2))
+		Expect(batches[0]).Should(HaveLen(10))
+		Expect(batches[1]).Should(HaveLen(2))
+		Expect(extendedHandles(batches)).Should(HaveLen(12))
+		for _, batch := range batches {
+			for i, entry := range batch {
+				Expect(*entry.Id).Should(Equal(fmt.Sprint(i)))
+				Expect(entry.VisibilityTimeout).Should(Equal(int32(45)))
+			}
+		}
+	})
+
+	// Tests that, once the function returned by Track is called, the message will no longer be extended
+	It("Track - Stopped - No longer extended", func() {
+
+		// First, create a heartbeat and track two messages with it
+		fake := newFakeSQS()
+		heartbeat := NewVisibilityHeartbeat(createConsumerConnection(fake), "test-queue")
+		stop := heartbeat.Track("a")
+		heartbeat.Track("b")
+
+		// Next, stop tracking the first message and extend the visibility of the tracked messages
+		stop()
+		heartbeat.Beat(context.Background())
+
+		// Finally, verify that only the second message was extended
+		Expect(extendedHandles(fake.visibilityBatches())).Should(Equal([]string{"b"}))
+	})
+
+	// Tests that, if a beat is extending a message when it stops being tracked, then the function returned by Track
+	// will wait for the beat to finish so that it can't overwrite a visibility change made after it returns
+	It("Track - Stopped during beat - Waits for beat", func() {
+
+		// First, create a heartbeat whose requests block until released and track a message with it
+		fake := &blockingSQS{fakeSQS: newFakeSQS(), started: make(chan struct{}), release: make(chan struct{})}
+		heartbeat := NewVisibilityHeartbeat(createConsumerConnection(fake), "test-queue")
+		stop := heartbeat.Track("a")
+
+		// Next, start a beat and wait for it to send its request
+		go heartbeat.Beat(context.Background())
+		Eventually(fake.started).Should(BeClosed())
+
+		// Now, stop tracking the message and verify that this doesn't return while the beat is in flight
+		stopped := make(chan struct{})
+		go func() {
+			stop()
+			close(stopped)
+		}()
+
+		Consistently(stopped, 50*time.Millisecond).ShouldNot(BeClosed())
+
+		// Finally, release the beat and verify that stopping the message returns
+		close(fake.release)
+		Eventually(stopped).Should(BeClosed())
+		Expect(extendedHandles(fake.visibilityBatches())).Should(Equal([]string{"a"}))
+	})
+
+	// Tests that the heartbeat will not extend a message beyond its maximum extension
+	It("Beat - Maximum extension reached - Capped and no longer extended", func() {
+
+		// First, create a heartbeat with a fake clock and a maximum extension and track a message with it
+		fake := newFakeSQS()
+		heartbeat := NewVisibilityHeartbeat(createConsumerConnection(fake), "test-queue",
+			WithHeartbeatTimeout(30*time.Second), WithMaxExtension(time.Minute))
+		now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		heartbeat.now = func() time.Time { return now }
+		heartbeat.Track("a")
+
+		// Next, extend the message before the maximum is near; it should be extended by the full timeout
+		now = now.Add(20 * time.Second)
+		heartbeat.Beat(context.Background())
+
+		// Now, extend the message once it's within the timeout of the maximum; it should be extended only up to
+		// the maximum and then no longer be tracked
+		now = now.Add(30 * time.Second)
+		heartbeat.Beat(context.Background())
+		heartbeat.Beat(context.Background())
+
+		// Finally, verify the extensions that were requested
+		batches := fake.visibilityBatches()
+		Expect(batches).Should(HaveLen(2))
+		Expect(batches[0][0].VisibilityTimeout).Should(Equal(int32(30)))
+		Expect(batches[1][0].VisibilityTimeout).Should(Equal(int32(10)))
+	})
+
+	// Tests that, if extending a message fails because of the request, then it will no longer be tracked but that
+	// other failures will be retried on the next beat
+	It("Beat - Entries fail - Sender faults no longer tracked", func() {
+
+		// First, create a heartbeat and track three messages, two of which will be rejected
+		fake := newFakeSQS()
+		fake.rejected["a"] = true
+		fake.rejected["b"] = false
+		heartbeat := NewVisibilityHeartbeat(createConsumerConnection(fake), "test-queue")
+		heartbeat.Track("a")
+		heartbeat.Track("b")
+		heartbeat.Track("c")
+
+		// Next, extend the visibility of the messages twice
+		heartbeat.Beat(context.Background())
+		heartbeat.Beat(context.Background())
+
+		// Finally, verify that the message rejected permanently was only attempted once
+		batches := fake.visibilityBatches()
+		Expect(batches).Should(HaveLen(2))
+		Expect(extendedHandles(batches[:1])).Should(Equal([]string{"a", "b", "c"}))
+		Expect(extendedHandles(batches[1:])).Should(Equal([]string{"b", "c"}))
+	})
+
+	// Tests that, if the batch request fails, then the messages will still be tracked so they can be retried
+	It("Beat - Request fails - Still tracked", func() {
+
+		// First, create a heartbeat that will fail and track a message with it
+		fake := newFakeSQS()
+		fake.err = fmt.Errorf("change visibility failed")
+		heartbeat := NewVisibilityHeartbeat(createConsumerConnection(fake), "test-queue")
+		heartbeat.Track("a")
+
+		// Next, attempt to extend the message; this will fail
+		heartbeat.Beat(context.Background())
+
+		// Finally, allow requests to succeed and verify that the message is extended on the next beat
+		fake.lock.Lock()
+		fake.err = nil
+		fake.lock.Unlock()
+		heartbeat.Beat(context.Background())
+		Expect(extendedHandles(fake.visibilityBatches())).Should(Equal([]string{"a"}))
+	})
+
+	// Tests that a consumer with a heartbeat will extend the visibility of messages while they're being handled
+	It("Consumer - WithHeartbeat - Messages extended while handled", func() {
+
+		// First, create a fake queue with a message and a consumer, with a fast heartbeat, whose handler waits until
+		// the message's visibility has been extended
+		fake := newFakeSQS()
+		fake.add(3, 1)
+		consumer := NewConsumer(createConsumerConnection(fake), "test-queue",
+			func(ctx context.Context, message *Message[int]) error {
+				defer GinkgoRecover()
+				Eventually(func() []string {
+					return extendedHandles(fake.visibilityBatches())
+				}).Should(ContainElement(message.ReceiptHandle))
+				return nil
+			}, WithHeartbeat{WithHeartbeatInterval(time.Millisecond)})
+
+		// Next, run the consumer until the message has been deleted
+		ctx, cancel := context.WithCancel(context.Background())
+		done := runConsumer(ctx, consumer)
+		Eventually(fake.deletedHandles).Should(ConsistOf("3"))
+
+		// Now, verify that the message is no longer extended once it has been handled
+		count := len(fake.visibilityBatches())
+		Consistently(func() int { return len(fake.visibilityBatches()) }, 20*time.Millisecond).Should(Equal(count))
+
+		// Finally, shut the consumer down
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+	})
+})
+
+// Helper function that returns the sorted receipt handles of all the entries in the batches
+func extendedHandles(batches [][]types.ChangeMessageVisibilityBatchRequestEntry) []string {
+	handles := make([]string, 0)
+	for _, batch := range batches {
+		for _, entry := range batch {
+			handles = append(handles, aws.ToString(entry.ReceiptHandle))
+		}
+	}
+
+	sort.Strings(handles)
+	return handles
+}
+
+// Helper type that blocks requests to extend the visibility of messages until it is released
+type blockingSQS struct {
+	*fakeSQS
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+// ChangeMessageVisibilityBatch signals that the request was started and then waits to be released before extending
+// the visibility of the messages
+func (fake *blockingSQS) ChangeMessageVisibilityBatch(ctx context.Context,
+	params *sqs.ChangeMessageVisibilityBatchInput,
+	optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	fake.once.Do(func() { close(fake.started) })
+	<-fake.release
+	return fake.fakeSQS.ChangeMessageVisibilityBatch(ctx, params, optFns...)
+}
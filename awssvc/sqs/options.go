@@ -1,9 +1,12 @@
 package sqs
 
 import (
+	"time"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/xefino/goutils/awssvc/s3"
 )
 
 // SQSOption contains the functionality necessary to modify the SQS connection
@@ -20,6 +23,68 @@ func (w WithSendMessagesBatchSize) Apply(sqs *SQSConnection) {
 	sqs.sendBatchSize = int(w)
 }
 
+// WithSendMessagesAttempts allows the user to set the maximum number of attempts that will be made to send each
+// entry passed to SendMessages when SQS fails the entry for a reason that was not the fault of the sender. By
+// default, this value is 3
+type WithSendMessagesAttempts int
+
+// Apply sets the maximum number of send attempts associated with the SQS connection
+func (w WithSendMessagesAttempts) Apply(sqs *SQSConnection) {
+	sqs.sendAttempts = int(w)
+}
+
+// WithSendRetryBackoff allows the user to set the exponential backoff used between attempts to send entries passed
+// to SendMessages that failed. By default, the backoff will start at 100 milliseconds and grow to at most 5 seconds
+type WithSendRetryBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// Apply sets the send retry backoff associated with the SQS connection
+func (w WithSendRetryBackoff) Apply(sqs *SQSConnection) {
+	sqs.sendBackoff = w
+}
+
+// WithCodec allows the user to set the codec the SQS connection will use to encode the bodies of the messages it
+// sends. The name of the codec will be recorded in the CodecAttribute of each message. Messages received by the
+// connection will be decoded with the codec named by their CodecAttribute, which may be this codec or any of the
+// built-in codecs. Messages without the attribute will be decoded with this codec. By default, Base64JSONCodec
+// will be used
+type WithCodec struct {
+	Codec ICodec
+}
+
+// Apply sets the codec associated with the SQS connection
+func (w WithCodec) Apply(sqs *SQSConnection) {
+	if w.Codec != nil {
+		sqs.codec = w.Codec
+	}
+}
+
+// WithExtendedClient allows the user to have the SQS connection offload the bodies of messages that are too large
+// to send to SQS to S3, sending a pointer to the payload in its place, in a format that is compatible with the AWS
+// Extended Client. Messages received by the connection will have their payloads downloaded from S3 automatically.
+// Messages larger than the threshold, or all messages if AlwaysOffload is set, will be offloaded to the bucket with
+// keys beginning with the prefix. If the threshold is not set then the SQS message size limit will be used. If
+// DeletePayloads is set then payloads will be deleted from S3 when their messages are deleted by DeleteMessage
+type WithExtendedClient struct {
+	Store          s3.IConnection
+	Bucket         string
+	Prefix         string
+	Threshold      int
+	AlwaysOffload  bool
+	DeletePayloads bool
+}
+
+// Apply sets the extended client configuration associated with the SQS connection
+func (w WithExtendedClient) Apply(sqs *SQSConnection) {
+	if w.Threshold <= 0 {
+		w.Threshold = maxBatchPayloadSize
+	}
+
+	sqs.extended = &w
+}
+
 // SendMessageOption describes the functionality necessary to configure an SQS.SendMessageInput beyond
 // the base fields required
 type SendMessageOption interface {
@@ -145,3 +210,179 @@ type WithAWSAccountID string
 func (w WithAWSAccountID) Apply(input *sqs.GetQueueUrlInput) {
 	input.QueueOwnerAWSAccountId = aws.String(string(w))
 }
+
+// ReceiveMessageOption describes the functionality necessary to configure an SQS.ReceiveMessageInput beyond
+// the base fields required
+type ReceiveMessageOption interface {
+	Apply(*sqs.ReceiveMessageInput)
+}
+
+// WithMaxNumberOfMessages allows the user to set the maximum number of messages to receive on the
+// SQS.ReceiveMessageInput. This value should be between 1 and 10
+type WithMaxNumberOfMessages int32
+
+// Apply sets the maximum number of messages on the SQS.ReceiveMessageInput
+func (w WithMaxNumberOfMessages) Apply(input *sqs.ReceiveMessageInput) {
+	input.MaxNumberOfMessages = int32(w)
+}
+
+// WithWaitTimeSeconds allows the user to set the long-polling wait time, in seconds, on the
+// SQS.ReceiveMessageInput. This value should be at most 20
+type WithWaitTimeSeconds int32
+
+// Apply sets the wait time on the SQS.ReceiveMessageInput
+func (w WithWaitTimeSeconds) Apply(input *sqs.ReceiveMessageInput) {
+	input.WaitTimeSeconds = int32(w)
+}
+
+// WithReceiveVisibilityTimeout allows the user to set the visibility timeout, in seconds, of the messages
+// received with the SQS.ReceiveMessageInput
+type WithReceiveVisibilityTimeout int32
+
+// Apply sets the visibility timeout on the SQS.ReceiveMessageInput
+func (w WithReceiveVisibilityTimeout) Apply(input *sqs.ReceiveMessageInput) {
+	input.VisibilityTimeout = int32(w)
+}
+
+// WithReceiveRequestAttemptID allows the user to set the receive request attempt ID on the
+// SQS.ReceiveMessageInput. This only applies to FIFO queues
+type WithReceiveRequestAttemptID string
+
+// Apply sets the receive request attempt ID on the SQS.ReceiveMessageInput
+func (w WithReceiveRequestAttemptID) Apply(input *sqs.ReceiveMessageInput) {
+	input.ReceiveRequestAttemptId = aws.String(string(w))
+}
+
+// Helper type containing the configuration used by a Consumer
+type consumerConfig struct {
+	workers           int
+	maxMessages       int32
+	waitTime          int32
+	visibilityTimeout int32
+	errorDelay        time.Duration
+	shutdownTimeout   time.Duration
+	backoff           *WithRetryBackoff
+	heartbeat         WithHeartbeat
+}
+
+// ConsumerOption contains the functionality necessary to modify an SQS consumer
+type ConsumerOption interface {
+	Apply(*consumerConfig)
+}
+
+// WithWorkers allows the user to set the maximum number of messages the consumer will handle concurrently. The
+// default value is 10
+type WithWorkers int
+
+// Apply sets the number of workers associated with the consumer
+func (w WithWorkers) Apply(config *consumerConfig) {
+	config.workers = int(w)
+}
+
+// WithMaxMessages allows the user to set the maximum number of messages the consumer will request with each call
+// to SQS.ReceiveMessage. This value should be between 1 and 10; the default value is 10
+type WithMaxMessages int32
+
+// Apply sets the maximum number of messages received at once by the consumer
+func (w WithMaxMessages) Apply(config *consumerConfig) {
+	config.maxMessages = int32(w)
+}
+
+// WithWaitTime allows the user to set the amount of time each call to SQS.ReceiveMessage will wait for messages
+// to arrive. This value will be truncated to the second and should be at most 20 seconds, the default
+type WithWaitTime time.Duration
+
+// Apply sets the long-polling wait time associated with the consumer
+func (w WithWaitTime) Apply(config *consumerConfig) {
+	config.waitTime = int32(time.Duration(w).Seconds())
+}
+
+// WithVisibilityTimeout allows the user to set the visibility timeout requested for the messages received by the
+// consumer. This value will be truncated to the second. If this option is not set then the queue's visibility
+// timeout will be used
+type WithVisibilityTimeout time.Duration
+
+// Apply sets the visibility timeout associated with the consumer
+func (w WithVisibilityTimeout) Apply(config *consumerConfig) {
+	config.visibilityTimeout = int32(time.Duration(w).Seconds())
+}
+
+// WithReceiveErrorDelay allows the user to set the amount of time the consumer will wait before receiving again
+// after a call to SQS.ReceiveMessage fails. The default value is one second
+type WithReceiveErrorDelay time.Duration
+
+// Apply sets the receive error delay associated with the consumer
+func (w WithReceiveErrorDelay) Apply(config *consumerConfig) {
+	config.errorDelay = time.Duration(w)
+}
+
+// WithShutdownTimeout allows the user to set the amount of time the consumer will allow in-flight messages to
+// finish after its context has been cancelled, before cancelling the context passed to the handler. If this
+// option is not set then the consumer will wait for in-flight messages indefinitely
+type WithShutdownTimeout time.Duration
+
+// Apply sets the shutdown timeout associated with the consumer
+func (w WithShutdownTimeout) Apply(config *consumerConfig) {
+	config.shutdownTimeout = time.Duration(w)
+}
+
+// WithRetryBackoff allows the user to have messages that failed to be handled redelivered after a backoff rather
+// than after the visibility timeout. When a message fails, its visibility timeout will be changed to the initial
+// interval, doubled for each time the message has been received before, up to the maximum interval. Intervals
+// will be truncated to the second and cannot exceed 12 hours
+type WithRetryBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// Apply sets the retry backoff associated with the consumer
+func (w WithRetryBackoff) Apply(config *consumerConfig) {
+	config.backoff = &w
+}
+
+// WithHeartbeat allows the user to have the consumer extend the visibility of messages while they are being
+// handled, using a VisibilityHeartbeat created with the options provided. The heartbeat will be enabled even
+// if no options are provided
+type WithHeartbeat []HeartbeatOption
+
+// Apply sets the heartbeat options associated with the consumer
+func (w WithHeartbeat) Apply(config *consumerConfig) {
+	if w == nil {
+		w = WithHeartbeat{}
+	}
+
+	config.heartbeat = w
+}
+
+// HeartbeatOption contains the functionality necessary to modify a visibility heartbeat
+type HeartbeatOption interface {
+	Apply(*VisibilityHeartbeat)
+}
+
+// WithHeartbeatInterval allows the user to set how often the heartbeat will extend the visibility of the messages
+// it is tracking. This value should be shorter than the heartbeat timeout; the default value is 10 seconds
+type WithHeartbeatInterval time.Duration
+
+// Apply sets the interval associated with the heartbeat
+func (w WithHeartbeatInterval) Apply(heartbeat *VisibilityHeartbeat) {
+	heartbeat.interval = time.Duration(w)
+}
+
+// WithHeartbeatTimeout allows the user to set the visibility timeout the heartbeat will set on each message it is
+// tracking whenever it extends them. This value will be truncated to the second; the default value is 30 seconds
+type WithHeartbeatTimeout time.Duration
+
+// Apply sets the visibility timeout associated with the heartbeat
+func (w WithHeartbeatTimeout) Apply(heartbeat *VisibilityHeartbeat) {
+	heartbeat.timeout = time.Duration(w)
+}
+
+// WithMaxExtension allows the user to set the total amount of time the heartbeat will keep a message invisible,
+// measured from when it started tracking the message. The default value is 12 hours, which is the maximum
+// visibility timeout SQS allows
+type WithMaxExtension time.Duration
+
+// Apply sets the maximum extension associated with the heartbeat
+func (w WithMaxExtension) Apply(heartbeat *VisibilityHeartbeat) {
+	heartbeat.maxExtension = time.Duration(w)
+}
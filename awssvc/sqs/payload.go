@@ -0,0 +1,190 @@
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/google/uuid"
+)
+
+// ExtendedPayloadSizeAttribute is the message attribute set on messages whose payloads have been offloaded to S3. Its
+// value is the size of the original payload, in bytes. This is the same attribute used by the AWS Extended Client
+const ExtendedPayloadSizeAttribute = "ExtendedPayloadSize"
+
+// Helper constants describing the legacy payload size attribute, which older versions of the AWS Extended Client
+// set instead of ExtendedPayloadSizeAttribute, and the class name the AWS Extended Client embeds in its pointers
+const (
+	legacyPayloadSizeAttribute = "SQSLargePayloadSize"
+	payloadPointerClass        = "software.amazon.payloadoffloading.PayloadS3Pointer"
+)
+
+// PayloadS3Pointer describes the location in S3 of a message payload that was offloaded. When serialized to JSON, it
+// uses the same format as the AWS Extended Client so messages can be exchanged with that library
+type PayloadS3Pointer struct {
+	Bucket string `json:"s3BucketName"`
+	Key    string `json:"s3Key"`
+}
+
+// Helper type used to serialize the fields of a PayloadS3Pointer without recursing into its JSON functions
+type payloadS3Pointer PayloadS3Pointer
+
+// MarshalJSON converts the pointer to JSON in the format used by the AWS Extended Client, which is an array
+// containing the pointer's class name and an object containing its bucket and key
+func (pointer PayloadS3Pointer) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{payloadPointerClass, payloadS3Pointer(pointer)})
+}
+
+// UnmarshalJSON reads the pointer from JSON in the format used by the AWS Extended Client
+func (pointer *PayloadS3Pointer) UnmarshalJSON(data []byte) error {
+
+	// First, attempt to read the data as an array of raw JSON values; if this fails then return an error
+	var parts []json.RawMessage
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return err
+	}
+
+	// Next, verify that the array contains the class name and the pointer; if it doesn't then return an error
+	var class string
+	if len(parts) != 2 {
+		return fmt.Errorf("S3 payload pointer had %d parts, expected 2", len(parts))
+	} else if err := json.Unmarshal(parts[0], &class); err != nil {
+		return err
+	} else if class != payloadPointerClass {
+		return fmt.Errorf("S3 payload pointer had class %q, expected %q", class, payloadPointerClass)
+	}
+
+	// Finally, attempt to read the bucket and key from the pointer object
+	return json.Unmarshal(parts[1], (*payloadS3Pointer)(pointer))
+}
+
+// DeleteMessage deletes a message received with ReceiveMessages from the SQS queue indicated by the URL. If the
+// message's payload was offloaded to S3 and the connection was configured to delete payloads, then the payload will
+// also be deleted from S3
+func DeleteMessage[T any](ctx context.Context, conn *SQSConnection, url string, message *Message[T]) error {
+
+	// First, attempt to delete the message from SQS; if this fails then return an error
+	if _, err := conn.sqs.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(url),
+		ReceiptHandle: aws.String(message.ReceiptHandle),
+	}); err != nil {
+		return conn.logger.Error(err, "Failed to delete SQS message %q from %q", message.ID, url)
+	}
+
+	// Next, if the message has no payload in S3 or we shouldn't delete payloads then we're done
+	if message.Payload == nil || conn.extended == nil || !conn.extended.DeletePayloads {
+		return nil
+	}
+
+	// Finally, attempt to delete the payload from S3; if this fails then return an error
+	if err := conn.extended.Store.Delete(ctx, message.Payload.Bucket, message.Payload.Key); err != nil {
+		return conn.logger.Error(err, "Failed to delete payload of SQS message %q from %s in %s",
+			message.ID, message.Payload.Key, message.Payload.Bucket)
+	}
+
+	return nil
+}
+
+// Helper function that uploads the body of a message to S3 if the connection was configured to offload payloads
+// and the message is too large to send to SQS. If this happens, the body will be replaced with a pointer to the
+// payload in S3 and the size of the payload will be added to the attributes. Otherwise, the body and attributes
+// will be returned unchanged
+func (conn *SQSConnection) offloadPayload(ctx context.Context, body *string,
+	attributes map[string]types.MessageAttributeValue) (*string, map[string]types.MessageAttributeValue, error) {
+
+	// First, check if we need to offload the message; if we don't then return the body and attributes as-is
+	if conn.extended == nil ||
+		(!conn.extended.AlwaysOffload && messageSize(body, attributes) <= conn.extended.Threshold) {
+		return body, attributes, nil
+	}
+
+	// Next, upload the body to S3 with a unique key; if this fails then return an error
+	pointer := PayloadS3Pointer{Bucket: conn.extended.Bucket, Key: conn.extended.Prefix + uuid.NewString()}
+	payload := aws.ToString(body)
+	if err := conn.extended.Store.UploadFromStream(ctx, pointer.Bucket, pointer.Key,
+		strings.NewReader(payload)); err != nil {
+		return nil, nil, err
+	}
+
+	// Now, create the pointer we'll send in place of the body; if this fails then return an error
+	data, err := json.Marshal(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Finally, copy the attributes and add the size of the payload to them so that receivers know the body is a
+	// pointer to the payload in S3
+	updated := make(map[string]types.MessageAttributeValue, len(attributes)+1)
+	for key, value := range attributes {
+		updated[key] = value
+	}
+
+	updated[ExtendedPayloadSizeAttribute] = types.MessageAttributeValue{
+		DataType:    aws.String("Number"),
+		StringValue: aws.String(strconv.Itoa(len(payload))),
+	}
+
+	return aws.String(string(data)), updated, nil
+}
+
+// Helper function that checks whether the payload of a message received from SQS was offloaded to S3 and, if it was,
+// downloads the payload and replaces the body of the message with it. The pointer to the payload will be returned
+// so that it can be deleted later, or nil if the payload wasn't offloaded
+func (conn *SQSConnection) resolvePayload(ctx context.Context, inner *types.Message) (*PayloadS3Pointer, error) {
+
+	// First, check if the message has the attribute indicating that its payload was offloaded; if it doesn't then
+	// the body is the payload so return here
+	name := ExtendedPayloadSizeAttribute
+	if _, ok := inner.MessageAttributes[name]; !ok {
+		name = legacyPayloadSizeAttribute
+		if _, ok := inner.MessageAttributes[name]; !ok {
+			return nil, nil
+		}
+	}
+
+	// Next, if we don't have a store to download the payload from then return an error
+	if conn.extended == nil {
+		return nil, fmt.Errorf("payload was offloaded to S3 but no extended client was configured")
+	}
+
+	// Now, attempt to read the pointer to the payload from the body; if this fails then return an error
+	var pointer PayloadS3Pointer
+	if err := json.Unmarshal([]byte(aws.ToString(inner.Body)), &pointer); err != nil {
+		return nil, err
+	}
+
+	// Attempt to download the payload from S3; if this fails then return an error
+	payload, err := conn.extended.Store.Download(ctx, pointer.Bucket, pointer.Key)
+	if err != nil {
+		return &pointer, err
+	}
+
+	// Finally, replace the body of the message with the payload and remove the attribute that marked it as a
+	// pointer, copying the attributes so that we don't modify the original message
+	attributes := make(map[string]types.MessageAttributeValue, len(inner.MessageAttributes))
+	for key, value := range inner.MessageAttributes {
+		if key != name {
+			attributes[key] = value
+		}
+	}
+
+	inner.Body = aws.String(string(payload))
+	inner.MessageAttributes = attributes
+	return &pointer, nil
+}
+
+// Helper function that calculates the size of a message, as counted towards the SQS payload limit, from the size
+// of its body and message attributes
+func messageSize(body *string, attributes map[string]types.MessageAttributeValue) int {
+	size := len(aws.ToString(body))
+	for name, attr := range attributes {
+		size += len(name) + len(aws.ToString(attr.DataType)) + len(aws.ToString(attr.StringValue)) + len(attr.BinaryValue)
+	}
+
+	return size
+}
@@ -0,0 +1,329 @@
+package sqs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/awssvc/s3"
+	"github.com/xefino/goutils/utils"
+)
+
+// Ensure that both the S3 connection and our fake can be used to store payloads
+var _ s3.IConnection = new(s3.Connection)
+var _ s3.IConnection = newFakePayloadStore()
+
+var _ = Describe("SQS Payload Offloading Tests", func() {
+
+	// Tests that a payload pointer is serialized in the same format used by the AWS Extended Client
+	It("PayloadS3Pointer - MarshalJSON - Extended Client format", func() {
+		data, err := json.Marshal(PayloadS3Pointer{Bucket: "test-bucket", Key: "test-key"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(data)).Should(Equal(`["software.amazon.payloadoffloading.PayloadS3Pointer",` +
+			`{"s3BucketName":"test-bucket","s3Key":"test-key"}]`))
+	})
+
+	// Tests that a payload pointer can be read from the format used by the AWS Extended Client and that invalid
+	// pointers will be rejected
+	DescribeTable("PayloadS3Pointer - UnmarshalJSON",
+		func(data string, expected *PayloadS3Pointer, message string) {
+			var pointer PayloadS3Pointer
+			err := json.Unmarshal([]byte(data), &pointer)
+			if expected != nil {
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(pointer).Should(Equal(*expected))
+			} else if Expect(err).Should(HaveOccurred()); message != "" {
+				Expect(err.Error()).Should(Equal(message))
+			}
+		},
+		Entry("Valid", `["software.amazon.payloadoffloading.PayloadS3Pointer",{"s3BucketName":"b","s3Key":"k"}]`,
+			&PayloadS3Pointer{Bucket: "b", Key: "k"}, ""),
+		Entry("Not an array", `{"s3BucketName":"b","s3Key":"k"}`, nil, ""),
+		Entry("Wrong length", `["software.amazon.payloadoffloading.PayloadS3Pointer"]`, nil,
+			"S3 payload pointer had 1 parts, expected 2"),
+		Entry("Wrong class", `["derp",{"s3BucketName":"b","s3Key":"k"}]`, nil,
+			"S3 payload pointer had class \"derp\", expected "+
+				"\"software.amazon.payloadoffloading.PayloadS3Pointer\""))
+
+	// Tests that messages that fit within the threshold will be sent to SQS directly
+	It("SendMessage - Under threshold - Not offloaded", func() {
+
+		// First, create an extended connection to a fake queue and store
+		fake, store := newFakeSQS(), newFakePayloadStore()
+		conn := createConsumerConnection(fake, WithExtendedClient{Store: store, Bucket: "test-bucket"})
+
+		// Next, send a small message; this should not fail
+		_, err := conn.SendMessage(context.Background(), "test-queue", "small")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that the message was sent as-is and that nothing was uploaded
+		Expect(fake.messages).Should(HaveLen(1))
+		Expect(*fake.messages[0].Body).Should(Equal(encodeTestBody("small")))
+		Expect(fake.messages[0].MessageAttributes).ShouldNot(HaveKey(ExtendedPayloadSizeAttribute))
+		Expect(store.objects).Should(BeEmpty())
+	})
+
+	// Tests that messages that exceed the threshold will be offloaded to S3 and replaced with a pointer
+	It("SendMessage - Over threshold - Offloaded", func() {
+
+		// First, create an extended connection to a fake queue and store with a small threshold
+		fake, store := newFakeSQS(), newFakePayloadStore()
+		conn := createConsumerConnection(fake, WithExtendedClient{Store: store, Bucket: "test-bucket",
+			Prefix: "payloads/", Threshold: 100})
+
+		// Next, send a message that exceeds the threshold with an attribute; this should not fail
+		payload := strings.Repeat("a", 200)
+		_, err := conn.SendMessage(context.Background(), "test-queue", payload, WithMessageAttribute("source",
+			&types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String("test")}))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Now, verify that the body sent to SQS is a pointer to the payload in S3
+		Expect(fake.messages).Should(HaveLen(1))
+		var pointer PayloadS3Pointer
+		Expect(json.Unmarshal([]byte(*fake.messages[0].Body), &pointer)).ShouldNot(HaveOccurred())
+		Expect(pointer.Bucket).Should(Equal("test-bucket"))
+		Expect(pointer.Key).Should(HavePrefix("payloads/"))
+
+		// Finally, verify the attributes that were sent and that the payload was uploaded to S3
+		body := encodeTestBody(payload)
+		attributes := fake.messages[0].MessageAttributes
+		Expect(*attributes[ExtendedPayloadSizeAttribute].DataType).Should(Equal("Number"))
+		Expect(*attributes[ExtendedPayloadSizeAttribute].StringValue).Should(Equal(fmt.Sprint(len(body))))
+		Expect(*attributes["source"].StringValue).Should(Equal("test"))
+		Expect(store.get("test-bucket", pointer.Key)).Should(Equal(body))
+	})
+
+	// Tests that, if AlwaysOffload is set, then even small messages will be offloaded
+	It("SendMessage - Always offload - Offloaded", func() {
+		fake, store := newFakeSQS(), newFakePayloadStore()
+		conn := createConsumerConnection(fake, WithExtendedClient{Store: store, Bucket: "test-bucket",
+			AlwaysOffload: true})
+
+		_, err := conn.SendMessage(context.Background(), "test-queue", "small")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(fake.messages[0].MessageAttributes).Should(HaveKey(ExtendedPayloadSizeAttribute))
+		Expect(store.objects).Should(HaveLen(1))
+	})
+
+	// Tests that, if the payload cannot be uploaded, then calling SendMessage will return an error
+	It("SendMessage - Upload fails - Error", func() {
+
+		// First, create an extended connection to a fake queue and a store that will fail
+		fake, store := newFakeSQS(), newFakePayloadStore()
+		store.err = fmt.Errorf("upload failed")
+		conn := createConsumerConnection(fake, WithExtendedClient{Store: store, Bucket: "test-bucket",
+			AlwaysOffload: true})
+
+		// Next, attempt to send a message; this should fail
+		output, err := conn.SendMessage(context.Background(), "test-queue", "small")
+
+		// Finally, verify the error and that nothing was sent
+		Expect(output).Should(BeNil())
+		Expect(err.(*utils.GError).Message).Should(Equal("Failed to offload SQS message payload to S3"))
+		Expect(err.(*utils.GError).Inner).Should(Equal(store.err))
+		Expect(fake.messages).Should(BeEmpty())
+	})
+
+	// Tests that SendMessages will offload large entries before paging them so that they can be batched together
+	It("SendMessages - Over threshold - Offloaded and batched", func() {
+
+		// First, create an extended connection to a fake queue and store
+		fake, store := newFakeSQS(), newFakePayloadStore()
+		conn := createConsumerConnection(fake, WithExtendedClient{Store: store, Bucket: "test-bucket"})
+
+		// Next, send a number of items that are each too large to send to SQS; this should not fail
+		items := []any{strings.Repeat("a", 300000), "small", strings.Repeat("b", 300000)}
+		output, err := conn.SendMessages(context.Background(), "test-queue", items)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(output.Successful).Should(HaveLen(3))
+
+		// Finally, verify that the entries were sent together and that only the large items were offloaded
+		batches := fake.sentBatches()
+		Expect(batches).Should(HaveLen(1))
+		Expect(batches[0].Entries[0].MessageAttributes).Should(HaveKey(ExtendedPayloadSizeAttribute))
+		Expect(batches[0].Entries[1].MessageAttributes).ShouldNot(HaveKey(ExtendedPayloadSizeAttribute))
+		Expect(batches[0].Entries[2].MessageAttributes).Should(HaveKey(ExtendedPayloadSizeAttribute))
+		Expect(store.objects).Should(HaveLen(2))
+	})
+
+	// Tests that messages sent with an offloaded payload will have the payload resolved when they are received
+	It("ReceiveMessages - Offloaded payload - Resolved", func() {
+
+		// First, create an extended connection to a fake queue and store and send a large message to it
+		fake, store := newFakeSQS(), newFakePayloadStore()
+		conn := createConsumerConnection(fake, WithExtendedClient{Store: store, Bucket: "test-bucket",
+			Threshold: 10})
+		_, err := conn.SendMessage(context.Background(), "test-queue", "a large message", WithMessageAttribute("source",
+			&types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String("test")}))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, receive the message; this should not fail
+		messages, err := ReceiveMessages[string](context.Background(), conn, "test-queue")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that the payload was resolved and that the pointer attribute was removed
+		Expect(messages).Should(HaveLen(1))
+		Expect(messages[0].Err).ShouldNot(HaveOccurred())
+		Expect(messages[0].Body).Should(Equal("a large message"))
+		Expect(messages[0].Payload).ShouldNot(BeNil())
+		Expect(messages[0].Payload.Bucket).Should(Equal("test-bucket"))
+		Expect(messages[0].Attributes).Should(HaveKey("source"))
+		Expect(messages[0].Attributes).ShouldNot(HaveKey(ExtendedPayloadSizeAttribute))
+	})
+
+	// Tests that messages offloaded by older versions of the AWS Extended Client will be resolved
+	It("ReceiveMessages - Legacy attribute - Resolved", func() {
+
+		// First, create an extended connection to a fake queue and store and add a message in the legacy format
+		fake, store := newFakeSQS(), newFakePayloadStore()
+		conn := createConsumerConnection(fake, WithExtendedClient{Store: store, Bucket: "test-bucket"})
+		store.objects["test-bucket/legacy"] = []byte(encodeTestBody(42))
+		fake.messages = append(fake.messages, types.Message{
+			MessageId:     aws.String("legacy"),
+			ReceiptHandle: aws.String("legacy"),
+			Body: aws.String(`["software.amazon.payloadoffloading.PayloadS3Pointer",` +
+				`{"s3BucketName":"test-bucket","s3Key":"legacy"}]`),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				"SQSLargePayloadSize": {DataType: aws.String("Number"), StringValue: aws.String("4")},
+			},
+		})
+
+		// Next, receive the message; this should not fail
+		messages, err := ReceiveMessages[int](context.Background(), conn, "test-queue")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that the payload was resolved
+		Expect(messages[0].Err).ShouldNot(HaveOccurred())
+		Expect(messages[0].Body).Should(Equal(42))
+		Expect(messages[0].Attributes).Should(BeEmpty())
+	})
+
+	// Tests that, if a message has an offloaded payload but the connection has no extended client, then the message
+	// will be returned with an error
+	It("ReceiveMessages - No extended client - Error on message", func() {
+
+		// First, send a large message with an extended connection
+		fake, store := newFakeSQS(), newFakePayloadStore()
+		sender := createConsumerConnection(fake, WithExtendedClient{Store: store, Bucket: "test-bucket",
+			AlwaysOffload: true})
+		_, err := sender.SendMessage(context.Background(), "test-queue", "message")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, receive the message with a connection that isn't extended; this should not fail
+		messages, err := ReceiveMessages[string](context.Background(), createConsumerConnection(fake), "test-queue")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that the message has an error but still has its metadata
+		Expect(messages).Should(HaveLen(1))
+		Expect(messages[0].ID).Should(Equal("sent-0"))
+		Expect(messages[0].Err.(*utils.GError).Inner.Error()).Should(
+			Equal("payload was offloaded to S3 but no extended client was configured"))
+	})
+
+	// Tests that deleting a message with an offloaded payload will also delete the payload if the connection is
+	// configured to delete payloads
+	DescribeTable("DeleteMessage - Offloaded payload",
+		func(deletePayloads bool, remaining int) {
+
+			// First, create an extended connection to a fake queue and store and send a large message to it
+			fake, store := newFakeSQS(), newFakePayloadStore()
+			conn := createConsumerConnection(fake, WithExtendedClient{Store: store, Bucket: "test-bucket",
+				AlwaysOffload: true, DeletePayloads: deletePayloads})
+			_, err := conn.SendMessage(context.Background(), "test-queue", "message")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			// Next, receive the message and then delete it; this should not fail
+			messages, err := ReceiveMessages[string](context.Background(), conn, "test-queue")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(DeleteMessage(context.Background(), conn, "test-queue", messages[0])).ShouldNot(HaveOccurred())
+
+			// Finally, verify that the message was deleted and whether the payload was deleted
+			Expect(fake.deletedHandles()).Should(Equal([]string{"sent-0"}))
+			Expect(store.objects).Should(HaveLen(remaining))
+		},
+		Entry("Delete payloads set - Payload deleted", true, 0),
+		Entry("Delete payloads not set - Payload kept", false, 1))
+})
+
+// Helper type that fakes an S3 bucket for storing payloads
+type fakePayloadStore struct {
+	lock    sync.Mutex
+	objects map[string][]byte
+	err     error
+}
+
+// Helper function that creates a new, empty fake payload store
+func newFakePayloadStore() *fakePayloadStore {
+	return &fakePayloadStore{objects: make(map[string][]byte)}
+}
+
+// Download returns the object stored with the bucket and key
+func (store *fakePayloadStore) Download(ctx context.Context, bucket string, key string) ([]byte, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	data, ok := store.objects[bucket+"/"+key]
+	if !ok {
+		return nil, fmt.Errorf("object %s/%s not found", bucket, key)
+	}
+
+	return data, nil
+}
+
+// DownloadToStream returns the object stored with the bucket and key
+func (store *fakePayloadStore) DownloadToStream(ctx context.Context, bucket string, key string) (io.Writer, error) {
+	data, err := store.Download(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewBuffer(data), nil
+}
+
+// UploadFromStream stores the object with the bucket and key
+func (store *fakePayloadStore) UploadFromStream(ctx context.Context, bucket string, key string, body io.Reader) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if store.err != nil {
+		return store.err
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	store.objects[bucket+"/"+key] = data
+	return nil
+}
+
+// Delete removes the object stored with the bucket and key
+func (store *fakePayloadStore) Delete(ctx context.Context, bucket string, key string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	delete(store.objects, bucket+"/"+key)
+	return nil
+}
+
+// Helper function that returns the object stored with the bucket and key as a string
+func (store *fakePayloadStore) get(bucket string, key string) string {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return string(store.objects[bucket+"/"+key])
+}
+
+// Helper function that encodes a value in the same way as SendMessage
+func encodeTestBody(value any) string {
+	data, err := json.Marshal(value)
+	Expect(err).ShouldNot(HaveOccurred())
+	return base64.StdEncoding.EncodeToString(data)
+}
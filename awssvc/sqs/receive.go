@@ -0,0 +1,118 @@
+package sqs
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Message contains a message received from SQS along with its decoded body
+type Message[T any] struct {
+	ID               string
+	ReceiptHandle    string
+	ReceiveCount     int
+	Attributes       map[string]types.MessageAttributeValue
+	SystemAttributes map[string]string
+	Body             T
+	Payload          *PayloadS3Pointer
+	Err              error
+	Inner            types.Message
+}
+
+// ReceiveMessages receives messages from the SQS queue indicated by the URL and decodes the body of each into the
+// type provided, reversing the encoding done by SendMessage and SendMessages. The options provided may be used to
+// modify the request. By default, up to 10 messages will be received and all their attributes will be returned.
+// If a message cannot be decoded then its Err field will be set and the remaining messages will still be returned
+func ReceiveMessages[T any](ctx context.Context, conn *SQSConnection, url string,
+	options ...ReceiveMessageOption) ([]*Message[T], error) {
+
+	// First, create our receive-message input from the URL, requesting all the attributes associated with the messages
+	input := sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String(url),
+		AttributeNames:        []types.QueueAttributeName{types.QueueAttributeNameAll},
+		MaxNumberOfMessages:   10,
+		MessageAttributeNames: []string{"All"},
+	}
+
+	// Next, iterate over all the options and apply them to the input
+	for _, option := range options {
+		option.Apply(&input)
+	}
+
+	// Finally, attempt to receive and decode messages from SQS; if this fails then return an error
+	messages, err := receiveMessages[T](ctx, conn, &input)
+	if err != nil {
+		return nil, conn.logger.Error(err, "Failed to receive SQS messages from %q", url)
+	}
+
+	return messages, nil
+}
+
+// DecodeMessage decodes the body of an SQS message, written by SendMessage or SendMessages, into the type provided.
+// The body will be decoded with the codec named by the message's CodecAttribute, which may be one of the codecs
+// provided or one of the built-in codecs. If the message has no such attribute then the first codec provided, or
+// Base64JSONCodec if none were provided, will be used. The message returned will contain the message's metadata
+// even if decoding fails, in which case its Err field will also be set to the error that was returned
+func DecodeMessage[T any](inner types.Message, codecs ...ICodec) (*Message[T], error) {
+
+	// First, create our message from the metadata associated with the SQS message
+	message := Message[T]{
+		ID:               aws.ToString(inner.MessageId),
+		ReceiptHandle:    aws.ToString(inner.ReceiptHandle),
+		Attributes:       inner.MessageAttributes,
+		SystemAttributes: inner.Attributes,
+		Inner:            inner,
+	}
+
+	// Next, extract the approximate receive count from the system attributes, if it was requested
+	if count, ok := inner.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)]; ok {
+		if message.ReceiveCount, message.Err = strconv.Atoi(count); message.Err != nil {
+			return &message, message.Err
+		}
+	}
+
+	// Now, find the codec the message was encoded with; if there isn't one then return an error
+	codec, err := findCodec(aws.ToString(inner.MessageAttributes[CodecAttribute].StringValue), codecs...)
+	if err != nil {
+		message.Err = err
+		return &message, err
+	}
+
+	// Finally, attempt to decode the body of the message into our value
+	message.Err = codec.Decode(aws.ToString(inner.Body), &message.Body)
+	return &message, message.Err
+}
+
+// Helper function that receives messages from SQS and decodes each of them, downloading any payloads that were
+// offloaded to S3. If a message fails to decode then the error will be recorded on the message rather than failing
+// the entire request
+func receiveMessages[T any](ctx context.Context, conn *SQSConnection,
+	input *sqs.ReceiveMessageInput) ([]*Message[T], error) {
+
+	// First, attempt to receive messages from SQS; if this fails then return an error
+	output, err := conn.sqs.ReceiveMessage(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	// Finally, resolve and decode each of the messages we received
+	messages := make([]*Message[T], len(output.Messages))
+	for i, inner := range output.Messages {
+		pointer, resolveErr := conn.resolvePayload(ctx, &inner)
+		messages[i], err = DecodeMessage[T](inner, conn.codec)
+		if resolveErr != nil {
+			err = resolveErr
+		}
+
+		messages[i].Payload = pointer
+		if err != nil {
+			messages[i].Err = conn.logger.Error(err, "Failed to decode SQS message %q from %q",
+				messages[i].ID, *input.QueueUrl)
+		}
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,169 @@
+package sqs
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xefino/goutils/utils"
+)
+
+var _ = Describe("SQS Receive Tests", func() {
+
+	// Tests that, if the SQS.ReceiveMessage function fails, then calling ReceiveMessages will return an error
+	It("ReceiveMessages - ReceiveMessage fails - Error", func() {
+
+		// First, create a fake queue that will fail to receive messages
+		fake := newFakeSQS()
+		fake.err = fmt.Errorf("receive failed")
+
+		// Next, attempt to receive messages from the queue; this should fail
+		messages, err := ReceiveMessages[int](context.Background(), createConsumerConnection(fake), "test-queue")
+
+		// Finally, verify the error that was returned
+		Expect(messages).Should(BeNil())
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*utils.GError).Message).Should(Equal("Failed to receive SQS messages from \"test-queue\""))
+		Expect(err.(*utils.GError).Inner).Should(Equal(fake.err))
+	})
+
+	// Tests that, if no options are provided, then calling ReceiveMessages will request all the attributes of up to
+	// ten messages and that options will modify the request
+	It("ReceiveMessages - Options - Applied to request", func() {
+
+		// First, create an empty fake queue and a connection to it
+		fake := newFakeSQS()
+		conn := createConsumerConnection(fake)
+
+		// Next, receive messages without any options and verify the request; this should not fail
+		messages, err := ReceiveMessages[int](context.Background(), conn, "test-queue")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(messages).Should(BeEmpty())
+		Expect(*fake.input.QueueUrl).Should(Equal("test-queue"))
+		Expect(fake.input.MaxNumberOfMessages).Should(Equal(int32(10)))
+		Expect(fake.input.AttributeNames).Should(Equal([]types.QueueAttributeName{types.QueueAttributeNameAll}))
+		Expect(fake.input.MessageAttributeNames).Should(Equal([]string{"All"}))
+
+		// Now, receive messages with options; this should not fail
+		_, err = ReceiveMessages[int](context.Background(), conn, "test-queue", WithMaxNumberOfMessages(3),
+			WithWaitTimeSeconds(5), WithReceiveVisibilityTimeout(30), WithReceiveRequestAttemptID("attempt"))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify that the options were applied to the request
+		Expect(fake.input.MaxNumberOfMessages).Should(Equal(int32(3)))
+		Expect(fake.input.WaitTimeSeconds).Should(Equal(int32(5)))
+		Expect(fake.input.VisibilityTimeout).Should(Equal(int32(30)))
+		Expect(*fake.input.ReceiveRequestAttemptId).Should(Equal("attempt"))
+	})
+
+	// Tests that, if some messages cannot be decoded, then calling ReceiveMessages will return all the messages
+	// with errors set only on the messages that failed
+	It("ReceiveMessages - Some messages fail to decode - Errors reported per message", func() {
+
+		// First, create a fake queue with a valid message, a message that isn't base-64 encoded and a message
+		// that isn't valid JSON
+		fake := newFakeSQS()
+		fake.add(3, 2)
+		fake.addRaw("bad-base64", "not-base64!", 1)
+		fake.addRaw("bad-json", base64.StdEncoding.EncodeToString([]byte("{")), 1)
+
+		// Next, receive the messages from the queue; this should not fail
+		messages, err := ReceiveMessages[int](context.Background(), createConsumerConnection(fake), "test-queue")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(messages).Should(HaveLen(3))
+
+		// Now, verify that the valid message was decoded
+		Expect(messages[0].Err).ShouldNot(HaveOccurred())
+		Expect(messages[0].ID).Should(Equal("3"))
+		Expect(messages[0].ReceiptHandle).Should(Equal("3"))
+		Expect(messages[0].ReceiveCount).Should(Equal(2))
+		Expect(messages[0].Body).Should(Equal(3))
+
+		// Finally, verify that the invalid messages have errors but still have their metadata
+		for _, message := range messages[1:] {
+			Expect(message.Err).Should(HaveOccurred())
+			Expect(message.Err.(*utils.GError).Message).Should(Equal(
+				fmt.Sprintf("Failed to decode SQS message %q from \"test-queue\"", message.ID)))
+			Expect(message.ReceiptHandle).Should(Equal(message.ID))
+			Expect(message.ReceiveCount).Should(Equal(1))
+		}
+	})
+
+	// Tests that a message written in the format used by SendMessage can be decoded along with its metadata
+	It("DecodeMessage - Valid message - Decoded", func() {
+
+		// First, create a message with a JSON body encoded as a base-64 string, along with some attributes
+		inner := types.Message{
+			MessageId:     aws.String("message-id"),
+			ReceiptHandle: aws.String("receipt-handle"),
+			Body:          aws.String(base64.StdEncoding.EncodeToString([]byte(`{"Name":"test","Value":42}`))),
+			Attributes:    map[string]string{"ApproximateReceiveCount": "4", "SentTimestamp": "1000"},
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				"source": {DataType: aws.String("String"), StringValue: aws.String("test")},
+			},
+		}
+
+		// Next, attempt to decode the message; this should not fail
+		message, err := DecodeMessage[testPayload](inner)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Finally, verify the message that was decoded
+		Expect(message.ID).Should(Equal("message-id"))
+		Expect(message.ReceiptHandle).Should(Equal("receipt-handle"))
+		Expect(message.ReceiveCount).Should(Equal(4))
+		Expect(message.SystemAttributes).Should(HaveKeyWithValue("SentTimestamp", "1000"))
+		Expect(*message.Attributes["source"].StringValue).Should(Equal("test"))
+		Expect(message.Body).Should(Equal(testPayload{Name: "test", Value: 42}))
+		Expect(message.Err).ShouldNot(HaveOccurred())
+		Expect(message.Inner).Should(Equal(inner))
+	})
+
+	// Tests that, if the receive count is not a valid integer, then calling DecodeMessage will return an error
+	It("DecodeMessage - Receive count invalid - Error", func() {
+
+		// First, create a message with an invalid receive count
+		inner := types.Message{
+			MessageId:  aws.String("message-id"),
+			Body:       aws.String(base64.StdEncoding.EncodeToString([]byte(`{}`))),
+			Attributes: map[string]string{"ApproximateReceiveCount": "derp"},
+		}
+
+		// Next, attempt to decode the message; this should fail
+		message, err := DecodeMessage[testPayload](inner)
+
+		// Finally, verify the error and that the metadata is still available
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(Equal("strconv.Atoi: parsing \"derp\": invalid syntax"))
+		Expect(message.Err).Should(Equal(err))
+		Expect(message.ID).Should(Equal("message-id"))
+	})
+
+	// Tests that, if the body is not valid JSON, then calling DecodeMessage will return an error
+	It("DecodeMessage - Body invalid - Error", func() {
+
+		// First, create a message with a body that isn't valid JSON
+		inner := types.Message{
+			MessageId: aws.String("message-id"),
+			Body:      aws.String(base64.StdEncoding.EncodeToString([]byte(`{"Name":`))),
+		}
+
+		// Next, attempt to decode the message; this should fail
+		message, err := DecodeMessage[testPayload](inner)
+
+		// Finally, verify the error and that the metadata is still available
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(Equal("unexpected end of JSON input"))
+		Expect(message.Err).Should(Equal(err))
+		Expect(message.ReceiveCount).Should(BeZero())
+	})
+})
+
+// Helper type used to test decoding messages into structured payloads
+type testPayload struct {
+	Name  string
+	Value int
+}
@@ -0,0 +1,15 @@
+package testing
+
+import (
+	gotesting "testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// Create a new test runner we'll use to test all the
+// modules in the testing package
+func TestTesting(t *gotesting.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Testing Suite")
+}
@@ -0,0 +1,596 @@
+package testing
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/shopspring/decimal"
+)
+
+// FakeDynamoDB is an in-process, in-memory implementation of the DynamoDB API that can be used in place of a
+// DynamoDB client when testing. It supports creating, describing, updating and deleting tables with global
+// and local secondary indexes, the single-item operations, Query and Scan (including parallel scans) with key
+// condition, filter, projection and update expressions, BatchGetItem, BatchWriteItem, TransactGetItems and
+// TransactWriteItems. Errors, such as throttling, may be injected into any operation so that retry logic can
+// be exercised. Operations that are not supported will return an error. FakeDynamoDB is safe for concurrent use
+type FakeDynamoDB struct {
+	lock        sync.Mutex
+	tables      map[string]*fakeTable
+	failures    map[string][]error
+	unprocessed int
+	calls       map[string]int
+	tokens      map[string]struct{}
+}
+
+// Helper type that contains the description and items associated with a single table
+type fakeTable struct {
+	description *types.TableDescription
+	ttl         *types.TimeToLiveDescription
+	items       map[string]map[string]types.AttributeValue
+}
+
+// Helper type that describes the key schema of a table or one of its indexes
+type fakeIndex struct {
+	name         string
+	partitionKey string
+	sortKey      string
+	projection   *types.Projection
+}
+
+// NewFakeDynamoDB creates a new, empty, in-memory DynamoDB fake
+func NewFakeDynamoDB() *FakeDynamoDB {
+	return &FakeDynamoDB{
+		tables:   make(map[string]*fakeTable),
+		failures: make(map[string][]error),
+		calls:    make(map[string]int),
+		tokens:   make(map[string]struct{}),
+	}
+}
+
+// FailNext causes the next count calls to the operation, which should be the name of a DynamoDB API function
+// such as PutItem, to fail with the error provided. The error will be wrapped in a smithy.OperationError, as
+// it would be by the DynamoDB client
+func (fake *FakeDynamoDB) FailNext(operation string, count int, err error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	for i := 0; i < count; i++ {
+		fake.failures[operation] = append(fake.failures[operation], err)
+	}
+}
+
+// ThrottleNext causes the next count calls to the operation, which should be the name of a DynamoDB API
+// function such as PutItem, to fail with a ProvisionedThroughputExceededException
+func (fake *FakeDynamoDB) ThrottleNext(operation string, count int) {
+	fake.FailNext(operation, count, &types.ProvisionedThroughputExceededException{
+		Message: aws.String("The level of configured provisioned throughput for the table was exceeded"),
+	})
+}
+
+// UnprocessNext causes the next count calls to BatchGetItem or BatchWriteItem to process only the first key or
+// request in the batch, returning all the others as unprocessed
+func (fake *FakeDynamoDB) UnprocessNext(count int) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.unprocessed += count
+}
+
+// Calls returns the number of times the operation, which should be the name of a DynamoDB API function such as
+// PutItem, has been called, including calls that failed
+func (fake *FakeDynamoDB) Calls(operation string) int {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return fake.calls[operation]
+}
+
+// CreateTable creates a new table with the key schema, attribute definitions and indexes on the input. The
+// table will be active as soon as this function returns
+func (fake *FakeDynamoDB) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	const operation = "CreateTable"
+
+	// First, check if we should fail the operation; if we should then return an error
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if err := fake.intercept(operation); err != nil {
+		return nil, err
+	}
+
+	// Next, ensure that the table doesn't already exist
+	name := aws.ToString(params.TableName)
+	if _, ok := fake.tables[name]; ok {
+		return nil, operationError(operation, &types.ResourceInUseException{
+			Message: aws.String(fmt.Sprintf("Table already exists: %s", name)),
+		})
+	}
+
+	// Now, create the table description from the input and verify that the key schemas are valid
+	now := time.Now()
+	description := types.TableDescription{
+		TableName:            aws.String(name),
+		TableArn:             aws.String("arn:aws:dynamodb:local:000000000000:table/" + name),
+		TableId:              aws.String(fmt.Sprintf("%x", now.UnixNano())),
+		TableStatus:          types.TableStatusActive,
+		CreationDateTime:     aws.Time(now),
+		KeySchema:            append([]types.KeySchemaElement{}, params.KeySchema...),
+		AttributeDefinitions: append([]types.AttributeDefinition{}, params.AttributeDefinitions...),
+		BillingModeSummary:   &types.BillingModeSummary{BillingMode: params.BillingMode},
+		ProvisionedThroughput: &types.ProvisionedThroughputDescription{
+			NumberOfDecreasesToday: aws.Int64(0),
+		},
+		StreamSpecification: params.StreamSpecification,
+	}
+
+	if params.ProvisionedThroughput != nil {
+		description.ProvisionedThroughput.ReadCapacityUnits = params.ProvisionedThroughput.ReadCapacityUnits
+		description.ProvisionedThroughput.WriteCapacityUnits = params.ProvisionedThroughput.WriteCapacityUnits
+	}
+
+	if err := validateKeySchema(params.KeySchema, params.AttributeDefinitions); err != nil {
+		return nil, operationError(operation, err)
+	}
+
+	for _, index := range params.GlobalSecondaryIndexes {
+		if err := validateKeySchema(index.KeySchema, params.AttributeDefinitions); err != nil {
+			return nil, operationError(operation, err)
+		}
+
+		description.GlobalSecondaryIndexes = append(description.GlobalSecondaryIndexes,
+			createGSIDescription(name, index.IndexName, index.KeySchema, index.Projection))
+	}
+
+	for _, index := range params.LocalSecondaryIndexes {
+		if err := validateKeySchema(index.KeySchema, params.AttributeDefinitions); err != nil {
+			return nil, operationError(operation, err)
+		}
+
+		description.LocalSecondaryIndexes = append(description.LocalSecondaryIndexes,
+			types.LocalSecondaryIndexDescription{
+				IndexName:  index.IndexName,
+				IndexArn:   aws.String(fmt.Sprintf("arn:aws:dynamodb:local:000000000000:table/%s/index/%s", name, *index.IndexName)),
+				KeySchema:  index.KeySchema,
+				Projection: index.Projection,
+			})
+	}
+
+	// Finally, save the table and return its description
+	fake.tables[name] = &fakeTable{
+		description: &description,
+		ttl:         &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled},
+		items:       make(map[string]map[string]types.AttributeValue),
+	}
+
+	return &dynamodb.CreateTableOutput{TableDescription: fake.tables[name].describe()}, nil
+}
+
+// DeleteTable deletes a table and all its items
+func (fake *FakeDynamoDB) DeleteTable(ctx context.Context, params *dynamodb.DeleteTableInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error) {
+	const operation = "DeleteTable"
+
+	// First, check if we should fail the operation and get the table; if either fails then return an error
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	table, err := fake.table(operation, params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, delete the table and return its description
+	description := table.describe()
+	description.TableStatus = types.TableStatusDeleting
+	delete(fake.tables, *params.TableName)
+	return &dynamodb.DeleteTableOutput{TableDescription: description}, nil
+}
+
+// DescribeTable returns the description of a table
+func (fake *FakeDynamoDB) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	table, err := fake.table("DescribeTable", params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.DescribeTableOutput{Table: table.describe()}, nil
+}
+
+// ListTables returns the names of the tables that have been created, in alphabetical order
+func (fake *FakeDynamoDB) ListTables(ctx context.Context, params *dynamodb.ListTablesInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.ListTablesOutput, error) {
+
+	// First, check if we should fail the operation; if we should then return an error
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if err := fake.intercept("ListTables"); err != nil {
+		return nil, err
+	}
+
+	// Next, get the names of all the tables and sort them
+	names := make([]string, 0, len(fake.tables))
+	for name := range fake.tables {
+		if params.ExclusiveStartTableName == nil || name > *params.ExclusiveStartTableName {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	// Finally, limit the names to the requested page size and return them
+	output := dynamodb.ListTablesOutput{TableNames: names}
+	if limit := int(aws.ToInt32(params.Limit)); limit > 0 && len(names) > limit {
+		output.TableNames = names[:limit]
+		output.LastEvaluatedTableName = aws.String(names[limit-1])
+	}
+
+	return &output, nil
+}
+
+// UpdateTable modifies the attribute definitions, billing mode and provisioned throughput of a table and
+// creates or deletes global secondary indexes on it. New indexes will be active as soon as this function returns
+func (fake *FakeDynamoDB) UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error) {
+	const operation = "UpdateTable"
+
+	// First, check if we should fail the operation and get the table; if either fails then return an error
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	table, err := fake.table(operation, params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, merge the new attribute definitions with the existing ones
+	description := table.description
+	for _, definition := range params.AttributeDefinitions {
+		found := false
+		for i, existing := range description.AttributeDefinitions {
+			if aws.ToString(existing.AttributeName) == aws.ToString(definition.AttributeName) {
+				description.AttributeDefinitions[i], found = definition, true
+			}
+		}
+
+		if !found {
+			description.AttributeDefinitions = append(description.AttributeDefinitions, definition)
+		}
+	}
+
+	// Now, update the billing mode and throughput, if they were provided
+	if params.BillingMode != "" {
+		description.BillingModeSummary = &types.BillingModeSummary{BillingMode: params.BillingMode}
+	}
+
+	if params.ProvisionedThroughput != nil {
+		description.ProvisionedThroughput.ReadCapacityUnits = params.ProvisionedThroughput.ReadCapacityUnits
+		description.ProvisionedThroughput.WriteCapacityUnits = params.ProvisionedThroughput.WriteCapacityUnits
+	}
+
+	// Finally, apply each of the index updates to the table
+	for _, update := range params.GlobalSecondaryIndexUpdates {
+		switch {
+		case update.Create != nil:
+			if _, err := table.index(update.Create.IndexName); err == nil {
+				return nil, operationError(operation, validationError("Attempting to create an index which "+
+					"already exists: %s", aws.ToString(update.Create.IndexName)))
+			} else if err := validateKeySchema(update.Create.KeySchema, description.AttributeDefinitions); err != nil {
+				return nil, operationError(operation, err)
+			}
+
+			description.GlobalSecondaryIndexes = append(description.GlobalSecondaryIndexes,
+				createGSIDescription(*params.TableName, update.Create.IndexName, update.Create.KeySchema,
+					update.Create.Projection))
+		case update.Delete != nil:
+			remaining := make([]types.GlobalSecondaryIndexDescription, 0, len(description.GlobalSecondaryIndexes))
+			for _, index := range description.GlobalSecondaryIndexes {
+				if aws.ToString(index.IndexName) != aws.ToString(update.Delete.IndexName) {
+					remaining = append(remaining, index)
+				}
+			}
+
+			if len(remaining) == len(description.GlobalSecondaryIndexes) {
+				return nil, operationError(operation, &types.ResourceNotFoundException{
+					Message: aws.String(fmt.Sprintf("Requested resource not found: Index: %s",
+						aws.ToString(update.Delete.IndexName))),
+				})
+			}
+
+			description.GlobalSecondaryIndexes = remaining
+		}
+	}
+
+	return &dynamodb.UpdateTableOutput{TableDescription: table.describe()}, nil
+}
+
+// DescribeTimeToLive returns the time-to-live settings associated with a table
+func (fake *FakeDynamoDB) DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	table, err := fake.table("DescribeTimeToLive", params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := *table.ttl
+	return &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: &ttl}, nil
+}
+
+// UpdateTimeToLive enables or disables time-to-live on a table. Note that expired items will not be deleted
+func (fake *FakeDynamoDB) UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	const operation = "UpdateTimeToLive"
+
+	// First, check if we should fail the operation and get the table; if either fails then return an error
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	table, err := fake.table(operation, params.TableName)
+	if err != nil {
+		return nil, err
+	} else if params.TimeToLiveSpecification == nil {
+		return nil, operationError(operation, validationError("TimeToLiveSpecification is required"))
+	}
+
+	// Next, update the time-to-live settings on the table and return them
+	spec := params.TimeToLiveSpecification
+	if aws.ToBool(spec.Enabled) {
+		table.ttl = &types.TimeToLiveDescription{AttributeName: spec.AttributeName,
+			TimeToLiveStatus: types.TimeToLiveStatusEnabled}
+	} else {
+		table.ttl = &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled}
+	}
+
+	return &dynamodb.UpdateTimeToLiveOutput{TimeToLiveSpecification: spec}, nil
+}
+
+// Helper function that records a call to an operation and returns the next error that was injected for it,
+// if there is one. This function must be called while the lock is held
+func (fake *FakeDynamoDB) intercept(operation string) error {
+	fake.calls[operation]++
+	if failures := fake.failures[operation]; len(failures) > 0 {
+		fake.failures[operation] = failures[1:]
+		return operationError(operation, failures[0])
+	}
+
+	return nil
+}
+
+// Helper function that records a call to an operation and retrieves the table with the name provided. If an
+// error was injected for the operation, or the table doesn't exist, then an error will be returned. This
+// function must be called while the lock is held
+func (fake *FakeDynamoDB) table(operation string, name *string) (*fakeTable, error) {
+	if err := fake.intercept(operation); err != nil {
+		return nil, err
+	}
+
+	return fake.lookup(operation, name)
+}
+
+// Helper function that retrieves the table with the name provided, returning an error if it doesn't exist.
+// This function must be called while the lock is held
+func (fake *FakeDynamoDB) lookup(operation string, name *string) (*fakeTable, error) {
+	table, ok := fake.tables[aws.ToString(name)]
+	if !ok {
+		return nil, operationError(operation, &types.ResourceNotFoundException{
+			Message: aws.String("Cannot do operations on a non-existent table"),
+		})
+	}
+
+	return table, nil
+}
+
+// Helper function that creates a copy of the table's description with the current item count
+func (table *fakeTable) describe() *types.TableDescription {
+	description := *table.description
+	description.ItemCount = aws.Int64(int64(len(table.items)))
+	description.TableSizeBytes = aws.Int64(0)
+	description.AttributeDefinitions = append([]types.AttributeDefinition{}, description.AttributeDefinitions...)
+	description.GlobalSecondaryIndexes = append([]types.GlobalSecondaryIndexDescription{},
+		description.GlobalSecondaryIndexes...)
+	description.LocalSecondaryIndexes = append([]types.LocalSecondaryIndexDescription{},
+		description.LocalSecondaryIndexes...)
+	for i := range description.GlobalSecondaryIndexes {
+		description.GlobalSecondaryIndexes[i].ItemCount = aws.Int64(int64(len(table.items)))
+	}
+
+	return &description
+}
+
+// Helper function that retrieves the key schema of the table, or of one of its indexes if a name is provided
+func (table *fakeTable) index(name *string) (*fakeIndex, error) {
+
+	// First, if we have no index name then return the key schema of the table itself
+	if name == nil {
+		partitionKey, sortKey := keyNames(table.description.KeySchema)
+		return &fakeIndex{partitionKey: partitionKey, sortKey: sortKey}, nil
+	}
+
+	// Next, search the global and local secondary indexes for the index with the name provided
+	for _, index := range table.description.GlobalSecondaryIndexes {
+		if aws.ToString(index.IndexName) == *name {
+			partitionKey, sortKey := keyNames(index.KeySchema)
+			return &fakeIndex{name: *name, partitionKey: partitionKey, sortKey: sortKey,
+				projection: index.Projection}, nil
+		}
+	}
+
+	for _, index := range table.description.LocalSecondaryIndexes {
+		if aws.ToString(index.IndexName) == *name {
+			partitionKey, sortKey := keyNames(index.KeySchema)
+			return &fakeIndex{name: *name, partitionKey: partitionKey, sortKey: sortKey,
+				projection: index.Projection}, nil
+		}
+	}
+
+	// Finally, if we reached this point then the index doesn't exist so return an error
+	return nil, validationError("The table does not have the specified index: %s", *name)
+}
+
+// Helper function that creates the primary key of an item on the table, verifying that the item has all the
+// key attributes and that they have the correct type
+func (table *fakeTable) key(item map[string]types.AttributeValue) (string, error) {
+	partitionKey, sortKey := keyNames(table.description.KeySchema)
+	parts := make([]string, 0, 2)
+	for _, name := range []string{partitionKey, sortKey} {
+		if name == "" {
+			continue
+		}
+
+		// Get the key attribute from the item; if it doesn't exist then return an error
+		attr, ok := item[name]
+		if !ok {
+			return "", validationError("One of the required keys was not given a value")
+		}
+
+		// Ensure that the key attribute has the type it was defined with
+		for _, definition := range table.description.AttributeDefinitions {
+			if aws.ToString(definition.AttributeName) == name && string(definition.AttributeType) != typeOf(attr) {
+				return "", validationError("One or more parameter values were invalid: Type mismatch for key "+
+					"%s expected: %s actual: %s", name, definition.AttributeType, typeOf(attr))
+			}
+		}
+
+		// Encode the key attribute so that equal values will always produce the same key
+		encoded, err := encodeKeyValue(attr)
+		if err != nil {
+			return "", err
+		}
+
+		parts = append(parts, encoded)
+	}
+
+	return strings.Join(parts, "\x00"), nil
+}
+
+// Helper function that extracts the primary key attributes, and the key attributes of the index provided, from
+// an item
+func (table *fakeTable) keyAttributes(item map[string]types.AttributeValue,
+	index *fakeIndex) map[string]types.AttributeValue {
+	partitionKey, sortKey := keyNames(table.description.KeySchema)
+	key := make(map[string]types.AttributeValue)
+	for _, name := range []string{partitionKey, sortKey, index.partitionKey, index.sortKey} {
+		if attr, ok := item[name]; ok && name != "" {
+			key[name] = cloneValue(attr)
+		}
+	}
+
+	return key
+}
+
+// Helper function that applies the projection of an index to an item
+func (table *fakeTable) project(item map[string]types.AttributeValue,
+	index *fakeIndex) map[string]types.AttributeValue {
+
+	// First, if the index projects all attributes then return a copy of the item
+	if index.projection == nil || index.projection.ProjectionType == types.ProjectionTypeAll {
+		return cloneItem(item)
+	}
+
+	// Next, copy the key attributes and any included attributes from the item
+	projected := table.keyAttributes(item, index)
+	if index.projection.ProjectionType == types.ProjectionTypeInclude {
+		for _, name := range index.projection.NonKeyAttributes {
+			if attr, ok := item[name]; ok {
+				projected[name] = cloneValue(attr)
+			}
+		}
+	}
+
+	return projected
+}
+
+// Helper function that creates the description of a global secondary index
+func createGSIDescription(tableName string, name *string, schema []types.KeySchemaElement,
+	projection *types.Projection) types.GlobalSecondaryIndexDescription {
+	return types.GlobalSecondaryIndexDescription{
+		IndexName:   name,
+		IndexArn:    aws.String(fmt.Sprintf("arn:aws:dynamodb:local:000000000000:table/%s/index/%s", tableName, *name)),
+		IndexStatus: types.IndexStatusActive,
+		KeySchema:   schema,
+		Projection:  projection,
+		Backfilling: aws.Bool(false),
+	}
+}
+
+// Helper function that verifies that a key schema has a partition key and that every key attribute has been
+// defined with a scalar type
+func validateKeySchema(schema []types.KeySchemaElement, definitions []types.AttributeDefinition) error {
+
+	// First, ensure that the key schema has a partition key
+	if partitionKey, _ := keyNames(schema); partitionKey == "" {
+		return validationError("One or more parameter values were invalid: Missing the key schema's hash key")
+	}
+
+	// Next, ensure that every attribute in the key schema has been defined
+	for _, element := range schema {
+		found := false
+		for _, definition := range definitions {
+			if aws.ToString(definition.AttributeName) == aws.ToString(element.AttributeName) {
+				found = true
+			}
+		}
+
+		if !found {
+			return validationError("One or more parameter values were invalid: Some index key attributes are "+
+				"not defined in AttributeDefinitions. Keys: [%s]", aws.ToString(element.AttributeName))
+		}
+	}
+
+	return nil
+}
+
+// Helper function that returns the names of the partition and sort key attributes from a key schema
+func keyNames(schema []types.KeySchemaElement) (string, string) {
+	var partitionKey, sortKey string
+	for _, element := range schema {
+		switch element.KeyType {
+		case types.KeyTypeHash:
+			partitionKey = aws.ToString(element.AttributeName)
+		case types.KeyTypeRange:
+			sortKey = aws.ToString(element.AttributeName)
+		}
+	}
+
+	return partitionKey, sortKey
+}
+
+// Helper function that encodes a scalar key attribute as a string so that equal values produce equal strings
+func encodeKeyValue(attr types.AttributeValue) (string, error) {
+	switch casted := attr.(type) {
+	case *types.AttributeValueMemberS:
+		return "S" + casted.Value, nil
+	case *types.AttributeValueMemberN:
+		number, err := decimal.NewFromString(casted.Value)
+		if err != nil {
+			return "", validationError("The parameter cannot be converted to a numeric value: %s", casted.Value)
+		}
+
+		return "N" + number.String(), nil
+	case *types.AttributeValueMemberB:
+		return "B" + base64.StdEncoding.EncodeToString(casted.Value), nil
+	default:
+		return "", validationError("The provided key element does not match the schema")
+	}
+}
+
+// Helper function that creates the error DynamoDB returns when a request is invalid
+func validationError(format string, args ...interface{}) error {
+	return &smithy.GenericAPIError{
+		Code:    "ValidationException",
+		Message: fmt.Sprintf(format, args...),
+		Fault:   smithy.FaultClient,
+	}
+}
+
+// Helper function that wraps an error in an operation error, as the DynamoDB client would
+func operationError(operation string, err error) error {
+	return &smithy.OperationError{ServiceID: "DynamoDB", OperationName: operation, Err: err}
+}
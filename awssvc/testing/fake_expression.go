@@ -0,0 +1,1270 @@
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/shopspring/decimal"
+)
+
+// Helper type that describes the kind of a token in a DynamoDB expression
+type tokenKind int
+
+// The kinds of tokens that may appear in a DynamoDB expression
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenName
+	tokenValue
+	tokenNumber
+	tokenSymbol
+)
+
+// Helper type that describes a single token in a DynamoDB expression
+type exprToken struct {
+	kind tokenKind
+	text string
+}
+
+// Helper type that describes a single element of a document path, which is either the name of an attribute
+// or an index into a list
+type pathElement struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+// Helper type that describes a document path to an attribute on an item
+type exprPath []pathElement
+
+// Helper type that describes an operand in a DynamoDB expression, which will be evaluated against an item. If
+// the operand refers to an attribute that doesn't exist on the item then nil will be returned
+type exprOperand interface {
+	evaluate(item map[string]types.AttributeValue) (types.AttributeValue, error)
+}
+
+// Helper type that describes a condition in a DynamoDB expression, which will be evaluated against an item
+type exprCondition interface {
+	evaluate(item map[string]types.AttributeValue) (bool, error)
+}
+
+// Helper type that describes a single action in a DynamoDB update expression
+type updateAction struct {
+	keyword string
+	path    exprPath
+	value   exprOperand
+}
+
+// Helper type that parses a DynamoDB expression, resolving the attribute name and value placeholders as it goes
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+// Helper function that parses a condition, filter or key condition expression. If the expression is empty
+// then nil will be returned
+func parseCondition(expression *string, names map[string]string,
+	values map[string]types.AttributeValue) (exprCondition, error) {
+
+	// First, if we have no expression then return nil
+	if expression == nil || strings.TrimSpace(*expression) == "" {
+		return nil, nil
+	}
+
+	// Next, create our parser from the expression; if this fails then return an error
+	parser, err := newExprParser(*expression, names, values)
+	if err != nil {
+		return nil, err
+	}
+
+	// Finally, parse the condition and ensure that we consumed the entire expression
+	condition, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	} else if !parser.done() {
+		return nil, parser.unexpected()
+	}
+
+	return condition, nil
+}
+
+// Helper function that parses an update expression into a list of actions
+func parseUpdate(expression *string, names map[string]string,
+	values map[string]types.AttributeValue) ([]*updateAction, error) {
+
+	// First, if we have no expression then return an error as one is required
+	if expression == nil || strings.TrimSpace(*expression) == "" {
+		return nil, fmt.Errorf("Invalid UpdateExpression: The expression can not be empty")
+	}
+
+	// Next, create our parser from the expression; if this fails then return an error
+	parser, err := newExprParser(*expression, names, values)
+	if err != nil {
+		return nil, err
+	}
+
+	// Finally, iterate over the clauses in the update expression and parse the actions in each
+	actions := make([]*updateAction, 0)
+	for !parser.done() {
+
+		// First, read the keyword for the clause; this must be one of SET, REMOVE, ADD or DELETE
+		keyword := strings.ToUpper(parser.next().text)
+		switch keyword {
+		case "SET", "REMOVE", "ADD", "DELETE":
+		default:
+			parser.pos--
+			return nil, parser.unexpected()
+		}
+
+		// Next, parse each of the comma-separated actions in the clause
+		for {
+			path, err := parser.parsePath()
+			if err != nil {
+				return nil, err
+			}
+
+			action := updateAction{keyword: keyword, path: path}
+			switch keyword {
+			case "SET":
+				if err := parser.expect("="); err != nil {
+					return nil, err
+				}
+
+				if action.value, err = parser.parseSetValue(); err != nil {
+					return nil, err
+				}
+			case "ADD", "DELETE":
+				if action.value, err = parser.parseOperand(); err != nil {
+					return nil, err
+				}
+			}
+
+			actions = append(actions, &action)
+			if !parser.accept(",") {
+				break
+			}
+		}
+	}
+
+	return actions, nil
+}
+
+// Helper function that parses a projection expression into a list of document paths. If the expression is
+// empty then nil will be returned
+func parseProjection(expression *string, names map[string]string) ([]exprPath, error) {
+
+	// First, if we have no expression then return nil
+	if expression == nil || strings.TrimSpace(*expression) == "" {
+		return nil, nil
+	}
+
+	// Next, create our parser from the expression; if this fails then return an error
+	parser, err := newExprParser(*expression, names, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Finally, parse each of the comma-separated paths in the expression
+	paths := make([]exprPath, 0)
+	for {
+		path, err := parser.parsePath()
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, path)
+		if !parser.accept(",") {
+			break
+		}
+	}
+
+	if !parser.done() {
+		return nil, parser.unexpected()
+	}
+
+	return paths, nil
+}
+
+// Helper function that creates a new parser by splitting an expression into tokens
+func newExprParser(expression string, names map[string]string,
+	values map[string]types.AttributeValue) (*exprParser, error) {
+	parser := exprParser{names: names, values: values}
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '#' || r == ':' || unicode.IsLetter(r) || r == '_':
+
+			// Read the identifier, or the placeholder, up to the first character that can't be part of it
+			start := i
+			for i++; i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_'); i++ {
+			}
+
+			kind := tokenIdentifier
+			if r == '#' {
+				kind = tokenName
+			} else if r == ':' {
+				kind = tokenValue
+			}
+
+			if kind != tokenIdentifier && i-start == 1 {
+				return nil, fmt.Errorf("Invalid expression: Syntax error; token: %q, near: %q", string(r), expression)
+			}
+
+			parser.tokens = append(parser.tokens, exprToken{kind: kind, text: string(runes[start:i])})
+		case unicode.IsDigit(r):
+			start := i
+			for i++; i < len(runes) && unicode.IsDigit(runes[i]); i++ {
+			}
+
+			parser.tokens = append(parser.tokens, exprToken{kind: tokenNumber, text: string(runes[start:i])})
+		case r == '<' || r == '>':
+			if i+1 < len(runes) && (runes[i+1] == '=' || (r == '<' && runes[i+1] == '>')) {
+				parser.tokens = append(parser.tokens, exprToken{kind: tokenSymbol, text: string(runes[i : i+2])})
+				i += 2
+			} else {
+				parser.tokens = append(parser.tokens, exprToken{kind: tokenSymbol, text: string(r)})
+				i++
+			}
+		case strings.ContainsRune("=()[],.+-", r):
+			parser.tokens = append(parser.tokens, exprToken{kind: tokenSymbol, text: string(r)})
+			i++
+		default:
+			return nil, fmt.Errorf("Invalid expression: Syntax error; token: %q, near: %q", string(r), expression)
+		}
+	}
+
+	return &parser, nil
+}
+
+// Helper function that parses a list of conditions joined by OR
+func (parser *exprParser) parseOr() (exprCondition, error) {
+	left, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.acceptKeyword("OR") {
+		right, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &logicalCondition{and: false, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// Helper function that parses a list of conditions joined by AND
+func (parser *exprParser) parseAnd() (exprCondition, error) {
+	left, err := parser.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.acceptKeyword("AND") {
+		right, err := parser.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &logicalCondition{and: true, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// Helper function that parses a condition that may be negated with NOT
+func (parser *exprParser) parseNot() (exprCondition, error) {
+	if parser.acceptKeyword("NOT") {
+		inner, err := parser.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return &notCondition{inner: inner}, nil
+	}
+
+	return parser.parsePrimary()
+}
+
+// Helper function that parses a single condition, which may be a parenthesized condition, a function, a
+// comparison, a BETWEEN condition or an IN condition
+func (parser *exprParser) parsePrimary() (exprCondition, error) {
+
+	// First, if we have a parenthesized condition then parse it and return it
+	if parser.accept("(") {
+		inner, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		} else if err := parser.expect(")"); err != nil {
+			return nil, err
+		}
+
+		return inner, nil
+	}
+
+	// Next, if we have a condition function then parse its arguments and return it
+	token := parser.peek()
+	if token.kind == tokenIdentifier && parser.peekAt(1).text == "(" {
+		switch function := strings.ToLower(token.text); function {
+		case "attribute_exists", "attribute_not_exists", "attribute_type", "begins_with", "contains":
+			parser.pos++
+			args, err := parser.parseArguments()
+			if err != nil {
+				return nil, err
+			}
+
+			return newFunctionCondition(function, args)
+		}
+	}
+
+	// Now, parse the left operand and check which kind of condition we have
+	left, err := parser.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case parser.acceptKeyword("BETWEEN"):
+
+		// We have a BETWEEN condition so parse the lower and upper bounds
+		lower, err := parser.parseOperand()
+		if err != nil {
+			return nil, err
+		} else if !parser.acceptKeyword("AND") {
+			return nil, parser.unexpected()
+		}
+
+		upper, err := parser.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		return &betweenCondition{value: left, lower: lower, upper: upper}, nil
+	case parser.acceptKeyword("IN"):
+
+		// We have an IN condition so parse the list of possible values
+		if err := parser.expect("("); err != nil {
+			return nil, err
+		}
+
+		condition := inCondition{value: left}
+		for {
+			option, err := parser.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+
+			condition.options = append(condition.options, option)
+			if !parser.accept(",") {
+				break
+			}
+		}
+
+		if err := parser.expect(")"); err != nil {
+			return nil, err
+		}
+
+		return &condition, nil
+	default:
+
+		// Finally, we must have a comparison so parse the comparator and the right operand
+		comparator := parser.next()
+		switch comparator.text {
+		case "=", "<>", "<", "<=", ">", ">=":
+		default:
+			parser.pos--
+			return nil, parser.unexpected()
+		}
+
+		right, err := parser.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		return &comparisonCondition{left: left, comparator: comparator.text, right: right}, nil
+	}
+}
+
+// Helper function that parses the value of a SET action, which may be an operand or the sum or difference
+// of two operands
+func (parser *exprParser) parseSetValue() (exprOperand, error) {
+	left, err := parser.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if token := parser.peek(); token.text == "+" || token.text == "-" {
+		parser.pos++
+		right, err := parser.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		return &arithmeticOperand{left: left, plus: token.text == "+", right: right}, nil
+	}
+
+	return left, nil
+}
+
+// Helper function that parses a single operand, which may be a value placeholder, a function or a path
+func (parser *exprParser) parseOperand() (exprOperand, error) {
+
+	// First, if we have a value placeholder then resolve it and return the value
+	token := parser.peek()
+	if token.kind == tokenValue {
+		parser.pos++
+		value, ok := parser.values[token.text]
+		if !ok {
+			return nil, fmt.Errorf("Invalid expression: An expression attribute value used in expression "+
+				"is not defined; attribute value: %s", token.text)
+		}
+
+		return &valueOperand{value: value}, nil
+	}
+
+	// Next, if we have a function then parse its arguments and return it
+	if token.kind == tokenIdentifier && parser.peekAt(1).text == "(" {
+		parser.pos++
+		args, err := parser.parseArguments()
+		if err != nil {
+			return nil, err
+		}
+
+		switch function := strings.ToLower(token.text); function {
+		case "size":
+			if path, ok := args[0].(*pathOperand); ok && len(args) == 1 {
+				return &sizeOperand{path: path.path}, nil
+			}
+		case "if_not_exists":
+			if path, ok := args[0].(*pathOperand); ok && len(args) == 2 {
+				return &ifNotExistsOperand{path: path.path, value: args[1]}, nil
+			}
+		case "list_append":
+			if len(args) == 2 {
+				return &listAppendOperand{first: args[0], second: args[1]}, nil
+			}
+		default:
+			return nil, fmt.Errorf("Invalid expression: Invalid function name; function: %s", token.text)
+		}
+
+		return nil, fmt.Errorf("Invalid expression: Incorrect operands for function %s", token.text)
+	}
+
+	// Finally, we must have a path so parse it and return it
+	path, err := parser.parsePath()
+	if err != nil {
+		return nil, err
+	}
+
+	return &pathOperand{path: path}, nil
+}
+
+// Helper function that parses the parenthesized, comma-separated arguments to a function
+func (parser *exprParser) parseArguments() ([]exprOperand, error) {
+	if err := parser.expect("("); err != nil {
+		return nil, err
+	}
+
+	args := make([]exprOperand, 0)
+	for {
+		arg, err := parser.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, arg)
+		if !parser.accept(",") {
+			break
+		}
+	}
+
+	return args, parser.expect(")")
+}
+
+// Helper function that parses a document path, resolving any attribute name placeholders in it
+func (parser *exprParser) parsePath() (exprPath, error) {
+	path := make(exprPath, 0, 1)
+	for {
+
+		// First, read the name of the path element, resolving it if it's a placeholder
+		token := parser.next()
+		switch token.kind {
+		case tokenIdentifier:
+			path = append(path, pathElement{name: token.text})
+		case tokenName:
+			name, ok := parser.names[token.text]
+			if !ok {
+				return nil, fmt.Errorf("Invalid expression: An expression attribute name used in the document "+
+					"path is not defined; attribute name: %s", token.text)
+			}
+
+			path = append(path, pathElement{name: name})
+		default:
+			parser.pos--
+			return nil, parser.unexpected()
+		}
+
+		// Next, read any list indices associated with the path element
+		for parser.accept("[") {
+			token := parser.next()
+			if token.kind != tokenNumber {
+				parser.pos--
+				return nil, parser.unexpected()
+			}
+
+			index, _ := strconv.Atoi(token.text)
+			path = append(path, pathElement{index: index, isIndex: true})
+			if err := parser.expect("]"); err != nil {
+				return nil, err
+			}
+		}
+
+		// Finally, if the path continues then read the next element; otherwise, return the path
+		if !parser.accept(".") {
+			return path, nil
+		}
+	}
+}
+
+// Helper function that returns the current token without consuming it
+func (parser *exprParser) peek() exprToken {
+	return parser.peekAt(0)
+}
+
+// Helper function that returns the token at an offset from the current token without consuming it
+func (parser *exprParser) peekAt(offset int) exprToken {
+	if parser.pos+offset >= len(parser.tokens) {
+		return exprToken{kind: tokenEOF}
+	}
+
+	return parser.tokens[parser.pos+offset]
+}
+
+// Helper function that returns the current token and moves to the next one
+func (parser *exprParser) next() exprToken {
+	token := parser.peek()
+	parser.pos++
+	return token
+}
+
+// Helper function that consumes the current token if it is the symbol provided
+func (parser *exprParser) accept(symbol string) bool {
+	if token := parser.peek(); token.kind == tokenSymbol && token.text == symbol {
+		parser.pos++
+		return true
+	}
+
+	return false
+}
+
+// Helper function that consumes the current token if it is the keyword provided
+func (parser *exprParser) acceptKeyword(keyword string) bool {
+	if token := parser.peek(); token.kind == tokenIdentifier && strings.EqualFold(token.text, keyword) {
+		parser.pos++
+		return true
+	}
+
+	return false
+}
+
+// Helper function that consumes the current token if it is the symbol provided or returns an error otherwise
+func (parser *exprParser) expect(symbol string) error {
+	if !parser.accept(symbol) {
+		return parser.unexpected()
+	}
+
+	return nil
+}
+
+// Helper function that returns true if all the tokens have been consumed
+func (parser *exprParser) done() bool {
+	return parser.pos >= len(parser.tokens)
+}
+
+// Helper function that creates an error describing the current token as unexpected
+func (parser *exprParser) unexpected() error {
+	if parser.done() {
+		return fmt.Errorf("Invalid expression: Syntax error; token: <EOF>")
+	}
+
+	return fmt.Errorf("Invalid expression: Syntax error; token: %q", parser.peek().text)
+}
+
+// Helper type that describes an operand referring to an attribute on the item
+type pathOperand struct {
+	path exprPath
+}
+
+// Helper function that evaluates the path against the item
+func (op *pathOperand) evaluate(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+	return getPath(item, op.path), nil
+}
+
+// Helper type that describes an operand referring to an expression attribute value
+type valueOperand struct {
+	value types.AttributeValue
+}
+
+// Helper function that returns the value associated with the operand
+func (op *valueOperand) evaluate(map[string]types.AttributeValue) (types.AttributeValue, error) {
+	return op.value, nil
+}
+
+// Helper type that describes an operand returning the size of an attribute on the item
+type sizeOperand struct {
+	path exprPath
+}
+
+// Helper function that evaluates the size of the attribute on the item. If the attribute doesn't exist, or
+// has a type that doesn't have a size, then nil will be returned
+func (op *sizeOperand) evaluate(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+	var size int
+	switch casted := getPath(item, op.path).(type) {
+	case *types.AttributeValueMemberS:
+		size = len(casted.Value)
+	case *types.AttributeValueMemberB:
+		size = len(casted.Value)
+	case *types.AttributeValueMemberSS:
+		size = len(casted.Value)
+	case *types.AttributeValueMemberNS:
+		size = len(casted.Value)
+	case *types.AttributeValueMemberBS:
+		size = len(casted.Value)
+	case *types.AttributeValueMemberL:
+		size = len(casted.Value)
+	case *types.AttributeValueMemberM:
+		size = len(casted.Value)
+	default:
+		return nil, nil
+	}
+
+	return &types.AttributeValueMemberN{Value: strconv.Itoa(size)}, nil
+}
+
+// Helper type that describes an operand returning an attribute on the item or a default value if it doesn't exist
+type ifNotExistsOperand struct {
+	path  exprPath
+	value exprOperand
+}
+
+// Helper function that evaluates the attribute on the item, returning the default value if it doesn't exist
+func (op *ifNotExistsOperand) evaluate(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+	if existing := getPath(item, op.path); existing != nil {
+		return existing, nil
+	}
+
+	return op.value.evaluate(item)
+}
+
+// Helper type that describes an operand that appends one list to another
+type listAppendOperand struct {
+	first  exprOperand
+	second exprOperand
+}
+
+// Helper function that evaluates both lists and returns the result of appending the second to the first
+func (op *listAppendOperand) evaluate(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+	result := make([]types.AttributeValue, 0)
+	for _, operand := range []exprOperand{op.first, op.second} {
+		value, err := operand.evaluate(item)
+		if err != nil {
+			return nil, err
+		}
+
+		list, ok := value.(*types.AttributeValueMemberL)
+		if !ok {
+			return nil, fmt.Errorf("Invalid UpdateExpression: Incorrect operand type for operator or " +
+				"function; operator or function: list_append")
+		}
+
+		result = append(result, list.Value...)
+	}
+
+	return &types.AttributeValueMemberL{Value: result}, nil
+}
+
+// Helper type that describes an operand that adds or subtracts two numbers
+type arithmeticOperand struct {
+	left  exprOperand
+	plus  bool
+	right exprOperand
+}
+
+// Helper function that evaluates both operands and returns their sum or difference
+func (op *arithmeticOperand) evaluate(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+
+	// First, evaluate both operands and convert them to numbers; if either isn't a number then return an error
+	numbers := make([]decimal.Decimal, 2)
+	for i, operand := range []exprOperand{op.left, op.right} {
+		value, err := operand.evaluate(item)
+		if err != nil {
+			return nil, err
+		}
+
+		number, ok := value.(*types.AttributeValueMemberN)
+		if !ok {
+			return nil, fmt.Errorf("Invalid UpdateExpression: Incorrect operand type for operator or " +
+				"function; operator: +/-")
+		}
+
+		if numbers[i], err = decimal.NewFromString(number.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	// Next, add or subtract the numbers and return the result
+	if op.plus {
+		return &types.AttributeValueMemberN{Value: numbers[0].Add(numbers[1]).String()}, nil
+	}
+
+	return &types.AttributeValueMemberN{Value: numbers[0].Sub(numbers[1]).String()}, nil
+}
+
+// Helper type that describes two conditions joined by AND or OR
+type logicalCondition struct {
+	and   bool
+	left  exprCondition
+	right exprCondition
+}
+
+// Helper function that evaluates both conditions and combines their results
+func (cond *logicalCondition) evaluate(item map[string]types.AttributeValue) (bool, error) {
+	left, err := cond.left.evaluate(item)
+	if err != nil || left != cond.and {
+		return left, err
+	}
+
+	return cond.right.evaluate(item)
+}
+
+// Helper type that describes a negated condition
+type notCondition struct {
+	inner exprCondition
+}
+
+// Helper function that evaluates the inner condition and negates the result
+func (cond *notCondition) evaluate(item map[string]types.AttributeValue) (bool, error) {
+	result, err := cond.inner.evaluate(item)
+	return !result, err
+}
+
+// Helper type that describes a comparison between two operands
+type comparisonCondition struct {
+	left       exprOperand
+	comparator string
+	right      exprOperand
+}
+
+// Helper function that evaluates both operands and compares them. If either operand doesn't exist then the
+// comparison will be false, unless the comparator is <>
+func (cond *comparisonCondition) evaluate(item map[string]types.AttributeValue) (bool, error) {
+
+	// First, evaluate both operands; if either doesn't exist then return the result of the comparison
+	left, err := cond.left.evaluate(item)
+	if err != nil {
+		return false, err
+	}
+
+	right, err := cond.right.evaluate(item)
+	if err != nil {
+		return false, err
+	} else if left == nil || right == nil {
+		return cond.comparator == "<>", nil
+	}
+
+	// Next, if we have an equality comparison then compare the values directly
+	switch cond.comparator {
+	case "=":
+		return equalValues(left, right), nil
+	case "<>":
+		return !equalValues(left, right), nil
+	}
+
+	// Finally, we have an ordering comparison so order the values and check the result
+	order, ok := compareValues(left, right)
+	if !ok {
+		return false, nil
+	}
+
+	switch cond.comparator {
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	default:
+		return order >= 0, nil
+	}
+}
+
+// Helper type that describes a condition that an operand is between two bounds, inclusive
+type betweenCondition struct {
+	value exprOperand
+	lower exprOperand
+	upper exprOperand
+}
+
+// Helper function that evaluates the value and its bounds and checks that the value is between them
+func (cond *betweenCondition) evaluate(item map[string]types.AttributeValue) (bool, error) {
+	lower, err := (&comparisonCondition{left: cond.value, comparator: ">=", right: cond.lower}).evaluate(item)
+	if err != nil || !lower {
+		return false, err
+	}
+
+	return (&comparisonCondition{left: cond.value, comparator: "<=", right: cond.upper}).evaluate(item)
+}
+
+// Helper type that describes a condition that an operand is equal to one of a list of options
+type inCondition struct {
+	value   exprOperand
+	options []exprOperand
+}
+
+// Helper function that evaluates the value and each option and checks whether any option is equal to the value
+func (cond *inCondition) evaluate(item map[string]types.AttributeValue) (bool, error) {
+	for _, option := range cond.options {
+		if equal, err := (&comparisonCondition{left: cond.value, comparator: "=",
+			right: option}).evaluate(item); err != nil || equal {
+			return equal, err
+		}
+	}
+
+	return false, nil
+}
+
+// Helper type that describes a condition function
+type functionCondition struct {
+	function string
+	path     exprPath
+	arg      exprOperand
+}
+
+// Helper function that creates a condition function from its name and arguments, verifying the arguments
+func newFunctionCondition(function string, args []exprOperand) (exprCondition, error) {
+
+	// First, ensure that we have the right number of arguments for the function
+	expected := 2
+	if function == "attribute_exists" || function == "attribute_not_exists" {
+		expected = 1
+	}
+
+	if len(args) != expected {
+		return nil, fmt.Errorf("Invalid expression: Incorrect number of operands for operator or function; "+
+			"operator or function: %s, number of operands: %d", function, len(args))
+	}
+
+	// Next, ensure that the first argument is a path
+	path, ok := args[0].(*pathOperand)
+	if !ok {
+		return nil, fmt.Errorf("Invalid expression: Operator or function requires a document path; "+
+			"operator or function: %s", function)
+	}
+
+	// Finally, create the condition from the function and its arguments
+	cond := functionCondition{function: function, path: path.path}
+	if expected == 2 {
+		cond.arg = args[1]
+	}
+
+	return &cond, nil
+}
+
+// Helper function that evaluates the condition function against the item
+func (cond *functionCondition) evaluate(item map[string]types.AttributeValue) (bool, error) {
+
+	// First, get the attribute referred to by the path; if the function only checks for existence then
+	// return the result now
+	attr := getPath(item, cond.path)
+	switch cond.function {
+	case "attribute_exists":
+		return attr != nil, nil
+	case "attribute_not_exists":
+		return attr == nil, nil
+	}
+
+	// Next, evaluate the second argument to the function. If either the attribute or the argument doesn't
+	// exist then the condition is false
+	arg, err := cond.arg.evaluate(item)
+	if err != nil || attr == nil || arg == nil {
+		return false, err
+	}
+
+	// Finally, evaluate the function with the attribute and its argument
+	switch cond.function {
+	case "attribute_type":
+		casted, ok := arg.(*types.AttributeValueMemberS)
+		return ok && casted.Value == typeOf(attr), nil
+	case "begins_with":
+		switch casted := attr.(type) {
+		case *types.AttributeValueMemberS:
+			prefix, ok := arg.(*types.AttributeValueMemberS)
+			return ok && strings.HasPrefix(casted.Value, prefix.Value), nil
+		case *types.AttributeValueMemberB:
+			prefix, ok := arg.(*types.AttributeValueMemberB)
+			return ok && bytes.HasPrefix(casted.Value, prefix.Value), nil
+		}
+
+		return false, nil
+	default:
+		return containsValue(attr, arg), nil
+	}
+}
+
+// Helper function that determines whether an attribute contains a value. Strings and binary values may
+// contain substrings, sets may contain members of the same type and lists may contain any value
+func containsValue(attr types.AttributeValue, value types.AttributeValue) bool {
+	switch casted := attr.(type) {
+	case *types.AttributeValueMemberS:
+		inner, ok := value.(*types.AttributeValueMemberS)
+		return ok && strings.Contains(casted.Value, inner.Value)
+	case *types.AttributeValueMemberB:
+		inner, ok := value.(*types.AttributeValueMemberB)
+		return ok && bytes.Contains(casted.Value, inner.Value)
+	case *types.AttributeValueMemberL:
+		for _, item := range casted.Value {
+			if equalValues(item, value) {
+				return true
+			}
+		}
+	case *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+		for _, item := range setMembers(attr) {
+			if equalValues(item, value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Helper function that retrieves the attribute at a path on an item, returning nil if it doesn't exist
+func getPath(item map[string]types.AttributeValue, path exprPath) types.AttributeValue {
+	var current types.AttributeValue = &types.AttributeValueMemberM{Value: item}
+	for _, element := range path {
+		switch casted := current.(type) {
+		case *types.AttributeValueMemberM:
+			if element.isIndex {
+				return nil
+			}
+
+			current = casted.Value[element.name]
+		case *types.AttributeValueMemberL:
+			if !element.isIndex || element.index >= len(casted.Value) {
+				return nil
+			}
+
+			current = casted.Value[element.index]
+		default:
+			return nil
+		}
+
+		if current == nil {
+			return nil
+		}
+	}
+
+	return current
+}
+
+// Helper function that sets the attribute at a path on an item. The parent of the attribute must already
+// exist. If the attribute is an index into a list that is past the end of the list then the value will be
+// appended to the list
+func setPath(item map[string]types.AttributeValue, path exprPath, value types.AttributeValue) error {
+
+	// First, get the parent of the attribute we want to set; if it doesn't exist then return an error
+	parent := getPath(item, path[:len(path)-1])
+	last := path[len(path)-1]
+
+	// Next, set the value on the parent depending on its type
+	switch casted := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if !last.isIndex {
+			casted.Value[last.name] = value
+			return nil
+		}
+	case *types.AttributeValueMemberL:
+		if last.isIndex && last.index >= len(casted.Value) {
+			casted.Value = append(casted.Value, value)
+			return nil
+		} else if last.isIndex {
+			casted.Value[last.index] = value
+			return nil
+		}
+	}
+
+	// Finally, if we reached this point then the path was invalid so return an error
+	return fmt.Errorf("The document path provided in the update expression is invalid for update")
+}
+
+// Helper function that removes the attribute at a path on an item, if it exists
+func removePath(item map[string]types.AttributeValue, path exprPath) {
+	last := path[len(path)-1]
+	switch casted := getPath(item, path[:len(path)-1]).(type) {
+	case *types.AttributeValueMemberM:
+		delete(casted.Value, last.name)
+	case *types.AttributeValueMemberL:
+		if last.isIndex && last.index < len(casted.Value) {
+			casted.Value = append(casted.Value[:last.index], casted.Value[last.index+1:]...)
+		}
+	}
+}
+
+// Helper function that copies the attribute at a path on an item to the same path on another item, creating
+// any maps or lists on the path as necessary
+func projectPath(dst map[string]types.AttributeValue, src map[string]types.AttributeValue, path exprPath) {
+
+	// First, if the attribute doesn't exist on the source item then there's nothing to copy
+	value := getPath(src, path)
+	if value == nil {
+		return
+	}
+
+	// Next, walk the path on the destination item, creating maps or lists where they don't exist
+	var current types.AttributeValue = &types.AttributeValueMemberM{Value: dst}
+	for i, element := range path[:len(path)-1] {
+		next := getPath(dst, path[:i+1])
+		if next == nil {
+			if path[i+1].isIndex {
+				next = &types.AttributeValueMemberL{}
+			} else {
+				next = &types.AttributeValueMemberM{Value: make(map[string]types.AttributeValue)}
+			}
+
+			switch casted := current.(type) {
+			case *types.AttributeValueMemberM:
+				casted.Value[element.name] = next
+			case *types.AttributeValueMemberL:
+				casted.Value = append(casted.Value, next)
+				next = casted.Value[len(casted.Value)-1]
+			}
+		}
+
+		current = next
+	}
+
+	// Finally, set the value on the destination item
+	last := path[len(path)-1]
+	switch casted := current.(type) {
+	case *types.AttributeValueMemberM:
+		casted.Value[last.name] = cloneValue(value)
+	case *types.AttributeValueMemberL:
+		casted.Value = append(casted.Value, cloneValue(value))
+	}
+}
+
+// Helper function that returns the DynamoDB type code associated with an attribute value
+func typeOf(attr types.AttributeValue) string {
+	switch attr.(type) {
+	case *types.AttributeValueMemberS:
+		return "S"
+	case *types.AttributeValueMemberSS:
+		return "SS"
+	case *types.AttributeValueMemberN:
+		return "N"
+	case *types.AttributeValueMemberNS:
+		return "NS"
+	case *types.AttributeValueMemberB:
+		return "B"
+	case *types.AttributeValueMemberBS:
+		return "BS"
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL"
+	case *types.AttributeValueMemberNULL:
+		return "NULL"
+	case *types.AttributeValueMemberL:
+		return "L"
+	case *types.AttributeValueMemberM:
+		return "M"
+	default:
+		return ""
+	}
+}
+
+// Helper function that determines whether two attribute values are equal. Numbers are compared by value and
+// sets are compared without regard to the order of their members
+func equalValues(left types.AttributeValue, right types.AttributeValue) bool {
+	switch casted := left.(type) {
+	case *types.AttributeValueMemberS:
+		other, ok := right.(*types.AttributeValueMemberS)
+		return ok && casted.Value == other.Value
+	case *types.AttributeValueMemberN, *types.AttributeValueMemberB:
+		order, ok := compareValues(left, right)
+		return ok && order == 0
+	case *types.AttributeValueMemberBOOL:
+		other, ok := right.(*types.AttributeValueMemberBOOL)
+		return ok && casted.Value == other.Value
+	case *types.AttributeValueMemberNULL:
+		_, ok := right.(*types.AttributeValueMemberNULL)
+		return ok
+	case *types.AttributeValueMemberL:
+		other, ok := right.(*types.AttributeValueMemberL)
+		if !ok || len(casted.Value) != len(other.Value) {
+			return false
+		}
+
+		for i, item := range casted.Value {
+			if !equalValues(item, other.Value[i]) {
+				return false
+			}
+		}
+
+		return true
+	case *types.AttributeValueMemberM:
+		other, ok := right.(*types.AttributeValueMemberM)
+		if !ok || len(casted.Value) != len(other.Value) {
+			return false
+		}
+
+		for key, item := range casted.Value {
+			if otherItem, ok := other.Value[key]; !ok || !equalValues(item, otherItem) {
+				return false
+			}
+		}
+
+		return true
+	case *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+		if typeOf(left) != typeOf(right) {
+			return false
+		}
+
+		leftMembers, rightMembers := setMembers(left), setMembers(right)
+		if len(leftMembers) != len(rightMembers) {
+			return false
+		}
+
+		for _, member := range leftMembers {
+			if !containsValue(right, member) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return false
+	}
+}
+
+// Helper function that orders two attribute values. Only numbers, strings and binary values of the same type
+// may be ordered; if the values cannot be ordered then false will be returned
+func compareValues(left types.AttributeValue, right types.AttributeValue) (int, bool) {
+	switch casted := left.(type) {
+	case *types.AttributeValueMemberN:
+		other, ok := right.(*types.AttributeValueMemberN)
+		if !ok {
+			return 0, false
+		}
+
+		leftNumber, err := decimal.NewFromString(casted.Value)
+		if err != nil {
+			return 0, false
+		}
+
+		rightNumber, err := decimal.NewFromString(other.Value)
+		if err != nil {
+			return 0, false
+		}
+
+		return leftNumber.Cmp(rightNumber), true
+	case *types.AttributeValueMemberS:
+		other, ok := right.(*types.AttributeValueMemberS)
+		if !ok {
+			return 0, false
+		}
+
+		return strings.Compare(casted.Value, other.Value), true
+	case *types.AttributeValueMemberB:
+		other, ok := right.(*types.AttributeValueMemberB)
+		if !ok {
+			return 0, false
+		}
+
+		return bytes.Compare(casted.Value, other.Value), true
+	default:
+		return 0, false
+	}
+}
+
+// Helper function that returns the members of a set as individual attribute values
+func setMembers(attr types.AttributeValue) []types.AttributeValue {
+	members := make([]types.AttributeValue, 0)
+	switch casted := attr.(type) {
+	case *types.AttributeValueMemberSS:
+		for _, member := range casted.Value {
+			members = append(members, &types.AttributeValueMemberS{Value: member})
+		}
+	case *types.AttributeValueMemberNS:
+		for _, member := range casted.Value {
+			members = append(members, &types.AttributeValueMemberN{Value: member})
+		}
+	case *types.AttributeValueMemberBS:
+		for _, member := range casted.Value {
+			members = append(members, &types.AttributeValueMemberB{Value: member})
+		}
+	}
+
+	return members
+}
+
+// Helper function that creates a deep copy of an item so that it can't be modified by the caller
+func cloneItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if item == nil {
+		return nil
+	}
+
+	clone := make(map[string]types.AttributeValue, len(item))
+	for key, value := range item {
+		clone[key] = cloneValue(value)
+	}
+
+	return clone
+}
+
+// Helper function that creates a deep copy of an attribute value
+func cloneValue(attr types.AttributeValue) types.AttributeValue {
+	switch casted := attr.(type) {
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: casted.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: casted.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: append([]byte{}, casted.Value...)}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: casted.Value}
+	case *types.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: casted.Value}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: append([]string{}, casted.Value...)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: append([]string{}, casted.Value...)}
+	case *types.AttributeValueMemberBS:
+		clone := make([][]byte, len(casted.Value))
+		for i, member := range casted.Value {
+			clone[i] = append([]byte{}, member...)
+		}
+
+		return &types.AttributeValueMemberBS{Value: clone}
+	case *types.AttributeValueMemberL:
+		clone := make([]types.AttributeValue, len(casted.Value))
+		for i, item := range casted.Value {
+			clone[i] = cloneValue(item)
+		}
+
+		return &types.AttributeValueMemberL{Value: clone}
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: cloneItem(casted.Value)}
+	default:
+		return attr
+	}
+}
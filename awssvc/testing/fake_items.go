@@ -0,0 +1,1175 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/shopspring/decimal"
+)
+
+// The maximum number of items that may be included in a single batch or transaction request
+const (
+	maxBatchGetItems   = 100
+	maxBatchWriteItems = 25
+	maxTransactItems   = 100
+)
+
+// Helper type that describes a write that has been validated against a table but not yet committed to it
+type fakeWrite struct {
+	table   *fakeTable
+	key     string
+	old     map[string]types.AttributeValue
+	new     map[string]types.AttributeValue
+	updated []string
+}
+
+// Helper type describing an error that occurs when the condition on a write is not satisfied. This error
+// contains the item as it was when the condition was evaluated
+type conditionFailure struct {
+	item map[string]types.AttributeValue
+}
+
+// Error returns the message associated with the failed condition
+func (failure *conditionFailure) Error() string {
+	return "The conditional request failed"
+}
+
+// Helper type that associates an item with its position in a table or index so that items can be ordered
+type fakePosition struct {
+	item map[string]types.AttributeValue
+	sort types.AttributeValue
+	key  string
+}
+
+// GetItem returns the item with the key provided, or no item if it doesn't exist
+func (fake *FakeDynamoDB) GetItem(ctx context.Context, params *dynamodb.GetItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	const operation = "GetItem"
+
+	// First, check if we should fail the operation and get the table; if either fails then return an error
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	table, err := fake.table(operation, params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, attempt to read the item from the table and apply the projection to it
+	item, err := table.read(params.Key, params.ProjectionExpression, params.ExpressionAttributeNames)
+	if err != nil {
+		return nil, operationError(operation, err)
+	}
+
+	// Finally, return the item with the capacity that was consumed
+	return &dynamodb.GetItemOutput{
+		Item:             item,
+		ConsumedCapacity: consumedCapacity(params.TableName, 1, params.ReturnConsumedCapacity),
+	}, nil
+}
+
+// PutItem writes an item to the table, replacing any item with the same key, if the condition on the input
+// is satisfied
+func (fake *FakeDynamoDB) PutItem(ctx context.Context, params *dynamodb.PutItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	const operation = "PutItem"
+
+	// First, check if we should fail the operation and get the table; if either fails then return an error
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	table, err := fake.table(operation, params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, validate the write against the table; if this fails then return an error
+	write, err := table.preparePut(params.Item, params.ConditionExpression,
+		params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, writeError(operation, err)
+	}
+
+	// Finally, commit the write and return the old item if it was requested
+	write.commit()
+	output := dynamodb.PutItemOutput{
+		ConsumedCapacity: consumedCapacity(params.TableName, 1, params.ReturnConsumedCapacity),
+	}
+
+	if params.ReturnValues == types.ReturnValueAllOld {
+		output.Attributes = cloneItem(write.old)
+	}
+
+	return &output, nil
+}
+
+// DeleteItem removes the item with the key provided from the table, if the condition on the input is satisfied
+func (fake *FakeDynamoDB) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	const operation = "DeleteItem"
+
+	// First, check if we should fail the operation and get the table; if either fails then return an error
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	table, err := fake.table(operation, params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, validate the delete against the table; if this fails then return an error
+	write, err := table.prepareDelete(params.Key, params.ConditionExpression,
+		params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, writeError(operation, err)
+	}
+
+	// Finally, commit the delete and return the old item if it was requested
+	write.commit()
+	output := dynamodb.DeleteItemOutput{
+		ConsumedCapacity: consumedCapacity(params.TableName, 1, params.ReturnConsumedCapacity),
+	}
+
+	if params.ReturnValues == types.ReturnValueAllOld {
+		output.Attributes = cloneItem(write.old)
+	}
+
+	return &output, nil
+}
+
+// UpdateItem modifies the item with the key provided according to the update expression on the input, creating
+// the item if it doesn't exist, if the condition on the input is satisfied
+func (fake *FakeDynamoDB) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	const operation = "UpdateItem"
+
+	// First, check if we should fail the operation and get the table; if either fails then return an error
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	table, err := fake.table(operation, params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, validate the update against the table; if this fails then return an error
+	write, err := table.prepareUpdate(params.Key, params.UpdateExpression, params.ConditionExpression,
+		params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, writeError(operation, err)
+	}
+
+	// Now, commit the update to the table
+	write.commit()
+	output := dynamodb.UpdateItemOutput{
+		ConsumedCapacity: consumedCapacity(params.TableName, 1, params.ReturnConsumedCapacity),
+	}
+
+	// Finally, return the attributes that were requested
+	switch params.ReturnValues {
+	case types.ReturnValueAllOld:
+		output.Attributes = cloneItem(write.old)
+	case types.ReturnValueAllNew:
+		output.Attributes = cloneItem(write.new)
+	case types.ReturnValueUpdatedOld:
+		output.Attributes = selectNames(write.old, write.updated)
+	case types.ReturnValueUpdatedNew:
+		output.Attributes = selectNames(write.new, write.updated)
+	}
+
+	return &output, nil
+}
+
+// Query returns the items in a table or index that match the key condition expression on the input, ordered by
+// their sort key. The filter expression, projection expression, limit, exclusive start key and select settings
+// on the input are supported
+func (fake *FakeDynamoDB) Query(ctx context.Context, params *dynamodb.QueryInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	const operation = "Query"
+
+	// First, check if we should fail the operation and get the table and index; if any of these fail then
+	// return an error
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	table, err := fake.table(operation, params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := table.index(params.IndexName)
+	if err != nil {
+		return nil, operationError(operation, err)
+	}
+
+	// Next, parse the key condition and ensure that it restricts the query to a single partition
+	keyCondition, err := parseCondition(params.KeyConditionExpression,
+		params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, operationError(operation, validationError("Invalid KeyConditionExpression: %v", err))
+	} else if keyCondition == nil {
+		return nil, operationError(operation, validationError("Either the KeyConditions or "+
+			"KeyConditionExpression parameter must be specified in the request."))
+	} else if !hasPartitionEquality(keyCondition, index.partitionKey) {
+		return nil, operationError(operation, validationError("Query condition missed key schema "+
+			"element: %s", index.partitionKey))
+	}
+
+	// Now, collect all the items in the index that match the key condition, in order
+	positions, err := table.positions(index, params.ExclusiveStartKey, !aws.ToBool(params.ScanIndexForward) &&
+		params.ScanIndexForward != nil, func(item map[string]types.AttributeValue) (bool, error) {
+		return keyCondition.evaluate(item)
+	})
+
+	if err != nil {
+		return nil, operationError(operation, err)
+	}
+
+	// Finally, page the items, apply the filter and projection to them and return the results
+	page, err := table.page(positions, index, params.Limit, params.FilterExpression, params.ProjectionExpression,
+		params.Select, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, operationError(operation, err)
+	}
+
+	return &dynamodb.QueryOutput{
+		Items:            page.items,
+		Count:            page.count,
+		ScannedCount:     page.scanned,
+		LastEvaluatedKey: page.lastKey,
+		ConsumedCapacity: consumedCapacity(params.TableName, float64(page.scanned), params.ReturnConsumedCapacity),
+	}, nil
+}
+
+// Scan returns all the items in a table or index. The filter expression, projection expression, limit,
+// exclusive start key, select and segment settings on the input are supported
+func (fake *FakeDynamoDB) Scan(ctx context.Context, params *dynamodb.ScanInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	const operation = "Scan"
+
+	// First, check if we should fail the operation and get the table and index; if any of these fail then
+	// return an error
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	table, err := fake.table(operation, params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := table.index(params.IndexName)
+	if err != nil {
+		return nil, operationError(operation, err)
+	}
+
+	// Next, verify the segment settings on the input
+	segment, total := aws.ToInt32(params.Segment), aws.ToInt32(params.TotalSegments)
+	if (params.Segment == nil) != (params.TotalSegments == nil) || (total > 0 && (segment < 0 || segment >= total)) {
+		return nil, operationError(operation, validationError("The Segment parameter must be less than "+
+			"TotalSegments and both must be provided for a parallel scan"))
+	}
+
+	// Now, collect all the items in the index that belong to the segment, ordered by their primary key
+	positions, err := table.positions(&fakeIndex{name: index.name, partitionKey: index.partitionKey,
+		projection: index.projection}, params.ExclusiveStartKey, false,
+		func(item map[string]types.AttributeValue) (bool, error) {
+			if total == 0 {
+				return true, nil
+			}
+
+			key, err := table.key(item)
+			if err != nil {
+				return false, err
+			}
+
+			hash := fnv.New32a()
+			hash.Write([]byte(key))
+			return int32(hash.Sum32()%uint32(total)) == segment, nil
+		})
+
+	if err != nil {
+		return nil, operationError(operation, err)
+	}
+
+	// Finally, page the items, apply the filter and projection to them and return the results
+	page, err := table.page(positions, index, params.Limit, params.FilterExpression, params.ProjectionExpression,
+		params.Select, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, operationError(operation, err)
+	}
+
+	return &dynamodb.ScanOutput{
+		Items:            page.items,
+		Count:            page.count,
+		ScannedCount:     page.scanned,
+		LastEvaluatedKey: page.lastKey,
+		ConsumedCapacity: consumedCapacity(params.TableName, float64(page.scanned), params.ReturnConsumedCapacity),
+	}, nil
+}
+
+// BatchGetItem reads up to 100 items from one or more tables. Items that do not exist will not be returned.
+// If UnprocessNext was called then only the first key will be read and the rest will be returned as unprocessed
+func (fake *FakeDynamoDB) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	const operation = "BatchGetItem"
+
+	// First, check if we should fail the operation; if we should then return an error
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if err := fake.intercept(operation); err != nil {
+		return nil, err
+	}
+
+	// Next, ensure that all the tables exist and that we don't have too many keys
+	tables, count := sortedKeys(params.RequestItems), 0
+	for _, name := range tables {
+		if _, err := fake.lookup(operation, aws.String(name)); err != nil {
+			return nil, err
+		}
+
+		count += len(params.RequestItems[name].Keys)
+	}
+
+	if count > maxBatchGetItems || count == 0 {
+		return nil, operationError(operation, validationError("Too many items requested for the "+
+			"BatchGetItem call"))
+	}
+
+	// Now, determine how many keys we should process before returning the rest as unprocessed
+	limit := fake.unprocessedLimit(count)
+
+	// Finally, read each of the keys from its table, saving any that we don't process
+	output := dynamodb.BatchGetItemOutput{
+		Responses:       make(map[string][]map[string]types.AttributeValue),
+		UnprocessedKeys: make(map[string]types.KeysAndAttributes),
+	}
+
+	processed := 0
+	for _, name := range tables {
+		request, table := params.RequestItems[name], fake.tables[name]
+		for _, key := range request.Keys {
+
+			// If we've reached our limit then save the key as unprocessed and continue
+			if processed >= limit {
+				unprocessed := output.UnprocessedKeys[name]
+				if unprocessed.Keys == nil {
+					unprocessed = request
+					unprocessed.Keys = make([]map[string]types.AttributeValue, 0)
+				}
+
+				unprocessed.Keys = append(unprocessed.Keys, key)
+				output.UnprocessedKeys[name] = unprocessed
+				continue
+			}
+
+			// Otherwise, read the item and add it to the responses if it exists
+			processed++
+			item, err := table.read(key, request.ProjectionExpression, request.ExpressionAttributeNames)
+			if err != nil {
+				return nil, operationError(operation, err)
+			} else if item != nil {
+				output.Responses[name] = append(output.Responses[name], item)
+			}
+		}
+	}
+
+	return &output, nil
+}
+
+// BatchWriteItem writes up to 25 put or delete requests to one or more tables. If UnprocessNext was called then
+// only the first request will be written and the rest will be returned as unprocessed
+func (fake *FakeDynamoDB) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	const operation = "BatchWriteItem"
+
+	// First, check if we should fail the operation; if we should then return an error
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if err := fake.intercept(operation); err != nil {
+		return nil, err
+	}
+
+	// Next, validate every request against its table before we write anything. If any request is invalid,
+	// or if two requests refer to the same item, then return an error
+	tables, count := sortedKeys(params.RequestItems), 0
+	writes := make([]*fakeWrite, 0)
+	requests := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, name := range tables {
+		table, err := fake.lookup(operation, aws.String(name))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, request := range params.RequestItems[name] {
+			var write *fakeWrite
+			switch {
+			case request.PutRequest != nil:
+				write, err = table.preparePut(request.PutRequest.Item, nil, nil, nil)
+			case request.DeleteRequest != nil:
+				write, err = table.prepareDelete(request.DeleteRequest.Key, nil, nil, nil)
+			default:
+				err = validationError("Supplied AttributeValue has more than one datatypes set")
+			}
+
+			if err != nil {
+				return nil, operationError(operation, err)
+			} else if _, ok := seen[name+"\x00"+write.key]; ok {
+				return nil, operationError(operation, validationError("Provided list of item keys contains duplicates"))
+			}
+
+			seen[name+"\x00"+write.key] = struct{}{}
+			writes = append(writes, write)
+			requests = append(requests, name)
+			count++
+		}
+	}
+
+	if count > maxBatchWriteItems || count == 0 {
+		return nil, operationError(operation, validationError("Too many items requested for the "+
+			"BatchWriteItem call"))
+	}
+
+	// Finally, commit each of the writes up to our limit and return the rest as unprocessed
+	limit := fake.unprocessedLimit(count)
+	output := dynamodb.BatchWriteItemOutput{UnprocessedItems: make(map[string][]types.WriteRequest)}
+	for i, write := range writes {
+		if i < limit {
+			write.commit()
+		}
+	}
+
+	offsets, written := make(map[string]int), make(map[string]int)
+	for i, name := range requests {
+		if i >= limit {
+			output.UnprocessedItems[name] = append(output.UnprocessedItems[name], params.RequestItems[name][offsets[name]])
+		} else {
+			written[name]++
+		}
+
+		offsets[name]++
+	}
+
+	// Now that we know how many items were written to each table, report the capacity they consumed
+	for _, name := range tables {
+		if capacity := consumedCapacity(aws.String(name), float64(written[name]),
+			params.ReturnConsumedCapacity); capacity != nil {
+			output.ConsumedCapacity = append(output.ConsumedCapacity, *capacity)
+		}
+	}
+
+	return &output, nil
+}
+
+// TransactGetItems reads up to 100 items from one or more tables atomically. The responses will be in the same
+// order as the requests and will be empty for items that do not exist
+func (fake *FakeDynamoDB) TransactGetItems(ctx context.Context, params *dynamodb.TransactGetItemsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.TransactGetItemsOutput, error) {
+	const operation = "TransactGetItems"
+
+	// First, check if we should fail the operation; if we should then return an error
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if err := fake.intercept(operation); err != nil {
+		return nil, err
+	} else if len(params.TransactItems) > maxTransactItems || len(params.TransactItems) == 0 {
+		return nil, operationError(operation, validationError("Member must have length less than or equal to 100"))
+	}
+
+	// Next, read each of the items from its table
+	output := dynamodb.TransactGetItemsOutput{Responses: make([]types.ItemResponse, len(params.TransactItems))}
+	for i, request := range params.TransactItems {
+		if request.Get == nil {
+			return nil, operationError(operation, validationError("TransactItems can only contain Get operations"))
+		}
+
+		table, err := fake.lookup(operation, request.Get.TableName)
+		if err != nil {
+			return nil, err
+		}
+
+		item, err := table.read(request.Get.Key, request.Get.ProjectionExpression,
+			request.Get.ExpressionAttributeNames)
+		if err != nil {
+			return nil, operationError(operation, err)
+		}
+
+		output.Responses[i] = types.ItemResponse{Item: item}
+	}
+
+	return &output, nil
+}
+
+// TransactWriteItems writes up to 100 condition checks, puts, updates or deletes to one or more tables
+// atomically. If any condition fails then none of the writes will be committed and a TransactionCanceledException
+// will be returned with the reason associated with each request. Requests with a client request token that has
+// already been used will succeed without writing anything
+func (fake *FakeDynamoDB) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	const operation = "TransactWriteItems"
+
+	// First, check if we should fail the operation; if we should then return an error
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if err := fake.intercept(operation); err != nil {
+		return nil, err
+	} else if len(params.TransactItems) > maxTransactItems || len(params.TransactItems) == 0 {
+		return nil, operationError(operation, validationError("Member must have length less than or equal to 100"))
+	}
+
+	// Next, if we've already seen the client request token then the transaction was already committed
+	if token := aws.ToString(params.ClientRequestToken); token != "" {
+		if _, ok := fake.tokens[token]; ok {
+			return &dynamodb.TransactWriteItemsOutput{}, nil
+		}
+	}
+
+	// Now, validate each of the requests against its table, collecting the reason associated with each
+	writes := make([]*fakeWrite, len(params.TransactItems))
+	reasons := make([]types.CancellationReason, len(params.TransactItems))
+	seen := make(map[string]struct{})
+	cancelled := false
+	for i, request := range params.TransactItems {
+
+		// First, validate the request against its table to get the write we would make
+		var write *fakeWrite
+		var returnOld types.ReturnValuesOnConditionCheckFailure
+		var err error
+		switch {
+		case request.ConditionCheck != nil:
+			check := request.ConditionCheck
+			returnOld = check.ReturnValuesOnConditionCheckFailure
+			write, err = fake.prepareTransact(operation, check.TableName, func(table *fakeTable) (*fakeWrite, error) {
+				return table.prepareCheck(check.Key, check.ConditionExpression,
+					check.ExpressionAttributeNames, check.ExpressionAttributeValues)
+			})
+		case request.Put != nil:
+			put := request.Put
+			returnOld = put.ReturnValuesOnConditionCheckFailure
+			write, err = fake.prepareTransact(operation, put.TableName, func(table *fakeTable) (*fakeWrite, error) {
+				return table.preparePut(put.Item, put.ConditionExpression,
+					put.ExpressionAttributeNames, put.ExpressionAttributeValues)
+			})
+		case request.Delete != nil:
+			del := request.Delete
+			returnOld = del.ReturnValuesOnConditionCheckFailure
+			write, err = fake.prepareTransact(operation, del.TableName, func(table *fakeTable) (*fakeWrite, error) {
+				return table.prepareDelete(del.Key, del.ConditionExpression,
+					del.ExpressionAttributeNames, del.ExpressionAttributeValues)
+			})
+		case request.Update != nil:
+			update := request.Update
+			returnOld = update.ReturnValuesOnConditionCheckFailure
+			write, err = fake.prepareTransact(operation, update.TableName, func(table *fakeTable) (*fakeWrite, error) {
+				return table.prepareUpdate(update.Key, update.UpdateExpression, update.ConditionExpression,
+					update.ExpressionAttributeNames, update.ExpressionAttributeValues)
+			})
+		default:
+			err = operationError(operation, validationError("TransactItems must contain exactly one operation"))
+		}
+
+		// Next, if the condition failed then record the reason; if any other error occurred then return it
+		reasons[i].Code = aws.String("None")
+		if failure, ok := err.(*conditionFailure); ok {
+			cancelled = true
+			reasons[i].Code = aws.String("ConditionalCheckFailed")
+			reasons[i].Message = aws.String(failure.Error())
+			if returnOld == types.ReturnValuesOnConditionCheckFailureAllOld {
+				reasons[i].Item = cloneItem(failure.item)
+			}
+
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		// Finally, ensure that no other request in the transaction refers to the same item
+		id := aws.ToString(write.table.description.TableName) + "\x00" + write.key
+		if _, ok := seen[id]; ok {
+			return nil, operationError(operation, validationError("Transaction request cannot include "+
+				"multiple operations on one item"))
+		}
+
+		seen[id] = struct{}{}
+		writes[i] = write
+	}
+
+	// If any of the conditions failed then cancel the transaction and return the reasons
+	if cancelled {
+		codes := make([]string, len(reasons))
+		for i, reason := range reasons {
+			codes[i] = *reason.Code
+		}
+
+		return nil, operationError(operation, &types.TransactionCanceledException{
+			Message: aws.String(fmt.Sprintf("Transaction cancelled, please refer cancellation reasons for "+
+				"specific reasons [%s]", strings.Join(codes, ", "))),
+			CancellationReasons: reasons,
+		})
+	}
+
+	// Otherwise, commit all the writes and save the client request token
+	for _, write := range writes {
+		write.commit()
+	}
+
+	if token := aws.ToString(params.ClientRequestToken); token != "" {
+		fake.tokens[token] = struct{}{}
+	}
+
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+// Helper function that retrieves the table associated with a transaction request and validates the request
+// against it. Condition failures will be returned as-is and all other errors will be wrapped
+func (fake *FakeDynamoDB) prepareTransact(operation string, tableName *string,
+	prepare func(*fakeTable) (*fakeWrite, error)) (*fakeWrite, error) {
+	table, err := fake.lookup(operation, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	write, err := prepare(table)
+	if _, ok := err.(*conditionFailure); err != nil && !ok {
+		return nil, operationError(operation, err)
+	}
+
+	return write, err
+}
+
+// Helper function that determines how many requests in a batch should be processed. If we've been asked to
+// leave requests unprocessed then only the first will be processed. This function must be called while the
+// lock is held
+func (fake *FakeDynamoDB) unprocessedLimit(count int) int {
+	if fake.unprocessed > 0 {
+		fake.unprocessed--
+		return 1
+	}
+
+	return count
+}
+
+// Helper function that reads an item from the table and applies a projection expression to it. If the item
+// doesn't exist then nil will be returned
+func (table *fakeTable) read(key map[string]types.AttributeValue, projection *string,
+	names map[string]string) (map[string]types.AttributeValue, error) {
+
+	// First, convert the key to its encoded form; if this fails then return an error
+	encoded, err := table.exactKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, parse the projection expression; if this fails then return an error
+	paths, err := parseProjection(projection, names)
+	if err != nil {
+		return nil, validationError("Invalid ProjectionExpression: %v", err)
+	}
+
+	// Finally, get the item and apply the projection to it
+	item, ok := table.items[encoded]
+	if !ok {
+		return nil, nil
+	}
+
+	return selectPaths(item, paths), nil
+}
+
+// Helper function that validates a put against the table and returns the write that would be made
+func (table *fakeTable) preparePut(item map[string]types.AttributeValue, condition *string,
+	names map[string]string, values map[string]types.AttributeValue) (*fakeWrite, error) {
+
+	// First, get the key of the item; if this fails then return an error
+	key, err := table.key(item)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, check the condition against the existing item; if this fails then return an error
+	existing := table.items[key]
+	if err := checkCondition(existing, condition, names, values); err != nil {
+		return nil, err
+	}
+
+	return &fakeWrite{table: table, key: key, old: existing, new: cloneItem(item)}, nil
+}
+
+// Helper function that validates a delete against the table and returns the write that would be made
+func (table *fakeTable) prepareDelete(key map[string]types.AttributeValue, condition *string,
+	names map[string]string, values map[string]types.AttributeValue) (*fakeWrite, error) {
+
+	// First, get the encoded form of the key; if this fails then return an error
+	encoded, err := table.exactKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, check the condition against the existing item; if this fails then return an error
+	existing := table.items[encoded]
+	if err := checkCondition(existing, condition, names, values); err != nil {
+		return nil, err
+	}
+
+	return &fakeWrite{table: table, key: encoded, old: existing}, nil
+}
+
+// Helper function that validates a condition check against the table and returns a write that will not
+// modify the item
+func (table *fakeTable) prepareCheck(key map[string]types.AttributeValue, condition *string,
+	names map[string]string, values map[string]types.AttributeValue) (*fakeWrite, error) {
+	if condition == nil {
+		return nil, validationError("The ConditionExpression is required for a ConditionCheck")
+	}
+
+	write, err := table.prepareDelete(key, condition, names, values)
+	if err != nil {
+		return nil, err
+	}
+
+	write.new = write.old
+	return write, nil
+}
+
+// Helper function that validates an update against the table and returns the write that would be made
+func (table *fakeTable) prepareUpdate(key map[string]types.AttributeValue, update *string, condition *string,
+	names map[string]string, values map[string]types.AttributeValue) (*fakeWrite, error) {
+
+	// First, get the encoded form of the key; if this fails then return an error
+	encoded, err := table.exactKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, check the condition against the existing item; if this fails then return an error
+	existing := table.items[encoded]
+	if err := checkCondition(existing, condition, names, values); err != nil {
+		return nil, err
+	}
+
+	// Now, parse the update expression and ensure that it doesn't modify any of the key attributes
+	actions, err := parseUpdate(update, names, values)
+	if err != nil {
+		return nil, validationError("Invalid UpdateExpression: %v", err)
+	}
+
+	for _, action := range actions {
+		if _, ok := key[action.path[0].name]; ok {
+			return nil, validationError("One or more parameter values were invalid: Cannot update attribute "+
+				"%s. This attribute is part of the key", action.path[0].name)
+		}
+	}
+
+	// Finally, apply the update actions to a copy of the existing item, or the key if the item doesn't exist
+	base := existing
+	if base == nil {
+		base = key
+	}
+
+	updated, names2, err := applyUpdate(base, actions)
+	if err != nil {
+		return nil, validationError("%v", err)
+	}
+
+	return &fakeWrite{table: table, key: encoded, old: existing, new: updated, updated: names2}, nil
+}
+
+// Helper function that commits a write to its table
+func (write *fakeWrite) commit() {
+	if write.new == nil {
+		delete(write.table.items, write.key)
+	} else {
+		write.table.items[write.key] = write.new
+	}
+}
+
+// Helper function that creates the encoded form of a key, verifying that the key contains only the key
+// attributes of the table
+func (table *fakeTable) exactKey(key map[string]types.AttributeValue) (string, error) {
+	partitionKey, sortKey := keyNames(table.description.KeySchema)
+	expected := 1
+	if sortKey != "" {
+		expected = 2
+	}
+
+	if _, ok := key[partitionKey]; !ok || len(key) != expected {
+		return "", validationError("The provided key element does not match the schema")
+	}
+
+	return table.key(key)
+}
+
+// Helper function that collects the items in the index that satisfy the condition, ordered by their position
+// in the index, starting after the exclusive start key. Items that don't have the index's key attributes will
+// be ignored
+func (table *fakeTable) positions(index *fakeIndex, startKey map[string]types.AttributeValue, reverse bool,
+	condition func(map[string]types.AttributeValue) (bool, error)) ([]*fakePosition, error) {
+
+	// First, collect all the items that are in the index and satisfy the condition
+	positions := make([]*fakePosition, 0)
+	for key, item := range table.items {
+		if _, ok := item[index.partitionKey]; !ok {
+			continue
+		} else if _, ok := item[index.sortKey]; index.sortKey != "" && !ok {
+			continue
+		}
+
+		if ok, err := condition(item); err != nil {
+			return nil, validationError("%v", err)
+		} else if ok {
+			positions = append(positions, &fakePosition{item: item, sort: item[index.sortKey], key: key})
+		}
+	}
+
+	// Next, sort the items by their position in the index
+	sort.Slice(positions, func(i, j int) bool {
+		if reverse {
+			return positions[i].compare(positions[j]) > 0
+		}
+
+		return positions[i].compare(positions[j]) < 0
+	})
+
+	// Finally, if we have an exclusive start key then remove all the items up to and including it
+	if len(startKey) > 0 {
+		key, err := table.key(startKey)
+		if err != nil {
+			return nil, validationError("The provided starting key is invalid: %v", err)
+		}
+
+		start := &fakePosition{sort: startKey[index.sortKey], key: key}
+		offset := sort.Search(len(positions), func(i int) bool {
+			if reverse {
+				return positions[i].compare(start) < 0
+			}
+
+			return positions[i].compare(start) > 0
+		})
+
+		positions = positions[offset:]
+	}
+
+	return positions, nil
+}
+
+// Helper type that contains a page of results from a query or scan
+type fakePage struct {
+	items   []map[string]types.AttributeValue
+	count   int32
+	scanned int32
+	lastKey map[string]types.AttributeValue
+}
+
+// Helper function that creates a page of results from a list of ordered items by applying the limit, filter
+// expression, projection expression and select settings provided
+func (table *fakeTable) page(positions []*fakePosition, index *fakeIndex, limit *int32, filter *string,
+	projection *string, selection types.Select, names map[string]string,
+	values map[string]types.AttributeValue) (*fakePage, error) {
+
+	// First, parse the filter and projection expressions; if either fails then return an error
+	condition, err := parseCondition(filter, names, values)
+	if err != nil {
+		return nil, validationError("Invalid FilterExpression: %v", err)
+	}
+
+	paths, err := parseProjection(projection, names)
+	if err != nil {
+		return nil, validationError("Invalid ProjectionExpression: %v", err)
+	}
+
+	// Next, if we have a limit then restrict the items we evaluate to that limit and save the key of the
+	// last item we evaluated so the caller can resume from it
+	page := fakePage{items: make([]map[string]types.AttributeValue, 0)}
+	if max := int(aws.ToInt32(limit)); max > 0 && len(positions) >= max {
+		positions = positions[:max]
+		page.lastKey = table.keyAttributes(positions[max-1].item, index)
+	}
+
+	// Finally, evaluate the filter against each item and project the items that satisfy it
+	for _, position := range positions {
+		page.scanned++
+		if condition != nil {
+			if ok, err := condition.evaluate(position.item); err != nil {
+				return nil, validationError("Invalid FilterExpression: %v", err)
+			} else if !ok {
+				continue
+			}
+		}
+
+		page.count++
+		if selection != types.SelectCount {
+			page.items = append(page.items, selectPaths(table.project(position.item, index), paths))
+		}
+	}
+
+	if selection == types.SelectCount {
+		page.items = nil
+	}
+
+	return &page, nil
+}
+
+// Helper function that orders two positions in an index, first by their sort key and then by their primary key
+func (position *fakePosition) compare(other *fakePosition) int {
+	if position.sort != nil && other.sort != nil {
+		if order, ok := compareValues(position.sort, other.sort); ok && order != 0 {
+			return order
+		}
+	}
+
+	return strings.Compare(position.key, other.key)
+}
+
+// Helper function that checks a condition expression against an item. If the condition is not satisfied then
+// a conditionFailure will be returned
+func checkCondition(item map[string]types.AttributeValue, expression *string, names map[string]string,
+	values map[string]types.AttributeValue) error {
+
+	// First, parse the condition; if we have none then there's nothing to check
+	condition, err := parseCondition(expression, names, values)
+	if err != nil {
+		return validationError("Invalid ConditionExpression: %v", err)
+	} else if condition == nil {
+		return nil
+	}
+
+	// Next, evaluate the condition against the item; if it isn't satisfied then return a failure
+	if item == nil {
+		item = make(map[string]types.AttributeValue)
+	}
+
+	if ok, err := condition.evaluate(item); err != nil {
+		return validationError("Invalid ConditionExpression: %v", err)
+	} else if !ok {
+		return &conditionFailure{item: item}
+	}
+
+	return nil
+}
+
+// Helper function that applies a list of update actions to a copy of an item. All values are evaluated against
+// the original item before any action is applied. The updated item will be returned along with the names of
+// the top-level attributes that were updated
+func applyUpdate(item map[string]types.AttributeValue, actions []*updateAction) (map[string]types.AttributeValue,
+	[]string, error) {
+
+	// First, evaluate the values of all the actions against the original item
+	values := make([]types.AttributeValue, len(actions))
+	for i, action := range actions {
+		if action.value == nil {
+			continue
+		}
+
+		value, err := action.value.evaluate(item)
+		if err != nil {
+			return nil, nil, err
+		} else if value == nil {
+			return nil, nil, fmt.Errorf("The provided expression refers to an attribute that does not exist in the item")
+		}
+
+		values[i] = value
+	}
+
+	// Next, apply each action to a copy of the item, recording the top-level attributes that were updated
+	updated := cloneItem(item)
+	names := make([]string, 0, len(actions))
+	for i, action := range actions {
+		names = append(names, action.path[0].name)
+		existing := getPath(updated, action.path)
+		switch action.keyword {
+		case "SET":
+			if err := setPath(updated, action.path, cloneValue(values[i])); err != nil {
+				return nil, nil, err
+			}
+		case "REMOVE":
+			removePath(updated, action.path)
+		case "ADD":
+			result, err := addValues(existing, values[i])
+			if err != nil {
+				return nil, nil, err
+			} else if err := setPath(updated, action.path, result); err != nil {
+				return nil, nil, err
+			}
+		case "DELETE":
+			if existing == nil {
+				continue
+			} else if typeOf(existing) != typeOf(values[i]) || len(setMembers(existing)) == 0 {
+				return nil, nil, fmt.Errorf("Invalid UpdateExpression: Incorrect operand type for operator " +
+					"or function; operator: DELETE")
+			}
+
+			remaining := make([]types.AttributeValue, 0)
+			for _, member := range setMembers(existing) {
+				if !containsValue(values[i], member) {
+					remaining = append(remaining, member)
+				}
+			}
+
+			if len(remaining) == 0 {
+				removePath(updated, action.path)
+			} else if err := setPath(updated, action.path, createSet(typeOf(existing), remaining)); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return updated, names, nil
+}
+
+// Helper function that adds a value to an existing attribute for an ADD action. Numbers will be summed and sets
+// will be combined. If the attribute doesn't exist then the value will be returned
+func addValues(existing types.AttributeValue, value types.AttributeValue) (types.AttributeValue, error) {
+
+	// First, if the attribute doesn't exist then the result is the value, if it can be added
+	invalid := fmt.Errorf("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: ADD")
+	if existing == nil {
+		switch value.(type) {
+		case *types.AttributeValueMemberN, *types.AttributeValueMemberSS,
+			*types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+			return cloneValue(value), nil
+		default:
+			return nil, invalid
+		}
+	}
+
+	// Next, ensure that the attribute and the value have the same type
+	if typeOf(existing) != typeOf(value) {
+		return nil, invalid
+	}
+
+	// Finally, sum the numbers or combine the sets
+	switch casted := existing.(type) {
+	case *types.AttributeValueMemberN:
+		left, err := decimal.NewFromString(casted.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := decimal.NewFromString(value.(*types.AttributeValueMemberN).Value)
+		if err != nil {
+			return nil, err
+		}
+
+		return &types.AttributeValueMemberN{Value: left.Add(right).String()}, nil
+	case *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+		members := setMembers(existing)
+		for _, member := range setMembers(value) {
+			if !containsValue(existing, member) {
+				members = append(members, member)
+			}
+		}
+
+		return createSet(typeOf(existing), members), nil
+	default:
+		return nil, invalid
+	}
+}
+
+// Helper function that creates a set of the type provided from a list of members
+func createSet(setType string, members []types.AttributeValue) types.AttributeValue {
+	switch setType {
+	case "SS":
+		set := make([]string, len(members))
+		for i, member := range members {
+			set[i] = member.(*types.AttributeValueMemberS).Value
+		}
+
+		return &types.AttributeValueMemberSS{Value: set}
+	case "NS":
+		set := make([]string, len(members))
+		for i, member := range members {
+			set[i] = member.(*types.AttributeValueMemberN).Value
+		}
+
+		return &types.AttributeValueMemberNS{Value: set}
+	default:
+		set := make([][]byte, len(members))
+		for i, member := range members {
+			set[i] = member.(*types.AttributeValueMemberB).Value
+		}
+
+		return &types.AttributeValueMemberBS{Value: set}
+	}
+}
+
+// Helper function that determines whether a key condition contains an equality condition on the partition key
+func hasPartitionEquality(condition exprCondition, partitionKey string) bool {
+	switch casted := condition.(type) {
+	case *logicalCondition:
+		return casted.and && (hasPartitionEquality(casted.left, partitionKey) ||
+			hasPartitionEquality(casted.right, partitionKey))
+	case *comparisonCondition:
+		if casted.comparator != "=" {
+			return false
+		}
+
+		for _, operand := range []exprOperand{casted.left, casted.right} {
+			if path, ok := operand.(*pathOperand); ok && len(path.path) == 1 && path.path[0].name == partitionKey {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Helper function that creates a copy of an item containing only the attributes at the paths provided. If no
+// paths were provided then a copy of the entire item will be returned
+func selectPaths(item map[string]types.AttributeValue, paths []exprPath) map[string]types.AttributeValue {
+	if paths == nil {
+		return cloneItem(item)
+	}
+
+	selected := make(map[string]types.AttributeValue)
+	for _, path := range paths {
+		projectPath(selected, item, path)
+	}
+
+	return selected
+}
+
+// Helper function that creates a copy of an item containing only the top-level attributes with the names provided
+func selectNames(item map[string]types.AttributeValue, names []string) map[string]types.AttributeValue {
+	selected := make(map[string]types.AttributeValue)
+	for _, name := range names {
+		if attr, ok := item[name]; ok {
+			selected[name] = cloneValue(attr)
+		}
+	}
+
+	return selected
+}
+
+// Helper function that creates the consumed capacity for a request, if it was requested
+func consumedCapacity(tableName *string, units float64, mode types.ReturnConsumedCapacity) *types.ConsumedCapacity {
+	if mode == "" || mode == types.ReturnConsumedCapacityNone {
+		return nil
+	}
+
+	if units < 1 {
+		units = 1
+	}
+
+	return &types.ConsumedCapacity{TableName: tableName, CapacityUnits: aws.Float64(units),
+		Table: &types.Capacity{CapacityUnits: aws.Float64(units)}}
+}
+
+// Helper function that creates an error for a failed write, converting condition failures to the exception
+// DynamoDB would return
+func writeError(operation string, err error) error {
+	if _, ok := err.(*conditionFailure); ok {
+		return operationError(operation, &types.ConditionalCheckFailedException{Message: aws.String(err.Error())})
+	}
+
+	return operationError(operation, err)
+}
+
+// Helper function that returns the keys of a map in sorted order
+func sortedKeys[T any](mapping map[string]T) []string {
+	keys := make([]string, 0, len(mapping))
+	for key := range mapping {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
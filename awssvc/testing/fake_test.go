@@ -0,0 +1,412 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fake DynamoDB Tests", func() {
+
+	// Create a new fake with our test table before each test
+	var fake *FakeDynamoDB
+	BeforeEach(func() {
+		fake = createFakeDynamoDB()
+	})
+
+	// Tests that items can be written, read, updated and deleted against the fake
+	It("CRUD - Works", func() {
+
+		// First, write an item to the fake; this should not fail
+		_, err := fake.PutItem(context.Background(), &dynamodb.PutItemInput{
+			TableName: aws.String("TEST_TABLE"),
+			Item:      createFakeItem("test_id", "a", 1),
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, update the item with an expression and request the new values; this should not fail
+		updated, err := fake.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+			TableName:                aws.String("TEST_TABLE"),
+			Key:                      createFakeKey("test_id", "a"),
+			UpdateExpression:         aws.String("ADD #d :d SET #t = :t"),
+			ConditionExpression:      aws.String("attribute_exists(#i)"),
+			ExpressionAttributeNames: map[string]string{"#d": "data", "#t": "tags", "#i": "id"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":d": &types.AttributeValueMemberN{Value: "41"},
+				":t": &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: "x"}}},
+			},
+			ReturnValues: types.ReturnValueAllNew,
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(updated.Attributes["data"]).Should(Equal(&types.AttributeValueMemberN{Value: "42"}))
+
+		// Now, read the item back and verify that the update was applied
+		item, err := fake.GetItem(context.Background(), &dynamodb.GetItemInput{
+			TableName: aws.String("TEST_TABLE"),
+			Key:       createFakeKey("test_id", "a"),
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(item.Item).Should(HaveLen(4))
+		Expect(item.Item["data"]).Should(Equal(&types.AttributeValueMemberN{Value: "42"}))
+
+		// Finally, delete the item and verify that it no longer exists
+		deleted, err := fake.DeleteItem(context.Background(), &dynamodb.DeleteItemInput{
+			TableName:    aws.String("TEST_TABLE"),
+			Key:          createFakeKey("test_id", "a"),
+			ReturnValues: types.ReturnValueAllOld,
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(deleted.Attributes).Should(HaveKey("tags"))
+
+		item, err = fake.GetItem(context.Background(), &dynamodb.GetItemInput{
+			TableName: aws.String("TEST_TABLE"),
+			Key:       createFakeKey("test_id", "a"),
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(item.Item).Should(BeNil())
+	})
+
+	// Tests that, if the condition on a write is not satisfied, then the fake will return a conditional check
+	// failure, as DynamoDB would
+	It("PutItem - Condition failed - Error", func() {
+
+		// First, write an item to the fake; this should not fail
+		writeFakeItems(fake, "test_id", 1)
+
+		// Next, attempt to write the item again on the condition that it doesn't exist
+		_, err := fake.PutItem(context.Background(), &dynamodb.PutItemInput{
+			TableName:                aws.String("TEST_TABLE"),
+			Item:                     createFakeItem("test_id", "0", 2),
+			ConditionExpression:      aws.String("attribute_not_exists(#i)"),
+			ExpressionAttributeNames: map[string]string{"#i": "id"},
+		})
+
+		// Finally, verify that the write failed with a conditional check failure
+		var failed *types.ConditionalCheckFailedException
+		Expect(err).Should(HaveOccurred())
+		Expect(errors.As(err, &failed)).Should(BeTrue())
+		Expect(fake.Calls("PutItem")).Should(Equal(2))
+	})
+
+	// Tests that throttling errors injected into the fake will be returned for the number of calls requested
+	It("ThrottleNext - Returned, then cleared", func() {
+
+		// First, cause the next two writes to be throttled
+		fake.ThrottleNext("PutItem", 2)
+
+		// Next, attempt to write an item to the fake three times, recording the errors returned
+		var throttled *types.ProvisionedThroughputExceededException
+		for i := 0; i < 3; i++ {
+			_, err := fake.PutItem(context.Background(), &dynamodb.PutItemInput{
+				TableName: aws.String("TEST_TABLE"),
+				Item:      createFakeItem("test_id", "a", 1),
+			})
+
+			// Now, verify that only the first two writes were throttled
+			if i < 2 {
+				Expect(errors.As(err, &throttled)).Should(BeTrue())
+			} else {
+				Expect(err).ShouldNot(HaveOccurred())
+			}
+		}
+
+		// Finally, verify the number of calls that were recorded
+		Expect(fake.Calls("PutItem")).Should(Equal(3))
+	})
+
+	// Tests that Query will return the items in a partition, ordered by sort key, across multiple pages and
+	// that the filter expression is applied
+	It("Query - Works", func() {
+
+		// First, write a number of items to two partitions in the fake
+		writeFakeItems(fake, "test_id", 5)
+		writeFakeItems(fake, "other_id", 3)
+
+		// Next, create a query that reads from one partition in reverse order, with a filter and small pages
+		input := dynamodb.QueryInput{
+			TableName:                aws.String("TEST_TABLE"),
+			Limit:                    aws.Int32(2),
+			ScanIndexForward:         aws.Bool(false),
+			KeyConditionExpression:   aws.String("#i = :i AND #s > :s"),
+			FilterExpression:         aws.String("#d < :d"),
+			ExpressionAttributeNames: map[string]string{"#i": "id", "#s": "sort_key", "#d": "data"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":i": &types.AttributeValueMemberS{Value: "test_id"},
+				":s": &types.AttributeValueMemberS{Value: "0"},
+				":d": &types.AttributeValueMemberN{Value: "4"},
+			},
+		}
+
+		// Now, run the query until there are no more pages; this should not fail
+		items := make([]map[string]types.AttributeValue, 0)
+		for {
+			output, err := fake.Query(context.Background(), &input)
+			Expect(err).ShouldNot(HaveOccurred())
+			items = append(items, output.Items...)
+			if output.LastEvaluatedKey == nil {
+				break
+			}
+
+			input.ExclusiveStartKey = output.LastEvaluatedKey
+		}
+
+		// Finally, verify the items that were returned and that multiple pages were read
+		Expect(items).Should(HaveLen(3))
+		Expect(items[0]["sort_key"]).Should(Equal(&types.AttributeValueMemberS{Value: "3"}))
+		Expect(items[1]["sort_key"]).Should(Equal(&types.AttributeValueMemberS{Value: "2"}))
+		Expect(items[2]["sort_key"]).Should(Equal(&types.AttributeValueMemberS{Value: "1"}))
+		Expect(fake.Calls("Query")).Should(Equal(3))
+	})
+
+	// Tests that, as with DynamoDB, Query will return a LastEvaluatedKey when the limit is reached even if no
+	// items remain after it, and that the following page will then be empty
+	It("Query - Limit equals remaining items - LastEvaluatedKey returned", func() {
+
+		// First, write a number of items to the fake
+		writeFakeItems(fake, "test_id", 2)
+
+		// Next, query the partition with a limit equal to the number of items in it; this should not fail
+		input := dynamodb.QueryInput{
+			TableName:                 aws.String("TEST_TABLE"),
+			Limit:                     aws.Int32(2),
+			KeyConditionExpression:    aws.String("#i = :i"),
+			ExpressionAttributeNames:  map[string]string{"#i": "id"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":i": &types.AttributeValueMemberS{Value: "test_id"}},
+		}
+
+		output, err := fake.Query(context.Background(), &input)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Now, verify that all the items were returned along with the key of the last item
+		Expect(output.Items).Should(HaveLen(2))
+		Expect(output.LastEvaluatedKey).Should(Equal(createFakeKey("test_id", "1")))
+
+		// Finally, query from the last key and verify that the page is empty and has no key
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+		output, err = fake.Query(context.Background(), &input)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(output.Items).Should(BeEmpty())
+		Expect(output.LastEvaluatedKey).Should(BeNil())
+	})
+
+	// Tests that Query will return the items in a global secondary index
+	It("Query - Index - Works", func() {
+
+		// First, write a number of items to the fake
+		writeFakeItems(fake, "test_id", 4)
+
+		// Next, query the index for all items with a particular data value
+		output, err := fake.Query(context.Background(), &dynamodb.QueryInput{
+			TableName:                 aws.String("TEST_TABLE"),
+			IndexName:                 aws.String("data_index"),
+			KeyConditionExpression:    aws.String("#d = :d"),
+			ExpressionAttributeNames:  map[string]string{"#d": "data"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":d": &types.AttributeValueMemberN{Value: "2"}},
+		})
+
+		// Finally, verify the item that was returned
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(output.Items).Should(HaveLen(1))
+		Expect(output.Items[0]["sort_key"]).Should(Equal(&types.AttributeValueMemberS{Value: "2"}))
+	})
+
+	// Tests that Query will fail if the key condition does not restrict the partition key
+	It("Query - Missing partition key - Error", func() {
+		_, err := fake.Query(context.Background(), &dynamodb.QueryInput{
+			TableName:                 aws.String("TEST_TABLE"),
+			KeyConditionExpression:    aws.String("#s = :s"),
+			ExpressionAttributeNames:  map[string]string{"#s": "sort_key"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":s": &types.AttributeValueMemberS{Value: "1"}},
+		})
+
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("Query condition missed key schema element: id"))
+	})
+
+	// Tests that the segments of a parallel scan cover all the items in the table without overlapping
+	It("Scan - Segments - Works", func() {
+
+		// First, write a number of items to the fake
+		writeFakeItems(fake, "test_id", 10)
+
+		// Next, scan each segment of the table and record the items returned
+		seen := make(map[string]struct{})
+		for segment := int32(0); segment < 3; segment++ {
+			output, err := fake.Scan(context.Background(), &dynamodb.ScanInput{
+				TableName:     aws.String("TEST_TABLE"),
+				Segment:       aws.Int32(segment),
+				TotalSegments: aws.Int32(3),
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			for _, item := range output.Items {
+				key := item["sort_key"].(*types.AttributeValueMemberS).Value
+				Expect(seen).ShouldNot(HaveKey(key))
+				seen[key] = struct{}{}
+			}
+		}
+
+		// Finally, verify that every item was returned
+		Expect(seen).Should(HaveLen(10))
+	})
+
+	// Tests that, if UnprocessNext was called, then BatchWriteItem will only write the first request and will
+	// return the rest as unprocessed
+	It("BatchWriteItem - Unprocessed - Returned", func() {
+
+		// First, cause the next batch write to leave all but one request unprocessed
+		fake.UnprocessNext(1)
+
+		// Next, write a number of items to the fake in a batch; this should not fail
+		requests := make([]types.WriteRequest, 5)
+		for i := range requests {
+			requests[i] = types.WriteRequest{PutRequest: &types.PutRequest{
+				Item: createFakeItem("test_id", fmt.Sprint(i), i)}}
+		}
+
+		output, err := fake.BatchWriteItem(context.Background(), &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{"TEST_TABLE": requests},
+		})
+
+		// Now, verify that all but the first request were returned as unprocessed
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(output.UnprocessedItems["TEST_TABLE"]).Should(Equal(requests[1:]))
+
+		// Finally, resubmit the unprocessed requests and verify that all the items were written
+		output, err = fake.BatchWriteItem(context.Background(),
+			&dynamodb.BatchWriteItemInput{RequestItems: output.UnprocessedItems})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(output.UnprocessedItems).Should(BeEmpty())
+
+		scanned, err := fake.Scan(context.Background(), &dynamodb.ScanInput{TableName: aws.String("TEST_TABLE")})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(scanned.Items).Should(HaveLen(5))
+	})
+
+	// Tests that, if a condition in a transaction fails, then none of the writes will be applied and the
+	// cancellation reasons will be returned
+	It("TransactWriteItems - Condition failed - Cancelled", func() {
+
+		// First, write an item to the fake; this should not fail
+		writeFakeItems(fake, "test_id", 1)
+
+		// Next, attempt a transaction that writes a new item and checks a condition that will fail
+		_, err := fake.TransactWriteItems(context.Background(), &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				{Put: &types.Put{TableName: aws.String("TEST_TABLE"), Item: createFakeItem("test_id", "9", 9)}},
+				{ConditionCheck: &types.ConditionCheck{
+					TableName:                           aws.String("TEST_TABLE"),
+					Key:                                 createFakeKey("test_id", "0"),
+					ConditionExpression:                 aws.String("#d = :d"),
+					ExpressionAttributeNames:            map[string]string{"#d": "data"},
+					ExpressionAttributeValues:           map[string]types.AttributeValue{":d": &types.AttributeValueMemberN{Value: "5"}},
+					ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+				}},
+			},
+		})
+
+		// Now, verify the cancellation reasons on the error
+		var cancelled *types.TransactionCanceledException
+		Expect(errors.As(err, &cancelled)).Should(BeTrue())
+		Expect(cancelled.CancellationReasons).Should(HaveLen(2))
+		Expect(*cancelled.CancellationReasons[0].Code).Should(Equal("None"))
+		Expect(*cancelled.CancellationReasons[1].Code).Should(Equal("ConditionalCheckFailed"))
+		Expect(cancelled.CancellationReasons[1].Item).Should(Equal(createFakeItem("test_id", "0", 0)))
+
+		// Finally, verify that the put was not applied
+		scanned, err := fake.Scan(context.Background(), &dynamodb.ScanInput{TableName: aws.String("TEST_TABLE")})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(scanned.Items).Should(HaveLen(1))
+	})
+
+	// Tests that a transaction that is retried with the same client request token will only be applied once
+	It("TransactWriteItems - Same token - Idempotent", func() {
+
+		// First, create a transaction that increments a counter
+		input := dynamodb.TransactWriteItemsInput{
+			ClientRequestToken: aws.String("test_token"),
+			TransactItems: []types.TransactWriteItem{{Update: &types.Update{
+				TableName:                 aws.String("TEST_TABLE"),
+				Key:                       createFakeKey("test_id", "a"),
+				UpdateExpression:          aws.String("ADD #d :d"),
+				ExpressionAttributeNames:  map[string]string{"#d": "data"},
+				ExpressionAttributeValues: map[string]types.AttributeValue{":d": &types.AttributeValueMemberN{Value: "1"}},
+			}}},
+		}
+
+		// Next, submit the transaction twice; neither submission should fail
+		for i := 0; i < 2; i++ {
+			_, err := fake.TransactWriteItems(context.Background(), &input)
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+
+		// Finally, verify that the counter was only incremented once
+		item, err := fake.GetItem(context.Background(), &dynamodb.GetItemInput{
+			TableName: aws.String("TEST_TABLE"),
+			Key:       createFakeKey("test_id", "a"),
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(item.Item["data"]).Should(Equal(&types.AttributeValueMemberN{Value: "1"}))
+	})
+})
+
+// Helper function that creates a fake containing our test table, with a global secondary index on the data field
+func createFakeDynamoDB() *FakeDynamoDB {
+	fake := NewFakeDynamoDB()
+	if _, err := fake.CreateTable(context.Background(), &dynamodb.CreateTableInput{
+		TableName: aws.String("TEST_TABLE"),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("sort_key"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("data"), AttributeType: types.ScalarAttributeTypeN},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("sort_key"), KeyType: types.KeyTypeRange},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName:  aws.String("data_index"),
+			KeySchema:  []types.KeySchemaElement{{AttributeName: aws.String("data"), KeyType: types.KeyTypeHash}},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+		BillingMode: types.BillingModePayPerRequest,
+	}); err != nil {
+		panic(err)
+	}
+
+	return fake
+}
+
+// Helper function that writes a number of items to a partition in our test table, with sort keys and data
+// values ranging from 0 to count - 1
+func writeFakeItems(fake *FakeDynamoDB, id string, count int) {
+	for i := 0; i < count; i++ {
+		if _, err := fake.PutItem(context.Background(), &dynamodb.PutItemInput{
+			TableName: aws.String("TEST_TABLE"),
+			Item:      createFakeItem(id, fmt.Sprint(i), i),
+		}); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Helper function that creates a test item with the key and data provided
+func createFakeItem(id string, sortKey string, data int) map[string]types.AttributeValue {
+	item := createFakeKey(id, sortKey)
+	item["data"] = &types.AttributeValueMemberN{Value: fmt.Sprint(data)}
+	return item
+}
+
+// Helper function that creates the key of a test item
+func createFakeKey(id string, sortKey string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id":       &types.AttributeValueMemberS{Value: id},
+		"sort_key": &types.AttributeValueMemberS{Value: sortKey},
+	}
+}
@@ -0,0 +1,236 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// Helper function that returns the error associated with an operation that FakeDynamoDB does not support.
+// Injected failures will still be returned so that call counts and retries behave consistently
+func (fake *FakeDynamoDB) unsupported(operation string) error {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if err := fake.intercept(operation); err != nil {
+		return err
+	}
+
+	return operationError(operation, fmt.Errorf("%s is not supported by FakeDynamoDB", operation))
+}
+
+// BatchExecuteStatement is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) BatchExecuteStatement(ctx context.Context, params *dynamodb.BatchExecuteStatementInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.BatchExecuteStatementOutput, error) {
+	return nil, fake.unsupported("BatchExecuteStatement")
+}
+
+// CreateBackup is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) CreateBackup(ctx context.Context, params *dynamodb.CreateBackupInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.CreateBackupOutput, error) {
+	return nil, fake.unsupported("CreateBackup")
+}
+
+// CreateGlobalTable is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) CreateGlobalTable(ctx context.Context, params *dynamodb.CreateGlobalTableInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.CreateGlobalTableOutput, error) {
+	return nil, fake.unsupported("CreateGlobalTable")
+}
+
+// DeleteBackup is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) DeleteBackup(ctx context.Context, params *dynamodb.DeleteBackupInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteBackupOutput, error) {
+	return nil, fake.unsupported("DeleteBackup")
+}
+
+// DescribeBackup is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) DescribeBackup(ctx context.Context, params *dynamodb.DescribeBackupInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeBackupOutput, error) {
+	return nil, fake.unsupported("DescribeBackup")
+}
+
+// DescribeContinuousBackups is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) DescribeContinuousBackups(ctx context.Context, params *dynamodb.DescribeContinuousBackupsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeContinuousBackupsOutput, error) {
+	return nil, fake.unsupported("DescribeContinuousBackups")
+}
+
+// DescribeContributorInsights is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) DescribeContributorInsights(ctx context.Context, params *dynamodb.DescribeContributorInsightsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeContributorInsightsOutput, error) {
+	return nil, fake.unsupported("DescribeContributorInsights")
+}
+
+// DescribeEndpoints is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) DescribeEndpoints(ctx context.Context, params *dynamodb.DescribeEndpointsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeEndpointsOutput, error) {
+	return nil, fake.unsupported("DescribeEndpoints")
+}
+
+// DescribeExport is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) DescribeExport(ctx context.Context, params *dynamodb.DescribeExportInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeExportOutput, error) {
+	return nil, fake.unsupported("DescribeExport")
+}
+
+// DescribeGlobalTable is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) DescribeGlobalTable(ctx context.Context, params *dynamodb.DescribeGlobalTableInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeGlobalTableOutput, error) {
+	return nil, fake.unsupported("DescribeGlobalTable")
+}
+
+// DescribeGlobalTableSettings is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) DescribeGlobalTableSettings(ctx context.Context, params *dynamodb.DescribeGlobalTableSettingsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeGlobalTableSettingsOutput, error) {
+	return nil, fake.unsupported("DescribeGlobalTableSettings")
+}
+
+// DescribeImport is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) DescribeImport(ctx context.Context, params *dynamodb.DescribeImportInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeImportOutput, error) {
+	return nil, fake.unsupported("DescribeImport")
+}
+
+// DescribeKinesisStreamingDestination is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) DescribeKinesisStreamingDestination(ctx context.Context, params *dynamodb.DescribeKinesisStreamingDestinationInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeKinesisStreamingDestinationOutput, error) {
+	return nil, fake.unsupported("DescribeKinesisStreamingDestination")
+}
+
+// DescribeLimits is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) DescribeLimits(ctx context.Context, params *dynamodb.DescribeLimitsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeLimitsOutput, error) {
+	return nil, fake.unsupported("DescribeLimits")
+}
+
+// DescribeTableReplicaAutoScaling is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) DescribeTableReplicaAutoScaling(ctx context.Context, params *dynamodb.DescribeTableReplicaAutoScalingInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableReplicaAutoScalingOutput, error) {
+	return nil, fake.unsupported("DescribeTableReplicaAutoScaling")
+}
+
+// DisableKinesisStreamingDestination is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) DisableKinesisStreamingDestination(ctx context.Context, params *dynamodb.DisableKinesisStreamingDestinationInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DisableKinesisStreamingDestinationOutput, error) {
+	return nil, fake.unsupported("DisableKinesisStreamingDestination")
+}
+
+// EnableKinesisStreamingDestination is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) EnableKinesisStreamingDestination(ctx context.Context, params *dynamodb.EnableKinesisStreamingDestinationInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.EnableKinesisStreamingDestinationOutput, error) {
+	return nil, fake.unsupported("EnableKinesisStreamingDestination")
+}
+
+// ExecuteStatement is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) ExecuteStatement(ctx context.Context, params *dynamodb.ExecuteStatementInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.ExecuteStatementOutput, error) {
+	return nil, fake.unsupported("ExecuteStatement")
+}
+
+// ExecuteTransaction is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) ExecuteTransaction(ctx context.Context, params *dynamodb.ExecuteTransactionInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.ExecuteTransactionOutput, error) {
+	return nil, fake.unsupported("ExecuteTransaction")
+}
+
+// ExportTableToPointInTime is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) ExportTableToPointInTime(ctx context.Context, params *dynamodb.ExportTableToPointInTimeInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.ExportTableToPointInTimeOutput, error) {
+	return nil, fake.unsupported("ExportTableToPointInTime")
+}
+
+// ImportTable is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) ImportTable(ctx context.Context, params *dynamodb.ImportTableInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.ImportTableOutput, error) {
+	return nil, fake.unsupported("ImportTable")
+}
+
+// ListBackups is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) ListBackups(ctx context.Context, params *dynamodb.ListBackupsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.ListBackupsOutput, error) {
+	return nil, fake.unsupported("ListBackups")
+}
+
+// ListContributorInsights is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) ListContributorInsights(ctx context.Context, params *dynamodb.ListContributorInsightsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.ListContributorInsightsOutput, error) {
+	return nil, fake.unsupported("ListContributorInsights")
+}
+
+// ListExports is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) ListExports(ctx context.Context, params *dynamodb.ListExportsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.ListExportsOutput, error) {
+	return nil, fake.unsupported("ListExports")
+}
+
+// ListGlobalTables is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) ListGlobalTables(ctx context.Context, params *dynamodb.ListGlobalTablesInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.ListGlobalTablesOutput, error) {
+	return nil, fake.unsupported("ListGlobalTables")
+}
+
+// ListImports is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) ListImports(ctx context.Context, params *dynamodb.ListImportsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.ListImportsOutput, error) {
+	return nil, fake.unsupported("ListImports")
+}
+
+// ListTagsOfResource is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) ListTagsOfResource(ctx context.Context, params *dynamodb.ListTagsOfResourceInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.ListTagsOfResourceOutput, error) {
+	return nil, fake.unsupported("ListTagsOfResource")
+}
+
+// RestoreTableFromBackup is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) RestoreTableFromBackup(ctx context.Context, params *dynamodb.RestoreTableFromBackupInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.RestoreTableFromBackupOutput, error) {
+	return nil, fake.unsupported("RestoreTableFromBackup")
+}
+
+// RestoreTableToPointInTime is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) RestoreTableToPointInTime(ctx context.Context, params *dynamodb.RestoreTableToPointInTimeInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.RestoreTableToPointInTimeOutput, error) {
+	return nil, fake.unsupported("RestoreTableToPointInTime")
+}
+
+// TagResource is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) TagResource(ctx context.Context, params *dynamodb.TagResourceInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.TagResourceOutput, error) {
+	return nil, fake.unsupported("TagResource")
+}
+
+// UntagResource is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) UntagResource(ctx context.Context, params *dynamodb.UntagResourceInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.UntagResourceOutput, error) {
+	return nil, fake.unsupported("UntagResource")
+}
+
+// UpdateContinuousBackups is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) UpdateContinuousBackups(ctx context.Context, params *dynamodb.UpdateContinuousBackupsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateContinuousBackupsOutput, error) {
+	return nil, fake.unsupported("UpdateContinuousBackups")
+}
+
+// UpdateContributorInsights is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) UpdateContributorInsights(ctx context.Context, params *dynamodb.UpdateContributorInsightsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateContributorInsightsOutput, error) {
+	return nil, fake.unsupported("UpdateContributorInsights")
+}
+
+// UpdateGlobalTable is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) UpdateGlobalTable(ctx context.Context, params *dynamodb.UpdateGlobalTableInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateGlobalTableOutput, error) {
+	return nil, fake.unsupported("UpdateGlobalTable")
+}
+
+// UpdateGlobalTableSettings is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) UpdateGlobalTableSettings(ctx context.Context, params *dynamodb.UpdateGlobalTableSettingsInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateGlobalTableSettingsOutput, error) {
+	return nil, fake.unsupported("UpdateGlobalTableSettings")
+}
+
+// UpdateTableReplicaAutoScaling is not supported by FakeDynamoDB and will always return an error
+func (fake *FakeDynamoDB) UpdateTableReplicaAutoScaling(ctx context.Context, params *dynamodb.UpdateTableReplicaAutoScalingInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableReplicaAutoScalingOutput, error) {
+	return nil, fake.unsupported("UpdateTableReplicaAutoScaling")
+}
@@ -0,0 +1,38 @@
+package concurrency
+
+import (
+	"context"
+	"time"
+)
+
+// WithoutCancel returns a context that carries the values of the parent context but is never cancelled or
+// timed out when the parent is. This is useful for work that must be allowed to finish, or that is shared
+// between callers, after the context of the caller that started it has been cancelled
+func WithoutCancel(parent context.Context) context.Context {
+	return detachedContext{parent: parent}
+}
+
+// Helper type that carries the values of a parent context without being cancelled when the parent is
+type detachedContext struct {
+	parent context.Context
+}
+
+// Deadline returns no deadline as the context is never cancelled by its parent
+func (ctx detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// Done returns nil as the context is never cancelled by its parent
+func (ctx detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+// Err returns nil as the context is never cancelled by its parent
+func (ctx detachedContext) Err() error {
+	return nil
+}
+
+// Value returns the value associated with the key on the parent context
+func (ctx detachedContext) Value(key any) any {
+	return ctx.parent.Value(key)
+}
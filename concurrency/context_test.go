@@ -0,0 +1,50 @@
+package concurrency
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// Helper type that we'll use as a context key in our tests
+type contextKey string
+
+var _ = Describe("Context Tests", func() {
+
+	// Test that the context returned by WithoutCancel keeps the values of its parent but is not cancelled,
+	// nor does it inherit the deadline, when the parent is cancelled
+	It("WithoutCancel - Parent cancelled - Not cancelled", func() {
+
+		// First, create a parent context with a value and a deadline
+		parent, cancel := context.WithTimeout(context.WithValue(context.Background(),
+			contextKey("key"), "value"), time.Hour)
+
+		// Next, detach a context from the parent and then cancel the parent
+		ctx := WithoutCancel(parent)
+		cancel()
+
+		// Finally, verify that the parent was cancelled but the detached context was not
+		Expect(parent.Err()).Should(HaveOccurred())
+		Expect(ctx.Err()).ShouldNot(HaveOccurred())
+		Expect(ctx.Done()).Should(BeNil())
+		_, ok := ctx.Deadline()
+		Expect(ok).Should(BeFalse())
+		Expect(ctx.Value(contextKey("key"))).Should(Equal("value"))
+	})
+
+	// Test that a context derived from the one returned by WithoutCancel can still be cancelled on its own
+	It("WithoutCancel - Child cancelled - Cancelled", func() {
+
+		// First, derive a cancellable context from a detached context
+		ctx, cancel := context.WithCancel(WithoutCancel(context.Background()))
+
+		// Next, cancel the derived context
+		cancel()
+
+		// Finally, verify that the derived context was cancelled
+		Eventually(ctx.Done()).Should(BeClosed())
+		Expect(ctx.Err()).Should(MatchError(context.Canceled))
+	})
+})
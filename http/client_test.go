@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -480,6 +482,106 @@ var _ = Describe("WebClient Tests", func() {
 		Expect(value.Key).Should(Equal("herp"))
 		Expect(value.Value).Should(Equal("derp"))
 	})
+
+	// Test that GetData will retry and successfully get the data when the responses are replayed from a
+	// recorded cassette fixture
+	It("GetData - Replayed from cassette - Data populated", func() {
+
+		// First, load the cassette from our test fixture; this should not fail
+		cassette, err := testutils.NewCassette("testdata/getdata.yaml", testutils.ReplayMode)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, create the web client from the cassette's client
+		client := generateClient(cassette.Client())
+
+		// Now, create the HTTP request
+		request, _ := http.NewRequest(http.MethodGet, "https://test.url/data", http.NoBody)
+		request.Header.Add("Authorization", "Bearer REAL_KEY")
+
+		// Finally, attempt to send the request; this should not fail
+		var value test
+		err = client.GetData(request, &value)
+
+		// Verify the data and that all the recorded interactions were used
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(value.Key).Should(Equal("herp"))
+		Expect(value.Value).Should(Equal("derp"))
+		Expect(cassette.Unused()).Should(BeEmpty())
+	})
+
+	// Test that, if a request is made that does not match any recorded interaction, then the cassette
+	// will fail the request
+	It("DoRequest - Unexpected cassette request - Error", func() {
+
+		// First, load the cassette from our test fixture; this should not fail
+		cassette, err := testutils.NewCassette("testdata/getdata.yaml", testutils.ReplayMode,
+			testutils.WithMatchHeaders{"Authorization"})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Next, create the web client from the cassette's client
+		client := generateClient(cassette.Client())
+
+		// Now, create an HTTP request that was never recorded
+		request, _ := http.NewRequest(http.MethodPost, "https://test.url/data",
+			bytes.NewBufferString("{\"Key\":\"herp\"}"))
+
+		// Finally, attempt to send the request; this should fail
+		resp, err := client.DoRequest(request)
+
+		// Verify the failure
+		Expect(resp).Should(BeNil())
+		Expect(err).Should(HaveOccurred())
+		Expect(err.(*Error).Inner.Error()).Should(Equal("Post \"https://test.url/data\": cassette " +
+			"testdata/getdata.yaml has no unused interaction matching POST https://test.url/data"))
+		Expect(cassette.Unused()).Should(HaveLen(2))
+	})
+
+	// Test that a cassette in record mode will record interactions with secrets redacted and that the
+	// saved fixture can then be replayed
+	It("GetData - Recorded to cassette - Replayed", func() {
+
+		// First, create a test server that will return our data
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte("{\"Key\":\"herp\",\"Value\":\"derp\"}"))
+		}))
+		defer server.Close()
+
+		// Next, create a cassette in record mode that will save to a temporary JSON file
+		path := filepath.Join(GinkgoT().TempDir(), "recorded.json")
+		recorder, err := testutils.NewCassette(path, testutils.RecordMode,
+			testutils.WithRedactedQueryParams{"apiKey"})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Now, send a request through the recording cassette; this should not fail
+		request, _ := http.NewRequest(http.MethodGet, server.URL+"/data?apiKey=SECRET", http.NoBody)
+		request.Header.Add("Authorization", "Bearer REAL_KEY")
+		var recorded test
+		err = generateClient(recorder.Client()).GetData(request, &recorded)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(recorded.Key).Should(Equal("herp"))
+
+		// Save the cassette and verify that no secrets were written to the fixture
+		Expect(recorder.Save()).ShouldNot(HaveOccurred())
+		data, err := ioutil.ReadFile(path)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(data)).ShouldNot(ContainSubstring("SECRET"))
+		Expect(string(data)).ShouldNot(ContainSubstring("REAL_KEY"))
+
+		// Shut down the server and replay the request from the saved fixture; this should not fail
+		server.Close()
+		player, err := testutils.NewCassette(path, testutils.ReplayMode,
+			testutils.WithRedactedQueryParams{"apiKey"})
+		Expect(err).ShouldNot(HaveOccurred())
+		request, _ = http.NewRequest(http.MethodGet, server.URL+"/data?apiKey=OTHER", http.NoBody)
+		var replayed test
+		err = generateClient(player.Client()).GetData(request, &replayed)
+
+		// Verify the replayed data
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(replayed).Should(Equal(recorded))
+		Expect(player.Unused()).Should(BeEmpty())
+	})
 })
 
 // Helper function that generates a fake client that can be used for testing
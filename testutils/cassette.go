@@ -0,0 +1,417 @@
+package testutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/xefino/goutils/collections"
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted is the value that will replace any secret data that is removed from a recorded interaction
+const Redacted = "REDACTED"
+
+// CassetteMode describes how a Cassette should handle the HTTP requests sent through it
+type CassetteMode int
+
+const (
+
+	// ReplayMode indicates that all requests should be answered from the interactions recorded on the
+	// cassette. Any request that does not match a recorded interaction will result in an error
+	ReplayMode CassetteMode = iota
+
+	// RecordMode indicates that all requests should be sent to the inner transport and that the
+	// resulting interactions should be recorded on the cassette
+	RecordMode
+
+	// ReplayOrRecordMode indicates that requests should be answered from the interactions recorded on
+	// the cassette if possible. Any request that does not match will be sent to the inner transport and
+	// the resulting interaction will be recorded on the cassette
+	ReplayOrRecordMode
+)
+
+// RecordedRequest contains the data associated with an HTTP request that was recorded on a cassette
+type RecordedRequest struct {
+	Method  string      `json:"method" yaml:"method"`
+	URL     string      `json:"url" yaml:"url"`
+	Headers http.Header `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body    string      `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// RecordedResponse contains the data associated with an HTTP response that was recorded on a cassette
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code" yaml:"status_code"`
+	Headers    http.Header `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body       string      `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// Interaction contains a single HTTP request and the response that was received for it
+type Interaction struct {
+	Request  *RecordedRequest  `json:"request" yaml:"request"`
+	Response *RecordedResponse `json:"response" yaml:"response"`
+	used     bool
+}
+
+// Cassette is an HTTP round-tripper that records HTTP interactions to a fixture file so that they can be
+// replayed later without making real HTTP requests. Requests are matched against recorded interactions
+// on their method, URL, body and any headers that were selected for matching. Each recorded interaction
+// will be used at most once so that repeated requests may receive different responses
+type Cassette struct {
+	Path          string
+	Mode          CassetteMode
+	Interactions  []*Interaction
+	inner         http.RoundTripper
+	matchHeaders  []string
+	redactHeaders []string
+	redactParams  []string
+	reqRedactor   func(*RecordedRequest)
+	respRedactor  func(*RecordedResponse)
+	lock          *sync.Mutex
+}
+
+// NewCassette creates a new Cassette from the path to its fixture file, the mode it should operate in and
+// any options that should modify its behavior. If the cassette is not in record mode then the interactions
+// will be loaded from the fixture file. The file will be read as JSON if it has a .json extension and
+// as YAML otherwise
+func NewCassette(path string, mode CassetteMode, opts ...ICassetteOption) (*Cassette, error) {
+
+	// First, create our cassette with default values
+	cassette := Cassette{
+		Path:          path,
+		Mode:          mode,
+		Interactions:  make([]*Interaction, 0),
+		inner:         http.DefaultTransport,
+		matchHeaders:  make([]string, 0),
+		redactHeaders: []string{"Authorization"},
+		redactParams:  make([]string, 0),
+		lock:          new(sync.Mutex),
+	}
+
+	// Next, call each of our options to modify the cassette
+	for _, opt := range opts {
+		opt.Apply(&cassette)
+	}
+
+	// Now, if we're not recording then attempt to read the interactions from the fixture file. If the
+	// file doesn't exist and we're allowed to record then we'll start with an empty cassette
+	if mode != RecordMode {
+		if err := cassette.load(); err != nil && !(mode == ReplayOrRecordMode && os.IsNotExist(err)) {
+			return nil, err
+		}
+	}
+
+	// Finally, return a pointer to the cassette
+	return &cassette, nil
+}
+
+// Client returns an HTTP client that will send all its requests through the cassette
+func (cassette *Cassette) Client() *http.Client {
+	return &http.Client{Transport: cassette}
+}
+
+// RoundTrip runs a single HTTP request against the cassette. In replay mode, the request will be answered
+// from a matching recorded interaction or an error will be returned if no such interaction exists. In
+// record mode, the request will be sent to the inner transport and the interaction will be recorded
+func (cassette *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	// First, read the body of the request so we can match on it and then replace it so that the request
+	// can still be sent if we need to record it
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cassette %s failed to read request body: %w", cassette.Path, err)
+	}
+
+	// Next, convert the request into a redacted, recorded request so we can compare it against our
+	// recorded interactions; this is necessary because the interactions will have been redacted as well
+	recorded := &RecordedRequest{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: req.Header.Clone(),
+		Body:    string(body),
+	}
+
+	cassette.redactRequest(recorded)
+
+	// Now, if we're not recording then attempt to find a matching interaction. If we find one then
+	// generate a response from it. Otherwise, if we're not allowed to record then return an error
+	if cassette.Mode != RecordMode {
+		if interaction, ok := cassette.match(recorded); ok {
+			return interaction.Response.toResponse(req), nil
+		} else if cassette.Mode == ReplayMode {
+			return nil, fmt.Errorf("cassette %s has no unused interaction matching %s %s",
+				cassette.Path, recorded.Method, recorded.URL)
+		}
+	}
+
+	// Finally, send the request to the inner transport and record the resulting interaction
+	return cassette.record(req, recorded)
+}
+
+// Unused returns all the interactions on the cassette that have not been used to answer a request. This
+// is useful for verifying that all expected requests were made
+func (cassette *Cassette) Unused() []*Interaction {
+	cassette.lock.Lock()
+	defer cassette.lock.Unlock()
+
+	unused := make([]*Interaction, 0)
+	for _, interaction := range cassette.Interactions {
+		if !interaction.used {
+			unused = append(unused, interaction)
+		}
+	}
+
+	return unused
+}
+
+// Save writes all the interactions on the cassette to its fixture file. The file will be written as JSON
+// if it has a .json extension and as YAML otherwise
+func (cassette *Cassette) Save() error {
+	cassette.lock.Lock()
+	defer cassette.lock.Unlock()
+
+	// First, serialize the interactions to JSON or YAML depending on the extension of the fixture file
+	var data []byte
+	var err error
+	if cassette.isJSON() {
+		data, err = json.MarshalIndent(cassette.Interactions, "", "\t")
+	} else {
+		data, err = yaml.Marshal(cassette.Interactions)
+	}
+
+	// Next, if the serialization failed then return an error
+	if err != nil {
+		return fmt.Errorf("cassette %s failed to serialize interactions: %w", cassette.Path, err)
+	}
+
+	// Now, ensure that the directory containing the fixture file exists
+	if err := os.MkdirAll(filepath.Dir(cassette.Path), 0755); err != nil {
+		return fmt.Errorf("cassette %s failed to create fixture directory: %w", cassette.Path, err)
+	}
+
+	// Finally, write the data to the fixture file
+	if err := ioutil.WriteFile(cassette.Path, data, 0644); err != nil {
+		return fmt.Errorf("cassette %s failed to write fixture: %w", cassette.Path, err)
+	}
+
+	return nil
+}
+
+// Helper function that reads the interactions on the cassette from its fixture file
+func (cassette *Cassette) load() error {
+
+	// First, attempt to read the fixture file; if this fails then return the error
+	data, err := ioutil.ReadFile(cassette.Path)
+	if err != nil {
+		return err
+	}
+
+	// Next, deserialize the interactions from JSON or YAML depending on the extension of the file
+	var interactions []*Interaction
+	if cassette.isJSON() {
+		err = json.Unmarshal(data, &interactions)
+	} else {
+		err = yaml.Unmarshal(data, &interactions)
+	}
+
+	// Now, if the deserialization failed then return an error
+	if err != nil {
+		return fmt.Errorf("cassette %s failed to deserialize interactions: %w", cassette.Path, err)
+	}
+
+	// Finally, save the interactions to the cassette; these will all be unused as they were just loaded
+	cassette.Interactions = interactions
+	return nil
+}
+
+// Helper function that finds the first unused interaction matching the request provided, marking it as
+// used. If no such interaction could be found then false will be returned
+func (cassette *Cassette) match(req *RecordedRequest) (*Interaction, bool) {
+	cassette.lock.Lock()
+	defer cassette.lock.Unlock()
+
+	// Iterate over all our interactions and check each against the request, skipping any that have
+	// already been used to answer a request
+	for _, interaction := range cassette.Interactions {
+		if interaction.used || interaction.Request.Method != req.Method ||
+			interaction.Request.URL != req.URL || interaction.Request.Body != req.Body {
+			continue
+		}
+
+		// Check that all the headers we care about match as well; if they don't then move on
+		if collections.ContainsFunc(cassette.matchHeaders, func(header string) bool {
+			return !headersEqual(interaction.Request.Headers.Values(header), req.Headers.Values(header))
+		}) {
+			continue
+		}
+
+		// We found our match so mark it as used and return it
+		interaction.used = true
+		return interaction, true
+	}
+
+	return nil, false
+}
+
+// Helper function that sends the request to the inner transport and records the resulting interaction
+// on the cassette. The response will be returned to the caller with its body intact
+func (cassette *Cassette) record(req *http.Request, recorded *RecordedRequest) (*http.Response, error) {
+
+	// First, send the request to our inner transport; if this fails then return the error
+	resp, err := cassette.inner.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Next, read the body of the response and replace it so that the caller can still read it
+	body, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cassette %s failed to read response body: %w", cassette.Path, err)
+	}
+
+	// Now, create the interaction from the request and response and redact any secrets from the
+	// response; the request will have already been redacted
+	interaction := Interaction{
+		Request: recorded,
+		Response: &RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    resp.Header.Clone(),
+			Body:       string(body),
+		},
+		used: true,
+	}
+
+	cassette.redactResponse(interaction.Response)
+
+	// Finally, add the interaction to the cassette, marking it as used, and return the response
+	cassette.lock.Lock()
+	defer cassette.lock.Unlock()
+	cassette.Interactions = append(cassette.Interactions, &interaction)
+	return resp, nil
+}
+
+// Helper function that removes secret data from a recorded request
+func (cassette *Cassette) redactRequest(req *RecordedRequest) {
+
+	// First, replace the values of any secret headers on the request
+	for _, header := range cassette.redactHeaders {
+		if req.Headers.Get(header) != "" {
+			req.Headers.Set(header, Redacted)
+		}
+	}
+
+	// Next, replace the values of any secret query parameters on the request URL
+	if len(cassette.redactParams) > 0 {
+		if parsed, err := url.Parse(req.URL); err == nil {
+			query := parsed.Query()
+			for _, param := range cassette.redactParams {
+				if query.Has(param) {
+					query.Set(param, Redacted)
+				}
+			}
+
+			parsed.RawQuery = query.Encode()
+			req.URL = parsed.String()
+		}
+	}
+
+	// Finally, if we have a custom redaction function then call it
+	if cassette.reqRedactor != nil {
+		cassette.reqRedactor(req)
+	}
+}
+
+// Helper function that removes secret data from a recorded response
+func (cassette *Cassette) redactResponse(resp *RecordedResponse) {
+
+	// First, replace the values of any secret headers on the response
+	for _, header := range cassette.redactHeaders {
+		if resp.Headers.Get(header) != "" {
+			resp.Headers.Set(header, Redacted)
+		}
+	}
+
+	// Next, if we have a custom redaction function then call it
+	if cassette.respRedactor != nil {
+		cassette.respRedactor(resp)
+	}
+}
+
+// Helper function that determines whether the fixture file should be treated as JSON
+func (cassette *Cassette) isJSON() bool {
+	return strings.EqualFold(filepath.Ext(cassette.Path), ".json")
+}
+
+// Helper function that converts a recorded response to an HTTP response for the request provided
+func (resp *RecordedResponse) toResponse(req *http.Request) *http.Response {
+
+	// If we have data then enclose it in a ReadCloser, otherwise set it to NoBody
+	var body io.ReadCloser
+	if resp.Body == "" {
+		body = http.NoBody
+	} else {
+		body = ioutil.NopCloser(bytes.NewBufferString(resp.Body))
+	}
+
+	// Create the response and return it
+	header := resp.Headers.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+		StatusCode:    resp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: int64(len(resp.Body)),
+		Request:       req,
+	}
+}
+
+// Helper function that reads all the data from a body and then replaces the body with a new reader over
+// the same data so that it can be read again
+func readBody(body *io.ReadCloser) ([]byte, error) {
+
+	// If we have no body then there's nothing to read so return here
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+
+	// Otherwise, read all the data from the body and close it
+	defer (*body).Close()
+	data, err := ioutil.ReadAll(*body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace the body with a new reader over the data we read and return the data
+	*body = ioutil.NopCloser(bytes.NewBuffer(data))
+	return data, nil
+}
+
+// Helper function that determines whether two lists of header values are equal
+func headersEqual(lhs []string, rhs []string) bool {
+	if len(lhs) != len(rhs) {
+		return false
+	}
+
+	for i, value := range lhs {
+		if value != rhs[i] {
+			return false
+		}
+	}
+
+	return true
+}
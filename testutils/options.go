@@ -0,0 +1,70 @@
+package testutils
+
+import "net/http"
+
+// ICassetteOption defines the functionality that will allow the behavior of a Cassette to be
+// modified at construction
+type ICassetteOption interface {
+	Apply(*Cassette)
+}
+
+// WithMatchHeaders allows the user to set the request headers that must match, in addition to the
+// method, URL and body, for a recorded interaction to be used to answer a request
+type WithMatchHeaders []string
+
+// Apply modifies the Cassette so that it matches on the headers defined by this object
+func (w WithMatchHeaders) Apply(cassette *Cassette) {
+	cassette.matchHeaders = w
+}
+
+// WithRedactedHeaders allows the user to set the request and response headers whose values should be
+// removed before an interaction is recorded. By default, only the Authorization header is redacted
+type WithRedactedHeaders []string
+
+// Apply modifies the Cassette so that it redacts the headers defined by this object
+func (w WithRedactedHeaders) Apply(cassette *Cassette) {
+	cassette.redactHeaders = w
+}
+
+// WithRedactedQueryParams allows the user to set the URL query parameters whose values should be
+// removed before an interaction is recorded
+type WithRedactedQueryParams []string
+
+// Apply modifies the Cassette so that it redacts the query parameters defined by this object
+func (w WithRedactedQueryParams) Apply(cassette *Cassette) {
+	cassette.redactParams = w
+}
+
+// WithRequestRedactor allows the user to set a function that will remove any other secret data from a
+// request before it is recorded or matched against a recorded interaction
+type WithRequestRedactor func(*RecordedRequest)
+
+// Apply modifies the Cassette so that it has the request redactor defined by this object
+func (w WithRequestRedactor) Apply(cassette *Cassette) {
+	cassette.reqRedactor = w
+}
+
+// WithResponseRedactor allows the user to set a function that will remove any other secret data from
+// a response before it is recorded
+type WithResponseRedactor func(*RecordedResponse)
+
+// Apply modifies the Cassette so that it has the response redactor defined by this object
+func (w WithResponseRedactor) Apply(cassette *Cassette) {
+	cassette.respRedactor = w
+}
+
+// Helper type that allows the user to set the transport used to make real HTTP requests
+type withTransport struct {
+	transport http.RoundTripper
+}
+
+// WithTransport creates a new option that will set the transport used by the Cassette to make real
+// HTTP requests when it is recording. By default, http.DefaultTransport is used
+func WithTransport(transport http.RoundTripper) withTransport {
+	return withTransport{transport: transport}
+}
+
+// Apply modifies the Cassette so that it has the transport defined by this object
+func (w withTransport) Apply(cassette *Cassette) {
+	cassette.inner = w.transport
+}